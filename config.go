@@ -10,11 +10,14 @@ import (
 	"io"
 	"os"
 	"path"
+	"slices"
 	"strings"
 
 	"github.com/mmp/imgui-go/v4"
 	"github.com/mmp/vice/pkg/log"
 	"github.com/mmp/vice/pkg/panes"
+	"github.com/mmp/vice/pkg/panes/asdex"
+	"github.com/mmp/vice/pkg/panes/eram"
 	"github.com/mmp/vice/pkg/panes/stars"
 	"github.com/mmp/vice/pkg/platform"
 	"github.com/mmp/vice/pkg/renderer"
@@ -42,7 +45,12 @@ import (
 // 24: packages, audio to platform, flight plan processing
 // 25: remove ArrivalGroup/Index from Aircraft
 // 26: make allow_long_scratchpad a single bool
-const CurrentConfigVersion = 26
+// 27: STARS NOTAM list
+// 28: STARS meter list
+// 29: STARS TMI list
+// 30: STARS coordination list
+// 31: flight strip bays kept in the Sim
+const CurrentConfigVersion = 31
 
 // Slightly convoluted, but the full Config definition is split into
 // the part with the Sim and the rest of it.  In this way, we can first
@@ -66,6 +74,10 @@ type ConfigNoSim struct {
 	UIFontSize    int
 
 	DisplayRoot *panes.DisplayNode
+	// Scopes that aren't currently displayed; they are kept so that their
+	// settings are still there when the user signs on to a position that
+	// uses them again.
+	InactiveScopes []*panes.DisplayNode
 
 	AskedDiscordOptIn        bool
 	InhibitDiscordActivity   util.AtomicBool
@@ -236,3 +248,72 @@ func (gc *Config) Activate(c *sim.ControlClient, r renderer.Renderer, p platform
 
 	panes.Activate(gc.DisplayRoot, state, r, p, eventStream, lg)
 }
+
+// SetScopeForPosition makes sure that the display has the scope that
+// matches the signed-in position: ERAM for center positions, ASDE-X for
+// tower positions, and STARS for everyone else. eventStream may be nil if
+// the display hasn't been activated yet, in which case the new scope is
+// activated along with the rest of the panes.
+func (gc *Config) SetScopeForPosition(c *sim.ControlClient, r renderer.Renderer, p platform.Platform,
+	eventStream *sim.EventStream, lg *log.Logger) {
+	if gc.DisplayRoot == nil {
+		return
+	}
+
+	isScope := func(p panes.Pane) bool {
+		switch p.(type) {
+		case *stars.STARSPane, *eram.ERAMPane, *asdex.ASDEXPane:
+			return true
+		default:
+			return false
+		}
+	}
+	var scope panes.Pane
+	gc.DisplayRoot.VisitPanes(func(p panes.Pane) {
+		if isScope(p) {
+			scope = p
+		}
+	})
+	if scope == nil {
+		return
+	}
+
+	// isWanted returns true if the pane is the kind of scope we want and
+	// newPane creates a new one.
+	var isWanted func(panes.Pane) bool
+	var newPane func() panes.Pane
+	if ctrl := c.Controllers[c.Callsign]; ctrl != nil && ctrl.ERAMFacility {
+		isWanted = func(p panes.Pane) bool { _, ok := p.(*eram.ERAMPane); return ok }
+		newPane = func() panes.Pane { return eram.NewERAMPane(&c.State) }
+	} else if airport, ok := c.State.TowerAirport(c.Callsign); ok {
+		isWanted = func(p panes.Pane) bool { _, ok := p.(*asdex.ASDEXPane); return ok }
+		newPane = func() panes.Pane {
+			ap := asdex.NewASDEXPane(&c.State)
+			ap.Airport = airport
+			return ap
+		}
+	} else {
+		isWanted = func(p panes.Pane) bool { _, ok := p.(*stars.STARSPane); return ok }
+		newPane = func() panes.Pane { return stars.NewSTARSPane(&c.State) }
+	}
+	if isWanted(scope) {
+		return
+	}
+
+	// Use the previous scope of that type if we have one so that its
+	// settings are preserved.
+	var newScope panes.Pane
+	if idx := slices.IndexFunc(gc.InactiveScopes, func(n *panes.DisplayNode) bool { return isWanted(n.Pane) }); idx != -1 {
+		newScope = gc.InactiveScopes[idx].Pane
+		gc.InactiveScopes = slices.Delete(gc.InactiveScopes, idx, idx+1)
+	} else {
+		newScope = newPane()
+	}
+	if eventStream != nil {
+		newScope.Activate(&c.State, r, p, eventStream, lg)
+	}
+
+	gc.DisplayRoot.NodeForPane(scope).Pane = newScope
+	gc.InactiveScopes = append(gc.InactiveScopes, &panes.DisplayNode{Pane: scope})
+	lg.Infof("switched scope to %T", newScope)
+}
@@ -9,12 +9,14 @@ package main
 // exits.
 
 import (
+	"crypto/tls"
 	_ "embed"
 	"flag"
 	"fmt"
 	"log/slog"
 	_ "net/http/pprof"
 	"os"
+	"path"
 	"runtime"
 	"runtime/debug"
 	"strings"
@@ -44,11 +46,20 @@ var (
 	lintScenarios     = flag.Bool("lint", false, "check the validity of the built-in scenarios")
 	server            = flag.Bool("runserver", false, "run vice scenario server")
 	serverPort        = flag.Int("port", sim.ViceServerPort, "port to listen on when running server")
+	apiPort           = flag.Int("apiport", 0, fmt.Sprintf("port to serve the JSON API on when running server (e.g., %d; 0 to disable)", sim.ViceAPIPort))
 	serverAddress     = flag.String("server", sim.ViceServerAddress+fmt.Sprintf(":%d", sim.ViceServerPort), "IP address of vice multi-controller server")
 	scenarioFilename  = flag.String("scenario", "", "filename of JSON file with a scenario definition")
 	videoMapFilename  = flag.String("videomap", "", "filename of JSON file with video map definitions")
 	broadcastMessage  = flag.String("broadcast", "", "message to broadcast to all active clients on the server")
-	broadcastPassword = flag.String("password", "", "password to authenticate with server for broadcast message")
+	broadcastPassword = flag.String("password", "", "password to authenticate with server for broadcast messages and admin commands")
+	authToken         = flag.String("authtoken", "", "access token to identify yourself to the multi-controller server")
+	serverConfig      = flag.String("serverconfig", "", "JSON file listing users and access rules when running server")
+	tlsCert           = flag.String("tlscert", "", "TLS certificate PEM file to use when running server")
+	tlsKey            = flag.String("tlskey", "", "TLS private key PEM file to use when running server")
+	requireTLS        = flag.Bool("requiretls", false, "only accept TLS connections when running server")
+	announce          = flag.Bool("announce", false, "announce the server to vice clients on the local network when running server")
+	useTLS            = flag.Bool("tls", false, "connect to the multi-controller server using TLS")
+	tlsTOFU           = flag.Bool("tlstofu", false, "connect using TLS and trust the server's (possibly self-signed) certificate the first time it's seen")
 	resetSim          = flag.Bool("resetsim", false, "discard the saved simulation and do not try to resume it")
 	showRoutes        = flag.String("routes", "", "display the STARS, SIDs, and approaches known for the given airport")
 	listMaps          = flag.String("listmaps", "", "path to a video map file to list maps of (e.g., resources/videomaps/ZNY-videomaps.gob.zst)")
@@ -114,9 +125,24 @@ func main() {
 		}
 		os.Exit(0)
 	} else if *broadcastMessage != "" {
-		sim.BroadcastMessage(*serverAddress, *broadcastMessage, *broadcastPassword, lg)
+		sim.BroadcastMessage(*serverAddress, *broadcastMessage, *broadcastPassword, *authToken,
+			clientTLSConfig(lg), lg)
+	} else if flag.Arg(0) == "admin" {
+		token := util.Select(*authToken != "", *authToken, *broadcastPassword)
+		if err := sim.RunAdminCommand(*serverAddress, token, clientTLSConfig(lg), flag.Args()[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	} else if *server {
-		sim.RunServer(*scenarioFilename, *videoMapFilename, *serverPort, lg)
+		sim.RunServer(*scenarioFilename, *videoMapFilename, sim.ServerOptions{
+			Port:           *serverPort,
+			APIPort:        *apiPort,
+			ConfigFilename: *serverConfig,
+			TLSCertFile:    *tlsCert,
+			TLSKeyFile:     *tlsKey,
+			RequireTLS:     *requireTLS,
+			Announce:       *announce,
+		}, lg)
 	} else if *showRoutes != "" {
 		if err := av.PrintCIFPRoutes(*showRoutes); err != nil {
 			lg.Errorf("%s", err)
@@ -131,6 +157,7 @@ func main() {
 		var stats Stats
 		var render renderer.Renderer
 		var plat platform.Platform
+		var eventStream *sim.EventStream
 
 		// Catch any panics so that we can put up a dialog box and hopefully
 		// get a bug report.
@@ -157,9 +184,10 @@ func main() {
 		var controlClient *sim.ControlClient
 		var mgr *sim.ConnectionManager
 		var err error
-		mgr, err = sim.MakeServerConnection(*serverAddress, *scenarioFilename, *videoMapFilename, lg,
+		mgr, err = sim.MakeServerConnection(*serverAddress, *authToken, clientTLSConfig(lg), *scenarioFilename, *videoMapFilename, lg,
 			func(c *sim.ControlClient) { // updated client
 				if c != nil {
+					config.SetScopeForPosition(c, render, plat, eventStream, lg)
 					panes.Reset(config.DisplayRoot, c.State, lg)
 				}
 				uiResetControlClient(c)
@@ -175,6 +203,11 @@ func main() {
 							"thanks for your help testing vice; when the beta is released, the server\n"+
 							"will be updated as well.)")
 
+				case sim.ErrNotAuthorized:
+					ShowErrorDialog(plat, lg,
+						"The vice multi-controller server did not accept your access token.\n"+
+							"Please check the token given with the -authtoken option.")
+
 				case sim.ErrServerDisconnected:
 					ShowErrorDialog(plat, lg, "Lost connection to the vice server.")
 					uiShowConnectDialog(mgr, false, config, plat, lg)
@@ -210,7 +243,7 @@ func main() {
 			}
 		}
 
-		eventStream := sim.NewEventStream(lg)
+		eventStream = sim.NewEventStream(lg)
 
 		uiInit(render, plat, config, eventStream, lg)
 
@@ -226,7 +259,11 @@ func main() {
 
 		stats.startTime = time.Now()
 		for {
-			plat.SetWindowTitle("vice: " + controlClient.Status())
+			if mgr.Reconnecting() {
+				plat.SetWindowTitle("vice: " + controlClient.Status() + " [reconnecting]")
+			} else {
+				plat.SetWindowTitle("vice: " + controlClient.Status())
+			}
 
 			if controlClient == nil {
 				SetDiscordStatus(DiscordStatus{Start: mgr.ConnectionStartTime()}, config, lg)
@@ -274,3 +311,16 @@ func main() {
 		}
 	}
 }
+
+// clientTLSConfig returns the TLS configuration for connecting to
+// multi-controller servers given the command-line options, or nil if TLS
+// isn't being used.
+func clientTLSConfig(lg *log.Logger) util.ClientTLSConfig {
+	if *tlsTOFU {
+		// Remember servers' certificates alongside the config file.
+		return util.MakeTOFUTLSConfig(path.Join(path.Dir(configFilePath(lg)), "known_servers.json"), lg)
+	} else if *useTLS {
+		return func(string) *tls.Config { return &tls.Config{} }
+	}
+	return nil
+}
@@ -49,6 +49,11 @@ type Aircraft struct {
 	// State related to navigation. Pointers are used for optional values;
 	// nil -> unset/unspecified.
 	Nav Nav
+	// Only set in the redacted copies of aircraft sent to controllers,
+	// which don't have the approach's waypoints: whether the aircraft is
+	// established on the approach and at or above its next altitude
+	// restriction. See MVAsApply.
+	EstablishedOnApproach bool
 
 	// Departure related state
 	DepartureContactAltitude   float32
@@ -59,6 +64,8 @@ type Aircraft struct {
 	STAR                string
 	STARRunwayWaypoints map[string]WaypointArray
 	GotContactTower     bool
+	ClearedToLand       bool
+	ATIS                string // code of the arrival airport ATIS the pilot has
 
 	// Who to try to hand off to at a waypoint with /ho
 	WaypointHandoffController string
@@ -185,6 +192,7 @@ func (ac *Aircraft) Update(wind WindModel, simlg *log.Logger) *Waypoint {
 }
 
 func (ac *Aircraft) GoAround() []RadioTransmission {
+	ac.ClearedToLand = false
 	resp := ac.Nav.GoAround()
 	return []RadioTransmission{RadioTransmission{
 		Controller: ac.ControllingController,
@@ -203,6 +211,14 @@ func (ac *Aircraft) AssignSpeed(speed int, afterAltitude bool) []RadioTransmissi
 	return ac.transmitResponse(resp)
 }
 
+func (ac *Aircraft) HoldAtFix(fix string, seconds float32) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.HoldAtFix(strings.ToUpper(fix), seconds))
+}
+
+func (ac *Aircraft) Holding() bool {
+	return ac.Nav.Holding()
+}
+
 func (ac *Aircraft) MaintainSlowestPractical() []RadioTransmission {
 	return ac.transmitResponse(ac.Nav.MaintainSlowestPractical())
 }
@@ -231,29 +247,58 @@ func (ac *Aircraft) ExpediteClimb() []RadioTransmission {
 	return ac.transmitResponse(ac.Nav.ExpediteClimb())
 }
 
-func (ac *Aircraft) AssignHeading(heading int, turn TurnMethod) []RadioTransmission {
+// The WeatherCells passed to the following heading assignment methods are
+// used so that pilots can refuse vectors into hazardous weather.
+func (ac *Aircraft) AssignHeading(heading int, turn TurnMethod, wx []WeatherCell) []RadioTransmission {
+	if resp, ok := ac.Nav.CheckHeadingForWeather(float32(heading), wx); ok {
+		return ac.transmitResponse(resp)
+	}
 	resp := ac.Nav.AssignHeading(float32(heading), turn)
 	return ac.transmitResponse(resp)
 }
 
-func (ac *Aircraft) TurnLeft(deg int) []RadioTransmission {
+func (ac *Aircraft) TurnLeft(deg int, wx []WeatherCell) []RadioTransmission {
 	hdg := math.NormalizeHeading(ac.Nav.FlightState.Heading - float32(deg))
+	if resp, ok := ac.Nav.CheckHeadingForWeather(hdg, wx); ok {
+		return ac.transmitResponse(resp)
+	}
 	ac.Nav.AssignHeading(hdg, TurnLeft)
 	return ac.readback(rand.Sample("turn %d degrees left", "%d to the left"), deg)
 }
 
-func (ac *Aircraft) TurnRight(deg int) []RadioTransmission {
+func (ac *Aircraft) TurnRight(deg int, wx []WeatherCell) []RadioTransmission {
 	hdg := math.NormalizeHeading(ac.Nav.FlightState.Heading + float32(deg))
+	if resp, ok := ac.Nav.CheckHeadingForWeather(hdg, wx); ok {
+		return ac.transmitResponse(resp)
+	}
 	ac.Nav.AssignHeading(hdg, TurnRight)
 	return ac.readback(rand.Sample("turn %d degrees right", "%d to the right"), deg)
 }
 
+// UpdateWeatherDeviation returns any transmissions from the pilot
+// related to deviating around the given weather cells.
+func (ac *Aircraft) UpdateWeatherDeviation(wx []WeatherCell) []RadioTransmission {
+	resp := ac.Nav.UpdateWeatherDeviation(wx)
+	if resp == nil {
+		return nil
+	}
+	return []RadioTransmission{RadioTransmission{
+		Controller: ac.ControllingController,
+		Message:    resp.Message,
+		Type:       RadioTransmissionType(util.Select(resp.Unexpected, RadioTransmissionUnexpected, RadioTransmissionContact)),
+	}}
+}
+
+func (ac *Aircraft) ApproveDeviation() []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.ApproveDeviation())
+}
+
 func (ac *Aircraft) FlyPresentHeading() []RadioTransmission {
 	return ac.transmitResponse(ac.Nav.FlyPresentHeading())
 }
 
-func (ac *Aircraft) DirectFix(fix string) []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.DirectFix(strings.ToUpper(fix)))
+func (ac *Aircraft) DirectFix(fix string, restricted []SpecialUseAirspace) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.DirectFix(strings.ToUpper(fix), restricted))
 }
 
 func (ac *Aircraft) DepartFixHeading(fix string, hdg int) []RadioTransmission {
@@ -296,6 +341,18 @@ func (ac *Aircraft) ClearedStraightInApproach(id string) []RadioTransmission {
 	return ac.transmitResponse(resp)
 }
 
+// FlyLocalizerOnly is called when the aircraft's approach clearance is for
+// an ILS with the glideslope out of service.
+func (ac *Aircraft) FlyLocalizerOnly() {
+	ac.Nav.Approach.LocalizerOnly = true
+}
+
+// Unable returns the pilot's response when an instruction can't be
+// followed for the given reason.
+func (ac *Aircraft) Unable(reason string) []RadioTransmission {
+	return ac.transmitResponse(PilotResponse{Message: "unable. " + reason, Unexpected: true})
+}
+
 func (ac *Aircraft) CancelApproachClearance() []RadioTransmission {
 	return ac.transmitResponse(ac.Nav.CancelApproachClearance())
 }
@@ -337,6 +394,14 @@ func (ac *Aircraft) ContactTower(controllers map[string]*Controller, lg *log.Log
 	}
 }
 
+func (ac *Aircraft) ClearToLand() []RadioTransmission {
+	if ac.Nav.Approach.Assigned == nil || !ac.Nav.Approach.Cleared {
+		return ac.readbackUnexpected("unable. We haven't been cleared for the approach.")
+	}
+	ac.ClearedToLand = true
+	return ac.readback("cleared to land runway %s", ac.Nav.Approach.Assigned.Runway)
+}
+
 func (ac *Aircraft) InterceptLocalizer() []RadioTransmission {
 	resp := ac.Nav.InterceptLocalizer(ac.FlightPlan.ArrivalAirport)
 	return ac.transmitResponse(resp)
@@ -495,8 +560,39 @@ func (ac *Aircraft) NavSummary(lg *log.Logger) string {
 	return ac.Nav.Summary(*ac.FlightPlan, lg)
 }
 
-func (ac *Aircraft) ContactMessage(reportingPoints []ReportingPoint) string {
-	return ac.Nav.ContactMessage(reportingPoints, ac.STAR)
+// Redacted returns a shallow copy of the aircraft with only what the
+// radar and flight data systems would show a controller: the track,
+// transponder, flight plan, and datablock state. The pilot's navigation
+// state and intentions are left out; see Nav.Redacted.
+func (ac *Aircraft) Redacted() *Aircraft {
+	rac := *ac
+	rac.Nav = ac.Nav.Redacted()
+	rac.EstablishedOnApproach = ac.OnApproach(true)
+	rac.DepartureContactAltitude = 0
+	rac.DepartureContactController = ""
+	rac.GoAroundDistance = nil
+	rac.STARRunwayWaypoints = nil
+	rac.GotContactTower = false
+	rac.ATIS = ""
+	return &rac
+}
+
+// ContactMessage returns what the pilot says when checking in; currentATIS
+// is the code of the arrival airport's current ATIS, if any. If the
+// pilot's ATIS is out of date, they ask about it and then pick up the
+// current one; the ATIS code that the pilot has after checking in is
+// returned along with the message.
+func (ac *Aircraft) ContactMessage(reportingPoints []ReportingPoint, currentATIS string) (string, string) {
+	msg := ac.Nav.ContactMessage(reportingPoints, ac.STAR)
+	if ac.ATIS == "" {
+		return msg, ac.ATIS
+	}
+
+	if currentATIS != "" && ac.ATIS != currentATIS {
+		msg += ", we have information " + ATISPhonetic(ac.ATIS) + ", is that still current?"
+		return msg, currentATIS
+	}
+	return msg + ", with information " + ATISPhonetic(ac.ATIS), ac.ATIS
 }
 
 func (ac *Aircraft) DepartOnCourse(lg *log.Logger) {
@@ -572,10 +668,12 @@ func (ac *Aircraft) ATPAVolume() *ATPAVolume {
 
 func (ac *Aircraft) MVAsApply() bool {
 	// Start issuing MVAs 5 miles from the departure airport but not if
-	// they're established on an approach.
+	// they're established on an approach. This is called on the client
+	// with a redacted aircraft, so whether it's established was worked
+	// out by Redacted.
 	// TODO: are there better criteria?
 	return math.NMDistance2LL(ac.Position(), ac.Nav.FlightState.DepartureAirportLocation) > 5 &&
-		!ac.OnApproach(true)
+		!ac.EstablishedOnApproach
 }
 
 func (ac *Aircraft) ToggleSPCOverride(spc string) {
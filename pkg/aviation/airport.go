@@ -37,6 +37,10 @@ type Airport struct {
 
 	ATPAVolumes           map[string]*ATPAVolume `json:"atpa_volumes"`
 	OmitArrivalScratchpad bool                   `json:"omit_arrival_scratchpad"`
+
+	// Optional: taxiway centerlines for the surface display, keyed by
+	// taxiway name.
+	Taxiways map[string][]math.Point2LL `json:"taxiways,omitempty"`
 }
 
 type ConvergingRunways struct {
@@ -0,0 +1,81 @@
+// pkg/aviation/atis.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package aviation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mmp/vice/pkg/util"
+)
+
+var phoneticAlphabet = [26]string{"Alpha", "Bravo", "Charlie", "Delta", "Echo", "Foxtrot", "Golf",
+	"Hotel", "India", "Juliett", "Kilo", "Lima", "Mike", "November", "Oscar", "Papa", "Quebec",
+	"Romeo", "Sierra", "Tango", "Uniform", "Victor", "Whiskey", "X-ray", "Yankee", "Zulu"}
+
+// ATISPhonetic returns the spoken form of an ATIS code, e.g. "Kilo" for
+// "K".
+func ATISPhonetic(code string) string {
+	if len(code) != 1 || code[0] < 'A' || code[0] > 'Z' {
+		return code
+	}
+	return phoneticAlphabet[code[0]-'A']
+}
+
+// NextATISCode returns the code that follows the given one, wrapping
+// around from Z to A; the first code is A.
+func NextATISCode(code string) string {
+	if len(code) != 1 || code[0] < 'A' || code[0] >= 'Z' {
+		return "A"
+	}
+	return string(code[0] + 1)
+}
+
+// MakeATISContents returns the text of the airport's ATIS broadcast,
+// excluding the code and the observation time, so that the contents only
+// change when the conditions do.
+func MakeATISContents(metar *METAR, approaches []string, arrivalRunways []string,
+	departureRunways []string) string {
+	var s []string
+
+	if wind, err := metar.GetWind(); err == nil {
+		if wind.Speed == 0 {
+			s = append(s, "WIND CALM")
+		} else {
+			dir := util.Select(wind.Direction == -1, "VARIABLE", fmt.Sprintf("%03d", wind.Direction))
+			w := fmt.Sprintf("WIND %s AT %d", dir, wind.Speed)
+			if wind.Gust > wind.Speed {
+				w += fmt.Sprintf(" GUST %d", wind.Gust)
+			}
+			s = append(s, w)
+		}
+	}
+	if metar.Visibility > 0 {
+		if metar.Visibility >= 10 {
+			s = append(s, "VISIBILITY 10")
+		} else {
+			s = append(s, "VISIBILITY "+strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", metar.Visibility), "0"), "."))
+		}
+	}
+	if metar.Ceiling != UnlimitedCeiling {
+		s = append(s, fmt.Sprintf("CEILING %d", metar.Ceiling))
+	}
+	s = append(s, fmt.Sprintf("TEMPERATURE %d, DEWPOINT %d", metar.Temperature, metar.Dewpoint))
+	if alt := strings.TrimPrefix(metar.Altimeter, "A"); alt != "" {
+		s = append(s, "ALTIMETER "+alt)
+	}
+
+	if len(approaches) > 0 {
+		s = append(s, strings.ToUpper(strings.Join(approaches, ", "))+" IN USE")
+	}
+	if len(arrivalRunways) > 0 {
+		s = append(s, "LANDING "+strings.Join(arrivalRunways, ", "))
+	}
+	if len(departureRunways) > 0 {
+		s = append(s, "DEPARTING "+strings.Join(departureRunways, ", "))
+	}
+
+	return strings.Join(s, ". ") + "."
+}
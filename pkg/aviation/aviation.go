@@ -73,18 +73,36 @@ type METAR struct {
 	Weather     string
 	Altimeter   string
 	Rmk         string
+
+	// The following are decoded from Weather by ParseMETAR.
+	Visibility  float32 // statute miles; 0 if not reported
+	Ceiling     int     // feet AGL of the lowest broken or overcast layer
+	Temperature int     // Celsius
+	Dewpoint    int     // Celsius
 }
 
+// Ceiling value for METARs that don't report a broken or overcast layer.
+const UnlimitedCeiling = 99999
+
+// String returns the METAR's text; it can be parsed by ParseMETAR.
 func (m METAR) String() string {
-	auto := ""
+	fields := []string{m.AirportICAO, m.Time}
 	if m.Auto {
-		auto = "AUTO"
+		fields = append(fields, "AUTO")
+	}
+	fields = append(fields, m.Wind, m.Weather, m.Altimeter)
+	if m.Rmk != "" {
+		fields = append(fields, "RMK", m.Rmk)
 	}
-	return strings.Join([]string{m.AirportICAO, m.Time, auto, m.Wind, m.Weather, m.Altimeter, m.Rmk}, " ")
+	fields = slices.DeleteFunc(fields, func(f string) bool { return f == "" })
+	return strings.Join(fields, " ")
 }
 
 func ParseMETAR(str string) (*METAR, error) {
 	fields := strings.Fields(str)
+	if len(fields) > 0 && (fields[0] == "METAR" || fields[0] == "SPECI") {
+		fields = fields[1:]
+	}
 	if len(fields) < 3 {
 		return nil, fmt.Errorf("Expected >= 3 fields in METAR text")
 	}
@@ -99,7 +117,7 @@ func ParseMETAR(str string) (*METAR, error) {
 		return s
 	}
 
-	m := &METAR{AirportICAO: next(), Time: next(), Wind: next()}
+	m := &METAR{AirportICAO: next(), Time: next(), Wind: next(), Ceiling: UnlimitedCeiling}
 	if m.Wind == "AUTO" {
 		m.Auto = true
 		m.Wind = next()
@@ -117,6 +135,7 @@ func ParseMETAR(str string) (*METAR, error) {
 		m.Weather += s + " "
 	}
 	m.Weather = strings.TrimRight(m.Weather, " ")
+	m.decodeWeather()
 
 	if s := next(); s != "RMK" {
 		// TODO: improve the METAR parser...
@@ -131,6 +150,109 @@ func ParseMETAR(str string) (*METAR, error) {
 	return m, nil
 }
 
+// decodeWeather decodes the visibility, ceiling, temperature and dewpoint
+// from the METAR's weather groups.
+func (m *METAR) decodeWeather() {
+	parseFraction := func(s string) (float32, bool) {
+		if n, d, ok := strings.Cut(s, "/"); ok {
+			nv, nerr := strconv.Atoi(n)
+			dv, derr := strconv.Atoi(d)
+			if nerr != nil || derr != nil || dv == 0 {
+				return 0, false
+			}
+			return float32(nv) / float32(dv), true
+		}
+		v, err := strconv.Atoi(s)
+		return float32(v), err == nil
+	}
+	// Convert C, possibly prefixed with M for negative values.
+	parseTemperature := func(s string) (int, bool) {
+		neg := strings.HasPrefix(s, "M")
+		v, err := strconv.Atoi(strings.TrimPrefix(s, "M"))
+		return util.Select(neg, -v, v), err == nil
+	}
+
+	groups := strings.Fields(m.Weather)
+	for i, g := range groups {
+		switch {
+		case strings.HasSuffix(g, "SM"):
+			// Statute miles: 10SM, 1/2SM, M1/4SM, P6SM, and 1 1/2SM,
+			// where the whole number is in the previous group.
+			v, ok := parseFraction(strings.TrimLeft(strings.TrimSuffix(g, "SM"), "MP"))
+			if !ok {
+				continue
+			}
+			if i > 0 {
+				if w, err := strconv.Atoi(groups[i-1]); err == nil {
+					v += float32(w)
+				}
+			}
+			m.Visibility = v
+
+		case g == "CAVOK":
+			m.Visibility = 10000 / 1609.34
+
+		case len(g) == 4 && m.Visibility == 0 && i <= 1:
+			// ICAO visibility in meters, possibly after a variable wind
+			// group; 9999 is 10km or more.
+			if v, err := strconv.Atoi(g); err == nil {
+				m.Visibility = float32(v) / 1609.34
+			}
+
+		case strings.HasPrefix(g, "BKN") || strings.HasPrefix(g, "OVC") || strings.HasPrefix(g, "VV"):
+			hs := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(g, "BKN"), "OVC"), "VV")
+			if len(hs) >= 3 {
+				if h, err := strconv.Atoi(hs[:3]); err == nil {
+					m.Ceiling = math.Min(m.Ceiling, 100*h)
+				}
+			}
+
+		case strings.Contains(g, "/") && !strings.Contains(g, "SM"):
+			// Temperature/dewpoint, e.g. 18/06 or M02/M05.
+			t, d, _ := strings.Cut(g, "/")
+			if tv, ok := parseTemperature(t); ok {
+				m.Temperature = tv
+				if dv, ok := parseTemperature(d); ok {
+					m.Dewpoint = dv
+				}
+			}
+		}
+	}
+}
+
+// GetWind decodes the METAR's wind group; variable winds are returned
+// with a direction of -1.
+func (m METAR) GetWind() (Wind, error) {
+	w := strings.TrimSuffix(m.Wind, "KT")
+	if len(w) < 5 || len(w) == len(m.Wind) {
+		return Wind{}, fmt.Errorf("%s: invalid wind", m.Wind)
+	}
+
+	var wind Wind
+	if w[:3] == "VRB" {
+		wind.Direction = -1
+	} else if d, err := strconv.Atoi(w[:3]); err != nil {
+		return Wind{}, fmt.Errorf("%s: invalid wind direction", m.Wind)
+	} else {
+		wind.Direction = int32(d)
+	}
+
+	spd, gst, _ := strings.Cut(w[3:], "G")
+	if s, err := strconv.Atoi(spd); err != nil {
+		return Wind{}, fmt.Errorf("%s: invalid wind speed", m.Wind)
+	} else {
+		wind.Speed = int32(s)
+	}
+	if gst != "" {
+		if g, err := strconv.Atoi(gst); err != nil {
+			return Wind{}, fmt.Errorf("%s: invalid wind gust", m.Wind)
+		} else {
+			wind.Gust = int32(g)
+		}
+	}
+	return wind, nil
+}
+
 type ATIS struct {
 	Airport  string
 	AppDep   string
@@ -570,6 +692,34 @@ func TASToIAS(tas, altitude float32) float32 {
 	return tas * math.Sqrt(DensityRatioAtAltitude(altitude))
 }
 
+// CWTApproachSeparation returns the required in-trail separation in
+// nautical miles between aircraft on approach given the consolidated wake
+// turbulence categories of the leading and trailing aircraft, per
+// 7110.126B TBL 5-5-2. Zero is returned if only the minimum radar
+// separation is required.
+func CWTApproachSeparation(front, back string) float32 {
+	class := func(cwt string) int {
+		if idx := strings.Index("IHGFEDCBA", cwt); len(cwt) == 1 && idx != -1 {
+			return idx
+		}
+		return 9 // NOWGT
+	}
+
+	cwtOnApproachLookUp := [10][10]float32{ // [front][back]
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind I
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind H
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind G
+		{4, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind F
+		{4, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind E
+		{6, 6, 5, 5, 5, 4, 4, 3, 0, 10},          // Behind D
+		{6, 5, 3.5, 3.5, 3.5, 0, 0, 0, 0, 10},    // Behind C
+		{6, 5, 5, 5, 5, 4, 4, 3, 0, 10},          // Behind B
+		{8, 8, 7, 7, 7, 6, 6, 5, 0, 10},          // Behind A
+		{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, // Behind NOWGT (No weight: 7110.762)
+	}
+	return cwtOnApproachLookUp[class(front)][class(back)]
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // Arrival
 
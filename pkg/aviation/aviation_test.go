@@ -5,8 +5,12 @@
 package aviation
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/mmp/vice/pkg/math"
 	"github.com/mmp/vice/pkg/rand"
 )
 
@@ -66,6 +70,52 @@ func TestParseAltitudeRestriction(t *testing.T) {
 	}
 }
 
+func TestParseMETAR(t *testing.T) {
+	type testcase struct {
+		s           string
+		vis         float32
+		ceiling     int
+		temp, dewpt int
+		wind        Wind
+	}
+	for _, test := range []testcase{
+		testcase{s: "KJFK 181851Z 31012G22KT 10SM FEW050 BKN250 18/06 A3001 RMK AO2 SLP162",
+			vis: 10, ceiling: 25000, temp: 18, dewpt: 6, wind: Wind{Direction: 310, Speed: 12, Gust: 22}},
+		testcase{s: "METAR KBOS 181854Z AUTO VRB04KT 1 1/2SM BR OVC008 M02/M05 A2987",
+			vis: 1.5, ceiling: 800, temp: -2, dewpt: -5, wind: Wind{Direction: -1, Speed: 4}},
+		testcase{s: "KSFO 181856Z 00000KT M1/4SM FG VV001 12/12 A2999",
+			vis: 0.25, ceiling: 100, temp: 12, dewpt: 12},
+		testcase{s: "KDEN 181853Z 17008KT 10SM SCT100 25/M01 A3012",
+			vis: 10, ceiling: UnlimitedCeiling, temp: 25, dewpt: -1, wind: Wind{Direction: 170, Speed: 8}},
+	} {
+		m, err := ParseMETAR(test.s)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.s, err)
+			continue
+		}
+		if m.Visibility != test.vis {
+			t.Errorf("%s: got visibility %f, expected %f", test.s, m.Visibility, test.vis)
+		}
+		if m.Ceiling != test.ceiling {
+			t.Errorf("%s: got ceiling %d, expected %d", test.s, m.Ceiling, test.ceiling)
+		}
+		if m.Temperature != test.temp || m.Dewpoint != test.dewpt {
+			t.Errorf("%s: got temperature/dewpoint %d/%d, expected %d/%d", test.s, m.Temperature,
+				m.Dewpoint, test.temp, test.dewpt)
+		}
+		if w, err := m.GetWind(); err != nil {
+			t.Errorf("%s: unexpected error decoding wind: %v", test.s, err)
+		} else if w != test.wind {
+			t.Errorf("%s: got wind %+v, expected %+v", test.s, w, test.wind)
+		}
+		if m2, err := ParseMETAR(m.String()); err != nil {
+			t.Errorf("%s: error parsing String() \"%s\": %v", test.s, m.String(), err)
+		} else if *m2 != *m {
+			t.Errorf("%s: String() round trip gave %+v, expected %+v", test.s, *m2, *m)
+		}
+	}
+}
+
 func TestSquawkCodePoolBasics(t *testing.T) {
 	for _, p := range []*SquawkCodePool{MakeCompleteSquawkCodePool(), MakeSquawkBankCodePool(1), MakeSquawkBankCodePool(6)} {
 		sq, err := p.Get()
@@ -134,3 +184,158 @@ func TestSquawkCodePoolRandoms(t *testing.T) {
 		}
 	}
 }
+
+func TestWeatherCellDeviation(t *testing.T) {
+	const nmPerLongitude = 45
+	p := math.Point2LL{-73, 40}
+	// 15nm east and 2nm north of the aircraft.
+	cell := WeatherCell{
+		Id:     "WX1",
+		Center: math.Point2LL{-73 + 15./nmPerLongitude, 40 + 2./60},
+		Radius: 4,
+		Level:  4,
+		Top:    30000,
+	}
+	cells := []WeatherCell{cell}
+
+	if c := HazardousWeatherAlongPath(cells, p, 90, 10000, 1, nmPerLongitude, 0); c == nil {
+		t.Errorf("expected to find cell ahead heading 090")
+	}
+	if c := HazardousWeatherAlongPath(cells, p, 180, 10000, 1, nmPerLongitude, 0); c != nil {
+		t.Errorf("unexpected cell found heading 180")
+	}
+	if c := HazardousWeatherAlongPath(cells, p, 90, 35000, 1, nmPerLongitude, 0); c != nil {
+		t.Errorf("unexpected cell found above its top")
+	}
+
+	turn, deg := cell.DeviationAround(p, 90, nmPerLongitude, 0)
+	if turn != TurnRight || deg != 20 {
+		t.Errorf("got deviation %s %d; expected right 20", turn, deg)
+	}
+	if cell.IntersectsPath(p, 90+float32(deg), WeatherLookaheadDistance, 1, nmPerLongitude, 0) {
+		t.Errorf("deviation heading still intersects cell")
+	}
+
+	// Dissipating cells become less intense and are no longer avoided.
+	cell.Lifetime = 60
+	cell.Age = 52.5
+	if l := cell.CurrentLevel(); l != 2 {
+		t.Errorf("got level %d for dissipating cell; expected 2", l)
+	}
+	if cell.IsHazardous(10000) {
+		t.Errorf("dissipating cell shouldn't be hazardous")
+	}
+}
+
+func TestNavRedacted(t *testing.T) {
+	alt, spd, hdg := float32(5000), float32(210), float32(270)
+	filed := []Waypoint{{Fix: "MERIT", Location: math.Point2LL{-73, 41}}, {Fix: "HFD", Location: math.Point2LL{-72.6, 41.6}}}
+	nav := Nav{
+		FlightState: FlightState{Altitude: 7000, Heading: 45},
+		Altitude:    NavAltitude{Assigned: &alt, Expedite: true},
+		Speed:       NavSpeed{Assigned: &spd},
+		Heading:     NavHeading{Assigned: &hdg},
+		FixAssignments: map[string]NavFixAssignment{
+			"HFD": {Hold: &hdg},
+		},
+		DeferredHeading: &DeferredHeading{Time: time.Now(), Heading: NavHeading{Assigned: &hdg}},
+		Deviation:       &NavDeviation{Cell: "WX1", Heading: 90},
+		FinalAltitude:   17000,
+		// Cleared direct HFD, with a handoff there.
+		Waypoints:  []Waypoint{{Fix: "HFD", Location: filed[1].Location, Handoff: true, Speed: 250}},
+		FiledRoute: filed,
+	}
+
+	r := nav.Redacted()
+	if r.FlightState != nav.FlightState {
+		t.Errorf("flight state should be unchanged")
+	}
+	if !reflect.DeepEqual(r.Altitude, NavAltitude{}) || !reflect.DeepEqual(r.Speed, NavSpeed{}) ||
+		!reflect.DeepEqual(r.Heading, NavHeading{}) {
+		t.Errorf("redacted nav has assignments: %+v %+v %+v", r.Altitude, r.Speed, r.Heading)
+	}
+	if r.FixAssignments != nil || r.DeferredHeading != nil || r.Deviation != nil || r.FinalAltitude != 0 {
+		t.Errorf("redacted nav has pilot state: %+v", r)
+	}
+	if !reflect.DeepEqual(r.Waypoints, filed) || r.FiledRoute != nil {
+		t.Errorf("expected only the filed route %+v, got %+v", filed, r.Waypoints)
+	}
+}
+
+func TestRedactedMVAsApply(t *testing.T) {
+	restriction := AltitudeRestriction{Range: [2]float32{3000, 0}}
+	ac := &Aircraft{Callsign: "AAL1"}
+	ac.Nav.FlightState.DepartureAirportLocation = math.Point2LL{-75, 40}
+	ac.Nav.FlightState.Position = math.Point2LL{-73.8, 40.6}
+	ac.Nav.Approach.Cleared = true
+	ac.Nav.Approach.PassedApproachFix = true
+	ac.Nav.Waypoints = []Waypoint{{Fix: "ZALPO", AltitudeRestriction: &restriction}, {Fix: "_31L_THRESHOLD"}}
+	ac.Nav.FiledRoute = []Waypoint{{Fix: "CAMRN"}}
+
+	// MVAs still apply to aircraft on the approach but below its altitude
+	// restrictions, even though the redacted aircraft doesn't have the
+	// approach's waypoints.
+	for _, test := range []struct {
+		alt        float32
+		clearedApp bool
+		mvas       bool
+	}{
+		{alt: 2500, clearedApp: true, mvas: true},
+		{alt: 3000, clearedApp: true, mvas: false},
+		{alt: 4000, clearedApp: true, mvas: false},
+		{alt: 4000, clearedApp: false, mvas: true},
+	} {
+		ac.Nav.FlightState.Altitude = test.alt
+		ac.Nav.Approach.Cleared = test.clearedApp
+		if r := ac.Redacted(); r.MVAsApply() != test.mvas {
+			t.Errorf("%+v: got MVAsApply %v", test, r.MVAsApply())
+		}
+	}
+
+	// Departures close to the airport are exempt.
+	ac.Nav.FlightState.Position = ac.Nav.FlightState.DepartureAirportLocation
+	ac.Nav.Approach.Cleared = false
+	if ac.Redacted().MVAsApply() {
+		t.Errorf("MVAs shouldn't apply at the departure airport")
+	}
+}
+
+func TestSUASchedule(t *testing.T) {
+	var sua SpecialUseAirspace
+	if err := json.Unmarshal([]byte(`{"name": "R-1", "type": "restricted",
+		"schedule": [{"start": "1300", "end": "1500"}, {"start": "2200", "end": "0100"}]}`), &sua); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !sua.Restricted() {
+		t.Errorf("expected restricted airspace")
+	}
+
+	for _, tc := range []struct {
+		hh, mm int
+		active bool
+	}{{12, 59, false}, {13, 0, true}, {14, 59, true}, {15, 0, false}, {23, 30, true}, {0, 30, true}, {1, 0, false}} {
+		tm := time.Date(2024, 7, 4, tc.hh, tc.mm, 0, 0, time.UTC)
+		if sua.Active(tm) != tc.active {
+			t.Errorf("%02d%02d: expected active %v", tc.hh, tc.mm, tc.active)
+		}
+	}
+
+	var st SUATime
+	for _, bad := range []string{`"130"`, `"2460"`, `"1x00"`, `1300`} {
+		if err := json.Unmarshal([]byte(bad), &st); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+	// Types in non-addressable values (here, a struct in a map) should
+	// still be marshaled by name.
+	b, err := json.Marshal(map[string]SpecialUseAirspace{"R-1": sua})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var m map[string]SpecialUseAirspace
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Errorf("%s: unmarshal: %v", string(b), err)
+	} else if r := m["R-1"]; r.Type != SUARestricted || len(r.Schedule) != 2 || r.Schedule[1] != sua.Schedule[1] {
+		t.Errorf("%s: round trip gave %+v, expected %+v", string(b), r, sua)
+	}
+}
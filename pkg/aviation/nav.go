@@ -35,8 +35,16 @@ type Nav struct {
 	// followed, it's fine for the second to override it.
 	DeferredHeading *DeferredHeading
 
+	// Deviation is non-nil if the pilot has requested or is flying a
+	// deviation for weather.
+	Deviation *NavDeviation
+
 	FinalAltitude float32
 	Waypoints     []Waypoint
+
+	// FiledRoute is the route the aircraft was created with; only the
+	// waypoints' Fix and Location are set.
+	FiledRoute []Waypoint
 }
 
 // DeferredHeading stores a heading assignment from the controller and the
@@ -54,6 +62,20 @@ type DeferredHeading struct {
 	Heading NavHeading
 }
 
+// NavDeviation records a pilot's deviation from their route or assigned
+// heading in order to avoid a weather cell.
+type NavDeviation struct {
+	Cell     string // Id of the WeatherCell being avoided
+	Turn     TurnMethod
+	Degrees  int
+	Heading  float32 // heading to fly while deviating
+	Approved bool    // once set, the deviation heading is being flown
+
+	// Countdown gives the number of updates (seconds) to wait for
+	// approval of the request before deviating anyway.
+	Countdown int
+}
+
 type FlightState struct {
 	InitialDepartureClimb     bool
 	DepartureAirportLocation  math.Point2LL
@@ -118,6 +140,7 @@ type NavHeading struct {
 	JoiningArc   bool
 	RacetrackPT  *FlyRacetrackPT
 	Standard45PT *FlyStandard45PT
+	Hold         *FlyHold
 }
 
 type NavApproach struct {
@@ -129,6 +152,7 @@ type NavApproach struct {
 	PassedApproachFix bool // have we passed a fix on the approach yet?
 	NoPT              bool
 	AtFixClearedRoute []Waypoint
+	LocalizerOnly     bool // glideslope out of service; fly to localizer minimums
 }
 
 type NavFixAssignment struct {
@@ -140,6 +164,7 @@ type NavFixAssignment struct {
 		Fix     *Waypoint
 		Heading *float32
 	}
+	Hold *float32 // seconds to hold at the fix before continuing
 }
 
 type InterceptLocalizerState int
@@ -254,6 +279,9 @@ func makeNav(fp FlightPlan, perf AircraftPerformance, wp []Waypoint, nmPerLongit
 	// Filter out airways...
 	nav.Waypoints = util.FilterSlice(nav.Waypoints,
 		func(wp Waypoint) bool { return !wp.Location.IsZero() })
+	for _, wp := range nav.Waypoints {
+		nav.FiledRoute = append(nav.FiledRoute, Waypoint{Fix: wp.Fix, Location: wp.Location})
+	}
 
 	if ap, ok := DB.Airports[fp.DepartureAirport]; !ok {
 		lg.Errorf("%s: departure airport unknown", fp.DepartureAirport)
@@ -370,6 +398,31 @@ func (nav *Nav) OnExtendedCenterline(maxNmDeviation float32) bool {
 	return distance < maxNmDeviation
 }
 
+// Redacted returns the part of the Nav that a controller could know from
+// the radar track, the aircraft's filed route, and the approach it has
+// been told to expect. The pilot's pending altitude, speed, and heading
+// assignments, the route as they're currently flying it, and the details
+// of how they're flying the approach are omitted, though the result's
+// OnApproach(false) matches the original's. The filed route is returned
+// in Waypoints.
+func (nav *Nav) Redacted() Nav {
+	established := nav.OnApproach(false)
+	return Nav{
+		FlightState: nav.FlightState,
+		Perf:        nav.Perf,
+		Approach: NavApproach{
+			Assigned:          nav.Approach.Assigned,
+			AssignedId:        nav.Approach.AssignedId,
+			ATPAVolume:        nav.Approach.ATPAVolume,
+			Cleared:           nav.Approach.Cleared,
+			NoPT:              nav.Approach.NoPT,
+			PassedApproachFix: established && nav.Approach.PassedApproachFix,
+			InterceptState:    util.Select(established, nav.Approach.InterceptState, NotIntercepting),
+		},
+		Waypoints: nav.FiledRoute,
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // Communication
 
@@ -821,13 +874,130 @@ func (nav *Nav) Update(wind WindModel, lg *log.Logger) *Waypoint {
 	// Don't refer to DeferredHeading here; assume that if the pilot hasn't
 	// punched in a new heading assignment, we should update waypoints or
 	// not as per the old assignment.
-	if nav.Heading.Assigned == nil {
+	if nav.Heading.Assigned == nil && nav.Heading.Hold == nil {
 		return nav.updateWaypoints(wind, lg)
 	}
 
 	return nil
 }
 
+// intendedHeading returns the heading the aircraft would be flying if it
+// weren't deviating for weather.
+func (nav *Nav) intendedHeading() float32 {
+	if hdg, ok := nav.AssignedHeading(); ok {
+		return hdg
+	} else if nav.Heading.Arc == nil && len(nav.Waypoints) > 0 {
+		return math.Heading2LL(nav.FlightState.Position, nav.Waypoints[0].Location,
+			nav.FlightState.NmPerLongitude, nav.FlightState.MagneticVariation)
+	}
+	return nav.FlightState.Heading
+}
+
+// UpdateWeatherDeviation checks for hazardous weather along the aircraft's
+// path and requests, starts, and ends deviations around it as needed. It
+// returns a message for the pilot to transmit, if there's something to
+// say.
+func (nav *Nav) UpdateWeatherDeviation(cells []WeatherCell) *PilotResponse {
+	fs := &nav.FlightState
+	if !nav.IsAirborne() || fs.InitialDepartureClimb || nav.OnApproach(false) {
+		nav.Deviation = nil
+		return nil
+	}
+
+	hdg := nav.intendedHeading()
+	ahead := HazardousWeatherAlongPath(cells, fs.Position, hdg, fs.Altitude, 1,
+		fs.NmPerLongitude, fs.MagneticVariation)
+
+	dev := nav.Deviation
+	if dev == nil {
+		if ahead == nil {
+			return nil
+		}
+
+		turn, deg := ahead.DeviationAround(fs.Position, hdg, fs.NmPerLongitude, fs.MagneticVariation)
+		nav.Deviation = &NavDeviation{
+			Cell:      ahead.Id,
+			Turn:      turn,
+			Degrees:   deg,
+			Heading:   deviationHeading(hdg, turn, deg),
+			Countdown: 30,
+		}
+		return &PilotResponse{
+			Message: fmt.Sprintf(rand.Sample("request %d %s for weather", "we'd like %d degrees %s for weather"),
+				deg, turn),
+		}
+	}
+
+	idx := slices.IndexFunc(cells, func(c WeatherCell) bool { return c.Id == dev.Cell })
+	if idx == -1 || ahead == nil {
+		// The weather has moved or dissipated; back on course.
+		nav.Deviation = nil
+		if !dev.Approved {
+			return nil
+		}
+		msg := "we're clear of the weather"
+		if _, ok := nav.AssignedHeading(); ok {
+			msg += fmt.Sprintf(", back on heading %03d", int(hdg))
+		} else if len(nav.Waypoints) > 0 && nav.Waypoints[0].Fix != "" && nav.Heading.Arc == nil {
+			msg += ", proceeding direct " + FixReadback(nav.Waypoints[0].Fix)
+		}
+		return &PilotResponse{Message: msg}
+	}
+
+	cell := &cells[idx]
+	if !dev.Approved {
+		dev.Countdown--
+		if d := math.NMDistance2LL(fs.Position, cell.Center) - cell.Radius; dev.Countdown <= 0 || d < WeatherCellBuffer+2 {
+			// No word from the controller and we're running out of room.
+			dev.Approved = true
+			return &PilotResponse{
+				Message:    fmt.Sprintf("we're deviating %d degrees %s for weather", dev.Degrees, dev.Turn),
+				Unexpected: true,
+			}
+		}
+	} else if dev.Degrees < 90 && cell.IntersectsPath(fs.Position, dev.Heading, WeatherLookaheadDistance, 1,
+		fs.NmPerLongitude, fs.MagneticVariation) {
+		// The cell has moved or grown into the path we're flying; turn
+		// further away from it.
+		dev.Degrees += 10
+		dev.Heading = deviationHeading(hdg, dev.Turn, dev.Degrees)
+	}
+
+	return nil
+}
+
+func deviationHeading(hdg float32, turn TurnMethod, deg int) float32 {
+	return math.NormalizeHeading(hdg + float32(util.Select(turn == TurnLeft, -deg, deg)))
+}
+
+// ApproveDeviation is called when the controller approves a requested
+// weather deviation.
+func (nav *Nav) ApproveDeviation() PilotResponse {
+	dev := nav.Deviation
+	if dev == nil {
+		return PilotResponse{Message: "we're not requesting a deviation", Unexpected: true}
+	}
+	dev.Approved = true
+	return PilotResponse{Message: fmt.Sprintf("deviating %d %s, we'll advise when clear", dev.Degrees, dev.Turn)}
+}
+
+// CheckHeadingForWeather returns true and a response from the pilot if
+// flying the given heading would take the aircraft into hazardous weather.
+func (nav *Nav) CheckHeadingForWeather(hdg float32, cells []WeatherCell) (PilotResponse, bool) {
+	fs := &nav.FlightState
+	if !nav.IsAirborne() {
+		return PilotResponse{}, false
+	}
+	if c := HazardousWeatherAlongPath(cells, fs.Position, hdg, fs.Altitude, 0, fs.NmPerLongitude,
+		fs.MagneticVariation); c != nil {
+		return PilotResponse{
+			Message:    fmt.Sprintf("unable heading %03d, that takes us into the weather", int(hdg)),
+			Unexpected: true,
+		}, true
+	}
+	return PilotResponse{}, false
+}
+
 func (nav *Nav) TargetHeading(wind WindModel, lg *log.Logger) (heading float32, turn TurnMethod, rate float32) {
 	// Is it time to start following a heading given by the controller a
 	// few seconds ago?
@@ -839,6 +1009,11 @@ func (nav *Nav) TargetHeading(wind WindModel, lg *log.Logger) (heading float32,
 
 	heading, turn, rate = nav.FlightState.Heading, TurnClosest, 3 // baseline
 
+	if dev := nav.Deviation; dev != nil && dev.Approved {
+		lg.Debugf("heading: deviating for weather %.0f", dev.Heading)
+		return dev.Heading, dev.Turn, 3
+	}
+
 	// nav.Heading.Assigned may still be nil pending a deferred turn
 	if (nav.Approach.InterceptState == InitialHeading ||
 		nav.Approach.InterceptState == TurningToJoin) && nav.Heading.Assigned != nil {
@@ -851,6 +1026,9 @@ func (nav *Nav) TargetHeading(wind WindModel, lg *log.Logger) (heading float32,
 	if nav.Heading.Standard45PT != nil {
 		return nav.Heading.Standard45PT.GetHeading(nav, wind, lg)
 	}
+	if nav.Heading.Hold != nil {
+		return nav.Heading.Hold.GetHeading(nav, lg)
+	}
 
 	if nav.Heading.Assigned != nil {
 		heading = *nav.Heading.Assigned
@@ -1085,6 +1263,11 @@ func (nav *Nav) TargetAltitude(lg *log.Logger) (alt, rate float32) {
 		return MaximumRate
 	}
 
+	if mda, ok := nav.localizerOnlyMDA(); ok {
+		lg.Debugf("alt: localizer only, level at %.0f", mda)
+		return mda, MaximumRate
+	}
+
 	if nav.Altitude.Assigned != nil {
 		alt, rate = *nav.Altitude.Assigned, getAssignedRate(*nav.Altitude.Assigned)
 		lg.Debugf("alt: assigned %.0f, rate %.0f", alt, rate)
@@ -1109,6 +1292,32 @@ func (nav *Nav) TargetAltitude(lg *log.Logger) (alt, rate float32) {
 	return
 }
 
+// localizerOnlyMDA returns the altitude to level off at when flying a
+// localizer-only approach: after the FAF, aircraft descend to a minimum
+// descent altitude 500' above the runway and stay there until they are
+// close enough to the runway to descend visually.
+func (nav *Nav) localizerOnlyMDA() (float32, bool) {
+	if !nav.Approach.LocalizerOnly || !nav.Approach.Cleared || len(nav.Waypoints) == 0 ||
+		slices.ContainsFunc(nav.Waypoints, func(wp Waypoint) bool { return wp.FAF }) {
+		return 0, false
+	}
+
+	// The route ends with the airport; the runway threshold is the last
+	// waypoint with an altitude restriction before it.
+	rwy := nav.Waypoints[len(nav.Waypoints)-1]
+	for i := len(nav.Waypoints) - 1; i >= 0; i-- {
+		if nav.Waypoints[i].AltitudeRestriction != nil {
+			rwy = nav.Waypoints[i]
+			break
+		}
+	}
+	if math.NMDistance2LL(nav.FlightState.Position, rwy.Location) < 2 {
+		return 0, false
+	}
+	mda := nav.FlightState.ArrivalAirportElevation + 500
+	return math.Min(mda, nav.FlightState.Altitude), true
+}
+
 func (nav *Nav) flyingPT() bool {
 	return (nav.Heading.RacetrackPT != nil && nav.Heading.RacetrackPT.State != PTStateApproaching) ||
 		(nav.Heading.Standard45PT != nil && nav.Heading.Standard45PT.State != PT45StateApproaching)
@@ -1129,7 +1338,7 @@ type WaypointCrossingConstraint struct {
 // than it would otherwise at one waypoint in order to make a restriction
 // at a subsequent waypoint.
 func (nav *Nav) getWaypointAltitudeConstraint() *WaypointCrossingConstraint {
-	if nav.Heading.Assigned != nil {
+	if nav.Heading.Assigned != nil || nav.Heading.Hold != nil {
 		// ignore what's going on with the fixes
 		return nil
 	}
@@ -1571,6 +1780,13 @@ func (nav *Nav) updateWaypoints(wind WindModel, lg *log.Logger) *Waypoint {
 			nav.Heading = NavHeading{Arc: wp.Arc, JoiningArc: true}
 		}
 
+		if nfa, ok := nav.FixAssignments[wp.Fix]; ok && nfa.Hold != nil {
+			lg.Debugf("entering hold at %s for %.0f seconds", wp.Fix, *nfa.Hold)
+			nav.Heading = NavHeading{Hold: MakeFlyHold(nav, wp, *nfa.Hold)}
+			nfa.Hold = nil
+			nav.FixAssignments[wp.Fix] = nfa
+		}
+
 		if wp.NoPT {
 			nav.Approach.NoPT = true
 		}
@@ -1947,6 +2163,7 @@ func (nav *Nav) assignHeading(hdg float32, turn TurnMethod) {
 
 	// Don't carry this from a waypoint we may have previously passed.
 	nav.Approach.NoPT = false
+	nav.Deviation = nil
 	nav.EnqueueHeading(NavHeading{Assigned: &hdg, Turn: &turn})
 }
 
@@ -2046,11 +2263,21 @@ func (nav *Nav) directFix(fix string) bool {
 	return false
 }
 
-func (nav *Nav) DirectFix(fix string) PilotResponse {
+// DirectFix sends the aircraft direct to the given fix, unless doing so
+// would take it through one of the given restricted airspaces.
+func (nav *Nav) DirectFix(fix string, restricted []SpecialUseAirspace) PilotResponse {
+	wps := nav.Waypoints
 	if nav.directFix(fix) {
+		if sua := nav.restrictedAirspaceOnPath(nav.Waypoints[0].Location, restricted); sua != nil {
+			nav.Waypoints = wps
+			return PilotResponse{Message: "unable. Direct " + FixReadback(fix) + " would take us through " +
+				sua.Name, Unexpected: true}
+		}
+
 		nav.EnqueueHeading(NavHeading{})
 		nav.Approach.NoPT = false
 		nav.Approach.InterceptState = NotIntercepting
+		nav.Deviation = nil
 
 		return PilotResponse{Message: "direct " + FixReadback(fix)}
 	} else {
@@ -2058,6 +2285,55 @@ func (nav *Nav) DirectFix(fix string) PilotResponse {
 	}
 }
 
+// restrictedAirspaceOnPath returns the first of the given airspaces that
+// a straight path from the aircraft's position to p would enter at either
+// its current altitude or the altitude it has been assigned.
+func (nav *Nav) restrictedAirspaceOnPath(p math.Point2LL, restricted []SpecialUseAirspace) *SpecialUseAirspace {
+	alts := []int{int(nav.FlightState.Altitude)}
+	if nav.Altitude.Assigned != nil {
+		alts = append(alts, int(*nav.Altitude.Assigned))
+	}
+
+	for i := range restricted {
+		for _, alt := range alts {
+			if restricted[i].SegmentInside(nav.FlightState.Position, p, alt) {
+				return &restricted[i]
+			}
+		}
+	}
+	return nil
+}
+
+// HoldAtFix has the aircraft hold at the given fix in its route for
+// (approximately) the given number of seconds before continuing.
+func (nav *Nav) HoldAtFix(fix string, seconds float32) PilotResponse {
+	if !slices.ContainsFunc(nav.Waypoints, func(wp Waypoint) bool { return wp.Fix == fix }) {
+		return PilotResponse{Message: "unable. " + FixReadback(fix) + " isn't in our route", Unexpected: true}
+	}
+
+	nfa := nav.FixAssignments[fix]
+	nfa.Hold = &seconds
+	nav.FixAssignments[fix] = nfa
+
+	efc := int(seconds+59) / 60
+	return PilotResponse{Message: fmt.Sprintf("hold at %s, right turns, expect further clearance in %d minutes",
+		FixReadback(fix), efc)}
+}
+
+// Holding returns true if the aircraft is holding or has been told to
+// hold at a fix ahead.
+func (nav *Nav) Holding() bool {
+	if nav.Heading.Hold != nil {
+		return true
+	}
+	for _, nfa := range nav.FixAssignments {
+		if nfa.Hold != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (nav *Nav) DepartFixDirect(fixa string, fixb string) PilotResponse {
 	fa, fb := nav.fixPairInRoute(fixa, fixb)
 	if fa == nil {
@@ -2424,6 +2700,7 @@ func (nav *Nav) CancelApproachClearance() PilotResponse {
 	nav.Approach.Cleared = false
 	nav.Approach.InterceptState = NotIntercepting
 	nav.Approach.NoPT = false
+	nav.Approach.LocalizerOnly = false
 
 	return PilotResponse{Message: "cancel approach clearance."}
 }
@@ -2837,3 +3114,86 @@ func (fp *FlyStandard45PT) GetHeading(nav *Nav, wind WindModel, lg *log.Logger)
 		return nav.FlightState.Heading, TurnClosest, StandardTurnRate
 	}
 }
+
+///////////////////////////////////////////////////////////////////////////
+// Holding
+
+// FlyHold flies a standard holding pattern at a fix: right turns and one
+// minute legs (1.5 minutes above 14,000'). Aircraft always make a direct
+// entry, starting the outbound turn when they reach the fix.
+type FlyHold struct {
+	Fix            string
+	FixLocation    math.Point2LL
+	InboundHeading float32
+	LegSeconds     float32
+	Elapsed        float32 // seconds on the current outbound leg
+	Remaining      float32 // seconds of holding remaining
+	State          int
+}
+
+const (
+	HoldStateTurningOutbound = iota
+	HoldStateFlyingOutbound
+	HoldStateTurningInbound
+	HoldStateFlyingInbound
+)
+
+func MakeFlyHold(nav *Nav, wp Waypoint, seconds float32) *FlyHold {
+	return &FlyHold{
+		Fix:            wp.Fix,
+		FixLocation:    wp.Location,
+		InboundHeading: nav.FlightState.Heading,
+		LegSeconds:     util.Select(nav.FlightState.Altitude > 14000, float32(90), float32(60)),
+		Remaining:      seconds,
+		State:          HoldStateTurningOutbound,
+	}
+}
+
+// GetHeading returns the heading to fly in the hold; it is called once
+// for each second of simulation time. When the holding time has expired,
+// the aircraft leaves the hold after next crossing the fix.
+func (fh *FlyHold) GetHeading(nav *Nav, lg *log.Logger) (float32, TurnMethod, float32) {
+	fh.Remaining--
+	outbound := math.OppositeHeading(fh.InboundHeading)
+
+	switch fh.State {
+	case HoldStateTurningOutbound:
+		if math.HeadingDifference(nav.FlightState.Heading, outbound) < 1 {
+			fh.State = HoldStateFlyingOutbound
+			fh.Elapsed = 0
+		}
+		return outbound, TurnRight, StandardTurnRate
+
+	case HoldStateFlyingOutbound:
+		fh.Elapsed++
+		if fh.Elapsed >= fh.LegSeconds {
+			fh.State = HoldStateTurningInbound
+		}
+		return outbound, TurnClosest, StandardTurnRate
+
+	case HoldStateTurningInbound:
+		if math.HeadingDifference(nav.FlightState.Heading, fh.InboundHeading) < 1 {
+			fh.State = HoldStateFlyingInbound
+		}
+		return fh.InboundHeading, TurnRight, StandardTurnRate
+
+	case HoldStateFlyingInbound:
+		hdg := math.Heading2LL(nav.FlightState.Position, fh.FixLocation, nav.FlightState.NmPerLongitude,
+			nav.FlightState.MagneticVariation)
+		dist := math.NMDistance2LL(nav.FlightState.Position, fh.FixLocation)
+		if eta := dist / nav.FlightState.GS * 3600; eta < 2 {
+			if fh.Remaining <= 0 {
+				lg.Debugf("leaving hold at %s", fh.Fix)
+				nav.Heading = NavHeading{}
+			} else {
+				fh.State = HoldStateTurningOutbound
+			}
+		}
+		return hdg, TurnClosest, StandardTurnRate
+
+	default:
+		lg.Errorf("%d: unhandled hold state; leaving hold at %s", fh.State, fh.Fix)
+		nav.Heading = NavHeading{}
+		return nav.FlightState.Heading, TurnClosest, StandardTurnRate
+	}
+}
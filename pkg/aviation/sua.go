@@ -0,0 +1,214 @@
+// pkg/aviation/sua.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package aviation
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/util"
+)
+
+// SpecialUseAirspace represents a restricted area, MOA, or TFR. It is
+// made up of one or more AirspaceVolumes and is active either all the
+// time or only during the time windows given by its schedule.
+type SpecialUseAirspace struct {
+	Name     string           `json:"name"`
+	Type     SUAType          `json:"type"`
+	Volumes  []AirspaceVolume `json:"volumes"`
+	Schedule []SUATimeWindow  `json:"schedule,omitempty"`
+}
+
+type SUAType int
+
+const (
+	SUARestricted SUAType = iota
+	SUAMOA
+	SUATFR
+)
+
+func (t SUAType) String() string {
+	return []string{"Restricted", "MOA", "TFR"}[t]
+}
+
+func (t SUAType) MarshalJSON() ([]byte, error) {
+	switch t {
+	case SUARestricted:
+		return []byte("\"restricted\""), nil
+	case SUAMOA:
+		return []byte("\"moa\""), nil
+	case SUATFR:
+		return []byte("\"tfr\""), nil
+	default:
+		return nil, fmt.Errorf("%d: unknown special use airspace type", t)
+	}
+}
+
+func (t *SUAType) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "\"restricted\"":
+		*t = SUARestricted
+		return nil
+	case "\"moa\"":
+		*t = SUAMOA
+		return nil
+	case "\"tfr\"":
+		*t = SUATFR
+		return nil
+	default:
+		return fmt.Errorf("%s: unknown special use airspace type", string(b))
+	}
+}
+
+// SUATimeWindow gives a period of time, in UTC, during which a special
+// use airspace is active. If End is before Start, the window spans
+// midnight.
+type SUATimeWindow struct {
+	Start SUATime `json:"start"`
+	End   SUATime `json:"end"`
+}
+
+// SUATime is a time of day, stored as minutes after midnight UTC and
+// represented in JSON as an "HHMM" string.
+type SUATime int
+
+func (t SUATime) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"%02d%02d\"", int(t)/60, int(t)%60)), nil
+}
+
+func (t *SUATime) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if len(s) != 6 || s[0] != '"' || s[5] != '"' {
+		return fmt.Errorf("%s: expected \"HHMM\" time", s)
+	}
+	hhmm, err := strconv.Atoi(s[1:5])
+	if err != nil {
+		return fmt.Errorf("%s: expected \"HHMM\" time", s)
+	}
+	hh, mm := hhmm/100, hhmm%100
+	if hh > 24 || mm > 59 || (hh == 24 && mm != 0) {
+		return fmt.Errorf("%s: invalid time", s)
+	}
+	*t = SUATime(60*hh + mm)
+	return nil
+}
+
+func (w SUATimeWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	m := SUATime(60*t.Hour() + t.Minute())
+	if w.Start <= w.End {
+		return m >= w.Start && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// Active returns true if the airspace is active at the given time.
+func (s *SpecialUseAirspace) Active(t time.Time) bool {
+	if len(s.Schedule) == 0 {
+		return true
+	}
+	for _, w := range s.Schedule {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Restricted returns true if IFR aircraft may not be routed through the
+// airspace when it is active.
+func (s *SpecialUseAirspace) Restricted() bool {
+	return s.Type == SUARestricted || s.Type == SUATFR
+}
+
+func (s *SpecialUseAirspace) Inside(p math.Point2LL, alt int) bool {
+	for i := range s.Volumes {
+		if s.Volumes[i].Inside(p, alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// SegmentInside returns true if any part of the line segment between the
+// two points at the given altitude is inside the airspace.
+func (s *SpecialUseAirspace) SegmentInside(p0, p1 math.Point2LL, alt int) bool {
+	// Sample the segment every half mile or so.
+	d := math.NMDistance2LL(p0, p1)
+	n := 1 + int(2*d)
+	for i := 0; i <= n; i++ {
+		t := float32(i) / float32(n)
+		if s.Inside(math.Lerp2f(t, p0, p1), alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// AltitudeRange returns the lowest floor and the highest ceiling of the
+// airspace's volumes.
+func (s *SpecialUseAirspace) AltitudeRange() (int, int) {
+	floor, ceiling := 0, 0
+	for i, v := range s.Volumes {
+		if i == 0 || v.Floor < floor {
+			floor = v.Floor
+		}
+		ceiling = math.Max(ceiling, v.Ceiling)
+	}
+	return floor, ceiling
+}
+
+func (s *SpecialUseAirspace) Center() math.Point2LL {
+	e := math.EmptyExtent2D()
+	for _, v := range s.Volumes {
+		switch v.Type {
+		case AirspaceVolumePolygon:
+			for _, p := range v.Vertices {
+				e = math.Union(e, p)
+			}
+		case AirspaceVolumeCircle:
+			e = math.Union(e, v.Center)
+		}
+	}
+	return e.Center()
+}
+
+func (s *SpecialUseAirspace) GenerateDrawCommands(cb *renderer.CommandBuffer, nmPerLongitude float32) {
+	for i := range s.Volumes {
+		s.Volumes[i].GenerateDrawCommands(cb, nmPerLongitude)
+	}
+}
+
+func (s *SpecialUseAirspace) Check(e *util.ErrorLogger) {
+	if s.Name == "" {
+		e.ErrorString("must specify \"name\"")
+	}
+	if len(s.Volumes) == 0 {
+		e.ErrorString("must specify at least one entry in \"volumes\"")
+	}
+	for i, v := range s.Volumes {
+		e.Push(fmt.Sprintf("Volume %d", i))
+		if v.Ceiling <= v.Floor {
+			e.ErrorString("\"ceiling\" %d must be greater than \"floor\" %d", v.Ceiling, v.Floor)
+		}
+		switch v.Type {
+		case AirspaceVolumePolygon:
+			if len(v.Vertices) < 3 {
+				e.ErrorString("polygon must have at least three \"vertices\"")
+			}
+		case AirspaceVolumeCircle:
+			if v.Center.IsZero() {
+				e.ErrorString("must specify \"center\"")
+			}
+			if v.Radius <= 0 {
+				e.ErrorString("\"radius\" must be greater than zero")
+			}
+		}
+		e.Pop()
+	}
+}
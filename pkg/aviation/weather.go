@@ -0,0 +1,141 @@
+// pkg/aviation/weather.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package aviation
+
+import (
+	"time"
+
+	"github.com/mmp/vice/pkg/math"
+)
+
+// WeatherCell is a synthetic convective cell: a roughly circular area of
+// precipitation that drifts and evolves over time. Aircraft below the
+// cell's top will not fly through it if it is level 3 or above.
+type WeatherCell struct {
+	Id     string        `json:"id"`
+	Center math.Point2LL `json:"center"`
+	Radius float32       `json:"radius"` // nm
+	Level  int           `json:"level"`  // peak intensity, 1-6, as in the WX levels on the scope
+	Top    int           `json:"top"`    // feet MSL
+
+	// Motion and evolution
+	Heading  float32 `json:"heading"`  // true heading the cell is moving toward
+	Speed    float32 `json:"speed"`    // knots
+	Growth   float32 `json:"growth"`   // change in radius, nm per hour
+	Lifetime float32 `json:"lifetime"` // minutes; zero means it never dissipates
+
+	Age float32 `json:"age,omitempty"` // minutes
+}
+
+// Cells at or above this level are avoided by pilots.
+const HazardousWeatherLevel = 3
+
+// Extra distance beyond a cell's radius that pilots try to keep clear of it.
+const WeatherCellBuffer = 3
+
+// WeatherLookaheadDistance is how far ahead (in nm) pilots look for
+// weather along their route.
+const WeatherLookaheadDistance = 25
+
+// Update moves and evolves the cell by the given amount of time.
+func (c *WeatherCell) Update(dt time.Duration, nmPerLongitude float32) {
+	hours := float32(dt.Hours())
+
+	hdg := math.Radians(c.Heading)
+	v := math.Scale2f([2]float32{math.Sin(hdg), math.Cos(hdg)}, c.Speed*hours)
+	p := math.Add2f(math.LL2NM(c.Center, nmPerLongitude), v)
+	c.Center = math.NM2LL(p, nmPerLongitude)
+
+	c.Radius = math.Max(0, c.Radius+c.Growth*hours)
+	c.Age += float32(dt.Minutes())
+}
+
+// Expired indicates whether the cell has dissipated completely.
+func (c *WeatherCell) Expired() bool {
+	return c.Radius <= 0 || (c.Lifetime > 0 && c.Age >= c.Lifetime)
+}
+
+// CurrentLevel returns the cell's intensity, accounting for dissipation
+// over the last quarter of its lifetime.
+func (c *WeatherCell) CurrentLevel() int {
+	if c.Lifetime == 0 || c.Age < 0.75*c.Lifetime {
+		return c.Level
+	}
+	remaining := math.Max(0, c.Lifetime-c.Age) / (0.25 * c.Lifetime)
+	return int(math.Ceil(remaining * float32(c.Level)))
+}
+
+// LevelAt returns the weather level at the given point; intensity falls
+// off from the cell's core to its edge.
+func (c *WeatherCell) LevelAt(p math.Point2LL, nmPerLongitude float32) int {
+	d := math.Distance2f(math.LL2NM(p, nmPerLongitude), math.LL2NM(c.Center, nmPerLongitude))
+	if d >= c.Radius {
+		return 0
+	}
+	return math.Max(1, int(math.Ceil((1-d/c.Radius)*float32(c.CurrentLevel()))))
+}
+
+// IsHazardous indicates whether an aircraft at the given altitude should
+// avoid the cell.
+func (c *WeatherCell) IsHazardous(alt float32) bool {
+	return c.CurrentLevel() >= HazardousWeatherLevel && alt < float32(c.Top)
+}
+
+// IntersectsPath returns true if the segment starting at p and extending
+// dist nm along the given magnetic heading comes within the cell's radius
+// plus the given buffer.
+func (c *WeatherCell) IntersectsPath(p math.Point2LL, hdg float32, dist float32, buffer float32,
+	nmPerLongitude float32, magneticVariation float32) bool {
+	p0 := math.LL2NM(p, nmPerLongitude)
+	h := math.Radians(hdg - magneticVariation)
+	p1 := math.Add2f(p0, math.Scale2f([2]float32{math.Sin(h), math.Cos(h)}, dist))
+	pc := math.LL2NM(c.Center, nmPerLongitude)
+	return math.PointSegmentDistance(pc, p0, p1) < c.Radius+buffer
+}
+
+// HazardousWeatherAlongPath returns the closest cell that an aircraft at
+// the given position and altitude would fly into along the given magnetic
+// heading, if any.
+func HazardousWeatherAlongPath(cells []WeatherCell, p math.Point2LL, hdg float32, alt float32, buffer float32,
+	nmPerLongitude float32, magneticVariation float32) *WeatherCell {
+	var closest *WeatherCell
+	closestDist := float32(0)
+	for i := range cells {
+		c := &cells[i]
+		if !c.IsHazardous(alt) ||
+			!c.IntersectsPath(p, hdg, WeatherLookaheadDistance, buffer, nmPerLongitude, magneticVariation) {
+			continue
+		}
+		if d := math.NMDistance2LL(p, c.Center); closest == nil || d < closestDist {
+			closest, closestDist = c, d
+		}
+	}
+	return closest
+}
+
+// DeviationAround returns the turn direction and number of degrees
+// (rounded up to a multiple of 10) that an aircraft at p flying the given
+// magnetic heading should deviate to stay clear of the cell.
+func (c *WeatherCell) DeviationAround(p math.Point2LL, hdg float32, nmPerLongitude float32,
+	magneticVariation float32) (TurnMethod, int) {
+	bearing := math.Heading2LL(p, c.Center, nmPerLongitude, magneticVariation)
+	// Signed angle to the cell's center; positive if it's to the right.
+	theta := math.NormalizeHeading(bearing-hdg+180) - 180
+
+	// Half-angle subtended by the cell plus the buffer.
+	d := math.NMDistance2LL(p, c.Center)
+	alpha := float32(90)
+	if r := c.Radius + WeatherCellBuffer; d > r {
+		alpha = math.Degrees(math.SafeASin(r / d))
+	}
+
+	var turn TurnMethod = TurnLeft
+	deg := alpha - theta
+	if theta < 0 {
+		turn, deg = TurnRight, alpha+theta
+	}
+	ideg := math.Clamp(10*int(math.Ceil(deg/10)), 10, 90)
+	return turn, ideg
+}
@@ -0,0 +1,357 @@
+// pkg/panes/asdex/asdex.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+// Package asdex implements an ASDE-X-style surface movement display for
+// tower and ground positions. It shows the airport's runways and
+// taxiways, aircraft on the ground and on short final, and the departure
+// queues; departures holding short are cleared for takeoff and arrivals
+// are cleared to land by clicking on them.
+package asdex
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/panes"
+	"github.com/mmp/vice/pkg/panes/stars"
+	"github.com/mmp/vice/pkg/platform"
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
+	"github.com/mmp/vice/pkg/util"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+var (
+	ASDEXBackgroundColor = renderer.RGB{.1, .1, .12}
+	ASDEXRunwayColor     = renderer.RGB{.5, .5, .5}
+	ASDEXTaxiwayColor    = renderer.RGB{.3, .3, .35}
+	ASDEXDepartureColor  = renderer.RGB{.3, .8, .9}
+	ASDEXArrivalColor    = renderer.RGB{.95, .85, .3}
+	ASDEXHoldingColor    = renderer.RGB{.3, .9, .3}
+	ASDEXListColor       = renderer.RGB{.85, .85, .85}
+	ASDEXErrorColor      = renderer.RGB{.9, .2, .2}
+)
+
+const (
+	ASDEXRunwayWidth = 150. / 6076 // nm
+	// Airborne aircraft are shown if they're within this distance of the
+	// airport and below this altitude above it.
+	ASDEXAirborneRange    = 6    // nm
+	ASDEXAirborneAltitude = 3000 // feet
+)
+
+type ASDEXPane struct {
+	FontIdentifier renderer.FontIdentifier
+	Airport        string
+	Range          float32
+	Offset         math.Point2LL // of the center from the airport
+
+	font     *renderer.Font
+	airports []string
+	message  string
+}
+
+func init() {
+	panes.RegisterUnmarshalPane("ASDEXPane", func(d []byte) (panes.Pane, error) {
+		var p ASDEXPane
+		err := json.Unmarshal(d, &p)
+		return &p, err
+	})
+}
+
+func NewASDEXPane(ss *sim.State) *ASDEXPane {
+	ap := &ASDEXPane{
+		FontIdentifier: renderer.FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 14},
+		Range:          2,
+	}
+	if ss != nil {
+		ap.Airport = ss.PrimaryAirport
+	}
+	return ap
+}
+
+func (ap *ASDEXPane) DisplayName() string { return "ASDE-X" }
+
+func (ap *ASDEXPane) Hide() bool { return false }
+
+func (ap *ASDEXPane) Activate(ss *sim.State, r renderer.Renderer, p platform.Platform,
+	eventStream *sim.EventStream, lg *log.Logger) {
+	if ap.font = renderer.GetFont(ap.FontIdentifier); ap.font == nil {
+		ap.font = renderer.GetDefaultFont()
+		ap.FontIdentifier = ap.font.Id
+	}
+	if ap.Range == 0 {
+		ap.Range = 2
+	}
+	if ss != nil {
+		ap.airports = util.SortedMapKeys(ss.Airports)
+	}
+}
+
+func (ap *ASDEXPane) Reset(ss sim.State, lg *log.Logger) {
+	ap.airports = util.SortedMapKeys(ss.Airports)
+	if !slices.Contains(ap.airports, ap.Airport) {
+		ap.Airport = ss.PrimaryAirport
+		ap.Offset = math.Point2LL{}
+	}
+	ap.message = ""
+}
+
+func (ap *ASDEXPane) CanTakeKeyboardFocus() bool { return false }
+
+func (ap *ASDEXPane) DrawUI(p platform.Platform, config *platform.Config) {
+	if newFont, changed := renderer.DrawFontPicker(&ap.FontIdentifier, "Font"); changed {
+		ap.font = newFont
+	}
+
+	if imgui.BeginComboV("Airport", ap.Airport, imgui.ComboFlagsHeightLarge) {
+		for _, airport := range ap.airports {
+			if imgui.SelectableV(airport, airport == ap.Airport, 0, imgui.Vec2{}) {
+				ap.Airport = airport
+				ap.Offset = math.Point2LL{}
+			}
+		}
+		imgui.EndCombo()
+	}
+}
+
+func (ap *ASDEXPane) Draw(ctx *panes.Context, cb *renderer.CommandBuffer) {
+	cb.ClearRGB(ASDEXBackgroundColor)
+
+	fap, ok := av.DB.Airports[ap.Airport]
+	if !ok {
+		return
+	}
+	center := math.Add2LL(fap.Location, ap.Offset)
+	transforms := stars.GetScopeTransformations(ctx.PaneExtent, ctx.ControlClient.MagneticVariation,
+		ctx.ControlClient.NmPerLongitude, center, ap.Range, 0)
+
+	ap.drawAirport(ctx, fap, transforms, cb)
+	ap.drawTargets(ctx, fap, transforms, cb)
+	ap.drawDepartureQueues(ctx, cb)
+
+	ap.consumeMouseEvents(ctx, fap, transforms)
+}
+
+func (ap *ASDEXPane) drawAirport(ctx *panes.Context, fap av.FAAAirport, transforms stars.ScopeTransformations,
+	cb *renderer.CommandBuffer) {
+	ld := renderer.GetLinesDrawBuilder()
+	defer renderer.ReturnLinesDrawBuilder(ld)
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+
+	nmPerLongitude := ctx.ControlClient.NmPerLongitude
+	style := renderer.TextStyle{Font: ap.font, Color: ASDEXRunwayColor}
+	for _, rwy := range fap.Runways {
+		opp, ok := av.LookupOppositeRunway(ap.Airport, rwy.Id)
+		if !ok {
+			continue
+		}
+
+		// Runway outline; each pair of runway ends is only drawn once.
+		if rwy.Id < opp.Id {
+			p0, p1 := math.LL2NM(rwy.Threshold, nmPerLongitude), math.LL2NM(opp.Threshold, nmPerLongitude)
+			dir := math.Normalize2f(math.Sub2f(p1, p0))
+			w := math.Scale2f([2]float32{dir[1], -dir[0]}, ASDEXRunwayWidth/2)
+			ld.AddLineLoop(util.MapSlice([][2]float32{math.Add2f(p0, w), math.Add2f(p1, w),
+				math.Sub2f(p1, w), math.Sub2f(p0, w)},
+				func(p [2]float32) [2]float32 { return [2]float32(math.NM2LL(p, nmPerLongitude)) }))
+		}
+
+		// Label just before the threshold.
+		p := math.Point2LL(math.Lerp2f(-.04, rwy.Threshold, opp.Threshold))
+		td.AddTextCentered(rwy.Id, transforms.WindowFromLatLongP(p), style)
+	}
+
+	cb.LineWidth(1, ctx.DPIScale)
+	cb.SetRGB(ASDEXRunwayColor)
+	transforms.LoadLatLongViewingMatrices(cb)
+	ld.GenerateCommands(cb)
+
+	if airport, ok := ctx.ControlClient.Airports[ap.Airport]; ok && len(airport.Taxiways) > 0 {
+		tld := renderer.GetLinesDrawBuilder()
+		defer renderer.ReturnLinesDrawBuilder(tld)
+
+		style := renderer.TextStyle{Font: ap.font, Color: ASDEXTaxiwayColor}
+		for _, name := range util.SortedMapKeys(airport.Taxiways) {
+			pts := airport.Taxiways[name]
+			for i := 1; i < len(pts); i++ {
+				tld.AddLine(pts[i-1], pts[i])
+			}
+			if len(pts) > 0 {
+				td.AddText(name, math.Add2f(transforms.WindowFromLatLongP(pts[0]), [2]float32{2, 2}), style)
+			}
+		}
+
+		cb.SetRGB(ASDEXTaxiwayColor)
+		tld.GenerateCommands(cb)
+	}
+
+	transforms.LoadWindowViewingMatrices(cb)
+	td.GenerateCommands(cb)
+}
+
+// airborneTargets returns aircraft arriving at or departing from the
+// airport that are close to it and low.
+func (ap *ASDEXPane) airborneTargets(ctx *panes.Context, fap av.FAAAirport) []*av.Aircraft {
+	var aircraft []*av.Aircraft
+	for _, ac := range ctx.ControlClient.Aircraft {
+		if ac.FlightPlan == nil ||
+			(ac.FlightPlan.ArrivalAirport != ap.Airport && ac.FlightPlan.DepartureAirport != ap.Airport) {
+			continue
+		}
+		if math.NMDistance2LL(ac.Position(), fap.Location) < ASDEXAirborneRange &&
+			ac.Altitude() < float32(fap.Elevation+ASDEXAirborneAltitude) {
+			aircraft = append(aircraft, ac)
+		}
+	}
+	sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+	return aircraft
+}
+
+func (ap *ASDEXPane) surfaceTargets(ctx *panes.Context) []sim.SurfaceTarget {
+	var targets []sim.SurfaceTarget
+	for _, t := range ctx.ControlClient.SurfaceTargets {
+		if t.Airport == ap.Airport {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+func (ap *ASDEXPane) drawTargets(ctx *panes.Context, fap av.FAAAirport, transforms stars.ScopeTransformations,
+	cb *renderer.CommandBuffer) {
+	ld := renderer.GetColoredLinesDrawBuilder()
+	defer renderer.ReturnColoredLinesDrawBuilder(ld)
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+
+	drawTarget := func(p math.Point2LL, heading float32, color renderer.RGB, lines ...string) {
+		pw := transforms.WindowFromLatLongP(p)
+
+		// An arrow pointing in the direction of travel.
+		h := math.Radians(heading - ctx.ControlClient.MagneticVariation)
+		fwd := [2]float32{math.Sin(h), math.Cos(h)}
+		side := [2]float32{fwd[1], -fwd[0]}
+		tip := math.Add2f(pw, math.Scale2f(fwd, 7))
+		l := math.Add2f(math.Sub2f(pw, math.Scale2f(fwd, 5)), math.Scale2f(side, 4))
+		r := math.Sub2f(math.Sub2f(pw, math.Scale2f(fwd, 5)), math.Scale2f(side, 4))
+		ld.AddLineLoop(color, [][2]float32{tip, l, r})
+
+		td.AddText(strings.Join(lines, "\n"), math.Add2f(pw, [2]float32{10, 10}),
+			renderer.TextStyle{Font: ap.font, Color: color})
+	}
+
+	for _, t := range ap.surfaceTargets(ctx) {
+		color := util.Select(t.Departure, ASDEXDepartureColor, ASDEXArrivalColor)
+		if t.State == sim.SurfaceHoldingShort {
+			color = ASDEXHoldingColor
+		}
+		drawTarget(t.Position, t.Heading, color, t.Callsign, t.AircraftType+" "+t.State.String())
+	}
+
+	for _, ac := range ap.airborneTargets(ctx, fap) {
+		alt := fmt.Sprintf("%03d", (int(ac.Altitude())+50)/100)
+		if ac.ClearedToLand {
+			alt += " CTL"
+		}
+		drawTarget(ac.Position(), ac.Heading(), ASDEXArrivalColor, ac.Callsign, alt)
+	}
+
+	transforms.LoadWindowViewingMatrices(cb)
+	cb.LineWidth(1, ctx.DPIScale)
+	ld.GenerateCommands(cb)
+	td.GenerateCommands(cb)
+}
+
+func (ap *ASDEXPane) drawDepartureQueues(ctx *panes.Context, cb *renderer.CommandBuffer) {
+	var runways []string
+	for _, t := range ap.surfaceTargets(ctx) {
+		if t.Departure && !slices.Contains(runways, t.Runway) {
+			runways = append(runways, t.Runway)
+		}
+	}
+	slices.Sort(runways)
+
+	var lines []string
+	for _, rwy := range runways {
+		q := ctx.ControlClient.DepartureQueue(ap.Airport, rwy)
+		lines = append(lines, fmt.Sprintf("RWY %s: %s", rwy,
+			strings.Join(util.MapSlice(q, func(t sim.SurfaceTarget) string { return t.Callsign }), " ")))
+	}
+
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+
+	p := [2]float32{4, ctx.PaneExtent.Height() - 4}
+	td.AddText(strings.Join(append([]string{ap.Airport + " DEPARTURE QUEUES"}, lines...), "\n"), p,
+		renderer.TextStyle{Font: ap.font, Color: ASDEXListColor})
+	if ap.message != "" {
+		td.AddText(ap.message, [2]float32{4, float32(ap.font.Size + 4)},
+			renderer.TextStyle{Font: ap.font, Color: ASDEXErrorColor})
+	}
+
+	ctx.SetWindowCoordinateMatrices(cb)
+	td.GenerateCommands(cb)
+}
+
+func (ap *ASDEXPane) consumeMouseEvents(ctx *panes.Context, fap av.FAAAirport, transforms stars.ScopeTransformations) {
+	mouse := ctx.Mouse
+	if mouse == nil {
+		return
+	}
+
+	if mouse.Dragging[platform.MouseButtonSecondary] {
+		if delta := mouse.DragDelta; delta[0] != 0 || delta[1] != 0 {
+			ap.Offset = math.Sub2LL(ap.Offset, transforms.LatLongFromWindowV(delta))
+		}
+	}
+	if mouse.Wheel[1] != 0 {
+		ap.Range = math.Clamp(ap.Range*(1+mouse.Wheel[1]/10), .5, 10)
+	}
+
+	if !mouse.Clicked[platform.MouseButtonPrimary] {
+		return
+	}
+
+	// Clicking on a departure holding short clears it for takeoff;
+	// clicking on an arrival clears it to land.
+	onErr := func(err error) { ap.message = err.Error() }
+	var callsign string
+	var departure bool
+	distance := float32(15) // pixels
+	for _, t := range ap.surfaceTargets(ctx) {
+		if d := math.Distance2f(transforms.WindowFromLatLongP(t.Position), mouse.Pos); d < distance &&
+			t.State == sim.SurfaceHoldingShort {
+			callsign, departure, distance = t.Callsign, true, d
+		}
+	}
+	for _, ac := range ap.airborneTargets(ctx, fap) {
+		if d := math.Distance2f(transforms.WindowFromLatLongP(ac.Position()), mouse.Pos); d < distance &&
+			ac.FlightPlan.ArrivalAirport == ap.Airport {
+			callsign, departure, distance = ac.Callsign, false, d
+		}
+	}
+
+	ap.message = ""
+	if callsign == "" {
+		return
+	} else if departure {
+		ctx.ControlClient.ClearForTakeoff(callsign, onErr)
+	} else {
+		ctx.ControlClient.RunAircraftCommands(callsign, "CTL",
+			func(msg string, remaining string) {
+				if msg != "" {
+					ap.message = callsign + ": " + msg
+				}
+			})
+	}
+}
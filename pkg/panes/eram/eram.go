@@ -0,0 +1,766 @@
+// pkg/panes/eram/eram.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+// Package eram implements an ERAM-style display for en-route (ARTCC)
+// controllers. It is much simpler than the STARS display: it provides
+// full and limited datablocks, route display, and the commands needed
+// to work traffic with the adjacent facilities (track initiation and
+// drop, handoffs, pointouts, and interim altitudes).
+package eram
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/panes"
+	"github.com/mmp/vice/pkg/panes/stars"
+	"github.com/mmp/vice/pkg/platform"
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
+	"github.com/mmp/vice/pkg/util"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+var (
+	ERAMBackgroundColor = renderer.RGB{.02, .02, .1}
+	ERAMMapColor        = renderer.RGB{.4, .4, .45}
+	ERAMFDBColor        = renderer.RGB{.9, .9, .9}
+	ERAMLDBColor        = renderer.RGB{.6, .6, .6}
+	ERAMRouteColor      = renderer.RGB{.85, .85, .3}
+	ERAMInputColor      = renderer.RGB{.9, .9, .9}
+	ERAMErrorColor      = renderer.RGB{.9, .2, .2}
+)
+
+const (
+	// En-route radars sweep much less frequently than terminal radars.
+	ERAMTrackUpdateInterval = 12 * time.Second
+	// Reported altitudes within this many feet of the assigned altitude
+	// are shown as conforming.
+	ERAMConformingAltitude = 200
+	// Length of the velocity vector drawn for full datablock tracks.
+	ERAMVelocityVectorMinutes = 1
+)
+
+type ERAMPane struct {
+	FontIdentifier  renderer.FontIdentifier
+	Center          math.Point2LL
+	Range           float32
+	VideoMapVisible map[string]interface{}
+
+	font      *renderer.Font
+	videoMaps map[string]*av.VideoMap
+	events    *sim.EventsSubscription
+
+	tracks          map[string]*track
+	lastTrackUpdate time.Time
+
+	// Aircraft the user has asked to see full datablocks for even
+	// though they aren't tracked by the user.
+	forcedFDBs map[string]interface{}
+	// Aircraft whose routes are being displayed.
+	routeDisplays map[string]interface{}
+	// Pointouts to us that haven't been acknowledged: callsign ->
+	// controller callsign.
+	pointOuts map[string]string
+
+	input         string
+	response      string
+	responseError bool
+}
+
+// track records what the radar reported for an aircraft at its most
+// recent sweep.
+type track struct {
+	position math.Point2LL
+	altitude float32
+	modeC    bool
+	heading  float32
+	gs       float32
+	squawk   av.Squawk
+}
+
+func init() {
+	panes.RegisterUnmarshalPane("ERAMPane", func(d []byte) (panes.Pane, error) {
+		var p ERAMPane
+		err := json.Unmarshal(d, &p)
+		return &p, err
+	})
+}
+
+func NewERAMPane(ss *sim.State) *ERAMPane {
+	ep := &ERAMPane{
+		FontIdentifier: renderer.FontIdentifier{Name: "Fixed Demi Bold", Size: 14},
+		Range:          150,
+	}
+	if ss != nil {
+		ep.Center = ss.GetInitialCenter()
+	}
+	return ep
+}
+
+func (ep *ERAMPane) DisplayName() string { return "ERAM" }
+
+func (ep *ERAMPane) Hide() bool { return false }
+
+func (ep *ERAMPane) Activate(ss *sim.State, r renderer.Renderer, p platform.Platform,
+	eventStream *sim.EventStream, lg *log.Logger) {
+	if ep.font = renderer.GetFont(ep.FontIdentifier); ep.font == nil {
+		ep.font = renderer.GetDefaultFont()
+		ep.FontIdentifier = ep.font.Id
+	}
+	if ep.Range == 0 {
+		ep.Range = 150
+	}
+	ep.events = eventStream.Subscribe()
+
+	ep.tracks = make(map[string]*track)
+	ep.forcedFDBs = make(map[string]interface{})
+	ep.routeDisplays = make(map[string]interface{})
+	ep.pointOuts = make(map[string]string)
+
+	if ss != nil {
+		ep.makeMaps(*ss, lg)
+	}
+}
+
+func (ep *ERAMPane) Reset(ss sim.State, lg *log.Logger) {
+	ep.Center = ss.GetInitialCenter()
+	ep.VideoMapVisible = nil
+	ep.makeMaps(ss, lg)
+
+	ep.tracks = make(map[string]*track)
+	ep.lastTrackUpdate = time.Time{}
+	ep.forcedFDBs = make(map[string]interface{})
+	ep.routeDisplays = make(map[string]interface{})
+	ep.pointOuts = make(map[string]string)
+	ep.input, ep.response = "", ""
+}
+
+func (ep *ERAMPane) makeMaps(ss sim.State, lg *log.Logger) {
+	ep.videoMaps = make(map[string]*av.VideoMap)
+	videoMaps, defaultVideoMaps := ss.GetVideoMaps()
+	for _, vm := range videoMaps {
+		ep.videoMaps[vm.Name] = &vm
+	}
+
+	if ep.VideoMapVisible == nil {
+		ep.VideoMapVisible = make(map[string]interface{})
+		for _, dm := range defaultVideoMaps {
+			if _, ok := ep.videoMaps[dm]; ok {
+				ep.VideoMapVisible[dm] = nil
+			} else {
+				lg.Errorf("%s: \"default_map\" not found", dm)
+			}
+		}
+	}
+}
+
+func (ep *ERAMPane) CanTakeKeyboardFocus() bool { return true }
+
+func (ep *ERAMPane) DrawUI(p platform.Platform, config *platform.Config) {
+	if newFont, changed := renderer.DrawFontPicker(&ep.FontIdentifier, "Font"); changed {
+		ep.font = newFont
+	}
+
+	if len(ep.videoMaps) > 0 && imgui.CollapsingHeader("Maps") {
+		for _, name := range util.SortedMapKeys(ep.videoMaps) {
+			_, visible := ep.VideoMapVisible[name]
+			if imgui.Checkbox(name, &visible) {
+				if visible {
+					ep.VideoMapVisible[name] = nil
+				} else {
+					delete(ep.VideoMapVisible, name)
+				}
+			}
+		}
+	}
+}
+
+func (ep *ERAMPane) Draw(ctx *panes.Context, cb *renderer.CommandBuffer) {
+	ep.processEvents(ctx)
+	ep.updateTracks(ctx)
+
+	cb.ClearRGB(ERAMBackgroundColor)
+
+	ep.processKeyboardInput(ctx)
+
+	transforms := stars.GetScopeTransformations(ctx.PaneExtent, ctx.ControlClient.MagneticVariation,
+		ctx.ControlClient.NmPerLongitude, ep.Center, ep.Range, 0)
+
+	ep.drawMaps(ctx, transforms, cb)
+	ep.drawRoutes(ctx, transforms, cb)
+
+	aircraft := ep.visibleAircraft(ctx)
+	ep.drawTracks(aircraft, ctx, transforms, cb)
+	ep.drawDatablocks(aircraft, ctx, transforms, cb)
+
+	stars.DrawHighlighted(ctx, transforms, cb)
+	ep.drawInput(ctx, cb)
+
+	ep.consumeMouseEvents(ctx, transforms)
+}
+
+func (ep *ERAMPane) processEvents(ctx *panes.Context) {
+	for _, event := range ep.events.Get() {
+		switch event.Type {
+		case sim.PointOutEvent:
+			if event.ToController == ctx.ControlClient.Callsign {
+				ep.pointOuts[event.Callsign] = event.FromController
+			}
+
+		case sim.AcknowledgedPointOutEvent, sim.RejectedPointOutEvent:
+			if event.FromController == ctx.ControlClient.Callsign {
+				delete(ep.pointOuts, event.Callsign)
+			}
+
+		case sim.DroppedTrackEvent:
+			delete(ep.forcedFDBs, event.Callsign)
+		}
+	}
+}
+
+// updateTracks takes a new radar sweep when it's time for one.
+func (ep *ERAMPane) updateTracks(ctx *panes.Context) {
+	now := ctx.ControlClient.CurrentTime()
+	if now.Sub(ep.lastTrackUpdate) < ERAMTrackUpdateInterval {
+		return
+	}
+	ep.lastTrackUpdate = now
+
+	for callsign := range ep.tracks {
+		if _, ok := ctx.ControlClient.Aircraft[callsign]; !ok {
+			delete(ep.tracks, callsign)
+			delete(ep.forcedFDBs, callsign)
+			delete(ep.routeDisplays, callsign)
+			delete(ep.pointOuts, callsign)
+		}
+	}
+
+	for callsign, ac := range ctx.ControlClient.Aircraft {
+		if !ac.IsAirborne() {
+			delete(ep.tracks, callsign)
+			continue
+		}
+		p, alt := ctx.ControlClient.ExtrapolatedTrack(ac, now)
+		ep.tracks[callsign] = &track{
+			position: p,
+			altitude: alt,
+			modeC:    ac.Mode == av.Charlie,
+			heading:  ac.Heading(),
+			gs:       ac.GS(),
+			squawk:   ac.Squawk,
+		}
+	}
+}
+
+func (ep *ERAMPane) visibleAircraft(ctx *panes.Context) []*av.Aircraft {
+	var aircraft []*av.Aircraft
+	for callsign := range ep.tracks {
+		if ac, ok := ctx.ControlClient.Aircraft[callsign]; ok {
+			aircraft = append(aircraft, ac)
+		}
+	}
+	// Sort so that overlapping datablocks are drawn consistently.
+	sort.Slice(aircraft, func(i, j int) bool { return aircraft[i].Callsign < aircraft[j].Callsign })
+	return aircraft
+}
+
+func (ep *ERAMPane) drawMaps(ctx *panes.Context, transforms stars.ScopeTransformations, cb *renderer.CommandBuffer) {
+	cb.LineWidth(1, ctx.DPIScale)
+	cb.SetRGB(ERAMMapColor)
+	transforms.LoadLatLongViewingMatrices(cb)
+	for _, name := range util.SortedMapKeys(ep.VideoMapVisible) {
+		if vm, ok := ep.videoMaps[name]; ok {
+			cb.Call(vm.CommandBuffer)
+		}
+	}
+}
+
+func (ep *ERAMPane) drawRoutes(ctx *panes.Context, transforms stars.ScopeTransformations, cb *renderer.CommandBuffer) {
+	if len(ep.routeDisplays) == 0 {
+		return
+	}
+
+	ld := renderer.GetLinesDrawBuilder()
+	defer renderer.ReturnLinesDrawBuilder(ld)
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+
+	style := renderer.TextStyle{Font: ep.font, Color: ERAMRouteColor}
+	for _, callsign := range util.SortedMapKeys(ep.routeDisplays) {
+		ac, ok := ctx.ControlClient.Aircraft[callsign]
+		trk, tok := ep.tracks[callsign]
+		if !ok || !tok {
+			delete(ep.routeDisplays, callsign)
+			continue
+		}
+
+		prev := trk.position
+		for _, wp := range ac.Nav.Waypoints {
+			ld.AddLine(prev, wp.Location)
+			prev = wp.Location
+			if !strings.HasPrefix(wp.Fix, "_") {
+				p := math.Add2f(transforms.WindowFromLatLongP(wp.Location), [2]float32{4, 4})
+				td.AddText(wp.Fix, p, style)
+			}
+		}
+	}
+
+	cb.LineWidth(1, ctx.DPIScale)
+	cb.SetRGB(ERAMRouteColor)
+	transforms.LoadLatLongViewingMatrices(cb)
+	ld.GenerateCommands(cb)
+
+	transforms.LoadWindowViewingMatrices(cb)
+	td.GenerateCommands(cb)
+}
+
+// haveFDB returns true if the aircraft should be shown with a full
+// datablock: it is our track, it is being handed off to us, it has been
+// pointed out to us, or the user asked for one.
+func (ep *ERAMPane) haveFDB(ctx *panes.Context, ac *av.Aircraft) bool {
+	callsign := ctx.ControlClient.Callsign
+	if ac.TrackingController == callsign || ac.HandoffTrackController == callsign {
+		return true
+	}
+	if _, ok := ep.pointOuts[ac.Callsign]; ok {
+		return true
+	}
+	_, ok := ep.forcedFDBs[ac.Callsign]
+	return ok
+}
+
+func (ep *ERAMPane) drawTracks(aircraft []*av.Aircraft, ctx *panes.Context, transforms stars.ScopeTransformations,
+	cb *renderer.CommandBuffer) {
+	ld := renderer.GetColoredLinesDrawBuilder()
+	defer renderer.ReturnColoredLinesDrawBuilder(ld)
+
+	for _, ac := range aircraft {
+		trk := ep.tracks[ac.Callsign]
+		p := transforms.WindowFromLatLongP(trk.position)
+
+		if ep.haveFDB(ctx, ac) {
+			// Diamond target symbol and a velocity vector.
+			const sz = 4
+			ld.AddLineLoop(ERAMFDBColor, [][2]float32{{p[0], p[1] + sz}, {p[0] + sz, p[1]},
+				{p[0], p[1] - sz}, {p[0] - sz, p[1]}})
+
+			hdg := trk.heading - ctx.ControlClient.MagneticVariation
+			nm := trk.gs * ERAMVelocityVectorMinutes / 60
+			v := math.Scale2f([2]float32{math.Sin(math.Radians(hdg)), math.Cos(math.Radians(hdg))}, nm)
+			v = math.NM2LL(v, ctx.ControlClient.NmPerLongitude)
+			p1 := transforms.WindowFromLatLongP(math.Add2LL(trk.position, v))
+			ld.AddLine(p, p1, ERAMFDBColor)
+		} else if ac.TrackingController != "" {
+			// Slash for other controllers' tracks.
+			ld.AddLine(math.Add2f(p, [2]float32{-4, -4}), math.Add2f(p, [2]float32{4, 4}), ERAMLDBColor)
+		} else {
+			// Untracked: beacon target.
+			ld.AddLine(math.Add2f(p, [2]float32{-4, 0}), math.Add2f(p, [2]float32{4, 0}), ERAMLDBColor)
+			ld.AddLine(math.Add2f(p, [2]float32{0, -4}), math.Add2f(p, [2]float32{0, 4}), ERAMLDBColor)
+		}
+	}
+
+	transforms.LoadWindowViewingMatrices(cb)
+	cb.LineWidth(1, ctx.DPIScale)
+	ld.GenerateCommands(cb)
+}
+
+func (ep *ERAMPane) drawDatablocks(aircraft []*av.Aircraft, ctx *panes.Context, transforms stars.ScopeTransformations,
+	cb *renderer.CommandBuffer) {
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+	ld := renderer.GetColoredLinesDrawBuilder()
+	defer renderer.ReturnColoredLinesDrawBuilder(ld)
+
+	// Datablocks are drawn up and to the right of the target with a
+	// short leader line.
+	leader := [2]float32{16, 16}
+	for _, ac := range aircraft {
+		trk := ep.tracks[ac.Callsign]
+		p := transforms.WindowFromLatLongP(trk.position)
+
+		var lines []string
+		color := ERAMLDBColor
+		if ep.haveFDB(ctx, ac) {
+			lines = ep.fullDatablock(ctx, ac, trk)
+			color = ERAMFDBColor
+		} else {
+			lines = ep.limitedDatablock(ac, trk)
+		}
+
+		p1 := math.Add2f(p, leader)
+		ld.AddLine(math.Add2f(p, [2]float32{4, 4}), p1, color)
+
+		// Center the block vertically on the end of the leader line.
+		h := float32(len(lines)*ep.font.Size) / 2
+		td.AddText(strings.Join(lines, "\n"), math.Add2f(p1, [2]float32{2, h}),
+			renderer.TextStyle{Font: ep.font, Color: color})
+	}
+
+	transforms.LoadWindowViewingMatrices(cb)
+	cb.LineWidth(1, ctx.DPIScale)
+	ld.GenerateCommands(cb)
+	td.GenerateCommands(cb)
+}
+
+// fullDatablock returns the four lines of an ERAM full datablock: the
+// callsign, the altitude block, the CID with the groundspeed or handoff
+// information, and the destination and aircraft type.
+func (ep *ERAMPane) fullDatablock(ctx *panes.Context, ac *av.Aircraft, trk *track) []string {
+	line1 := ac.Callsign
+	if po, ok := ep.pointOuts[ac.Callsign]; ok {
+		line1 += " P" + sectorId(ctx, po)
+	}
+
+	line3 := cid(ac) + " " + fmt.Sprintf("%03d", int(trk.gs+0.5)/10*10)
+	if ac.HandoffTrackController != "" {
+		// Alternate the groundspeed with the handoff sector.
+		h := util.Select(ac.HandoffTrackController == ctx.ControlClient.Callsign,
+			sectorId(ctx, ac.TrackingController), sectorId(ctx, ac.HandoffTrackController))
+		if ctx.Now.Second()&1 == 0 {
+			line3 = cid(ac) + " H" + h
+		}
+	}
+
+	var line4 string
+	if fp := ac.FlightPlan; fp != nil {
+		line4 = fp.ArrivalAirport + " " + fp.BaseType()
+	}
+
+	return []string{line1, altitudeBlock(ac, trk), line3, line4}
+}
+
+// limitedDatablock returns the lines for a limited datablock: the callsign
+// (or beacon code if there's no flight plan) and reported altitude.
+func (ep *ERAMPane) limitedDatablock(ac *av.Aircraft, trk *track) []string {
+	id := ac.Callsign
+	if ac.FlightPlan == nil {
+		id = trk.squawk.String()
+	}
+	return []string{id, reportedAltitude(trk)}
+}
+
+func reportedAltitude(trk *track) string {
+	if !trk.modeC {
+		return "XXX"
+	}
+	return fmt.Sprintf("%03d", (int(trk.altitude)+50)/100)
+}
+
+// altitudeBlock returns the second line of a full datablock. An interim
+// altitude is shown with a "T" followed by the reported altitude.
+// Otherwise the assigned altitude is shown with a "C" if the aircraft is
+// at it or with a climb/descent indicator and the reported altitude if
+// it isn't. (The fonts only have Latin glyphs, so carets stand in for
+// ERAM's arrows.)
+func altitudeBlock(ac *av.Aircraft, trk *track) string {
+	reported := reportedAltitude(trk)
+	if ac.TempAltitude != 0 {
+		return fmt.Sprintf("%03dT%s", (ac.TempAltitude+50)/100, reported)
+	}
+
+	assigned := 0
+	if ac.FlightPlan != nil {
+		assigned = ac.FlightPlan.Altitude
+	}
+	if assigned == 0 {
+		return reported
+	} else if !trk.modeC {
+		return fmt.Sprintf("%03d%s", assigned/100, reported)
+	} else if math.Abs(int(trk.altitude)-assigned) <= ERAMConformingAltitude {
+		return fmt.Sprintf("%03dC", assigned/100)
+	} else {
+		return fmt.Sprintf("%03d%s%s", assigned/100, util.Select(int(trk.altitude) < assigned, "^", "v"), reported)
+	}
+}
+
+// cid returns the computer identification number for the aircraft's
+// flight plan, if it has one.
+func cid(ac *av.Aircraft) string {
+	if fp := ac.FlightPlan; fp != nil && fp.ECID != "XXX" {
+		return fp.ECID
+	}
+	return ""
+}
+
+// sectorId returns the identifier used for the controller in datablocks
+// and commands.
+func sectorId(ctx *panes.Context, callsign string) string {
+	ctrl, ok := ctx.ControlClient.Controllers[callsign]
+	if !ok {
+		return "??"
+	} else if ctrl.ERAMFacility {
+		return ctrl.SectorId
+	}
+	return ctrl.FacilityIdentifier + ctrl.SectorId
+}
+
+func (ep *ERAMPane) drawInput(ctx *panes.Context, cb *renderer.CommandBuffer) {
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+
+	lineHeight := float32(ep.font.Size + 2)
+	p := [2]float32{4, 2*lineHeight + 2}
+	input := ep.input
+	if ctx.HaveFocus {
+		input += "_"
+	}
+	td.AddText(input, p, renderer.TextStyle{Font: ep.font, Color: ERAMInputColor})
+	p[1] -= lineHeight
+	td.AddText(ep.response, p, renderer.TextStyle{Font: ep.font,
+		Color: util.Select(ep.responseError, ERAMErrorColor, ERAMInputColor)})
+
+	ctx.SetWindowCoordinateMatrices(cb)
+	td.GenerateCommands(cb)
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Commands
+
+func (ep *ERAMPane) processKeyboardInput(ctx *panes.Context) {
+	if !ctx.HaveFocus || ctx.Keyboard == nil {
+		return
+	}
+
+	ep.input += strings.ToUpper(ctx.Keyboard.Input)
+
+	if ctx.Keyboard.WasPressed(platform.KeyBackspace) && len(ep.input) > 0 {
+		ep.input = ep.input[:len(ep.input)-1]
+	}
+	if ctx.Keyboard.WasPressed(platform.KeyEscape) {
+		ep.input = ""
+	}
+	if ctx.Keyboard.WasPressed(platform.KeyEnter) {
+		ep.executeCommand(ctx, ep.input, nil)
+	}
+}
+
+func (ep *ERAMPane) consumeMouseEvents(ctx *panes.Context, transforms stars.ScopeTransformations) {
+	mouse := ctx.Mouse
+	if mouse == nil {
+		return
+	}
+
+	if (mouse.Clicked[platform.MouseButtonPrimary] || mouse.Clicked[platform.MouseButtonSecondary]) && !ctx.HaveFocus {
+		ctx.KeyboardFocus.Take(ep)
+	}
+
+	// Pan and zoom
+	if mouse.Dragging[platform.MouseButtonSecondary] {
+		if delta := mouse.DragDelta; delta[0] != 0 || delta[1] != 0 {
+			ep.Center = math.Sub2f(ep.Center, transforms.LatLongFromWindowV(delta))
+		}
+	}
+	if mouse.Wheel[1] != 0 {
+		r := ep.Range
+		ep.Range = math.Clamp(ep.Range+5*mouse.Wheel[1], 10, 1000)
+
+		// Zoom centered at the mouse position.
+		mouseLL := transforms.LatLongFromWindowP(mouse.Pos)
+		scale := ep.Range / r
+		centerTransform := math.Identity3x3().
+			Translate(mouseLL[0], mouseLL[1]).
+			Scale(scale, scale).
+			Translate(-mouseLL[0], -mouseLL[1])
+		ep.Center = centerTransform.TransformPoint(ep.Center)
+	}
+
+	if mouse.Clicked[platform.MouseButtonPrimary] {
+		if ac := ep.closestAircraft(ctx, mouse.Pos, transforms); ac != nil {
+			ep.executeCommand(ctx, ep.input, ac)
+		}
+	}
+}
+
+func (ep *ERAMPane) closestAircraft(ctx *panes.Context, p [2]float32, transforms stars.ScopeTransformations) *av.Aircraft {
+	var closest *av.Aircraft
+	distance := float32(20) // pixels
+	for _, ac := range ep.visibleAircraft(ctx) {
+		if d := math.Distance2f(transforms.WindowFromLatLongP(ep.tracks[ac.Callsign].position), p); d < distance {
+			closest, distance = ac, d
+		}
+	}
+	return closest
+}
+
+// executeCommand runs the given command. If ac is non-nil, the user
+// clicked on it to finish the command; otherwise the last field of the
+// command identifies the aircraft, either by callsign or by CID. The
+// supported commands are:
+//
+//	<flid>                 accept a handoff or pointout, or toggle the FDB
+//	<sector> <flid>        hand off the track
+//	QT <flid>              initiate a track
+//	QX <flid>              drop a track
+//	QP <sector> <flid>     point out
+//	QP <flid>              acknowledge a pointout
+//	QQ <altitude> <flid>   set the interim altitude
+//	QQ <flid>              clear the interim altitude
+//	QU <flid>              toggle route display
+func (ep *ERAMPane) executeCommand(ctx *panes.Context, cmd string, ac *av.Aircraft) {
+	fields := strings.Fields(cmd)
+	if ac == nil {
+		if len(fields) == 0 {
+			return
+		}
+		if ac = ep.lookupAircraft(ctx, fields[len(fields)-1]); ac == nil {
+			ep.setError("NO FLIGHT " + fields[len(fields)-1])
+			return
+		}
+		fields = fields[:len(fields)-1]
+	}
+
+	ep.input = ""
+	if err := ep.runCommand(ctx, fields, ac); err != nil {
+		ep.setError(err.Error())
+	} else {
+		ep.response, ep.responseError = "ACCEPT", false
+	}
+}
+
+func (ep *ERAMPane) runCommand(ctx *panes.Context, fields []string, ac *av.Aircraft) error {
+	callsign := ac.Callsign
+	onErr := func(err error) { ep.setError(strings.ToUpper(err.Error())) }
+
+	if len(fields) == 0 {
+		if ac.HandoffTrackController == ctx.ControlClient.Callsign {
+			ctx.ControlClient.AcceptHandoff(callsign, nil, onErr)
+		} else if _, ok := ep.pointOuts[callsign]; ok {
+			ctx.ControlClient.AcknowledgePointOut(callsign, nil, onErr)
+			delete(ep.pointOuts, callsign)
+		} else if ac.TrackingController == ctx.ControlClient.Callsign && ac.HandoffTrackController != "" {
+			ctx.ControlClient.CancelHandoff(callsign, nil, onErr)
+		} else if _, ok := ep.forcedFDBs[callsign]; ok {
+			delete(ep.forcedFDBs, callsign)
+		} else {
+			ep.forcedFDBs[callsign] = nil
+		}
+		return nil
+	}
+
+	switch fields[0] {
+	case "QT":
+		if len(fields) != 1 {
+			return ErrERAMFormat
+		}
+		ctx.ControlClient.InitiateTrack(callsign, nil, nil, onErr)
+		ep.forcedFDBs[callsign] = nil
+
+	case "QX":
+		if len(fields) != 1 {
+			return ErrERAMFormat
+		}
+		ctx.ControlClient.DropTrack(callsign, nil, onErr)
+
+	case "QP":
+		if len(fields) == 1 {
+			if _, ok := ep.pointOuts[callsign]; !ok {
+				return ErrERAMNoPointOut
+			}
+			ctx.ControlClient.AcknowledgePointOut(callsign, nil, onErr)
+			delete(ep.pointOuts, callsign)
+		} else if len(fields) == 2 {
+			ctrl := ep.lookupController(ctx, fields[1])
+			if ctrl == nil {
+				return ErrERAMIllegalSector
+			}
+			ctx.ControlClient.PointOut(callsign, ctrl.Callsign, nil, onErr)
+		} else {
+			return ErrERAMFormat
+		}
+
+	case "QQ":
+		if len(fields) == 1 {
+			ctx.ControlClient.SetTemporaryAltitude(callsign, 0, nil, onErr)
+		} else if len(fields) == 2 {
+			alt, err := parseAltitude(fields[1])
+			if err != nil {
+				return err
+			}
+			ctx.ControlClient.SetTemporaryAltitude(callsign, alt, nil, onErr)
+		} else {
+			return ErrERAMFormat
+		}
+
+	case "QU":
+		if len(fields) != 1 {
+			return ErrERAMFormat
+		}
+		if _, ok := ep.routeDisplays[callsign]; ok {
+			delete(ep.routeDisplays, callsign)
+		} else {
+			ep.routeDisplays[callsign] = nil
+		}
+
+	default:
+		if len(fields) != 1 {
+			return ErrERAMFormat
+		}
+		ctrl := ep.lookupController(ctx, fields[0])
+		if ctrl == nil {
+			return ErrERAMIllegalSector
+		} else if ac.TrackingController != ctx.ControlClient.Callsign {
+			return ErrERAMIllegalTrack
+		}
+		ctx.ControlClient.HandoffTrack(callsign, ctrl.Callsign, nil, onErr)
+	}
+	return nil
+}
+
+func (ep *ERAMPane) setError(msg string) {
+	ep.response, ep.responseError = "REJECT - "+msg, true
+}
+
+// lookupAircraft finds the aircraft with the given callsign or CID.
+func (ep *ERAMPane) lookupAircraft(ctx *panes.Context, flid string) *av.Aircraft {
+	if ac, ok := ctx.ControlClient.Aircraft[flid]; ok {
+		return ac
+	}
+	var match *av.Aircraft
+	for _, ac := range ctx.ControlClient.Aircraft {
+		if c := cid(ac); c != "" && c == flid {
+			if match != nil {
+				// Ambiguous; don't guess.
+				return nil
+			}
+			match = ac
+		}
+	}
+	if match != nil {
+		return match
+	}
+	return ctx.ControlClient.AircraftFromPartialCallsign(flid)
+}
+
+// lookupController finds the controller with the given sector id. Center
+// sectors are specified by their id; terminal sectors may also be
+// prefixed with their facility identifier.
+func (ep *ERAMPane) lookupController(ctx *panes.Context, id string) *av.Controller {
+	ctrls := util.SortedMapKeys(ctx.ControlClient.Controllers)
+	if idx := slices.IndexFunc(ctrls, func(callsign string) bool {
+		ctrl := ctx.ControlClient.Controllers[callsign]
+		return callsign != ctx.ControlClient.Callsign &&
+			(ctrl.SectorId == id || ctrl.FacilityIdentifier+ctrl.SectorId == id)
+	}); idx != -1 {
+		return ctx.ControlClient.Controllers[ctrls[idx]]
+	}
+	return nil
+}
+
+func parseAltitude(s string) (int, error) {
+	var alt int
+	if _, err := fmt.Sscanf(s, "%d", &alt); err != nil || alt <= 0 || alt > 999 {
+		return 0, ErrERAMIllegalAltitude
+	}
+	return alt * 100, nil
+}
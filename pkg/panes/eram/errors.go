@@ -0,0 +1,23 @@
+// pkg/panes/eram/errors.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package eram
+
+import "errors"
+
+type ERAMError struct {
+	error
+}
+
+func NewERAMError(msg string) *ERAMError {
+	return &ERAMError{errors.New(msg)}
+}
+
+var (
+	ErrERAMFormat          = NewERAMError("FORMAT")
+	ErrERAMIllegalAltitude = NewERAMError("ILLEGAL ALTITUDE")
+	ErrERAMIllegalSector   = NewERAMError("ILLEGAL SECTOR")
+	ErrERAMIllegalTrack    = NewERAMError("NOT YOUR CONTROL")
+	ErrERAMNoPointOut      = NewERAMError("NO POINT OUT")
+)
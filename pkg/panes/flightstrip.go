@@ -6,6 +6,10 @@ package panes
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -20,22 +24,56 @@ import (
 	"github.com/mmp/imgui-go/v4"
 )
 
+type FlightStripSort int
+
+const (
+	// Strips are in the order the controller arranged them.
+	FlightStripSortManual FlightStripSort = iota
+	FlightStripSortCallsign
+	FlightStripSortDeparturesFirst
+	FlightStripSortDestination
+	NumFlightStripSorts
+)
+
+func (s FlightStripSort) String() string {
+	return []string{"Manual", "Callsign", "Departures first", "Destination"}[s]
+}
+
+type FlightStripBayConfig struct {
+	Hide bool
+	Sort FlightStripSort
+}
+
+// FlightStripPane displays the controller's flight strip bays. The strips
+// themselves are kept in the Sim so that the strips pushed between
+// controllers are consistent; the pane just decides which strips to add
+// and how to display them.
 type FlightStripPane struct {
 	FontSize int
 	font     *renderer.Font
 
-	HideFlightStrips          bool
-	AutoAddDepartures         bool
-	AutoAddArrivals           bool
-	AutoAddOverflights        bool
-	AutoAddTracked            bool
-	AutoAddAcceptedHandoffs   bool
-	AutoRemoveDropped         bool
-	AutoRemoveHandoffs        bool
-	AddPushed                 bool
-	CollectDeparturesArrivals bool
-
-	strips        []string // callsigns
+	HideFlightStrips        bool
+	AutoAddDepartures       bool
+	AutoAddArrivals         bool
+	AutoAddOverflights      bool
+	AutoAddTracked          bool
+	AutoAddAcceptedHandoffs bool
+	AutoRemoveDropped       bool
+	AutoMoveHandoffs        bool
+	AutoAcknowledgePushed   bool
+
+	// Settings from before config version 31; Upgrade carries them over
+	// to the ones that replaced them.
+	AutoRemoveHandoffs        bool `json:",omitempty"`
+	AddPushed                 bool `json:",omitempty"`
+	CollectDeparturesArrivals bool `json:",omitempty"`
+
+	Bays [sim.NumStripBays]FlightStripBayConfig
+
+	PrintPNG     bool
+	printRequest int
+	printResult  string
+
 	addedAircraft map[string]interface{}
 
 	mouseDragging       bool
@@ -43,6 +81,7 @@ type FlightStripPane struct {
 	selectedStrip       int
 	selectedAnnotation  int
 	annotationCursorPos int
+	pushCallsign        string
 
 	events    *sim.EventsSubscription
 	scrollbar *ScrollBar
@@ -50,6 +89,12 @@ type FlightStripPane struct {
 	selectedAircraft string
 }
 
+const (
+	printNone = iota
+	printUpcomingDepartures
+	printStripBays
+)
+
 func init() {
 	RegisterUnmarshalPane("FlightStripPane", func(d []byte) (Pane, error) {
 		var p FlightStripPane
@@ -60,12 +105,11 @@ func init() {
 
 func NewFlightStripPane() *FlightStripPane {
 	return &FlightStripPane{
-		AddPushed:               true,
 		AutoAddDepartures:       true,
 		AutoAddTracked:          true,
 		AutoAddAcceptedHandoffs: true,
 		AutoRemoveDropped:       true,
-		AutoRemoveHandoffs:      true,
+		AutoMoveHandoffs:        true,
 
 		FontSize:           12,
 		selectedStrip:      -1,
@@ -73,6 +117,21 @@ func NewFlightStripPane() *FlightStripPane {
 	}
 }
 
+func (fsp *FlightStripPane) Upgrade(from, to int) {
+	if from < 31 {
+		// Handed-off strips now move to their own bay rather than being
+		// removed and pushed strips need to be acknowledged.
+		fsp.AutoMoveHandoffs = fsp.AutoRemoveHandoffs
+		fsp.AutoAcknowledgePushed = fsp.AddPushed
+		if fsp.CollectDeparturesArrivals {
+			for i := range fsp.Bays {
+				fsp.Bays[i].Sort = FlightStripSortDeparturesFirst
+			}
+		}
+		fsp.AutoRemoveHandoffs, fsp.AddPushed, fsp.CollectDeparturesArrivals = false, false, false
+	}
+}
+
 func (fsp *FlightStripPane) Activate(ss *sim.State, r renderer.Renderer, p platform.Platform,
 	eventStream *sim.EventStream, lg *log.Logger) {
 	if fsp.FontSize == 0 {
@@ -88,108 +147,176 @@ func (fsp *FlightStripPane) Activate(ss *sim.State, r renderer.Renderer, p platf
 		fsp.scrollbar = NewVerticalScrollBar(4, true)
 	}
 	fsp.events = eventStream.Subscribe()
-
-	if ss != nil {
-		for _, ac := range ss.Aircraft {
-			fsp.possiblyAddAircraft(ss, ac)
-		}
-	}
 }
 
-func (fsp *FlightStripPane) possiblyAddAircraft(ss *sim.State, ac *av.Aircraft) {
+// possiblyAddAircraft returns the bay that a strip for the aircraft
+// should be added to if the pane's settings call for one. Each aircraft is
+// only added once so that strips the controller has removed don't come
+// back.
+func (fsp *FlightStripPane) possiblyAddAircraft(ctx *Context, ac *av.Aircraft) (sim.StripBay, bool) {
 	if _, ok := fsp.addedAircraft[ac.Callsign]; ok {
-		return
+		return 0, false
 	}
 	if ac.FlightPlan == nil {
-		return
+		return 0, false
+	}
+	ss := &ctx.ControlClient.State
+	if _, ok := ss.FlightStrip(ss.Callsign, ac.Callsign); ok {
+		// Pushed to us or added in an earlier session.
+		fsp.addedAircraft[ac.Callsign] = nil
+		return 0, false
 	}
 
-	add := fsp.AutoAddTracked && ac.TrackingController == ss.Callsign && ac.FlightPlan != nil
+	bay := sim.StripBayPending
+	add := fsp.AutoAddTracked && ac.TrackingController == ss.Callsign
+	if add {
+		bay = sim.StripBayActive
+	}
 	add = add || ac.TrackingController == "" && fsp.AutoAddDepartures && ss.IsDeparture(ac)
 	add = add || ac.TrackingController == "" && fsp.AutoAddArrivals && ss.IsArrival(ac)
 	add = add || ac.TrackingController == "" && fsp.AutoAddOverflights && ss.IsOverflight(ac)
 
 	if add {
-		fsp.strips = append(fsp.strips, ac.Callsign)
 		fsp.addedAircraft[ac.Callsign] = nil
 	}
+	return bay, add
+}
+
+// activate moves the controller's strip to the active bay, adding it if
+// there isn't one.
+func (fsp *FlightStripPane) activate(ctx *Context, ac *av.Aircraft) {
+	ss := &ctx.ControlClient.State
+	if fs, ok := ss.FlightStrip(ss.Callsign, ac.Callsign); ok {
+		if fs.Bay != sim.StripBayActive && fs.PushedBy == "" {
+			ctx.ControlClient.MoveFlightStrip(ac.Callsign, sim.StripBayActive, -1, fsp.reportError(ctx.Lg))
+		}
+	} else if bay, ok := fsp.possiblyAddAircraft(ctx, ac); ok {
+		ctx.ControlClient.AddFlightStrips([]string{ac.Callsign}, bay, fsp.reportError(ctx.Lg))
+	}
+}
+
+func (fsp *FlightStripPane) reportError(lg *log.Logger) func(error) {
+	return func(err error) { lg.Warnf("flight strip: %v", err) }
 }
 
 func (fsp *FlightStripPane) Reset(ss sim.State, lg *log.Logger) {
-	fsp.strips = nil
 	fsp.addedAircraft = make(map[string]interface{})
+	fsp.selectedAircraft = ""
 }
 
 func (fsp *FlightStripPane) CanTakeKeyboardFocus() bool { return false /*true*/ }
 
 func (fsp *FlightStripPane) processEvents(ctx *Context) {
-	// First account for changes in world.Aircraft
-	// Added aircraft
+	ss := &ctx.ControlClient.State
+	// Add new aircraft with one request per bay rather than one per
+	// aircraft; there may be many of them when the controller signs on.
+	var add [sim.NumStripBays][]string
 	for _, ac := range ctx.ControlClient.Aircraft {
-		fsp.possiblyAddAircraft(&ctx.ControlClient.State, ac)
+		if bay, ok := fsp.possiblyAddAircraft(ctx, ac); ok {
+			add[bay] = append(add[bay], ac.Callsign)
+		}
+	}
+	for bay, callsigns := range add {
+		if len(callsigns) > 0 {
+			slices.Sort(callsigns)
+			ctx.ControlClient.AddFlightStrips(callsigns, sim.StripBay(bay), fsp.reportError(ctx.Lg))
+		}
 	}
 
-	// Removed aircraft
-	fsp.strips = util.FilterSlice(fsp.strips, func(callsign string) bool {
-		_, ok := ctx.ControlClient.Aircraft[callsign]
+	haveStrip := func(callsign string) bool {
+		_, ok := ss.FlightStrip(ss.Callsign, callsign)
 		return ok
-	})
-
-	remove := func(c string) {
-		fsp.strips = util.FilterSlice(fsp.strips, func(callsign string) bool { return callsign != c })
-		if fsp.selectedAircraft == c {
-			fsp.selectedAircraft = ""
-		}
 	}
 
 	for _, event := range fsp.events.Get() {
 		switch event.Type {
 		case sim.PushedFlightStripEvent:
-			if ac, ok := ctx.ControlClient.Aircraft[event.Callsign]; ok && fsp.AddPushed {
-				fsp.possiblyAddAircraft(&ctx.ControlClient.State, ac)
+			if event.ToController == ss.Callsign && fsp.AutoAcknowledgePushed {
+				ctx.ControlClient.AcknowledgeFlightStrip(event.Callsign, fsp.reportError(ctx.Lg))
 			}
 		case sim.InitiatedTrackEvent:
 			if ac, ok := ctx.ControlClient.Aircraft[event.Callsign]; ok {
-				if fsp.AutoAddTracked && ac.TrackingController == ctx.ControlClient.Callsign {
-					fsp.possiblyAddAircraft(&ctx.ControlClient.State, ac)
+				if fsp.AutoAddTracked && ac.TrackingController == ss.Callsign {
+					fsp.activate(ctx, ac)
 				}
 			}
 		case sim.DroppedTrackEvent:
-			if fsp.AutoRemoveDropped {
-				remove(event.Callsign)
+			if fsp.AutoRemoveDropped && haveStrip(event.Callsign) {
+				ctx.ControlClient.RemoveFlightStrip(event.Callsign, fsp.reportError(ctx.Lg))
 			}
 		case sim.AcceptedHandoffEvent, sim.AcceptedRedirectedHandoffEvent:
 			if ac, ok := ctx.ControlClient.Aircraft[event.Callsign]; ok {
-				if fsp.AutoAddAcceptedHandoffs && ac.TrackingController == ctx.ControlClient.Callsign {
-					fsp.possiblyAddAircraft(&ctx.ControlClient.State, ac)
+				if fsp.AutoAddAcceptedHandoffs && ac.TrackingController == ss.Callsign {
+					fsp.activate(ctx, ac)
 				}
 			}
 		case sim.HandoffControllEvent:
 			if ac, ok := ctx.ControlClient.Aircraft[event.Callsign]; ok {
-				if fsp.AutoRemoveHandoffs && ac.TrackingController != ctx.ControlClient.Callsign {
-					remove(event.Callsign)
+				if fsp.AutoMoveHandoffs && ac.TrackingController != ss.Callsign && haveStrip(event.Callsign) {
+					ctx.ControlClient.MoveFlightStrip(event.Callsign, sim.StripBayHandedOff, -1,
+						fsp.reportError(ctx.Lg))
 				}
 			}
 		}
 	}
 
-	// TODO: is this needed? Shouldn't there be a RemovedAircraftEvent?
-	fsp.strips = util.FilterSlice(fsp.strips, func(callsign string) bool {
-		return ctx.ControlClient.Aircraft[callsign] != nil
-	})
+	if fsp.selectedAircraft != "" && !haveStrip(fsp.selectedAircraft) {
+		fsp.selectedAircraft = ""
+	}
+}
+
+// flightStripRow is a row in the pane: either the header for a bay or one
+// of the strips in it.
+type flightStripRow struct {
+	bay    sim.StripBay
+	strip  *sim.BayStrip // nil for headers
+	ac     *av.Aircraft
+	index  int // index of the strip in the Sim's bay
+	y0, y1 float32
+}
+
+func (fsp *FlightStripPane) rows(ctx *Context) []flightStripRow {
+	ss := &ctx.ControlClient.State
 
-	if fsp.CollectDeparturesArrivals {
-		isDeparture := func(callsign string) bool {
-			ac := ctx.ControlClient.Aircraft[callsign]
-			return ac != nil && ctx.ControlClient.State.IsDeparture(ac)
+	var rows []flightStripRow
+	for bay := sim.StripBay(0); bay < sim.NumStripBays; bay++ {
+		if fsp.Bays[bay].Hide {
+			continue
+		}
+		rows = append(rows, flightStripRow{bay: bay})
+
+		var strips []flightStripRow
+		for i, fs := range ss.StripBay(ss.Callsign, bay) {
+			if ac := ctx.ControlClient.Aircraft[fs.Callsign]; ac != nil {
+				strips = append(strips, flightStripRow{bay: bay, strip: &fs, ac: ac, index: i})
+			}
+		}
+
+		switch fsp.Bays[bay].Sort {
+		case FlightStripSortCallsign:
+			slices.SortStableFunc(strips, func(a, b flightStripRow) int {
+				return strings.Compare(a.ac.Callsign, b.ac.Callsign)
+			})
+		case FlightStripSortDeparturesFirst:
+			slices.SortStableFunc(strips, func(a, b flightStripRow) int {
+				da, db := ss.IsDeparture(a.ac), ss.IsDeparture(b.ac)
+				return util.Select(da == db, 0, util.Select(da, -1, 1))
+			})
+		case FlightStripSortDestination:
+			arrival := func(ac *av.Aircraft) string {
+				if ac.FlightPlan == nil {
+					return ""
+				}
+				return ac.FlightPlan.ArrivalAirport
+			}
+			slices.SortStableFunc(strips, func(a, b flightStripRow) int {
+				return strings.Compare(arrival(a.ac), arrival(b.ac))
+			})
 		}
-		dep := util.FilterSlice(fsp.strips, isDeparture)
-		arr := util.FilterSlice(fsp.strips, func(callsign string) bool { return !isDeparture(callsign) })
 
-		fsp.strips = fsp.strips[:0]
-		fsp.strips = append(fsp.strips, dep...)
-		fsp.strips = append(fsp.strips, arr...)
+		rows = append(rows, strips...)
 	}
+	return rows
 }
 
 func (fsp *FlightStripPane) DisplayName() string { return "Flight Strips" }
@@ -205,13 +332,43 @@ func (fsp *FlightStripPane) DrawUI(p platform.Platform, config *platform.Config)
 	imgui.Checkbox("Automatically add departures", &fsp.AutoAddDepartures)
 	imgui.Checkbox("Automatically add arrivals", &fsp.AutoAddArrivals)
 	imgui.Checkbox("Automatically add overflights", &fsp.AutoAddOverflights)
-	imgui.Checkbox("Add pushed flight strips", &fsp.AddPushed)
+	imgui.Checkbox("Automatically acknowledge pushed flight strips", &fsp.AutoAcknowledgePushed)
 	imgui.Checkbox("Automatically add when track is initiated", &fsp.AutoAddTracked)
 	imgui.Checkbox("Automatically add handoffs", &fsp.AutoAddAcceptedHandoffs)
 	imgui.Checkbox("Automatically remove dropped tracks", &fsp.AutoRemoveDropped)
-	imgui.Checkbox("Automatically remove accepted handoffs", &fsp.AutoRemoveHandoffs)
+	imgui.Checkbox("Automatically move accepted handoffs to the handed off bay", &fsp.AutoMoveHandoffs)
+
+	for bay := sim.StripBay(0); bay < sim.NumStripBays; bay++ {
+		cfg := &fsp.Bays[bay]
+		show := !cfg.Hide
+		imgui.Checkbox("Show "+bay.String()+" bay", &show)
+		cfg.Hide = !show
+
+		imgui.SameLine()
+		imgui.PushItemWidth(150)
+		if imgui.BeginComboV("Sort##"+bay.String(), cfg.Sort.String(), imgui.ComboFlagsHeightLarge) {
+			for s := FlightStripSort(0); s < NumFlightStripSorts; s++ {
+				if imgui.SelectableV(s.String(), s == cfg.Sort, 0, imgui.Vec2{}) {
+					cfg.Sort = s
+				}
+			}
+			imgui.EndCombo()
+		}
+		imgui.PopItemWidth()
+	}
 
-	imgui.Checkbox("Collect departures and arrivals together", &fsp.CollectDeparturesArrivals)
+	imgui.Separator()
+	imgui.Checkbox("Print strips as PNG images instead of PDF", &fsp.PrintPNG)
+	if imgui.Button("Print upcoming departures") {
+		fsp.printRequest = printUpcomingDepartures
+	}
+	imgui.SameLine()
+	if imgui.Button("Print strips in bays") {
+		fsp.printRequest = printStripBays
+	}
+	if fsp.printResult != "" {
+		imgui.Text(fsp.printResult)
+	}
 
 	id := renderer.FontIdentifier{Name: fsp.font.Id.Name, Size: fsp.FontSize}
 	if newFont, changed := renderer.DrawFontSizeSelector(&id); changed {
@@ -221,9 +378,77 @@ func (fsp *FlightStripPane) DrawUI(p platform.Platform, config *platform.Config)
 	uiEndDisable(fsp.HideFlightStrips)
 }
 
+// printStrips prints the departures that are taxiing out or the strips
+// in the bays, depending on what was requested, to files in the user's
+// home directory and returns a message describing the result.
+func (fsp *FlightStripPane) printStrips(ctx *Context) string {
+	ss := &ctx.ControlClient.State
+
+	var strips []PrintableStrip
+	if fsp.printRequest == printUpcomingDepartures {
+		deps := util.FilterSlice(ss.SurfaceTargets, func(t sim.SurfaceTarget) bool {
+			return t.Departure && t.FlightPlan != nil &&
+				(t.State == sim.SurfaceTaxiingOut || t.State == sim.SurfaceHoldingShort)
+		})
+		// Order by when they'll be ready to go.
+		slices.SortFunc(deps, func(a, b sim.SurfaceTarget) int { return a.End.Compare(b.End) })
+		for _, t := range deps {
+			strips = append(strips, PrintableStrip{FlightPlan: *t.FlightPlan, ProposedTime: t.End})
+		}
+	} else {
+		for _, row := range fsp.rows(ctx) {
+			if row.strip != nil && row.ac.FlightPlan != nil {
+				strips = append(strips, PrintableStrip{Strip: row.ac.Strip, FlightPlan: *row.ac.FlightPlan})
+			}
+		}
+	}
+	if len(strips) == 0 {
+		return "No flight strips to print."
+	}
+
+	font := renderer.GetFont(renderer.FontIdentifier{Name: "Flight Strip Printer", Size: 20})
+	if font == nil {
+		font = renderer.GetDefaultFont()
+	}
+	sheets := RasterizeFlightStrips(strips, font)
+
+	prefix := "vice-strips-" + ss.SimTime.Format("150405")
+	if d, err := os.UserHomeDir(); err == nil {
+		prefix = filepath.Join(d, prefix)
+	}
+
+	if fsp.PrintPNG {
+		files, err := WriteFlightStripsPNG(sheets, prefix)
+		if err != nil {
+			ctx.Lg.Errorf("%s: %v", prefix, err)
+			return err.Error()
+		}
+		return fmt.Sprintf("Printed %d strips to %s", len(strips), strings.Join(files, ", "))
+	}
+
+	fn := prefix + ".pdf"
+	f, err := os.Create(fn)
+	if err == nil {
+		err = WriteFlightStripsPDF(f, sheets)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		ctx.Lg.Errorf("%s: %v", fn, err)
+		return err.Error()
+	}
+	return fmt.Sprintf("Printed %d strips to %s", len(strips), fn)
+}
+
 func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 	fsp.processEvents(ctx)
 
+	if fsp.printRequest != printNone {
+		fsp.printResult = fsp.printStrips(ctx)
+		fsp.printRequest = printNone
+	}
+
 	// Font width and height
 	// the 'Flight Strip Printer' font seems to have an unusually thin space,
 	// so instead use 'X' to get the expected per-character width for layout.
@@ -235,9 +460,11 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 	// 4 lines of text, 2 lines on top and below for padding, 1 pixel separator line
 	vpad := float32(2)
 	stripHeight := 1 + 2*vpad + 4*fh
+	headerHeight := 1 + 2*vpad + fh
 
-	visibleStrips := int(ctx.PaneExtent.Height() / stripHeight)
-	fsp.scrollbar.Update(len(fsp.strips), visibleStrips, ctx)
+	rows := fsp.rows(ctx)
+	visibleRows := int(ctx.PaneExtent.Height() / stripHeight)
+	fsp.scrollbar.Update(len(rows), visibleRows, ctx)
 
 	indent := float32(int32(fw / 2))
 	// column widths
@@ -266,8 +493,8 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 	// this sort of case would be handled more naturally... (And note that
 	// tracking the callsign won't work if we want to have strips for the
 	// same aircraft twice in a pane, for what that's worth...)
-	if fsp.selectedStrip >= len(fsp.strips) {
-		fsp.selectedStrip = len(fsp.strips) - 1
+	if fsp.selectedStrip >= len(rows) {
+		fsp.selectedStrip = len(rows) - 1
 	}
 
 	td := renderer.GetTextDrawBuilder()
@@ -277,17 +504,55 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 	trid := renderer.GetTrianglesDrawBuilder()
 	defer renderer.ReturnTrianglesDrawBuilder(trid)
 
-	// Draw from the bottom
-	scrollOffset := fsp.scrollbar.Offset()
-	y := stripHeight - 1 - vpad
-	for i := scrollOffset; i < math.Min(len(fsp.strips), visibleStrips+scrollOffset+1); i++ {
-		callsign := fsp.strips[i]
-		strip := ctx.ControlClient.Aircraft[callsign].Strip
-		ac := ctx.ControlClient.Aircraft[callsign]
-		if ac == nil {
-			ctx.Lg.Errorf("%s: no aircraft for callsign?!", strip.Callsign)
+	// The scroll offset is from the bottom: find the rows that fit above
+	// the last visible one.
+	rowHeight := func(r flightStripRow) float32 { return util.Select(r.strip == nil, headerHeight, stripHeight) }
+	last := len(rows) - 1 - fsp.scrollbar.Offset()
+	first, height := last+1, float32(0)
+	for first > 0 && height+rowHeight(rows[first-1]) <= ctx.PaneExtent.Height() {
+		first--
+		height += rowHeight(rows[first])
+	}
+
+	// Draw bay by bay; the rows start at the top unless the pane is
+	// scrolled, in which case the last visible row is at the bottom.
+	rowTop := ctx.PaneExtent.Height()
+	if fsp.scrollbar.Visible() {
+		rowTop = height
+	}
+	for i := first; i <= last; i++ {
+		row := &rows[i]
+		y := rowTop - 1 - vpad
+
+		if row.strip == nil {
+			// Bay header
+			n, npushed := 0, 0
+			for _, r := range rows {
+				if r.strip != nil && r.bay == row.bay {
+					n++
+					if r.strip.PushedBy != "" {
+						npushed++
+					}
+				}
+			}
+			label := fmt.Sprintf("%s (%d)", row.bay, n)
+			if npushed > 0 {
+				label += fmt.Sprintf(", %d to acknowledge", npushed)
+			}
+			td.AddText(label, [2]float32{indent, y}, renderer.TextStyle{Font: fsp.font, Color: UITextColor})
+
+			row.y0, row.y1 = rowTop-headerHeight, rowTop
+			ld.AddLine([2]float32{0, row.y0}, [2]float32{drawWidth, row.y0})
+			rowTop -= headerHeight
 			continue
 		}
+
+		row.y0, row.y1 = rowTop-stripHeight, rowTop
+		rowTop -= stripHeight
+
+		callsign := row.strip.Callsign
+		ac := row.ac
+		strip := ac.Strip
 		fp := ac.FlightPlan
 
 		style := renderer.TextStyle{Font: fsp.font, Color: renderer.RGB{.1, .1, .1}}
@@ -296,11 +561,10 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 		qb := renderer.GetColoredTrianglesDrawBuilder()
 		defer renderer.ReturnColoredTrianglesDrawBuilder(qb)
 		bgColor := func() renderer.RGB {
-			/*if fsp.isDeparture(ac) {
-				return ctx.cs.DepartureStrip
-			} else {
-				return ctx.cs.ArrivalStrip
-			}*/
+			if row.strip.PushedBy != "" {
+				// Waiting to be acknowledged
+				return renderer.RGB{.95, .85, .6}
+			}
 			return renderer.RGB{.9, .9, .85}
 		}()
 		y0, y1 := y+1+vpad-stripHeight, y+1+vpad
@@ -319,7 +583,9 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 
 		// Second column; 3 entries
 		x += width0
-		td.AddText(ac.FlightPlan.AssignedSquawk.String(), [2]float32{x, y}, style)
+		if fp != nil {
+			td.AddText(fp.AssignedSquawk.String(), [2]float32{x, y}, style)
+		}
 		td.AddText(strconv.Itoa(ac.TempAltitude), [2]float32{x, y - fh*3/2}, style)
 		if fp != nil {
 			td.AddText(strconv.Itoa(fp.Altitude), [2]float32{x, y - fh*3}, style)
@@ -353,6 +619,10 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 			// Similarly for the remarks
 			remarks, _ := util.WrapText(fp.Remarks, cols, 2 /* indent */, true)
 			text = append(text, strings.Split(remarks, "\n")...)
+			if row.strip.PushedBy != "" {
+				// Show who it's from in place of the last line.
+				text = append(text[:math.Min(len(text), 3)], "FROM "+row.strip.PushedBy)
+			}
 			// Limit to the first four lines so we don't spill over.
 			if len(text) > 4 {
 				text = text[:4]
@@ -420,43 +690,74 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 			ld.AddLine([2]float32{xp, y}, [2]float32{xp, y - stripHeight})
 		}
 
-		// Line at the top
-		yl := y + 1 + vpad
-		ld.AddLine([2]float32{0, yl}, [2]float32{drawWidth, yl})
+		// Line at the bottom
+		ld.AddLine([2]float32{0, y0}, [2]float32{drawWidth, y0})
+	}
 
-		y += stripHeight
+	// rowAt returns the index of the row at the given position, or -1.
+	rowAt := func(p [2]float32) int {
+		for i := first; i <= last; i++ {
+			if r := rows[i]; r.y1 > r.y0 && p[1] >= r.y0 && p[1] < r.y1 {
+				return i
+			}
+		}
+		return -1
+	}
+	// dropTarget returns the bay and index in the bay where a strip
+	// dropped at the given position should go.
+	dropTarget := func(p [2]float32) (sim.StripBay, int, float32, bool) {
+		ri := rowAt(p)
+		if ri == -1 {
+			return 0, 0, 0, false
+		}
+		r := rows[ri]
+		if r.strip == nil {
+			// At the start of the bay.
+			return r.bay, 0, r.y0, true
+		}
+		if p[1] > (r.y0+r.y1)/2 {
+			return r.bay, r.index, r.y1, true
+		}
+		return r.bay, r.index + 1, r.y0, true
 	}
 
-	// Handle selection, deletion, and reordering
-	if ctx.Mouse != nil {
+	ss := &ctx.ControlClient.State
+
+	// Handle selection, deletion, acknowledgement, pushes, and reordering
+	if ctx.Mouse != nil && ctx.Mouse.Pos[0] <= drawWidth {
 		// Ignore clicks if the mouse is over the scrollbar (and it's being drawn)
-		if ctx.Mouse.Clicked[platform.MouseButtonPrimary] && ctx.Mouse.Pos[0] <= drawWidth {
-			// from the bottom
-			stripIndex := int(ctx.Mouse.Pos[1] / stripHeight)
-			stripIndex += scrollOffset
-			if stripIndex < len(fsp.strips) {
+		ri := rowAt(ctx.Mouse.Pos)
+		if ctx.Mouse.Clicked[platform.MouseButtonPrimary] {
+			if ri != -1 && rows[ri].strip != nil {
+				fs := rows[ri].strip
 				io := imgui.CurrentIO()
 				if io.KeyShiftPressed() {
 					// delete the flight strip
-					copy(fsp.strips[stripIndex:], fsp.strips[stripIndex+1:])
-					fsp.strips = fsp.strips[:len(fsp.strips)-1]
+					ctx.ControlClient.RemoveFlightStrip(fs.Callsign, fsp.reportError(ctx.Lg))
 				} else {
+					if fs.PushedBy != "" {
+						ctx.ControlClient.AcknowledgeFlightStrip(fs.Callsign, fsp.reportError(ctx.Lg))
+					}
 					// select the aircraft
-					callsign := fsp.strips[stripIndex]
-					fsp.selectedAircraft = callsign
+					fsp.selectedAircraft = fs.Callsign
 				}
+			} else {
+				fsp.selectedAircraft = ""
 			}
 		}
-		if ctx.Mouse.Dragging[platform.MouseButtonPrimary] {
+		if ctx.Mouse.Clicked[platform.MouseButtonSecondary] && ri != -1 && rows[ri].strip != nil {
+			fsp.pushCallsign = rows[ri].strip.Callsign
+			imgui.OpenPopup("Push flight strip")
+		}
+		if ctx.Mouse.Dragging[platform.MouseButtonPrimary] && fsp.selectedAircraft != "" {
 			fsp.mouseDragging = true
 			fsp.lastMousePos = ctx.Mouse.Pos
 
-			// Offset so that the selection region is centered over the
-			// line between two strips; the index then is to the lower one.
-			splitIndex := int(ctx.Mouse.Pos[1]/stripHeight + 0.5)
-			yl := float32(splitIndex) * stripHeight
-			trid.AddQuad([2]float32{0, yl - 1}, [2]float32{drawWidth, yl - 1},
-				[2]float32{drawWidth, yl + 1}, [2]float32{0, yl + 1})
+			// Highlight the line where the strip will go.
+			if _, _, yl, ok := dropTarget(ctx.Mouse.Pos); ok {
+				trid.AddQuad([2]float32{0, yl - 1}, [2]float32{drawWidth, yl - 1},
+					[2]float32{drawWidth, yl + 1}, [2]float32{0, yl + 1})
+			}
 		}
 	}
 	if fsp.mouseDragging && (ctx.Mouse == nil || !ctx.Mouse.Dragging[platform.MouseButtonPrimary]) {
@@ -464,65 +765,40 @@ func (fsp *FlightStripPane) Draw(ctx *Context, cb *renderer.CommandBuffer) {
 
 		if fsp.selectedAircraft == "" {
 			ctx.Lg.Debug("No selected aircraft for flight strip drag?!")
-		} else {
-			// Figure out the index for the selected aircraft.
-			selectedIndex := func() int {
-				for i, fs := range fsp.strips {
-					if fs == fsp.selectedAircraft {
-						return i
-					}
-				}
-				ctx.Lg.Warnf("Couldn't find %s in flight strips?!", fsp.selectedAircraft)
-				return -1
-			}()
-
-			// The selected aircraft was set from the original mouse down so
-			// now we just need to move it to be in the right place given where
-			// the button was released.
-			destinationIndex := int(fsp.lastMousePos[1]/stripHeight + 0.5)
-			destinationIndex += scrollOffset
-			destinationIndex = math.Clamp(destinationIndex, 0, len(fsp.strips))
-
-			if selectedIndex != -1 && selectedIndex != destinationIndex {
-				// First remove it from the slice
-				fs := fsp.strips[selectedIndex]
-				fsp.strips = append(fsp.strips[:selectedIndex], fsp.strips[selectedIndex+1:]...)
-
-				if selectedIndex < destinationIndex {
-					destinationIndex--
+		} else if bay, index, _, ok := dropTarget(fsp.lastMousePos); ok {
+			fs, _ := ss.FlightStrip(ss.Callsign, fsp.selectedAircraft)
+			move := true
+			if fs != nil && fs.Bay == bay {
+				// The strip is removed from the bay before it's inserted,
+				// so account for that if it's moving down.
+				cur := slices.IndexFunc(ss.StripBay(ss.Callsign, bay),
+					func(fs sim.BayStrip) bool { return fs.Callsign == fsp.selectedAircraft })
+				if cur < index {
+					index--
 				}
-
-				// And stuff it in there
-				fin := fsp.strips[destinationIndex:]
-				fsp.strips = append([]string{}, fsp.strips[:destinationIndex]...)
-				fsp.strips = append(fsp.strips, fs)
-				fsp.strips = append(fsp.strips, fin...)
+				move = cur != index
+			}
+			if move {
+				ctx.ControlClient.MoveFlightStrip(fsp.selectedAircraft, bay, index, fsp.reportError(ctx.Lg))
 			}
 		}
 	}
-	// Take focus if the user clicks in the annotations
-	/*
-		if ctx.Mouse != nil && ctx.Mouse.Clicked[MouseButtonPrimary] {
-			annotationStartX := drawWidth - 3*widthAnn
-			if xp := ctx.Mouse.Pos[0]; xp >= annotationStartX && xp < drawWidth {
-				stripIndex := int(ctx.Mouse.Pos[1]/stripHeight) + scrollOffset
-				if stripIndex < len(fsp.strips) {
-					wmTakeKeyboardFocus(fsp, true)
-					fsp.selectedStrip = stripIndex
-
-					// Figure out which annotation was selected
-					xa := int(ctx.Mouse.Pos[0]-annotationStartX) / int(widthAnn)
-					ya := 2 - (int(ctx.Mouse.Pos[1])%int(stripHeight))/(int(stripHeight)/3)
-					xa, ya = clamp(xa, 0, 2), clamp(ya, 0, 2) // just in case
-					fsp.selectedAnnotation = 3*ya + xa
-
-					callsign := fsp.strips[fsp.selectedStrip]
-					strip := ctx.world.GetFlightStrip(callsign)
-					fsp.annotationCursorPos = len(strip.annotations[fsp.selectedAnnotation])
-				}
+
+	if imgui.BeginPopup("Push flight strip") {
+		imgui.Text("Push " + fsp.pushCallsign + " to:")
+		imgui.Separator()
+		for _, callsign := range util.SortedMapKeys(ctx.ControlClient.Controllers) {
+			if callsign == ss.Callsign {
+				continue
+			}
+			ctrl := ctx.ControlClient.Controllers[callsign]
+			if imgui.Selectable(callsign + " (" + ctrl.SectorId + ")") {
+				ctx.ControlClient.PushFlightStrip(fsp.pushCallsign, callsign, fsp.reportError(ctx.Lg))
 			}
 		}
-	*/
+		imgui.EndPopup()
+	}
+
 	fsp.scrollbar.Draw(ctx, cb)
 
 	cb.SetRGB(UIControlColor)
@@ -0,0 +1,51 @@
+// pkg/panes/flightstrip_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package panes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFlightStripPaneUpgrade(t *testing.T) {
+	for _, old := range []bool{false, true} {
+		config, _ := json.Marshal(map[string]interface{}{
+			"AutoAddDepartures":         true,
+			"AutoRemoveHandoffs":        old,
+			"AddPushed":                 old,
+			"CollectDeparturesArrivals": old,
+		})
+		var fsp FlightStripPane
+		if err := json.Unmarshal(config, &fsp); err != nil {
+			t.Fatal(err)
+		}
+		fsp.Upgrade(30, 31)
+
+		if !fsp.AutoAddDepartures || fsp.AutoMoveHandoffs != old || fsp.AutoAcknowledgePushed != old {
+			t.Errorf("%v: settings not carried over: %+v", old, fsp)
+		}
+		expected := FlightStripSortManual
+		if old {
+			expected = FlightStripSortDeparturesFirst
+		}
+		for i, b := range fsp.Bays {
+			if b.Sort != expected {
+				t.Errorf("%v: bay %d: expected sort %s, got %s", old, i, expected, b.Sort)
+			}
+		}
+
+		// The old settings aren't saved again.
+		b, err := json.Marshal(&fsp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"AutoRemoveHandoffs", "AddPushed", "CollectDeparturesArrivals"} {
+			if strings.Contains(string(b), `"`+name+`"`) {
+				t.Errorf("%v: %s saved in %s", old, name, b)
+			}
+		}
+	}
+}
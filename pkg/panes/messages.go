@@ -368,7 +368,8 @@ func (mp *MessagesPane) processEvents(ctx *Context) {
 	for _, event := range mp.events.Get() {
 		switch event.Type {
 		case sim.RadioTransmissionEvent:
-			if event.ToController == ctx.ControlClient.Callsign {
+			// Observers only receive transmissions if the sim lets them hear the radio.
+			if event.ToController == ctx.ControlClient.Callsign || ctx.ControlClient.Callsign == sim.ObserverPosition {
 				if event.Callsign != lastRadioCallsign || event.RadioTransmissionType != lastRadioType {
 					if len(transmissions) > 0 {
 						addTransmissions()
@@ -341,6 +341,11 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 			status.clear = true
 			return
 
+		case "DS":
+			sp.HideSUA = !sp.HideSUA
+			status.clear = true
+			return
+
 		case ".ROUTE":
 			sp.drawRouteAircraft = ""
 			status.clear = true
@@ -773,6 +778,49 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 				return
 			}
 
+		case "K":
+			// IFR release coordination: KR ACID (tower requests release),
+			// KA ACID [void minutes] (release), KD ACID [minutes] (delay),
+			// KX ACID (deny).
+			f := strings.Fields(cmd)
+			if len(f) < 2 || len(f) > 3 || len(f[0]) != 1 || (len(f) == 3 && f[0] != "A" && f[0] != "D") {
+				status.err = ErrSTARSCommandFormat
+				return
+			}
+			callsign := f[1]
+			if _, ok := ctx.ControlClient.DepartureRelease(callsign); !ok {
+				status.err = ErrSTARSNoFlight
+				return
+			}
+			minutes := 0
+			if len(f) == 3 {
+				var err error
+				if minutes, err = strconv.Atoi(f[2]); err != nil || minutes <= 0 {
+					status.err = ErrSTARSIllegalParam
+					return
+				}
+			}
+
+			onErr := func(err error) { sp.displayError(err, ctx) }
+			switch f[0] {
+			case "R":
+				ctx.ControlClient.RequestRelease(callsign, onErr)
+			case "A":
+				ctx.ControlClient.ApproveRelease(callsign, time.Duration(minutes)*time.Minute, onErr)
+			case "D":
+				if minutes == 0 {
+					minutes = 2
+				}
+				ctx.ControlClient.DelayRelease(callsign, time.Duration(minutes)*time.Minute, onErr)
+			case "X":
+				ctx.ControlClient.DenyRelease(callsign, onErr)
+			default:
+				status.err = ErrSTARSCommandFormat
+				return
+			}
+			status.clear = true
+			return
+
 		case "L":
 			// leader lines
 			if l := len(cmd); l == 1 {
@@ -1300,6 +1348,18 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 				case 'N':
 					updateList(cmd[1:], &ps.CRDAStatusList.Visible, nil)
 					return
+				case 'O':
+					updateList(cmd[1:], &ps.NOTAMList.Visible, &ps.NOTAMList.Lines)
+					return
+				case 'E':
+					updateList(cmd[1:], &ps.MeterList.Visible, &ps.MeterList.Lines)
+					return
+				case 'F':
+					updateList(cmd[1:], &ps.TMIList.Visible, &ps.TMIList.Lines)
+					return
+				case 'K':
+					updateList(cmd[1:], &ps.CoordinationList.Visible, &ps.CoordinationList.Lines)
+					return
 				}
 			}
 
@@ -2530,6 +2590,26 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *panes.Context, cmd string,
 			ps.CRDAStatusList.Visible = true
 			status.clear = true
 			return
+		} else if cmd == "TO" {
+			ps.NOTAMList.Position = transforms.NormalizedFromWindowP(mousePosition)
+			ps.NOTAMList.Visible = true
+			status.clear = true
+			return
+		} else if cmd == "TE" {
+			ps.MeterList.Position = transforms.NormalizedFromWindowP(mousePosition)
+			ps.MeterList.Visible = true
+			status.clear = true
+			return
+		} else if cmd == "TF" {
+			ps.TMIList.Position = transforms.NormalizedFromWindowP(mousePosition)
+			ps.TMIList.Visible = true
+			status.clear = true
+			return
+		} else if cmd == "TK" {
+			ps.CoordinationList.Position = transforms.NormalizedFromWindowP(mousePosition)
+			ps.CoordinationList.Visible = true
+			status.clear = true
+			return
 		} else if len(cmd) == 2 && cmd[0] == 'P' {
 			if idx, err := strconv.Atoi(cmd[1:]); err == nil && idx > 0 && idx <= 3 {
 				ps.TowerLists[idx-1].Position = transforms.NormalizedFromWindowP(mousePosition)
@@ -2706,7 +2786,7 @@ func (sp *STARSPane) consumeMouseEvents(ctx *panes.Context, ghosts []*av.GhostAi
 			// Otherwise leave sp.dwellAircraft as is
 		}
 	} else {
-		if ac, _ := sp.tryGetClosestAircraft(ctx, ctx.Mouse.Pos, transforms); ac != nil {
+		if ac, _ := sp.tryGetClosestAircraft(ctx, ctx.Mouse.Pos, transforms); ac != nil && ctx.ControlClient.NavSummaries[ac.Callsign] != "" {
 			td := renderer.GetTextDrawBuilder()
 			defer renderer.ReturnTextDrawBuilder(td)
 
@@ -2724,7 +2804,7 @@ func (sp *STARSPane) consumeMouseEvents(ctx *panes.Context, ghosts []*av.GhostAi
 			// Upper-left corner of where we start drawing the text
 			pad := float32(5)
 			ptext := math.Add2f([2]float32{2 * pad, 0}, pac)
-			info := ac.NavSummary(ctx.Lg)
+			info := ctx.ControlClient.NavSummaries[ac.Callsign]
 			td.AddText(info, ptext, style)
 
 			// Draw an alpha-blended quad behind the text to make it more legible.
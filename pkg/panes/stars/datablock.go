@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/math"
@@ -721,6 +722,12 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 			idx := util.Select(fieldEmpty(db.field6[0][:]), 0, 1)
 			formatDBText(db.field6[idx][:], ac.Squawk.String(), color, false)
 		}
+		// Metering delay, if there's room for it.
+		if ma, ok := ctx.ControlClient.Metering[ac.Callsign]; ok && ma.Delay() >= time.Minute {
+			if idx := slices.IndexFunc(db.field6[:], func(f [5]dbChar) bool { return fieldEmpty(f[:]) }); idx != -1 {
+				formatDBText(db.field6[idx][:], formatMeteringDelay(ma.Delay()), color, false)
+			}
+		}
 
 		// Field 7: assigned altitude, assigned beacon if mismatch
 		if ac.TempAltitude != 0 {
@@ -1009,6 +1016,9 @@ func (sp *STARSPane) getWarnings(ctx *panes.Context, ac *av.Aircraft) []string {
 		}
 		addWarning("AS" + altStrs)
 	}
+	if sp.WarnSUAPenetration(ctx, ac) {
+		addWarning("SUA")
+	}
 
 	if len(warnings) > 1 {
 		slices.Sort(warnings)
@@ -1016,3 +1026,9 @@ func (sp *STARSPane) getWarnings(ctx *panes.Context, ac *av.Aircraft) []string {
 
 	return warnings
 }
+
+// formatMeteringDelay returns the metering delay in minutes in the form
+// used in datablocks and the meter list, e.g., "+04".
+func formatMeteringDelay(d time.Duration) string {
+	return fmt.Sprintf("%+03d", int(d.Round(time.Minute).Minutes()))
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/mmp/vice/pkg/math"
 	"github.com/mmp/vice/pkg/panes"
 	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
 	"github.com/mmp/vice/pkg/util"
 )
 
@@ -145,9 +146,15 @@ func (sp *STARSPane) drawSystemLists(aircraft []*av.Aircraft, ctx *panes.Context
 			newline()
 		}
 
-		// ATIS and GI text always, apparently
-		if ps.CurrentATIS != "" {
-			pw = td.AddText(ps.CurrentATIS+" "+ps.GIText[0], pw, listStyle)
+		// ATIS and GI text always, apparently. Unless the code has been
+		// entered manually, show the sim's current ATIS code for the
+		// primary airport.
+		atis := ps.CurrentATIS
+		if a, ok := ctx.ControlClient.ATIS[ctx.ControlClient.PrimaryAirport]; ok && atis == "" {
+			atis = a.Code
+		}
+		if atis != "" {
+			pw = td.AddText(atis+" "+ps.GIText[0], pw, listStyle)
 			newline()
 		} else if ps.GIText[0] != "" {
 			pw = td.AddText(ps.GIText[0], pw, listStyle)
@@ -561,6 +568,96 @@ func (sp *STARSPane) drawSystemLists(aircraft []*av.Aircraft, ctx *panes.Context
 		drawList(text.String(), tl.Position, listStyle)
 	}
 
+	if ps.NOTAMList.Visible {
+		text.Reset()
+		notams := ctx.ControlClient.NOTAMs
+
+		text.WriteString("NOTAMS\n")
+		if len(notams) > ps.NOTAMList.Lines {
+			text.WriteString(fmt.Sprintf("MORE: %d/%d\n", ps.NOTAMList.Lines, len(notams)))
+		}
+		for i, n := range notams {
+			if i == ps.NOTAMList.Lines {
+				break
+			}
+			text.WriteString(n.String() + "\n")
+		}
+
+		drawList(text.String(), ps.NOTAMList.Position, listStyle)
+	}
+
+	if ps.MeterList.Visible {
+		text.Reset()
+		metered := ctx.ControlClient.MeteredArrivals("")
+
+		text.WriteString("METER\n")
+		if len(metered) > ps.MeterList.Lines {
+			text.WriteString(fmt.Sprintf("MORE: %d/%d\n", ps.MeterList.Lines, len(metered)))
+		}
+		for i, ma := range metered {
+			if i == ps.MeterList.Lines {
+				break
+			}
+			text.WriteString(fmt.Sprintf("%-8s %-3s %s %s\n", ma.Callsign, ma.Runway,
+				ma.RunwaySTA.UTC().Format("1504"), formatMeteringDelay(ma.Delay())))
+		}
+
+		drawList(text.String(), ps.MeterList.Position, listStyle)
+	}
+
+	if ps.TMIList.Visible {
+		text.Reset()
+		tmis := ctx.ControlClient.TMIs
+
+		text.WriteString("TMI\n")
+		if len(tmis) > ps.TMIList.Lines {
+			text.WriteString(fmt.Sprintf("MORE: %d/%d\n", ps.TMIList.Lines, len(tmis)))
+		}
+		for i, t := range tmis {
+			if i == ps.TMIList.Lines {
+				break
+			}
+			text.WriteString(t.String() + "\n")
+		}
+
+		drawList(text.String(), ps.TMIList.Position, listStyle)
+	}
+
+	if ps.CoordinationList.Visible {
+		// Departures awaiting release that we either request or approve.
+		var releases []sim.DepartureRelease
+		for _, r := range ctx.ControlClient.DepartureReleases {
+			if r.Tower == ctx.ControlClient.Callsign || r.DepartureController == ctx.ControlClient.Callsign {
+				releases = append(releases, r)
+			}
+		}
+
+		text.Reset()
+		text.WriteString("COORDINATION\n")
+		if len(releases) > ps.CoordinationList.Lines {
+			text.WriteString(fmt.Sprintf("MORE: %d/%d\n", ps.CoordinationList.Lines, len(releases)))
+		}
+		for i, r := range releases {
+			if i == ps.CoordinationList.Lines {
+				break
+			}
+			line := fmt.Sprintf("%-8s %-4s %-3s %-5s %s", r.Callsign, r.AircraftType, r.Runway, r.Exit, r.Status)
+			switch r.Status {
+			case sim.ReleaseRequested:
+				line += " " + r.RequestTime.UTC().Format("1504")
+			case sim.ReleaseApproved:
+				if !r.VoidTime.IsZero() {
+					line += " V" + r.VoidTime.UTC().Format("1504")
+				}
+			case sim.ReleaseDelayed:
+				line += " " + r.NextRequestTime.UTC().Format("1504")
+			}
+			text.WriteString(line + "\n")
+		}
+
+		drawList(text.String(), ps.CoordinationList.Position, listStyle)
+	}
+
 	if ps.SignOnList.Visible {
 		if ctrl := ctx.ControlClient.Controllers[ctx.ControlClient.Callsign]; ctrl != nil {
 			text.Reset()
@@ -195,6 +195,26 @@ type PreferenceSet struct {
 		Position [2]float32
 		Visible  bool
 	}
+	NOTAMList struct {
+		Position [2]float32
+		Visible  bool
+		Lines    int
+	}
+	MeterList struct {
+		Position [2]float32
+		Visible  bool
+		Lines    int
+	}
+	TMIList struct {
+		Position [2]float32
+		Visible  bool
+		Lines    int
+	}
+	CoordinationList struct {
+		Position [2]float32
+		Visible  bool
+		Lines    int
+	}
 	TowerLists [3]struct {
 		Position [2]float32
 		Visible  bool
@@ -316,6 +336,21 @@ func (sp *STARSPane) MakePreferenceSet(name string, ss *sim.State) PreferenceSet
 
 	ps.CRDAStatusList.Position = [2]float32{.05, .7}
 
+	ps.NOTAMList.Position = [2]float32{.8, .8}
+	ps.NOTAMList.Lines = 5
+	ps.NOTAMList.Visible = true
+
+	ps.MeterList.Position = [2]float32{.8, .6}
+	ps.MeterList.Lines = 10
+
+	ps.TMIList.Position = [2]float32{.8, .7}
+	ps.TMIList.Lines = 5
+	ps.TMIList.Visible = true
+
+	ps.CoordinationList.Position = [2]float32{.05, .6}
+	ps.CoordinationList.Lines = 6
+	ps.CoordinationList.Visible = true
+
 	ps.TowerLists[0].Position = [2]float32{.05, .5}
 	ps.TowerLists[0].Lines = 5
 	ps.TowerLists[0].Visible = true
@@ -451,4 +486,23 @@ func (ps *PreferenceSet) Upgrade(from, to int) {
 	if from < 24 {
 		ps.AudioVolume = 10
 	}
+	if from < 27 {
+		ps.NOTAMList.Position = [2]float32{.8, .8}
+		ps.NOTAMList.Lines = 5
+		ps.NOTAMList.Visible = true
+	}
+	if from < 28 {
+		ps.MeterList.Position = [2]float32{.8, .6}
+		ps.MeterList.Lines = 10
+	}
+	if from < 29 {
+		ps.TMIList.Position = [2]float32{.8, .7}
+		ps.TMIList.Lines = 5
+		ps.TMIList.Visible = true
+	}
+	if from < 30 {
+		ps.CoordinationList.Position = [2]float32{.05, .6}
+		ps.CoordinationList.Lines = 6
+		ps.CoordinationList.Visible = true
+	}
 }
@@ -127,6 +127,13 @@ type STARSPane struct {
 	// Various UI state
 	FlipNumericKeypad bool
 
+	// Where weather radar images come from; one of the WeatherSource*
+	// values.
+	WxSource int
+
+	// Don't draw active special use airspace
+	HideSUA bool
+
 	scopeClickHandler   func(pw [2]float32, transforms ScopeTransformations) CommandStatus
 	activeDCBMenu       int
 	selectedPlaceButton string
@@ -345,6 +352,8 @@ func (sp *STARSPane) Activate(ss *sim.State, r renderer.Renderer, p platform.Pla
 
 	sp.events = eventStream.Subscribe()
 
+	sp.weatherRadar.SetSource(MakeWeatherSource(sp.WxSource))
+
 	ps := sp.CurrentPreferenceSet
 	if ps.Brightness.Weather != 0 {
 		sp.weatherRadar.Activate(ps.Center, r, lg)
@@ -518,6 +527,19 @@ func (sp *STARSPane) DrawUI(p platform.Platform, config *platform.Config) {
 
 	imgui.Checkbox("Invert numeric keypad", &sp.FlipNumericKeypad)
 
+	showSUA := !sp.HideSUA
+	if imgui.Checkbox("Show active special use airspace", &showSUA) {
+		sp.HideSUA = !showSUA
+	}
+
+	imgui.Text("Weather radar source:")
+	for i, name := range []string{"Live (NOAA)", "Saved radar images", "Procedural"} {
+		imgui.SameLine()
+		if imgui.RadioButtonInt(name, &sp.WxSource, i) {
+			sp.weatherRadar.SetSource(MakeWeatherSource(sp.WxSource))
+		}
+	}
+
 	imgui.Checkbox("Enable additional sound effects", &config.AudioEnabled)
 
 	if !config.AudioEnabled {
@@ -552,6 +574,7 @@ func (sp *STARSPane) Upgrade(from, to int) {
 func (sp *STARSPane) Draw(ctx *panes.Context, cb *renderer.CommandBuffer) {
 	sp.processEvents(ctx)
 	sp.updateRadarTracks(ctx)
+	sp.weatherRadar.UpdateCells(ctx.ControlClient.WeatherCells, ctx.ControlClient.NmPerLongitude)
 
 	ps := sp.CurrentPreferenceSet
 
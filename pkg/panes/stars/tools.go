@@ -9,12 +9,8 @@ import (
 	"fmt"
 	"image"
 	"image/draw"
-	"image/png"
-	"log/slog"
 	gomath "math"
 	"math/bits"
-	"net/http"
-	"net/url"
 	"slices"
 	"sort"
 	"strconv"
@@ -34,18 +30,33 @@ import (
 // WeatherRadar
 
 // WeatherRadar provides functionality for fetching radar images to display
-// in radar scopes. Only locations in the USA are currently supported, as
-// the only current data source is the US NOAA...
+// in radar scopes. Images come from a WeatherSource; live weather is only
+// available for locations in the USA, as the only current online data
+// source is the US NOAA...
 type WeatherRadar struct {
 	active bool
 
 	// Radar images are fetched and processed in a separate goroutine;
-	// updated radar center locations are sent from the main thread via
-	// reqChan and command buffers to draw each of the 6 weather levels are
-	// returned by cbChan.
-	reqChan chan math.Point2LL
+	// updated radar center locations and sources are sent from the main
+	// thread via reqChan and command buffers to draw each of the 6 weather
+	// levels are returned by cbChan.
+	reqChan chan weatherRequest
 	cbChan  chan [NumWxLevels]*renderer.CommandBuffer
 	cb      [NumWxLevels]*renderer.CommandBuffer
+
+	center math.Point2LL
+	source WeatherSource
+
+	// If the sim has synthetic weather cells, they are drawn instead of
+	// the regular source so that the scope shows the same weather that
+	// the pilots are avoiding.
+	cells      CellWeatherSource
+	usingCells bool
+}
+
+type weatherRequest struct {
+	center math.Point2LL
+	source WeatherSource
 }
 
 const NumWxLevels = 6
@@ -62,15 +73,20 @@ const WxLatLongExtent = 2.5
 // radar images; it is called with an initial center position in
 // latitude-longitude coordinates.
 func (w *WeatherRadar) Activate(center math.Point2LL, r renderer.Renderer, lg *log.Logger) {
+	w.center = center
+	if w.source == nil {
+		w.source = MakeWeatherSource(WeatherSourceLive)
+	}
+
 	if w.active {
-		w.reqChan <- center
+		w.request()
 		return
 	}
 
 	w.active = true
-	w.reqChan = make(chan math.Point2LL, 1000) // lots of buffering
-	w.reqChan <- center
+	w.reqChan = make(chan weatherRequest, 1000) // lots of buffering
 	w.cbChan = make(chan [NumWxLevels]*renderer.CommandBuffer, 8)
+	w.request()
 
 	go fetchWeather(w.reqChan, w.cbChan, lg)
 }
@@ -83,11 +99,46 @@ func (w *WeatherRadar) HaveWeather() [NumWxLevels]bool {
 	return r
 }
 
+// SetSource changes the source of radar images; a new image is fetched
+// immediately.
+func (w *WeatherRadar) SetSource(src WeatherSource) {
+	w.source = src
+	w.request()
+}
+
+// UpdateCells provides the sim's current synthetic weather cells. When
+// there are any, they are displayed in place of the regular weather
+// source.
+func (w *WeatherRadar) UpdateCells(cells []av.WeatherCell, nmPerLongitude float32) {
+	using := len(cells) > 0
+	if using {
+		w.cells.SetCells(cells, nmPerLongitude)
+	}
+	if using != w.usingCells {
+		w.usingCells = using
+		w.request()
+	}
+}
+
 // UpdateCenter provides a new center point for the radar image, causing a
 // new image to be fetched.
 func (w *WeatherRadar) UpdateCenter(center math.Point2LL) {
+	w.center = center
+	w.request()
+}
+
+func (w *WeatherRadar) request() {
+	if !w.active {
+		return
+	}
+
+	req := weatherRequest{center: w.center, source: w.source}
+	if w.usingCells {
+		req.source = &w.cells
+	}
+
 	select {
-	case w.reqChan <- center:
+	case w.reqChan <- req:
 		// success
 	default:
 		// The channel is full; this may happen if the user is continuously
@@ -240,26 +291,31 @@ func invertRadarReflectivity(rgb [3]byte) float32 {
 }
 
 // fetchWeather runs asynchronously in a goroutine, receiving requests from
-// reqChan, fetching corresponding radar images from the NOAA, and sending
-// the results back on cbChan.  New images are also automatically
-// fetched periodically, with a wait time specified by the delay parameter.
-func fetchWeather(reqChan chan math.Point2LL, cbChan chan [NumWxLevels]*renderer.CommandBuffer, lg *log.Logger) {
-	// NOAA posts new maps every 2 minutes, so fetch a new map at minimum
-	// every 100s to stay current.
-	fetchRate := 100 * time.Second
-
-	// center stores the current center position of the radar image
-	var center math.Point2LL
+// reqChan, fetching corresponding radar images from the request's
+// WeatherSource, and sending the results back on cbChan.  New images are
+// also automatically fetched periodically, at the source's refresh
+// interval.
+func fetchWeather(reqChan chan weatherRequest, cbChan chan [NumWxLevels]*renderer.CommandBuffer, lg *log.Logger) {
+	// req stores the current center position of the radar image and the
+	// source to get it from.
+	var req weatherRequest
 	var lastFetch time.Time
+	// The source of the most recent image sent back on cbChan
+	var lastSource WeatherSource
 	for {
+		fetchRate := 100 * time.Second
+		if req.source != nil {
+			fetchRate = req.source.RefreshInterval()
+		}
+
 		var ok, timedOut bool
 		select {
-		case center, ok = <-reqChan:
+		case req, ok = <-reqChan:
 			if ok {
 				// Drain any additional requests so that we get the most
 				// recent one.
 				for len(reqChan) > 0 {
-					center = <-reqChan
+					req = <-reqChan
 				}
 			} else {
 				// The channel is closed; wrap up.
@@ -271,52 +327,36 @@ func fetchWeather(reqChan chan math.Point2LL, cbChan chan [NumWxLevels]*renderer
 			// changed.
 			timedOut = true
 		}
+		if req.source == nil {
+			continue
+		}
 
 		// Even if the center has moved, don't fetch more than every 15
-		// seconds.
-		if !timedOut && !lastFetch.IsZero() && time.Since(lastFetch) < 15*time.Second {
+		// seconds (or the source's refresh interval, if shorter) unless
+		// the source has changed.
+		minWait := math.Min(15*time.Second, req.source.RefreshInterval())
+		if !timedOut && req.source == lastSource && !lastFetch.IsZero() && time.Since(lastFetch) < minWait {
 			continue
 		}
 		lastFetch = time.Now()
 
 		// Lat-long bounds of the region we're going to request weather for.
-		rb := math.Extent2D{P0: math.Sub2LL(center, math.Point2LL{WxLatLongExtent, WxLatLongExtent}),
-			P1: math.Add2LL(center, math.Point2LL{WxLatLongExtent, WxLatLongExtent})}
-
-		// The weather radar image comes via a WMS GetMap request from the NOAA.
-		//
-		// Relevant background:
-		// https://enterprise.arcgis.com/en/server/10.3/publish-services/windows/communicating-with-a-wms-service-in-a-web-browser.htm
-		// http://schemas.opengis.net/wms/1.3.0/capabilities_1_3_0.xsd
-		// NOAA weather: https://opengeo.ncep.noaa.gov/geoserver/www/index.html
-		// https://opengeo.ncep.noaa.gov/geoserver/conus/conus_bref_qcd/ows?service=wms&version=1.3.0&request=GetCapabilities
-		params := url.Values{}
-		params.Add("SERVICE", "WMS")
-		params.Add("REQUEST", "GetMap")
-		params.Add("FORMAT", "image/png")
-		params.Add("WIDTH", "2048")
-		params.Add("HEIGHT", "2048")
-		params.Add("LAYERS", "conus_bref_qcd")
-		params.Add("BBOX", fmt.Sprintf("%f,%f,%f,%f", rb.P0[0], rb.P0[1], rb.P1[0], rb.P1[1]))
-
-		url := "https://opengeo.ncep.noaa.gov/geoserver/conus/conus_bref_qcd/ows?" + params.Encode()
-
-		// Request the image
-		lg.Info("Fetching weather", slog.String("url", url))
-		resp, err := http.Get(url)
-		if err != nil {
-			lg.Infof("Weather error: %s", err)
-			continue
-		}
-		defer resp.Body.Close()
+		rb := math.Extent2D{P0: math.Sub2LL(req.center, math.Point2LL{WxLatLongExtent, WxLatLongExtent}),
+			P1: math.Add2LL(req.center, math.Point2LL{WxLatLongExtent, WxLatLongExtent})}
 
-		img, err := png.Decode(resp.Body)
+		img, err := req.source.FetchImage(rb, lg)
 		if err != nil {
 			lg.Infof("Weather error: %s", err)
+			if req.source != lastSource {
+				// Don't keep showing weather from the previous source.
+				cbChan <- [NumWxLevels]*renderer.CommandBuffer{}
+				lastSource = req.source
+			}
 			continue
 		}
 
 		cbChan <- makeWeatherCommandBuffers(img, rb)
+		lastSource = req.source
 
 		lg.Info("finish weather fetch")
 	}
@@ -1007,8 +1047,30 @@ func (sp *STARSPane) drawAirspace(ctx *panes.Context, transforms ScopeTransforma
 		drawSectors(ctx.ControlClient.DepartureAirspace)
 	}
 
+	var sua []av.SpecialUseAirspace
+	if !sp.HideSUA {
+		sua = ctx.ControlClient.ActiveSpecialUseAirspace(ctx.ControlClient.CurrentTime())
+	}
+	suaColor := func(s *av.SpecialUseAirspace) renderer.RGB {
+		return ps.Brightness.Lists.ScaleRGB(util.Select(s.Restricted(), STARSTextAlertColor, STARSMapColor))
+	}
+	for i := range sua {
+		floor, ceiling := sua[i].AltitudeRange()
+		style := renderer.TextStyle{
+			Font:           sp.systemFont[ps.CharSize.Tools],
+			Color:          suaColor(&sua[i]),
+			DrawBackground: true,
+		}
+		label := fmt.Sprintf("%s\n%03d-%03d", sua[i].Name, floor/100, ceiling/100)
+		td.AddTextCentered(label, transforms.WindowFromLatLongP(sua[i].Center()), style)
+	}
+
 	transforms.LoadLatLongViewingMatrices(cb)
 	ld.GenerateCommands(cb)
+	for i := range sua {
+		cb.SetRGB(suaColor(&sua[i]))
+		sua[i].GenerateDrawCommands(cb, ctx.ControlClient.NmPerLongitude)
+	}
 	transforms.LoadWindowViewingMatrices(cb)
 	td.GenerateCommands(cb)
 }
@@ -7,6 +7,7 @@ package stars
 import (
 	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
@@ -367,6 +368,16 @@ func (sp *STARSPane) processEvents(ctx *panes.Context) {
 			if state, ok := sp.Aircraft[event.Callsign]; ok {
 				state.IFFlashing = false
 			}
+
+		case sim.ReleaseRequestedEvent:
+			if event.ToController == ctx.ControlClient.Callsign {
+				sp.playOnce(ctx.Platform, AudioInboundHandoff)
+			}
+
+		case sim.ReleaseApprovedEvent, sim.ReleaseDelayedEvent, sim.ReleaseDeniedEvent, sim.ReleaseVoidedEvent:
+			if event.ToController == ctx.ControlClient.Callsign {
+				sp.previewAreaOutput = event.Callsign + " " + strings.ToUpper(strings.TrimPrefix(event.Type.String(), "Release"))
+			}
 		}
 	}
 }
@@ -420,7 +431,9 @@ func (sp *STARSPane) updateMSAWs(ctx *panes.Context) {
 
 func (sp *STARSPane) updateRadarTracks(ctx *panes.Context) {
 	// FIXME: all aircraft radar tracks are updated at the same time.
-	now := ctx.ControlClient.SimTime
+	// Tracks are extrapolated from the last world update so that they
+	// keep updating if it's late.
+	now := ctx.ControlClient.CurrentTime()
 	if sp.radarMode(ctx.ControlClient.RadarSites) == RadarModeFused {
 		if now.Sub(sp.lastTrackUpdate) < 1*time.Second {
 			return
@@ -439,10 +452,11 @@ func (sp *STARSPane) updateRadarTracks(ctx *panes.Context) {
 			continue
 		}
 
+		p, alt := ctx.ControlClient.ExtrapolatedTrack(ac, now)
 		state.previousTrack = state.track
 		state.track = av.RadarTrack{
-			Position:    ac.Position(),
-			Altitude:    int(ac.Altitude()),
+			Position:    p,
+			Altitude:    int(alt),
 			Groundspeed: int(ac.Nav.FlightState.GS),
 			Time:        now,
 		}
@@ -877,6 +891,43 @@ func (sp *STARSPane) WarnOutsideAirspace(ctx *panes.Context, ac *av.Aircraft) (a
 	return
 }
 
+// WarnSUAPenetration returns true if one of our tracks is predicted to
+// enter active special use airspace in the next two minutes.
+func (sp *STARSPane) WarnSUAPenetration(ctx *panes.Context, ac *av.Aircraft) bool {
+	if trk := sp.getTrack(ctx, ac); trk == nil || trk.TrackOwner != ctx.ControlClient.Callsign {
+		return false
+	}
+
+	state := sp.Aircraft[ac.Callsign]
+	if !state.HaveHeading() {
+		return false
+	}
+	sua := ctx.ControlClient.ActiveSpecialUseAirspace(ctx.ControlClient.CurrentTime())
+	if len(sua) == 0 {
+		return false
+	}
+
+	// Extrapolate the track in 15 second steps, using its current rate of
+	// climb or descent.
+	hv := state.HeadingVector(ctx.ControlClient.NmPerLongitude, ctx.ControlClient.MagneticVariation)
+	p, alt := state.TrackPosition(), float32(state.TrackAltitude())
+	var altRate float32 // feet per minute
+	if dt := state.track.Time.Sub(state.previousTrack.Time).Minutes(); dt > 0 {
+		altRate = float32(float64(state.TrackDeltaAltitude()) / dt)
+	}
+	for i := range 9 {
+		t := float32(i) / 4 // minutes
+		pt := math.Add2f(p, math.Scale2f(hv, t))
+		a := int(alt + altRate*t)
+		for j := range sua {
+			if sua[j].Inside(pt, a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (sp *STARSPane) updateCAAircraft(ctx *panes.Context, aircraft []*av.Aircraft) {
 	inCAVolumes := func(state *AircraftState) bool {
 		for _, vol := range ctx.ControlClient.InhibitCAVolumes() {
@@ -1099,56 +1150,7 @@ func getCwtCategory(ctx *panes.Context, ac *av.Aircraft) string {
 }
 
 func (sp *STARSPane) checkInTrailCwtSeparation(ctx *panes.Context, back, front *av.Aircraft) {
-	cwtClass := func(ac *av.Aircraft) int {
-		perf, ok := av.DB.AircraftPerformance[ac.FlightPlan.BaseType()]
-		if !ok {
-			ctx.Lg.Errorf("%s: unable to get performance model for %s", ac.Callsign, ac.FlightPlan.BaseType())
-			return 9
-		}
-		wc := perf.Category.CWT
-		if len(wc) == 0 {
-			ctx.Lg.Errorf("%s: no CWT category found for %s", ac.Callsign, ac.FlightPlan.BaseType())
-			return 9
-		}
-		switch wc[0] {
-		case 'I':
-			return 0
-		case 'H':
-			return 1
-		case 'G':
-			return 2
-		case 'F':
-			return 3
-		case 'E':
-			return 4
-		case 'D':
-			return 5
-		case 'C':
-			return 6
-		case 'B':
-			return 7
-		case 'A':
-			return 8
-		default:
-			ctx.Lg.Errorf("%s: unexpected weight class \"%c\"", ac.Callsign, wc[0])
-			return 9
-		}
-	}
-	// 7110.126B TBL 5-5-2
-	// 0 value means minimum radar separation
-	cwtOnApproachLookUp := [10][10]float32{ // [front][back]
-		{0, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind I
-		{0, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind H
-		{0, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind G
-		{4, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind F
-		{4, 0, 0, 0, 0, 0, 0, 0, 0, 10},          // Behind E
-		{6, 6, 5, 5, 5, 4, 4, 3, 0, 10},          // Behind D
-		{6, 5, 3.5, 3.5, 3.5, 0, 0, 0, 0, 10},    // Behind C
-		{6, 5, 5, 5, 5, 4, 4, 3, 0, 10},          // Behind B
-		{8, 8, 7, 7, 7, 6, 6, 5, 0, 10},          // Behind A
-		{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, // Behind NOWGT (No weight: 7110.762)
-	}
-	cwtSeparation := cwtOnApproachLookUp[cwtClass(front)][cwtClass(back)]
+	cwtSeparation := av.CWTApproachSeparation(getCwtCategory(ctx, front), getCwtCategory(ctx, back))
 
 	state := sp.Aircraft[back.Callsign]
 	vol := back.ATPAVolume()
@@ -0,0 +1,617 @@
+// pkg/panes/stars/weather.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package stars
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	gomath "math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/math"
+
+	_ "golang.org/x/image/tiff" // for image.Decode
+)
+
+// WeatherSource provides the radar images that the WeatherRadar displays.
+// Images use the NOAA reflectivity color map and row y of the image
+// corresponds to latitude rb.Lerp(y/height), so that all sources can be
+// converted to weather levels by makeWeatherCommandBuffers.
+type WeatherSource interface {
+	// FetchImage returns a radar image covering the given lat-long extent.
+	FetchImage(rb math.Extent2D, lg *log.Logger) (image.Image, error)
+	// RefreshInterval returns how often a new image should be fetched
+	// even if the radar center hasn't changed.
+	RefreshInterval() time.Duration
+}
+
+// Weather source options offered in the STARS settings.
+const (
+	WeatherSourceLive = iota
+	WeatherSourceSaved
+	WeatherSourceProcedural
+)
+
+var ErrNoSavedWeather = errors.New("No saved weather image covers the area")
+
+// weatherCacheDir returns the directory where fetched radar images are
+// saved so that they are available when offline, <UserCacheDir>/Vice/weather.
+// It's also where the SavedWeatherSource looks for images, so users may put
+// their own there. If there's no user cache directory, images aren't saved.
+func weatherCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	dir = filepath.Join(dir, "Vice", "weather")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// MakeWeatherSource returns the WeatherSource for one of the
+// WeatherSource* options.
+func MakeWeatherSource(which int) WeatherSource {
+	switch which {
+	case WeatherSourceSaved:
+		return &SavedWeatherSource{Dir: weatherCacheDir()}
+	case WeatherSourceProcedural:
+		return &NoiseWeatherSource{Seed: 1, Coverage: 0.25, start: time.Now()}
+	default:
+		return &NOAAWeatherSource{CacheDir: weatherCacheDir()}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// NOAAWeatherSource
+
+// NOAAWeatherSource fetches live radar imagery from the NOAA's WMS
+// server. If CacheDir is set, fetched images are saved there and the most
+// recent saved image is used if the server can't be reached.
+type NOAAWeatherSource struct {
+	CacheDir string
+}
+
+// Maximum number of images kept in the weather cache.
+const maxCachedWeatherImages = 32
+
+func (n *NOAAWeatherSource) RefreshInterval() time.Duration {
+	// NOAA posts new maps every 2 minutes, so fetch a new map at minimum
+	// every 100s to stay current.
+	return 100 * time.Second
+}
+
+func (n *NOAAWeatherSource) FetchImage(rb math.Extent2D, lg *log.Logger) (image.Image, error) {
+	img, err := n.fetch(rb, lg)
+	if err != nil && n.CacheDir != "" {
+		lg.Infof("Weather error: %s; trying saved images", err)
+		saved := SavedWeatherSource{Dir: n.CacheDir}
+		if simg, serr := saved.FetchImage(rb, lg); serr == nil {
+			return simg, nil
+		}
+	}
+	return img, err
+}
+
+func (n *NOAAWeatherSource) fetch(rb math.Extent2D, lg *log.Logger) (image.Image, error) {
+	// The weather radar image comes via a WMS GetMap request from the NOAA.
+	//
+	// Relevant background:
+	// https://enterprise.arcgis.com/en/server/10.3/publish-services/windows/communicating-with-a-wms-service-in-a-web-browser.htm
+	// http://schemas.opengis.net/wms/1.3.0/capabilities_1_3_0.xsd
+	// NOAA weather: https://opengeo.ncep.noaa.gov/geoserver/www/index.html
+	// https://opengeo.ncep.noaa.gov/geoserver/conus/conus_bref_qcd/ows?service=wms&version=1.3.0&request=GetCapabilities
+	params := url.Values{}
+	params.Add("SERVICE", "WMS")
+	params.Add("REQUEST", "GetMap")
+	params.Add("FORMAT", "image/png")
+	params.Add("WIDTH", "2048")
+	params.Add("HEIGHT", "2048")
+	params.Add("LAYERS", "conus_bref_qcd")
+	params.Add("BBOX", fmt.Sprintf("%f,%f,%f,%f", rb.P0[0], rb.P0[1], rb.P1[0], rb.P1[1]))
+
+	url := "https://opengeo.ncep.noaa.gov/geoserver/conus/conus_bref_qcd/ows?" + params.Encode()
+
+	// Request the image
+	lg.Info("Fetching weather", slog.String("url", url))
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	if n.CacheDir != "" {
+		n.save(b, rb, lg)
+	}
+
+	return img, nil
+}
+
+// save writes the PNG for the given extent to the cache directory and
+// prunes old images.
+func (n *NOAAWeatherSource) save(b []byte, rb math.Extent2D, lg *log.Logger) {
+	fn := filepath.Join(n.CacheDir, savedWeatherFilename(rb))
+	if err := os.WriteFile(fn, b, 0o600); err != nil {
+		lg.Warnf("%s: %v", fn, err)
+		return
+	}
+
+	// Only prune the PNGs we've saved, not any GeoTIFFs the user has
+	// provided.
+	saved := slices.DeleteFunc(listSavedWeather(n.CacheDir), func(sw savedWeather) bool {
+		return filepath.Ext(sw.filename) != ".png"
+	})
+	for len(saved) > maxCachedWeatherImages {
+		// listSavedWeather returns the newest images first.
+		os.Remove(saved[len(saved)-1].filename)
+		saved = saved[:len(saved)-1]
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// SavedWeatherSource
+
+// SavedWeatherSource provides radar images from files in a directory;
+// these may be images saved by the NOAAWeatherSource or ones provided by
+// the user. PNG images must have their lat-long extent encoded in their
+// filename as wx_<lon0>_<lat0>_<lon1>_<lat1>.png. GeoTIFFs (.tif or
+// .tiff) carry their extent in their GeoTIFF tags; they must be in
+// geographic (lat-long) coordinates. Either way, images should use the
+// NOAA reflectivity color map.
+type SavedWeatherSource struct {
+	Dir string
+}
+
+type savedWeather struct {
+	filename string
+	extent   math.Extent2D
+	modTime  time.Time
+}
+
+func savedWeatherFilename(rb math.Extent2D) string {
+	return fmt.Sprintf("wx_%.4f_%.4f_%.4f_%.4f.png", rb.P0[0], rb.P0[1], rb.P1[0], rb.P1[1])
+}
+
+// listSavedWeather returns the PNG images in the given directory with
+// well-formed names and the GeoTIFFs with valid GeoTIFF tags, sorted from
+// newest to oldest.
+func listSavedWeather(dir string) []savedWeather {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var saved []savedWeather
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		filename := filepath.Join(dir, e.Name())
+		var extent math.Extent2D
+		if name, ok := strings.CutSuffix(e.Name(), ".png"); ok {
+			if extent, ok = parseSavedWeatherFilename(name); !ok {
+				continue
+			}
+		} else if ext := strings.ToLower(filepath.Ext(e.Name())); ext == ".tif" || ext == ".tiff" {
+			if extent, err = readGeoTIFFExtent(filename); err != nil {
+				continue
+			}
+		} else {
+			continue
+		}
+
+		saved = append(saved, savedWeather{
+			filename: filename,
+			extent:   extent,
+			modTime:  info.ModTime(),
+		})
+	}
+
+	slices.SortFunc(saved, func(a, b savedWeather) int { return b.modTime.Compare(a.modTime) })
+	return saved
+}
+
+// parseSavedWeatherFilename returns the extent encoded in a PNG's
+// filename, given without its extension.
+func parseSavedWeatherFilename(name string) (math.Extent2D, bool) {
+	f := strings.Split(name, "_")
+	if len(f) != 5 || f[0] != "wx" {
+		return math.Extent2D{}, false
+	}
+
+	var v [4]float32
+	for i := range v {
+		fv, err := strconv.ParseFloat(f[i+1], 32)
+		if err != nil {
+			return math.Extent2D{}, false
+		}
+		v[i] = float32(fv)
+	}
+	e := math.Extent2D{P0: [2]float32{v[0], v[1]}, P1: [2]float32{v[2], v[3]}}
+	return e, validWeatherExtent(e)
+}
+
+// validWeatherExtent returns true if the extent is a non-empty lat-long
+// rectangle; images with other extents (including ones with NaNs) can't
+// be resampled.
+func validWeatherExtent(e math.Extent2D) bool {
+	return -180 <= e.P0[0] && e.P0[0] < e.P1[0] && e.P1[0] <= 180 &&
+		-90 <= e.P0[1] && e.P0[1] < e.P1[1] && e.P1[1] <= 90
+}
+
+func (s *SavedWeatherSource) RefreshInterval() time.Duration {
+	// Pick up newly saved images reasonably quickly.
+	return 100 * time.Second
+}
+
+func (s *SavedWeatherSource) FetchImage(rb math.Extent2D, lg *log.Logger) (image.Image, error) {
+	center := rb.Center()
+	for _, sw := range listSavedWeather(s.Dir) {
+		if !sw.extent.Inside(center) {
+			continue
+		}
+
+		f, err := os.Open(sw.filename)
+		if err != nil {
+			lg.Warnf("%s: %v", sw.filename, err)
+			continue
+		}
+		// image.Decode handles both PNGs and TIFFs.
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			lg.Warnf("%s: %v", sw.filename, err)
+			continue
+		}
+
+		lg.Info("Using saved weather", slog.String("filename", sw.filename))
+		return resampleWeatherImage(img, sw.extent, rb), nil
+	}
+	return nil, ErrNoSavedWeather
+}
+
+// resampleWeatherImage returns an image covering the extent rb, taking
+// pixels from img, which covers the extent from. Areas not covered by img
+// are left clear.
+func resampleWeatherImage(img image.Image, from math.Extent2D, rb math.Extent2D) image.Image {
+	const res = 1024
+	out := image.NewRGBA(image.Rect(0, 0, res, res))
+	bounds := img.Bounds()
+	for y := 0; y < res; y++ {
+		for x := 0; x < res; x++ {
+			p := rb.Lerp([2]float32{(float32(x) + 0.5) / res, (float32(y) + 0.5) / res})
+			if !from.Inside(p) {
+				out.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+				continue
+			}
+			u := (p[0] - from.P0[0]) / from.Width()
+			v := (p[1] - from.P0[1]) / from.Height()
+			sx := bounds.Min.X + math.Min(int(u*float32(bounds.Dx())), bounds.Dx()-1)
+			sy := bounds.Min.Y + math.Min(int(v*float32(bounds.Dy())), bounds.Dy()-1)
+			out.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return out
+}
+
+// GeoTIFF tags; see http://geotiff.maptools.org/spec/geotiff2.6.html.
+const (
+	tiffTagImageWidth         = 256
+	tiffTagImageLength        = 257
+	geoTIFFTagModelPixelScale = 33550
+	geoTIFFTagModelTiepoint   = 33922
+	geoTIFFTagGeoKeyDirectory = 34735
+
+	geoKeyModelType        = 1024
+	geoModelTypeGeographic = 2
+)
+
+// readGeoTIFFExtent returns the lat-long extent of the GeoTIFF in the
+// given file. Only the tags needed to find the extent are read; the image
+// itself is decoded with image.Decode.
+func readGeoTIFFExtent(filename string) (math.Extent2D, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return math.Extent2D{}, err
+	}
+	defer f.Close()
+
+	tags, err := readTIFFTags(f)
+	if err != nil {
+		return math.Extent2D{}, fmt.Errorf("%s: %w", filename, err)
+	}
+	return geoTIFFExtent(tags)
+}
+
+// geoTIFFExtent computes the lat-long extent of a GeoTIFF image from its
+// tags.
+func geoTIFFExtent(tags map[uint16][]float64) (math.Extent2D, error) {
+	if keys := tags[geoTIFFTagGeoKeyDirectory]; len(keys) >= 4 {
+		// The header is followed by (key, location, count, value)
+		// entries.
+		for i := 4; i+3 < len(keys); i += 4 {
+			if keys[i] == geoKeyModelType && keys[i+1] == 0 && keys[i+3] != geoModelTypeGeographic {
+				return math.Extent2D{}, errors.New("GeoTIFF isn't in geographic coordinates")
+			}
+		}
+	}
+
+	w, h := tags[tiffTagImageWidth], tags[tiffTagImageLength]
+	scale, tie := tags[geoTIFFTagModelPixelScale], tags[geoTIFFTagModelTiepoint]
+	if len(w) != 1 || len(h) != 1 || len(scale) < 2 || len(tie) < 6 {
+		return math.Extent2D{}, errors.New("missing GeoTIFF tags")
+	}
+
+	// The tiepoint gives the lat-long (x, y) of the raster point (i, j);
+	// rows go from north to south.
+	lon0 := tie[3] - tie[0]*scale[0]
+	lat1 := tie[4] + tie[1]*scale[1]
+	lon1 := lon0 + w[0]*scale[0]
+	lat0 := lat1 - h[0]*scale[1]
+	e := math.Extent2D{P0: [2]float32{float32(lon0), float32(lat0)},
+		P1: [2]float32{float32(lon1), float32(lat1)}}
+	if !validWeatherExtent(e) {
+		return math.Extent2D{}, fmt.Errorf("invalid GeoTIFF extent %v", e)
+	}
+	return e, nil
+}
+
+// readTIFFTags returns the values of the numeric tags in the first image
+// file directory of a TIFF file.
+func readTIFFTags(r io.ReadSeeker) (map[uint16][]float64, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	var bo binary.ByteOrder
+	switch string(hdr[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return nil, errors.New("not a TIFF file")
+	}
+	if bo.Uint16(hdr[2:]) != 42 {
+		return nil, errors.New("not a TIFF file")
+	}
+
+	if _, err := r.Seek(int64(bo.Uint32(hdr[4:])), io.SeekStart); err != nil {
+		return nil, err
+	}
+	var n uint16
+	if err := binary.Read(r, bo, &n); err != nil {
+		return nil, err
+	}
+	entries := make([]byte, 12*int(n))
+	if _, err := io.ReadFull(r, entries); err != nil {
+		return nil, err
+	}
+
+	tags := make(map[uint16][]float64)
+	for i := range int(n) {
+		e := entries[12*i : 12*i+12]
+		tag, typ, count := bo.Uint16(e), bo.Uint16(e[2:]), bo.Uint32(e[4:])
+
+		var size int
+		switch typ {
+		case 3: // SHORT
+			size = 2
+		case 4: // LONG
+			size = 4
+		case 12: // DOUBLE
+			size = 8
+		default:
+			continue
+		}
+		if count > 1<<16 {
+			return nil, fmt.Errorf("tag %d: implausible count %d", tag, count)
+		}
+
+		// Values are stored in the entry if they fit; otherwise the
+		// entry holds their offset.
+		data := e[8:12]
+		if n := size * int(count); n > 4 {
+			data = make([]byte, n)
+			if _, err := r.Seek(int64(bo.Uint32(e[8:])), io.SeekStart); err != nil {
+				return nil, err
+			}
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+		}
+
+		v := make([]float64, count)
+		for j := range v {
+			switch typ {
+			case 3:
+				v[j] = float64(bo.Uint16(data[2*j:]))
+			case 4:
+				v[j] = float64(bo.Uint32(data[4*j:]))
+			case 12:
+				v[j] = gomath.Float64frombits(bo.Uint64(data[8*j:]))
+			}
+		}
+		tags[tag] = v
+	}
+
+	return tags, nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Synthetic sources
+
+// weatherLevelColor returns the color in the NOAA reflectivity color map
+// that makeWeatherCommandBuffers converts to the given weather level.
+func weatherLevelColor(level int) color.RGBA {
+	if level <= 0 {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+
+	// makeWeatherCommandBuffers computes levels as int(refl*7); aim for
+	// the middle of the reflectivity range for the level.
+	refl := (float32(math.Min(level, NumWxLevels)) + 0.5) / 7
+	i := 3 * int(refl*float32(len(radarReflectivity)/3))
+	return color.RGBA{R: radarReflectivity[i], G: radarReflectivity[i+1], B: radarReflectivity[i+2], A: 255}
+}
+
+// renderWeatherImage generates a radar image covering the extent rb with
+// the weather level at each point given by the provided callback.
+func renderWeatherImage(rb math.Extent2D, levelAt func(p math.Point2LL) int) image.Image {
+	const res = 512
+	img := image.NewRGBA(image.Rect(0, 0, res, res))
+	for y := 0; y < res; y++ {
+		for x := 0; x < res; x++ {
+			p := rb.Lerp([2]float32{(float32(x) + 0.5) / res, (float32(y) + 0.5) / res})
+			img.SetRGBA(x, y, weatherLevelColor(levelAt(p)))
+		}
+	}
+	return img
+}
+
+// CellWeatherSource renders the sim's synthetic weather cells so that the
+// scope shows the same weather that pilots are deviating around.
+type CellWeatherSource struct {
+	mu             sync.Mutex
+	cells          []av.WeatherCell
+	nmPerLongitude float32
+}
+
+// SetCells is called from the main thread with the sim's current cells.
+// The cells are only copied if they have changed since the last call.
+func (c *CellWeatherSource) SetCells(cells []av.WeatherCell, nmPerLongitude float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !slices.Equal(c.cells, cells) {
+		c.cells = slices.Clone(cells)
+	}
+	c.nmPerLongitude = nmPerLongitude
+}
+
+func (c *CellWeatherSource) RefreshInterval() time.Duration {
+	// Cells move and evolve, so keep the image fairly current.
+	return 5 * time.Second
+}
+
+func (c *CellWeatherSource) FetchImage(rb math.Extent2D, lg *log.Logger) (image.Image, error) {
+	c.mu.Lock()
+	cells, nmPerLongitude := c.cells, c.nmPerLongitude
+	c.mu.Unlock()
+
+	return renderWeatherImage(rb, func(p math.Point2LL) int {
+		level := 0
+		for i := range cells {
+			level = math.Max(level, cells[i].LevelAt(p, nmPerLongitude))
+		}
+		return level
+	}), nil
+}
+
+// NoiseWeatherSource generates procedural weather using fractal noise
+// that slowly drifts across the scope. Coverage, in [0,1], gives the
+// approximate fraction of the area that has some precipitation.
+type NoiseWeatherSource struct {
+	Seed     int
+	Coverage float32
+
+	start time.Time
+}
+
+func (n *NoiseWeatherSource) RefreshInterval() time.Duration {
+	return 30 * time.Second
+}
+
+func (n *NoiseWeatherSource) FetchImage(rb math.Extent2D, lg *log.Logger) (image.Image, error) {
+	if n.start.IsZero() {
+		n.start = time.Now()
+	}
+	// The weather moves east-northeast at 20 knots.
+	hours := float32(time.Since(n.start).Hours())
+	drift := [2]float32{18 * hours, 8 * hours}
+
+	// Use a fixed nm per longitude so that the features are consistent
+	// as the radar center moves.
+	const nmPerLongitude = 45
+	threshold := 1 - math.Clamp(n.Coverage, 0, 1)
+
+	return renderWeatherImage(rb, func(p math.Point2LL) int {
+		pn := math.Sub2f(math.LL2NM(p, nmPerLongitude), drift)
+		v := fractalNoise(pn[0]/40, pn[1]/40, n.Seed)
+		if v < threshold {
+			return 0
+		}
+		return 1 + int((v-threshold)/(1-threshold+1e-3)*NumWxLevels)
+	}), nil
+}
+
+// fractalNoise returns a sum of octaves of value noise at the given
+// point; the result is in [0,1].
+func fractalNoise(x, y float32, seed int) float32 {
+	sum, amp, norm := float32(0), float32(1), float32(0)
+	for octave := range 4 {
+		sum += amp * valueNoise(x, y, seed+octave)
+		norm += amp
+		x, y, amp = 2*x, 2*y, amp/2
+	}
+	return sum / norm
+}
+
+// valueNoise returns smoothly interpolated pseudo-random values in [0,1]
+// defined at the integer lattice points.
+func valueNoise(x, y float32, seed int) float32 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	// Smoothstep for continuous derivatives at the lattice points.
+	fx, fy = fx*fx*(3-2*fx), fy*fy*(3-2*fy)
+
+	lattice := func(dx, dy int) float32 {
+		// splitmix64 hash of the lattice coordinates and seed.
+		h := uint64(int64(x0)+int64(dx))*0x9e3779b97f4a7c15 ^ uint64(int64(y0)+int64(dy))*0xc2b2ae3d27d4eb4f ^
+			uint64(seed)
+		h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+		h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+		h ^= h >> 31
+		return float32(h>>40) / float32(1<<24)
+	}
+
+	v0 := math.Lerp(fx, lattice(0, 0), lattice(1, 0))
+	v1 := math.Lerp(fx, lattice(0, 1), lattice(1, 1))
+	return math.Lerp(fy, v0, v1)
+}
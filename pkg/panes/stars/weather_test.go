@@ -0,0 +1,225 @@
+// pkg/panes/stars/weather_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package stars
+
+import (
+	"bytes"
+	"encoding/binary"
+	gomath "math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmp/vice/pkg/math"
+)
+
+type testTIFFTag struct {
+	tag, typ uint16
+	values   []float64
+}
+
+// makeTIFF returns the bytes of a TIFF file with a single image file
+// directory with the given tags and no image data.
+func makeTIFF(bo binary.ByteOrder, tags ...testTIFFTag) []byte {
+	var b bytes.Buffer
+	if bo == binary.ByteOrder(binary.LittleEndian) {
+		b.WriteString("II")
+	} else {
+		b.WriteString("MM")
+	}
+	binary.Write(&b, bo, uint16(42))
+	binary.Write(&b, bo, uint32(8))
+
+	binary.Write(&b, bo, uint16(len(tags)))
+	var data bytes.Buffer
+	dataOffset := 8 + 2 + 12*len(tags) + 4
+	for _, t := range tags {
+		var v bytes.Buffer
+		for _, f := range t.values {
+			switch t.typ {
+			case 3:
+				binary.Write(&v, bo, uint16(f))
+			case 4:
+				binary.Write(&v, bo, uint32(f))
+			case 12:
+				binary.Write(&v, bo, f)
+			}
+		}
+
+		binary.Write(&b, bo, t.tag)
+		binary.Write(&b, bo, t.typ)
+		binary.Write(&b, bo, uint32(len(t.values)))
+		if v.Len() <= 4 {
+			b.Write(v.Bytes())
+			b.Write(make([]byte, 4-v.Len()))
+		} else {
+			binary.Write(&b, bo, uint32(dataOffset+data.Len()))
+			data.Write(v.Bytes())
+		}
+	}
+	binary.Write(&b, bo, uint32(0)) // no more IFDs
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+// geoTIFFTags returns the tags for a 100x50 image covering 74-73W,
+// 40-41N.
+func geoTIFFTags(modelType float64) []testTIFFTag {
+	return []testTIFFTag{
+		{tag: tiffTagImageWidth, typ: 3, values: []float64{100}},
+		{tag: tiffTagImageLength, typ: 4, values: []float64{50}},
+		{tag: 259, typ: 3, values: []float64{1}}, // compression; ignored
+		{tag: 270, typ: 2, values: []float64{0}}, // ASCII description; skipped
+		{tag: geoTIFFTagModelPixelScale, typ: 12, values: []float64{0.01, 0.02, 0}},
+		{tag: geoTIFFTagModelTiepoint, typ: 12, values: []float64{0, 0, 0, -74, 41, 0}},
+		{tag: geoTIFFTagGeoKeyDirectory, typ: 3, values: []float64{1, 1, 0, 1, geoKeyModelType, 0, 1, modelType}},
+	}
+}
+
+func TestReadGeoTIFFExtent(t *testing.T) {
+	want := math.Extent2D{P0: [2]float32{-74, 40}, P1: [2]float32{-73, 41}}
+	approxEqual := func(a, b math.Extent2D) bool {
+		return math.Abs(a.P0[0]-b.P0[0]) < 1e-4 && math.Abs(a.P0[1]-b.P0[1]) < 1e-4 &&
+			math.Abs(a.P1[0]-b.P1[0]) < 1e-4 && math.Abs(a.P1[1]-b.P1[1]) < 1e-4
+	}
+
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		tags, err := readTIFFTags(bytes.NewReader(makeTIFF(bo, geoTIFFTags(geoModelTypeGeographic)...)))
+		if err != nil {
+			t.Fatalf("%s: %v", bo, err)
+		}
+		if _, ok := tags[270]; ok {
+			t.Errorf("%s: ASCII tag shouldn't have been returned", bo)
+		}
+		if e, err := geoTIFFExtent(tags); err != nil {
+			t.Errorf("%s: %v", bo, err)
+		} else if !approxEqual(e, want) {
+			t.Errorf("%s: got extent %v, expected %v", bo, e, want)
+		}
+	}
+
+	le := binary.LittleEndian
+	valid := makeTIFF(le, geoTIFFTags(geoModelTypeGeographic)...)
+	for _, test := range []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"short header", valid[:6]},
+		{"bad magic", append([]byte("XX"), valid[2:]...)},
+		{"bad version", append([]byte("II\x2b\x00"), valid[4:]...)},
+		{"truncated directory", valid[:40]},
+		{"directory past end", append(valid[:4:4], 0xff, 0xff, 0, 0)},
+		{"value past end", valid[:len(valid)-8]},
+		{"implausible count", makeTIFF(le, testTIFFTag{tag: geoTIFFTagModelTiepoint, typ: 12,
+			values: make([]float64, 1<<16+1)})},
+	} {
+		if _, err := readTIFFTags(bytes.NewReader(test.b)); err == nil {
+			t.Errorf("%s: expected error reading tags", test.name)
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		tags []testTIFFTag
+	}{
+		{"projected", geoTIFFTags(1)},
+		{"no tiepoint", slices.DeleteFunc(geoTIFFTags(geoModelTypeGeographic),
+			func(t testTIFFTag) bool { return t.tag == geoTIFFTagModelTiepoint })},
+		{"no width", geoTIFFTags(geoModelTypeGeographic)[1:]},
+		{"zero scale", append(geoTIFFTags(geoModelTypeGeographic),
+			testTIFFTag{tag: geoTIFFTagModelPixelScale, typ: 12, values: []float64{0, 0, 0}})},
+		{"NaN scale", append(geoTIFFTags(geoModelTypeGeographic),
+			testTIFFTag{tag: geoTIFFTagModelPixelScale, typ: 12, values: []float64{gomath.NaN(), 0.02, 0}})},
+		{"off the globe", append(geoTIFFTags(geoModelTypeGeographic),
+			testTIFFTag{tag: geoTIFFTagModelTiepoint, typ: 12, values: []float64{0, 0, 0, -74, 91, 0}})},
+	} {
+		tags, err := readTIFFTags(bytes.NewReader(makeTIFF(le, test.tags...)))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if e, err := geoTIFFExtent(tags); err == nil {
+			t.Errorf("%s: expected error, got extent %v", test.name, e)
+		}
+	}
+}
+
+func TestParseSavedWeatherFilename(t *testing.T) {
+	rb := math.Extent2D{P0: [2]float32{-75.5, 39.25}, P1: [2]float32{-70.5, 44.25}}
+	if name, ok := strings.CutSuffix(savedWeatherFilename(rb), ".png"); !ok {
+		t.Errorf("%s: expected a PNG filename", name)
+	} else if e, ok := parseSavedWeatherFilename(name); !ok || e != rb {
+		t.Errorf("%s: got %v, %v; expected %v", name, e, ok, rb)
+	}
+
+	for _, test := range []struct {
+		name string
+		ok   bool
+	}{
+		{"wx_-74_40_-73_41", true},
+		{"wx_-74.0000_40.0000_-73.0000_41.0000", true},
+		{"", false},
+		{"wx", false},
+		{"wx_-74_40_-73", false},
+		{"wx_-74_40_-73_41_42", false},
+		{"xw_-74_40_-73_41", false},
+		{"wx_-74_40_-73_north", false},
+		{"wx_-74_40_-73_41 (1)", false},
+		{"wx__40_-73_41", false},
+		{"wx_-73_40_-74_41", false}, // empty extents
+		{"wx_-74_40_-74_41", false},
+		{"wx_-74_41_-73_40", false},
+		{"wx_-74_40_-73_NaN", false},
+		{"wx_-Inf_40_-73_41", false},
+		{"wx_-74_40_-73_91", false},
+		{"wx_-200_40_-73_41", false},
+	} {
+		if _, ok := parseSavedWeatherFilename(test.name); ok != test.ok {
+			t.Errorf("%q: got %v, expected %v", test.name, ok, test.ok)
+		}
+	}
+}
+
+func TestListSavedWeather(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, b []byte, age time.Duration) {
+		fn := filepath.Join(dir, name)
+		if err := os.WriteFile(fn, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		mt := time.Now().Add(-age)
+		if err := os.Chtimes(fn, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("wx_-74_40_-73_41.png", nil, time.Hour)
+	write("radar.TIF", makeTIFF(binary.BigEndian, geoTIFFTags(geoModelTypeGeographic)...), time.Minute)
+	write("wx_-74_40_-73.png", nil, 0)
+	write("wx_-74_40_-73_41.jpg", nil, 0)
+	write("notes.txt", nil, 0)
+	write("garbage.tiff", []byte("not a tiff"), 0)
+	write("projected.tif", makeTIFF(binary.LittleEndian, geoTIFFTags(1)...), 0)
+	if err := os.Mkdir(filepath.Join(dir, "wx_-74_40_-73_41.png.d"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := listSavedWeather(dir)
+	var names []string
+	for _, sw := range saved {
+		names = append(names, filepath.Base(sw.filename))
+	}
+	if !slices.Equal(names, []string{"radar.TIF", "wx_-74_40_-73_41.png"}) {
+		t.Errorf("got saved weather %v", names)
+	}
+
+	if listSavedWeather(filepath.Join(dir, "missing")) != nil {
+		t.Errorf("expected nothing from a missing directory")
+	}
+}
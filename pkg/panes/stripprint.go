@@ -0,0 +1,264 @@
+// pkg/panes/stripprint.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package panes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/util"
+)
+
+// Number of strips printed on each sheet.
+const stripsPerSheet = 7
+
+// PrintableStrip holds the information that is printed on a flight strip.
+type PrintableStrip struct {
+	Strip      av.FlightStrip
+	FlightPlan av.FlightPlan
+	// Proposed departure time; not printed if zero.
+	ProposedTime time.Time
+}
+
+// RasterizeFlightStrips draws the strips in the standard FAA layout into
+// sheets of stripsPerSheet strips each. Each strip is 80 characters wide
+// with the same 8" x 1 5/16" aspect ratio as paper strips.
+func RasterizeFlightStrips(strips []PrintableStrip, font *renderer.Font) []*image.RGBA {
+	bx, _ := font.BoundText("X", 0)
+	fw, fh := bx, font.Size
+	sw := 80 * fw
+	sh := max(sw*21/128, 3*fh+3*fh/2)
+	margin := 2 * fw
+
+	var sheets []*image.RGBA
+	for len(strips) > 0 {
+		n := min(len(strips), stripsPerSheet)
+		img := image.NewRGBA(image.Rect(0, 0, sw+2*margin, stripsPerSheet*sh+2*margin))
+		fillRect(img, img.Rect, color.RGBA{255, 255, 255, 255})
+		for i, s := range strips[:n] {
+			drawPrintableStrip(img, s, image.Pt(margin, margin+i*sh), sw, sh, fw, font)
+		}
+		sheets = append(sheets, img)
+		strips = strips[n:]
+	}
+	return sheets
+}
+
+func drawPrintableStrip(img *image.RGBA, s PrintableStrip, p image.Point, w, h, fw int, font *renderer.Font) {
+	black := color.RGBA{0, 0, 0, 255}
+	fp := s.FlightPlan
+
+	// Column widths, in characters: aircraft, beacon/time/altitude,
+	// departure airport, route, and three columns of annotations.
+	cols := []int{10, 7, 6, 42, 5, 5, 5}
+	var xs []int
+	x := p.X
+	for _, c := range cols {
+		xs = append(xs, x)
+		x += c * fw
+	}
+
+	// Outline and column separators
+	drawRect(img, image.Rect(p.X, p.Y, p.X+w, p.Y+h), black)
+	for i, x := range xs[1:] {
+		vline(img, x, p.Y, p.Y+h, black)
+		if i == 3 {
+			// Heavier line before the annotations.
+			vline(img, x+1, p.Y, p.Y+h, black)
+		}
+	}
+
+	// Each column has three rows; the route spans all of them.
+	rowh := h / 3
+	for _, c := range []int{1, 4, 5, 6} {
+		x1 := p.X + w
+		if c+1 < len(xs) {
+			x1 = xs[c+1]
+		}
+		for r := 1; r < 3; r++ {
+			hline(img, xs[c], x1, p.Y+r*rowh, black)
+		}
+	}
+
+	text := func(col, row int, s string) {
+		if s == "" {
+			return
+		}
+		y := p.Y + row*rowh + (rowh-font.Size)/2
+		font.RasterizeText(img, s, image.Pt(xs[col]+fw/2, y), black)
+	}
+
+	// Column 1: aircraft identification, type/equipment, computer ID
+	text(0, 0, fp.Callsign)
+	text(0, 1, fp.AircraftType)
+	text(0, 2, fp.ECID)
+
+	// Column 2: beacon code, proposed time, requested altitude
+	if fp.AssignedSquawk != 0 {
+		text(1, 0, fp.AssignedSquawk.String())
+	}
+	if !s.ProposedTime.IsZero() {
+		text(1, 1, "P"+s.ProposedTime.UTC().Format("1504"))
+	}
+	if fp.Altitude > 0 {
+		text(1, 2, fmt.Sprintf("%03d", fp.Altitude/100))
+	}
+
+	// Column 3: departure airport and flight rules
+	text(2, 0, fp.DepartureAirport)
+	if fp.Rules != av.IFR {
+		text(2, 2, fp.Rules.String())
+	}
+
+	// Route column: route and destination, then remarks
+	ncols := cols[3] - 1
+	route := strings.TrimSpace(fp.DepartureAirport + " " + fp.Route + " " + fp.ArrivalAirport)
+	wrapped, _ := util.WrapText(route, ncols, 2 /* indent */, true)
+	lines := strings.Split(wrapped, "\n")
+	if fp.Remarks != "" {
+		remarks, _ := util.WrapText(fp.Remarks, ncols, 2 /* indent */, true)
+		lines = append(lines, strings.Split(remarks, "\n")...)
+	}
+	for i, line := range lines[:min(len(lines), 3)] {
+		if len(line) > ncols {
+			line = line[:ncols]
+		}
+		text(3, i, line)
+	}
+
+	// Annotations
+	for i, ann := range s.Strip.Annotations {
+		text(4+i%3, i/3, ann)
+	}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func hline(img *image.RGBA, x0, x1, y int, c color.RGBA) {
+	fillRect(img, image.Rect(x0, y, x1, y+1), c)
+}
+
+func vline(img *image.RGBA, x, y0, y1 int, c color.RGBA) {
+	fillRect(img, image.Rect(x, y0, x+1, y1), c)
+}
+
+func drawRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	hline(img, r.Min.X, r.Max.X, r.Min.Y, c)
+	hline(img, r.Min.X, r.Max.X, r.Max.Y-1, c)
+	vline(img, r.Min.X, r.Min.Y, r.Max.Y, c)
+	vline(img, r.Max.X-1, r.Min.Y, r.Max.Y, c)
+}
+
+// WriteFlightStripsPNG writes each sheet to a PNG file; the files are
+// named using the given prefix and the sheet number. It returns the names
+// of the files written.
+func WriteFlightStripsPNG(sheets []*image.RGBA, prefix string) ([]string, error) {
+	var files []string
+	for i, img := range sheets {
+		fn := fmt.Sprintf("%s-%d.png", prefix, i+1)
+		f, err := os.Create(fn)
+		if err != nil {
+			return files, err
+		}
+		err = png.Encode(f, img)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, fn)
+	}
+	return files, nil
+}
+
+// WriteFlightStripsPDF writes a PDF with one letter-size page for each
+// sheet. The sheets are scaled so that the strips are 8" wide.
+func WriteFlightStripsPDF(w io.Writer, sheets []*image.RGBA) error {
+	var buf bytes.Buffer
+	var offsets []int
+	// Objects are numbered from 1 in the order they're added.
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Catalog and page tree are objects 1 and 2; each page then has a
+	// page object, its contents, and its image.
+	var kids []string
+	for i := range sheets {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 3+3*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(sheets)), nil)
+
+	const pageWidth, pageHeight = 612, 792 // letter, in points
+	for i, img := range sheets {
+		iw, ih := img.Rect.Dx(), img.Rect.Dy()
+		// Sheets are 84 characters wide, 80 of which are the strips; see
+		// RasterizeFlightStrips.
+		scale := float32(8*72) * 84 / 80 / float32(iw)
+		dw, dh := scale*float32(iw), scale*float32(ih)
+		x, y := (pageWidth-dw)/2, (pageHeight-dh)/2
+
+		content := []byte(fmt.Sprintf("q %.2f 0 0 %.2f %.2f %.2f cm /Im0 Do Q", dw, dh, x, y))
+
+		var rgb bytes.Buffer
+		zw := zlib.NewWriter(&rgb)
+		row := make([]byte, 3*iw)
+		for py := 0; py < ih; py++ {
+			for px := 0; px < iw; px++ {
+				c := img.RGBAAt(img.Rect.Min.X+px, img.Rect.Min.Y+py)
+				row[3*px], row[3*px+1], row[3*px+2] = c.R, c.G, c.B
+			}
+			if _, err := zw.Write(row); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R "+
+			"/Resources << /XObject << /Im0 %d 0 R >> >> >>", pageWidth, pageHeight, 4+3*i, 5+3*i), nil)
+		object(fmt.Sprintf("<< /Length %d >>", len(content)), content)
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB "+
+			"/BitsPerComponent 8 /Filter /FlateDecode /Length %d >>", iw, ih, rgb.Len()), rgb.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
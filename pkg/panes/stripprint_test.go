@@ -0,0 +1,112 @@
+// pkg/panes/stripprint_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package panes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteFlightStripsPDF(t *testing.T) {
+	var sheets []*image.RGBA
+	for i := 0; i < 3; i++ {
+		img := image.NewRGBA(image.Rect(0, 0, 84, 40))
+		fillRect(img, img.Rect, color.RGBA{uint8(50 * i), 255, 255, 255})
+		sheets = append(sheets, img)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFlightStripsPDF(&buf, sheets); err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.Bytes()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header: %q", pdf[:min(len(pdf), 16)])
+	}
+	if !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Errorf("missing %%%%EOF trailer")
+	}
+
+	// Follow startxref to the cross-reference table and make sure that
+	// each entry points at its object.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if xref >= len(pdf) || !bytes.HasPrefix(pdf[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %d doesn't point to the xref table", xref)
+	}
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	n, err := strconv.Atoi(strings.Fields(lines[1])[1])
+	if err != nil {
+		t.Fatalf("bad xref subsection header %q", lines[1])
+	}
+	if n != 2+3*len(sheets)+1 {
+		t.Errorf("expected %d xref entries, got %d", 2+3*len(sheets)+1, n)
+	}
+	for i := 1; i < n; i++ {
+		entry := lines[2+i]
+		if len(entry) != 19 { // each entry is 20 bytes with the newline
+			t.Errorf("xref entry %q is the wrong length", entry)
+		}
+		off, _ := strconv.Atoi(entry[:10])
+		if obj := fmt.Sprintf("%d 0 obj\n", i); !bytes.HasPrefix(pdf[off:], []byte(obj)) {
+			t.Errorf("xref entry %d points to %q", i, pdf[off:off+10])
+		}
+	}
+	if !strings.Contains(string(pdf), fmt.Sprintf("/Size %d ", n)) {
+		t.Errorf("trailer /Size doesn't match the xref table")
+	}
+
+	// Page count.
+	if !strings.Contains(string(pdf), fmt.Sprintf("/Count %d >>", len(sheets))) {
+		t.Errorf("expected page tree /Count %d", len(sheets))
+	}
+	if c := strings.Count(string(pdf), "/Type /Page /Parent 2 0 R"); c != len(sheets) {
+		t.Errorf("expected %d pages, got %d", len(sheets), c)
+	}
+
+	// The images decompress to RGB pixels.
+	streams := regexp.MustCompile(`(?s)/FlateDecode /Length (\d+) >>\nstream\n`).FindAllSubmatchIndex(pdf, -1)
+	if len(streams) != len(sheets) {
+		t.Fatalf("expected %d images, got %d", len(sheets), len(streams))
+	}
+	for i, s := range streams {
+		length, _ := strconv.Atoi(string(pdf[s[2]:s[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[s[1] : s[1]+length]))
+		if err != nil {
+			t.Fatalf("image %d: %v", i, err)
+		}
+		rgb, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("image %d: %v", i, err)
+		}
+		if len(rgb) != 3*84*40 || rgb[0] != uint8(50*i) || rgb[1] != 255 {
+			t.Errorf("image %d: unexpected pixels (%d bytes)", i, len(rgb))
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriteFlightStripsPDFError(t *testing.T) {
+	sheets := []*image.RGBA{image.NewRGBA(image.Rect(0, 0, 84, 40))}
+	if err := WriteFlightStripsPDF(failingWriter{}, sheets); err == nil {
+		t.Errorf("expected write error to be returned")
+	}
+}
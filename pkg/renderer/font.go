@@ -8,6 +8,7 @@ import (
 	"C"
 	"fmt"
 	"image"
+	"image/color"
 	gomath "math"
 	"runtime"
 	"sort"
@@ -314,6 +315,7 @@ func FontsInit(r Renderer, p platform.Platform) {
 		Pix:    unsafe.Slice((*uint8)(img.Pixels), 4*img.Width*img.Height),
 		Stride: 4 * img.Width,
 		Rect:   image.Rectangle{Max: image.Point{X: img.Width, Y: img.Height}}}
+	fontAtlas = rgb8Image
 	atlasId := r.CreateTextureFromImage(rgb8Image, true /* nearest */)
 	io.Fonts().SetTextureID(imgui.TextureID(atlasId))
 
@@ -326,6 +328,68 @@ func FontsInit(r Renderer, p platform.Platform) {
 	lg.Info("Finished initializing fonts")
 }
 
+// The glyph atlas is also kept on the CPU so that text can be drawn into
+// images; see RasterizeText.
+var fontAtlas *image.RGBA
+
+// RasterizeText draws the text into the image with its upper-left corner
+// at p. Unlike TextDrawBuilder, which generates commands for the GPU, it
+// draws on the CPU so that the result can be saved or printed.
+func (font *Font) RasterizeText(img *image.RGBA, s string, p image.Point, c color.RGBA) {
+	if fontAtlas == nil {
+		return
+	}
+
+	aw, ah := float32(fontAtlas.Rect.Dx()), float32(fontAtlas.Rect.Dy())
+	x, y := float32(p.X), float32(p.Y)
+	for _, ch := range s {
+		if ch == '\n' {
+			x, y = float32(p.X), y+float32(font.Size)
+			continue
+		}
+
+		g := font.LookupGlyph(ch)
+		if g.Visible {
+			// The atlas may be oversampled, so average the atlas pixels
+			// that cover each pixel in the image.
+			dx, dy := int(x+g.X0+0.5), int(y+g.Y0+0.5)
+			dw, dh := int(g.Width()+0.5), int(g.Height()+0.5)
+			su, sv := g.U0*aw, g.V0*ah
+			sw, sh := (g.U1-g.U0)*aw, (g.V1-g.V0)*ah
+			for j := 0; j < dh; j++ {
+				sy0 := int(sv + float32(j)*sh/float32(dh))
+				sy1 := max(sy0+1, int(sv+float32(j+1)*sh/float32(dh)))
+				for i := 0; i < dw; i++ {
+					sx0 := int(su + float32(i)*sw/float32(dw))
+					sx1 := max(sx0+1, int(su+float32(i+1)*sw/float32(dw)))
+
+					sum, n := 0, 0
+					for sy := sy0; sy < sy1; sy++ {
+						for sx := sx0; sx < sx1; sx++ {
+							sum += int(fontAtlas.Pix[fontAtlas.PixOffset(sx, sy)+3])
+							n++
+						}
+					}
+					blendPixel(img, dx+i, dy+j, c, sum/n)
+				}
+			}
+		}
+		x += g.AdvanceX
+	}
+}
+
+func blendPixel(img *image.RGBA, x, y int, c color.RGBA, coverage int) {
+	if !image.Pt(x, y).In(img.Rect) || coverage == 0 {
+		return
+	}
+	a := coverage * int(c.A) / 255
+	px := img.Pix[img.PixOffset(x, y):]
+	for i, v := range []uint8{c.R, c.G, c.B} {
+		px[i] = uint8((int(v)*a + int(px[i])*(255-a)) / 255)
+	}
+	px[3] = uint8(a + int(px[3])*(255-a)/255)
+}
+
 // getAllFonts returns a FontIdentifier slice that gives identifiers for
 // all of the available fonts, sorted by font name and then within each
 // name, by font size.
@@ -0,0 +1,427 @@
+// pkg/sim/admin.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/util"
+)
+
+// The server's HTTP port also provides endpoints for server
+// administration; see docs/server.md. Requests must include an
+// "Authorization: Bearer <token>" header, where the token is an admin's
+// access token on servers that restrict access and the contents of the
+// server's "password" file otherwise. The token is sent with every
+// request, so the endpoints should only be used over TLS.
+
+type AdminSim struct {
+	Name        string            `json:"name"`
+	Scenario    string            `json:"scenario"`
+	Paused      bool              `json:"paused"`
+	SimRate     float32           `json:"sim_rate"`
+	IdleSeconds int               `json:"idle_seconds"`
+	Controllers []AdminController `json:"controllers"`
+}
+
+type AdminController struct {
+	Callsign         string `json:"callsign"`
+	Name             string `json:"name,omitempty"` // observers only
+	Role             Role   `json:"role"`
+	LastHeardSeconds int    `json:"last_heard_seconds"`
+}
+
+type adminRateArgs struct {
+	Rate float32 `json:"rate"`
+}
+
+type adminMessageArgs struct {
+	Message string `json:"message"`
+}
+
+type adminKickArgs struct {
+	Controller string `json:"controller"`
+}
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+///////////////////////////////////////////////////////////////////////////
+// SimManager / Sim
+
+func (sm *SimManager) adminSims() []AdminSim {
+	sm.mu.Lock(sm.lg)
+	defer sm.mu.Unlock(sm.lg)
+
+	sims := []AdminSim{}
+	for _, name := range util.SortedMapKeys(sm.activeSims) {
+		s := sm.activeSims[name]
+		idle := s.IdleTime()
+
+		s.mu.Lock(s.lg)
+		as := AdminSim{
+			Name:        name,
+			Scenario:    s.ScenarioGroup + "/" + s.Scenario,
+			Paused:      s.Paused,
+			SimRate:     s.SimRate,
+			IdleSeconds: int(idle.Seconds()),
+			Controllers: []AdminController{},
+		}
+		for _, ctrl := range s.controllers {
+			as.Controllers = append(as.Controllers, AdminController{
+				Callsign:         ctrl.Callsign,
+				Name:             ctrl.observerName,
+				Role:             ctrl.role,
+				LastHeardSeconds: int(time.Since(ctrl.lastUpdateCall).Seconds()),
+			})
+		}
+		s.mu.Unlock(s.lg)
+
+		sort.Slice(as.Controllers, func(i, j int) bool {
+			return as.Controllers[i].Callsign+as.Controllers[i].Name < as.Controllers[j].Callsign+as.Controllers[j].Name
+		})
+		sims = append(sims, as)
+	}
+	return sims
+}
+
+func (sm *SimManager) lookupSim(name string) (*Sim, error) {
+	sm.mu.Lock(sm.lg)
+	defer sm.mu.Unlock(sm.lg)
+
+	if sim, ok := sm.activeSims[name]; ok {
+		return sim, nil
+	}
+	return nil, ErrNoNamedSim
+}
+
+// kickController signs off the controller at the given position, or the
+// observer with the given name.
+func (sm *SimManager) kickController(sim *Sim, controller string) error {
+	sim.mu.Lock(sim.lg)
+	var token string
+	for tok, ctrl := range sim.controllers {
+		if ctrl.Callsign == controller || (ctrl.IsObserver() && ctrl.observerName == controller) {
+			token = tok
+			break
+		}
+	}
+	if token != "" {
+		sim.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: controller + " was signed off by the server administrator.",
+		})
+	}
+	sim.mu.Unlock(sim.lg)
+
+	if token == "" {
+		return av.ErrNoController
+	}
+	if err := sim.SignOff(token); err != nil {
+		return err
+	}
+
+	sm.mu.Lock(sm.lg)
+	delete(sm.controllerTokenToSim, token)
+	sm.mu.Unlock(sm.lg)
+
+	return nil
+}
+
+func (s *Sim) adminSetPaused(paused bool) {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if s.Paused != paused {
+		s.Paused = paused
+		s.lg.Infof("paused by admin: %v", s.Paused)
+		s.lastUpdateTime = time.Now() // ignore time passage...
+		s.eventStream.Post(Event{
+			Type:    GlobalMessageEvent,
+			Message: "The server administrator has " + util.Select(paused, "paused", "unpaused") + " the sim",
+		})
+	}
+}
+
+func (s *Sim) adminSetSimRate(rate float32) {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	s.SimRate = rate
+	s.lg.Infof("sim rate set to %f by admin", s.SimRate)
+}
+
+func (s *Sim) postServerMessage(msg string) {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	s.eventStream.Post(Event{
+		Type:    ServerBroadcastMessageEvent,
+		Message: msg,
+	})
+}
+
+// terminate causes the SimManager to shut down the sim the next time it
+// checks whether it should exit.
+func (s *Sim) terminate() {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	s.terminated = true
+	s.eventStream.Post(Event{
+		Type:    ServerBroadcastMessageEvent,
+		Message: "This sim has been ended by the server administrator.",
+	})
+}
+
+func (s *Sim) isTerminated() bool {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.terminated
+}
+
+///////////////////////////////////////////////////////////////////////////
+// HTTP endpoints
+
+func registerAdminHandlers(mux *http.ServeMux, sm *SimManager) {
+	mux.HandleFunc("GET /admin/sims", adminHandler(sm, func(r *http.Request) (any, error) {
+		return sm.adminSims(), nil
+	}))
+	mux.HandleFunc("POST /admin/broadcast", adminHandler(sm, func(r *http.Request) (any, error) {
+		var args adminMessageArgs
+		if err := decodeAdminArgs(r, &args); err != nil {
+			return nil, err
+		}
+		sm.broadcast(args.Message)
+		return nil, nil
+	}))
+
+	simHandler := func(f func(sim *Sim, r *http.Request) error) http.HandlerFunc {
+		return adminHandler(sm, func(r *http.Request) (any, error) {
+			sim, err := sm.lookupSim(r.PathValue("sim"))
+			if err != nil {
+				return nil, err
+			}
+			return nil, f(sim, r)
+		})
+	}
+
+	mux.HandleFunc("POST /admin/sims/{sim}/pause", simHandler(func(sim *Sim, r *http.Request) error {
+		sim.adminSetPaused(true)
+		return nil
+	}))
+	mux.HandleFunc("POST /admin/sims/{sim}/resume", simHandler(func(sim *Sim, r *http.Request) error {
+		sim.adminSetPaused(false)
+		return nil
+	}))
+	mux.HandleFunc("POST /admin/sims/{sim}/terminate", simHandler(func(sim *Sim, r *http.Request) error {
+		sm.lg.Infof("%s: terminate requested by admin", sim.Name)
+		sim.terminate()
+		return nil
+	}))
+	mux.HandleFunc("POST /admin/sims/{sim}/rate", simHandler(func(sim *Sim, r *http.Request) error {
+		var args adminRateArgs
+		if err := decodeAdminArgs(r, &args); err != nil {
+			return err
+		} else if args.Rate <= 0 || args.Rate > 20 {
+			return fmt.Errorf("%.2f: sim rate must be between 0 and 20", args.Rate)
+		}
+		sim.adminSetSimRate(args.Rate)
+		return nil
+	}))
+	mux.HandleFunc("POST /admin/sims/{sim}/message", simHandler(func(sim *Sim, r *http.Request) error {
+		var args adminMessageArgs
+		if err := decodeAdminArgs(r, &args); err != nil {
+			return err
+		}
+		sim.postServerMessage(args.Message)
+		return nil
+	}))
+	mux.HandleFunc("POST /admin/sims/{sim}/kick", simHandler(func(sim *Sim, r *http.Request) error {
+		var args adminKickArgs
+		if err := decodeAdminArgs(r, &args); err != nil {
+			return err
+		}
+		sm.lg.Infof("%s: kicking %s at admin request", sim.Name, args.Controller)
+		return sm.kickController(sim, args.Controller)
+	}))
+}
+
+func decodeAdminArgs(r *http.Request, args any) error {
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	return nil
+}
+
+// adminHandler returns a handler that checks that the request is from an
+// admin, calls f, and returns its result or error as JSON.
+func adminHandler(sm *SimManager, f func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if err := sm.authorizeAdmin(token, token); err != nil {
+			sm.lg.Warnf("%s: unauthorized admin request %s %s: %v", r.RemoteAddr, r.Method, r.URL, err)
+			w.WriteHeader(http.StatusForbidden)
+			enc.Encode(adminError{Error: ErrNotAuthorized.Error()})
+			return
+		}
+
+		sm.lg.Infof("%s: admin request %s %s", r.RemoteAddr, r.Method, r.URL)
+		result, err := f(r)
+		if err != nil {
+			w.WriteHeader(util.Select(errors.Is(err, ErrNoNamedSim) || errors.Is(err, av.ErrNoController),
+				http.StatusNotFound, http.StatusBadRequest))
+			enc.Encode(adminError{Error: err.Error()})
+		} else if result != nil {
+			enc.Encode(result)
+		} else {
+			enc.Encode(struct{}{})
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Command-line client
+
+const adminUsage = `usage: vice [options] admin <command>
+commands:
+  list                      list sims and their controllers
+  pause <sim>               pause a sim
+  resume <sim>              resume a paused sim
+  terminate <sim>           end a sim, signing off its controllers
+  rate <sim> <rate>         change a sim's rate
+  kick <sim> <controller>   sign off a controller (or an observer, by name)
+  message <sim> <text>      send a message to a sim's controllers
+  broadcast <text>          send a message to all controllers on the server`
+
+// RunAdminCommand runs the given admin command against the server at the
+// given address, writing its output to w. token is the server admin's
+// access token (or the server password); tlsConfig should be non-nil if
+// the server uses TLS.
+func RunAdminCommand(address string, token string, tlsConfig util.ClientTLSConfig, args []string, w io.Writer) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	adminAddress := net.JoinHostPort(host, strconv.Itoa(ViceHTTPPort))
+	baseURL := util.Select(tlsConfig != nil, "https://", "http://") + adminAddress + "/admin"
+
+	transport := &http.Transport{}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig(adminAddress)
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}
+	call := func(method, path string, body any, result any) error {
+		var rd io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			if err != nil {
+				return err
+			}
+			rd = bytes.NewReader(b)
+		}
+		req, err := http.NewRequest(method, baseURL+path, rd)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			var ae adminError
+			if err := json.NewDecoder(resp.Body).Decode(&ae); err != nil || ae.Error == "" {
+				return errors.New(resp.Status)
+			}
+			return errors.New(ae.Error)
+		}
+		if result != nil {
+			return json.NewDecoder(resp.Body).Decode(result)
+		}
+		return nil
+	}
+
+	usage := errors.New(adminUsage)
+	if len(args) == 0 {
+		return usage
+	}
+	simPath := func() string { return "/sims/" + url.PathEscape(args[1]) }
+
+	switch cmd := args[0]; {
+	case cmd == "list" && len(args) == 1:
+		var sims []AdminSim
+		if err := call("GET", "/sims", nil, &sims); err != nil {
+			return err
+		}
+		printAdminSims(sims, w)
+		return nil
+
+	case (cmd == "pause" || cmd == "resume" || cmd == "terminate") && len(args) == 2:
+		return call("POST", simPath()+"/"+cmd, nil, nil)
+
+	case cmd == "rate" && len(args) == 3:
+		rate, err := strconv.ParseFloat(args[2], 32)
+		if err != nil {
+			return fmt.Errorf("%s: invalid rate", args[2])
+		}
+		return call("POST", simPath()+"/rate", adminRateArgs{Rate: float32(rate)}, nil)
+
+	case cmd == "kick" && len(args) == 3:
+		return call("POST", simPath()+"/kick", adminKickArgs{Controller: args[2]}, nil)
+
+	case cmd == "message" && len(args) >= 3:
+		return call("POST", simPath()+"/message", adminMessageArgs{Message: strings.Join(args[2:], " ")}, nil)
+
+	case cmd == "broadcast" && len(args) >= 2:
+		return call("POST", "/broadcast", adminMessageArgs{Message: strings.Join(args[1:], " ")}, nil)
+
+	default:
+		return usage
+	}
+}
+
+func printAdminSims(sims []AdminSim, w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSCENARIO\tSTATE\tRATE\tIDLE\tCONTROLLERS")
+	for _, s := range sims {
+		var ctrls []string
+		for _, c := range s.Controllers {
+			name := util.Select(c.Name != "", c.Name+" (observer)", c.Callsign)
+			if c.LastHeardSeconds > 5 {
+				name += fmt.Sprintf(" [%ds]", c.LastHeardSeconds)
+			}
+			ctrls = append(ctrls, name)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1f\t%s\t%s\n", s.Name, s.Scenario,
+			util.Select(s.Paused, "paused", "running"), s.SimRate,
+			time.Duration(s.IdleSeconds)*time.Second, strings.Join(ctrls, ", "))
+	}
+	tw.Flush()
+}
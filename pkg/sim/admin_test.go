@@ -0,0 +1,128 @@
+// pkg/sim/admin_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+	sm := NewSimManager(nil, nil, nil, &ServerConfig{
+		Users: []ServerUser{
+			{Name: "sam", Token: "admin-token", Role: RoleAdmin},
+			{Name: "lee", Token: "controller-token", Role: RoleController},
+		},
+	}, nil)
+	s := makeTestSim(time.Time{}, nil)
+	s.ScenarioGroup, s.Scenario = "N90", "JFK 31L"
+	s.SimRate = 1
+	sm.activeSims[s.Name] = s
+
+	var nsr NewSimResult
+	if err := sm.New(&NewSimConfiguration{
+		NewSimType:                NewSimJoinRemote,
+		SelectedRemoteSim:         "test",
+		SelectedRemoteSimPosition: "JFK_APP",
+		AuthToken:                 "controller-token",
+	}, &nsr); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	registerAdminHandlers(mux, sm)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	request := func(method, path, token, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	// Only admins are allowed.
+	for _, token := range []string{"", "bogus", "controller-token", "admin-token-x"} {
+		if code, body := request("GET", "/admin/sims", token, ""); code != http.StatusForbidden ||
+			!strings.Contains(body, ErrNotAuthorized.Error()) {
+			t.Errorf("token %q: expected forbidden, got %d %s", token, code, body)
+		}
+	}
+	if code, _ := request("POST", "/admin/sims/test/pause", "controller-token", ""); code != http.StatusForbidden ||
+		s.Paused {
+		t.Errorf("expected controller to be unable to pause, got %d", code)
+	}
+
+	code, body := request("GET", "/admin/sims", "admin-token", "")
+	var sims []AdminSim
+	if err := json.Unmarshal([]byte(body), &sims); err != nil || code != http.StatusOK {
+		t.Fatalf("list: %d %s %v", code, body, err)
+	}
+	if len(sims) != 1 || sims[0].Name != "test" || sims[0].Scenario != "N90/JFK 31L" ||
+		len(sims[0].Controllers) != 1 || sims[0].Controllers[0].Callsign != "JFK_APP" {
+		t.Errorf("unexpected sims %+v", sims)
+	}
+
+	for _, path := range []string{"/admin/sims/nosuch/pause", "/admin/sims/nosuch/terminate"} {
+		if code, _ := request("POST", path, "admin-token", ""); code != http.StatusNotFound {
+			t.Errorf("%s: expected not found, got %d", path, code)
+		}
+	}
+
+	if code, _ := request("POST", "/admin/sims/test/pause", "admin-token", ""); code != http.StatusOK || !s.Paused {
+		t.Errorf("expected sim to be paused, got %d", code)
+	}
+
+	// Sim rates must be reasonable.
+	for _, rate := range []string{`{"rate": 0}`, `{"rate": -1}`, `{"rate": 21}`, `{"rate": "fast"}`, `{`} {
+		if code, _ := request("POST", "/admin/sims/test/rate", "admin-token", rate); code != http.StatusBadRequest {
+			t.Errorf("%s: expected bad request, got %d", rate, code)
+		}
+	}
+	if s.SimRate != 1 {
+		t.Errorf("sim rate changed to %f", s.SimRate)
+	}
+	for body, rate := range map[string]float32{`{"rate": 0.5}`: 0.5, `{"rate": 20}`: 20} {
+		if code, _ := request("POST", "/admin/sims/test/rate", "admin-token", body); code != http.StatusOK ||
+			s.SimRate != rate {
+			t.Errorf("%s: expected rate %f, got %d, %f", body, rate, code, s.SimRate)
+		}
+	}
+
+	// Kicking a controller signs them off.
+	if code, _ := request("POST", "/admin/sims/test/kick", "admin-token", `{"controller": "NY_CTR"}`); code != http.StatusNotFound {
+		t.Errorf("expected not found kicking unknown controller, got %d", code)
+	}
+	if code, body := request("POST", "/admin/sims/test/kick", "admin-token", `{"controller": "JFK_APP"}`); code != http.StatusOK {
+		t.Errorf("kick: %d %s", code, body)
+	}
+	if s.controllerIsSignedIn("JFK_APP") {
+		t.Errorf("JFK_APP should have been signed off")
+	}
+	if _, ok := sm.ControllerTokenToSim(nsr.ControllerToken); ok {
+		t.Errorf("kicked controller's token should no longer be valid")
+	}
+
+	if code, _ := request("POST", "/admin/sims/test/terminate", "admin-token", ""); code != http.StatusOK ||
+		!s.isTerminated() || !sm.SimShouldExit(s) {
+		t.Errorf("expected sim to be terminated, got %d", code)
+	}
+}
@@ -0,0 +1,355 @@
+// pkg/sim/api.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/util"
+
+	"golang.org/x/net/websocket"
+)
+
+// The API is a JSON-RPC 1.0 service served over a WebSocket for clients
+// other than vice itself; see docs/api.md for the protocol. Unlike the
+// gob-encoded RPCs used by vice, its types are part of the documented
+// protocol; any incompatible change to them requires incrementing
+// APIVersion.
+
+const APIVersion = 1
+
+// ViceAPIPort is the conventional port for the API; servers only serve it
+// if it's enabled with -apiport.
+const ViceAPIPort = 9000
+
+// API serves a single connection; it keeps a copy of the world for each
+// controller signed on over the connection so that it only needs to get
+// what has changed from the sim.
+type API struct {
+	sm *SimManager
+	sd *Dispatcher
+
+	// Calls are handled concurrently, so access to worlds is protected
+	// by mu.
+	mu     sync.Mutex
+	worlds map[string]*worldCopy // controller token ->
+}
+
+type APIHelloArgs struct {
+	Version   int    `json:"version"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+type APIHelloResult struct {
+	Version int      `json:"version"`
+	Sims    []APISim `json:"sims"`
+}
+
+type APISim struct {
+	Name                    string   `json:"name"`
+	Group                   string   `json:"group"`
+	Scenario                string   `json:"scenario"`
+	PrimaryController       string   `json:"primary_controller"`
+	RequirePassword         bool     `json:"require_password"`
+	RequireObserverPassword bool     `json:"require_observer_password"`
+	AvailablePositions      []string `json:"available_positions"`
+	CoveredPositions        []string `json:"covered_positions"`
+	Observers               []string `json:"observers"`
+}
+
+type APISignOnArgs struct {
+	Sim       string `json:"sim"`
+	Position  string `json:"position"`
+	Password  string `json:"password,omitempty"`
+	Name      string `json:"name,omitempty"` // observers only
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+type APISignOnResult struct {
+	Token          string `json:"token"`
+	Callsign       string `json:"callsign"`
+	Facility       string `json:"facility"`
+	PrimaryAirport string `json:"primary_airport"`
+}
+
+type APITokenArgs struct {
+	Token string `json:"token"`
+}
+
+type APIUpdate struct {
+	Time     string        `json:"time"` // RFC 3339
+	Paused   bool          `json:"paused"`
+	SimRate  float32       `json:"sim_rate"`
+	Aircraft []APIAircraft `json:"aircraft"`
+	Events   []APIEvent    `json:"events"`
+}
+
+type APIAircraft struct {
+	Callsign string `json:"callsign"`
+	Squawk   string `json:"squawk"`
+	// Mode C altitude in feet; zero if the transponder isn't in mode C.
+	Altitude              int            `json:"altitude"`
+	Position              [2]float32     `json:"position"` // longitude, latitude
+	Heading               int            `json:"heading"`  // magnetic
+	Groundspeed           int            `json:"groundspeed"`
+	Scratchpad            string         `json:"scratchpad,omitempty"`
+	SecondaryScratchpad   string         `json:"secondary_scratchpad,omitempty"`
+	TemporaryAltitude     int            `json:"temporary_altitude,omitempty"`
+	TrackingController    string         `json:"tracking_controller,omitempty"`
+	ControllingController string         `json:"controlling_controller,omitempty"`
+	HandoffController     string         `json:"handoff_controller,omitempty"`
+	FlightPlan            *APIFlightPlan `json:"flight_plan,omitempty"`
+}
+
+type APIFlightPlan struct {
+	Rules            string `json:"rules"`
+	AircraftType     string `json:"aircraft_type"`
+	AssignedSquawk   string `json:"assigned_squawk"`
+	DepartureAirport string `json:"departure_airport"`
+	ArrivalAirport   string `json:"arrival_airport"`
+	Altitude         int    `json:"altitude"`
+	Route            string `json:"route"`
+}
+
+type APIEvent struct {
+	Type           string `json:"type"`
+	Callsign       string `json:"callsign,omitempty"`
+	FromController string `json:"from_controller,omitempty"`
+	ToController   string `json:"to_controller,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+type APICommandsArgs struct {
+	Token    string `json:"token"`
+	Callsign string `json:"callsign"`
+	Commands string `json:"commands"`
+}
+
+type APICommandsResult struct {
+	Error          string `json:"error,omitempty"`
+	RemainingInput string `json:"remaining_input,omitempty"`
+}
+
+func (api *API) Hello(args *APIHelloArgs, result *APIHelloResult) error {
+	if args.Version != APIVersion {
+		return ErrUnsupportedAPIVersion
+	}
+	if _, err := api.sm.config.authenticate(args.AuthToken); err != nil {
+		return err
+	}
+
+	var running map[string]*RemoteSim
+	if err := api.sm.GetRunningSims(0, &running); err != nil {
+		return err
+	}
+
+	result.Version = APIVersion
+	result.Sims = []APISim{}
+	for _, name := range util.SortedMapKeys(running) {
+		rs := running[name]
+		available := util.SortedMapKeys(rs.AvailablePositions)
+		covered := util.SortedMapKeys(rs.CoveredPositions)
+		result.Sims = append(result.Sims, APISim{
+			Name:                    name,
+			Group:                   rs.GroupName,
+			Scenario:                rs.ScenarioName,
+			PrimaryController:       rs.PrimaryController,
+			RequirePassword:         rs.RequirePassword,
+			RequireObserverPassword: rs.RequireObserverPassword,
+			AvailablePositions:      util.Select(available != nil, available, []string{}),
+			CoveredPositions:        util.Select(covered != nil, covered, []string{}),
+			Observers:               util.Select(rs.Observers != nil, rs.Observers, []string{}),
+		})
+	}
+	return nil
+}
+
+func (api *API) SignOn(args *APISignOnArgs, result *APISignOnResult) error {
+	var nsr NewSimResult
+	if err := api.sm.New(&NewSimConfiguration{
+		NewSimType:                NewSimJoinRemote,
+		SelectedRemoteSim:         args.Sim,
+		SelectedRemoteSimPosition: args.Position,
+		RemoteSimPassword:         args.Password,
+		ObserverName:              args.Name,
+		AuthToken:                 args.AuthToken,
+	}, &nsr); err != nil {
+		return err
+	}
+
+	*result = APISignOnResult{
+		Token:          nsr.ControllerToken,
+		Callsign:       nsr.SimState.Callsign,
+		Facility:       nsr.SimState.TRACON,
+		PrimaryAirport: nsr.SimState.PrimaryAirport,
+	}
+	return nil
+}
+
+func (api *API) SignOff(args *APITokenArgs, _ *struct{}) error {
+	api.mu.Lock()
+	delete(api.worlds, args.Token)
+	api.mu.Unlock()
+
+	return api.sd.SignOff(args.Token, nil)
+}
+
+// GetUpdate returns the aircraft as they appear to the controller and the
+// events since the last call.
+func (api *API) GetUpdate(args *APITokenArgs, result *APIUpdate) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	w, ok := api.worlds[args.Token]
+	if !ok {
+		w = &worldCopy{}
+	}
+
+	var wu WorldUpdate
+	if err := api.sd.GetWorldUpdate(&WorldUpdateArgs{ControllerToken: args.Token, AckVersion: w.version}, &wu); err != nil {
+		delete(api.worlds, args.Token)
+		return err
+	}
+	if err := w.apply(&wu); err != nil {
+		// Get everything with the next update.
+		delete(api.worlds, args.Token)
+		return err
+	}
+	api.worlds[args.Token] = w
+
+	*result = APIUpdate{
+		Time:     wu.Time.UTC().Format(time.RFC3339),
+		Paused:   w.state.SimIsPaused,
+		SimRate:  w.state.SimRate,
+		Aircraft: []APIAircraft{},
+		Events:   []APIEvent{},
+	}
+	for _, callsign := range util.SortedMapKeys(w.aircraft) {
+		result.Aircraft = append(result.Aircraft, makeAPIAircraft(w.aircraft[callsign]))
+	}
+	for _, e := range wu.Events {
+		result.Events = append(result.Events, APIEvent{
+			Type:           e.Type.String(),
+			Callsign:       e.Callsign,
+			FromController: e.FromController,
+			ToController:   e.ToController,
+			Message:        e.Message,
+		})
+	}
+	return nil
+}
+
+func makeAPIAircraft(ac *av.Aircraft) APIAircraft {
+	aac := APIAircraft{
+		Callsign:              ac.Callsign,
+		Squawk:                ac.Squawk.String(),
+		Position:              ac.Position(),
+		Heading:               int(ac.Heading() + 0.5),
+		Groundspeed:           int(ac.GS() + 0.5),
+		Scratchpad:            ac.Scratchpad,
+		SecondaryScratchpad:   ac.SecondaryScratchpad,
+		TemporaryAltitude:     ac.TempAltitude,
+		TrackingController:    ac.TrackingController,
+		ControllingController: ac.ControllingController,
+		HandoffController:     ac.HandoffTrackController,
+	}
+	if ac.Mode == av.Charlie {
+		aac.Altitude = int(ac.Altitude() + 0.5)
+	}
+	if fp := ac.FlightPlan; fp != nil {
+		aac.FlightPlan = &APIFlightPlan{
+			Rules:            fp.Rules.String(),
+			AircraftType:     fp.AircraftType,
+			AssignedSquawk:   fp.AssignedSquawk.String(),
+			DepartureAirport: fp.DepartureAirport,
+			ArrivalAirport:   fp.ArrivalAirport,
+			Altitude:         fp.Altitude,
+			Route:            fp.Route,
+		}
+	}
+	return aac
+}
+
+// RunAircraftCommands runs commands with the same syntax as vice's
+// command entry, e.g. "D40 S210" to descend to 4,000' and reduce speed to
+// 210 knots.
+func (api *API) RunAircraftCommands(args *APICommandsArgs, result *APICommandsResult) error {
+	var acr AircraftCommandsResult
+	if err := api.sd.RunAircraftCommands(&AircraftCommandsArgs{
+		ControllerToken: args.Token,
+		Callsign:        args.Callsign,
+		Commands:        args.Commands,
+	}, &acr); err != nil {
+		return err
+	}
+	*result = APICommandsResult{Error: acr.ErrorMessage, RemainingInput: acr.RemainingInput}
+	return nil
+}
+
+// apiHandshake accepts connections from clients that aren't browsers,
+// which don't send an Origin header, and from pages served by the same
+// host; it keeps arbitrary web pages a user visits from connecting to the
+// API.
+func apiHandshake(config *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != req.Host {
+		return errors.New(origin.String() + ": origin not allowed")
+	}
+	config.Origin = origin
+	return nil
+}
+
+// makeAPIHandler returns the handler for the API's WebSocket endpoint.
+func makeAPIHandler(sm *SimManager, lg *log.Logger) http.Handler {
+	return websocket.Server{
+		Handshake: apiHandshake,
+		Handler: func(ws *websocket.Conn) {
+			lg.Infof("%s: new API connection", ws.Request().RemoteAddr)
+
+			server := rpc.NewServer()
+			api := &API{sm: sm, sd: &Dispatcher{sm: sm}, worlds: make(map[string]*worldCopy)}
+			if err := server.RegisterName("Vice", api); err != nil {
+				lg.Errorf("unable to register API: %v", err)
+				return
+			}
+			server.ServeCodec(jsonrpc.NewServerCodec(ws))
+		},
+	}
+}
+
+// launchAPIServer serves the API at /api/v1 on the given port. If
+// tlsConfig is non-nil, clients may connect using TLS (wss://); if
+// requireTLS is set, they must.
+func launchAPIServer(sm *SimManager, port int, tlsConfig *tls.Config, requireTLS bool, lg *log.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle(fmt.Sprintf("/api/v%d", APIVersion), makeAPIHandler(sm, lg))
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		lg.Errorf("API server: %v", err)
+		return
+	}
+	if tlsConfig != nil {
+		l = util.MakeTLSListener(l, tlsConfig, requireTLS, lg)
+	}
+
+	lg.Infof("Serving API on port %d", port)
+	if err := http.Serve(l, mux); err != nil {
+		lg.Errorf("API server: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+// pkg/sim/api_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"net/http/httptest"
+	"net/rpc/jsonrpc"
+	"strings"
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestAPILoopback(t *testing.T) {
+	ac := &av.Aircraft{Callsign: "AAL1", Squawk: 0o1234, Mode: av.Charlie}
+	ac.Nav.FlightState = av.FlightState{Position: math.Point2LL{-73, 40}, Altitude: 5000, Heading: 90, GS: 250}
+	s := makeTestSim(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), nil)
+	s.State.Aircraft[ac.Callsign] = ac
+	sm := NewSimManager(nil, nil, nil, nil, nil)
+	sm.activeSims[s.Name] = s
+
+	srv := httptest.NewServer(makeAPIHandler(sm, nil))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// Browsers on other sites can't connect.
+	if _, err := websocket.Dial(url, "", "http://example.com"); err == nil {
+		t.Errorf("expected connection from another origin to be refused")
+	}
+
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := jsonrpc.NewClient(ws)
+	defer client.Close()
+
+	var hello APIHelloResult
+	if err := client.Call("Vice.Hello", &APIHelloArgs{Version: APIVersion + 1}, &hello); err == nil ||
+		err.Error() != ErrUnsupportedAPIVersion.Error() {
+		t.Errorf("expected unsupported version error, got %v", err)
+	}
+	if err := client.Call("Vice.Hello", &APIHelloArgs{Version: APIVersion}, &hello); err != nil {
+		t.Fatal(err)
+	}
+	if len(hello.Sims) != 1 || hello.Sims[0].Name != "test" || len(hello.Sims[0].AvailablePositions) != 1 ||
+		hello.Sims[0].AvailablePositions[0] != "JFK_APP" {
+		t.Errorf("unexpected sims %+v", hello.Sims)
+	}
+
+	var so APISignOnResult
+	if err := client.Call("Vice.SignOn", &APISignOnArgs{Sim: "test", Position: "JFK_APP"}, &so); err != nil {
+		t.Fatal(err)
+	}
+	if so.Token == "" || so.Callsign != "JFK_APP" {
+		t.Errorf("unexpected sign on result %+v", so)
+	}
+
+	getUpdate := func() APIUpdate {
+		t.Helper()
+		var u APIUpdate
+		if err := client.Call("Vice.GetUpdate", &APITokenArgs{Token: so.Token}, &u); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	u := getUpdate()
+	if len(u.Aircraft) != 1 || u.Aircraft[0].Callsign != "AAL1" || u.Aircraft[0].Altitude != 5000 ||
+		u.Aircraft[0].Squawk != "1234" {
+		t.Errorf("unexpected aircraft %+v", u.Aircraft)
+	}
+	if u.Time != "2024-06-01T12:00:00Z" {
+		t.Errorf("unexpected time %q", u.Time)
+	}
+
+	// Later updates are deltas that are applied to the connection's copy
+	// of the world, so unchanged aircraft are still reported.
+	s.mu.Lock(s.lg)
+	ac.Nav.FlightState.Altitude = 4000
+	s.State.Aircraft["UAL2"] = &av.Aircraft{Callsign: "UAL2", Squawk: 0o4321}
+	s.worldChanged = true // as when the sim is updated
+	s.mu.Unlock(s.lg)
+	u = getUpdate()
+	if len(u.Aircraft) != 2 || u.Aircraft[0].Altitude != 4000 || u.Aircraft[1].Callsign != "UAL2" {
+		t.Errorf("unexpected aircraft after delta %+v", u.Aircraft)
+	}
+	u = getUpdate()
+	if len(u.Aircraft) != 2 || u.Aircraft[0].Callsign != "AAL1" {
+		t.Errorf("unexpected aircraft after empty delta %+v", u.Aircraft)
+	}
+
+	if err := client.Call("Vice.SignOff", &APITokenArgs{Token: so.Token}, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	var bad APIUpdate
+	if err := client.Call("Vice.GetUpdate", &APITokenArgs{Token: so.Token}, &bad); err == nil {
+		t.Errorf("expected error getting updates after signing off")
+	}
+}
@@ -0,0 +1,145 @@
+// pkg/sim/auth.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Role determines what a user of the multi-controller server may do; each
+// role may also do everything that the roles before it may.
+type Role int
+
+const (
+	// RoleObserver users may watch sims but not change them.
+	RoleObserver Role = iota
+	// RoleController users may sign on to positions and control traffic.
+	RoleController
+	// RoleInstructor users may also manage sims: pause them, change the
+	// sim rate, launch aircraft, add weather and NOTAMs, and so forth.
+	RoleInstructor
+	// RoleAdmin users may also send server broadcast messages.
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	return []string{"observer", "controller", "instructor", "admin"}[r]
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	if r < RoleObserver || r > RoleAdmin {
+		return nil, fmt.Errorf("%d: unknown role", r)
+	}
+	return []byte(`"` + r.String() + `"`), nil
+}
+
+func (r *Role) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case `"observer"`:
+		*r = RoleObserver
+	case `"controller"`:
+		*r = RoleController
+	case `"instructor"`:
+		*r = RoleInstructor
+	case `"admin"`:
+		*r = RoleAdmin
+	default:
+		return fmt.Errorf("%s: unknown role", string(b))
+	}
+	return nil
+}
+
+// ServerConfig restricts access to a multi-controller server. It is
+// loaded from the JSON file given with the -serverconfig option; without
+// one, anyone may connect, create sims, and manage them.
+type ServerConfig struct {
+	Users []ServerUser `json:"users"`
+	// Names of the users who may create sims. If empty, instructors and
+	// admins may.
+	SimCreators []string `json:"sim_creators"`
+	// If non-empty, the only TRACONs and ARTCCs that sims may be created
+	// for.
+	Facilities []string `json:"facilities"`
+}
+
+// ServerUser is a user of the server; clients identify themselves by
+// providing the user's token.
+type ServerUser struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  Role   `json:"role"`
+}
+
+// anonymousUser is used for everyone when the server has no
+// configuration.
+var anonymousUser = ServerUser{Name: "anonymous", Role: RoleInstructor}
+
+func LoadServerConfig(filename string) (*ServerConfig, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ServerConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	tokens := make(map[string]string)
+	for _, u := range config.Users {
+		if u.Name == "" {
+			return nil, fmt.Errorf("%s: user must have a \"name\"", filename)
+		}
+		if u.Token == "" {
+			return nil, fmt.Errorf("%s: %s: user must have a \"token\"", filename, u.Name)
+		}
+		if other, ok := tokens[u.Token]; ok {
+			return nil, fmt.Errorf("%s: %s and %s have the same token", filename, u.Name, other)
+		}
+		tokens[u.Token] = u.Name
+	}
+	for _, name := range config.SimCreators {
+		if !slices.ContainsFunc(config.Users, func(u ServerUser) bool { return u.Name == name }) {
+			return nil, fmt.Errorf("%s: %s: unknown user in \"sim_creators\"", filename, name)
+		}
+	}
+
+	return &config, nil
+}
+
+// authenticate returns the user with the given token. Tokens are compared
+// in constant time so that response times don't reveal anything about
+// them.
+func (c *ServerConfig) authenticate(token string) (ServerUser, error) {
+	if c == nil {
+		return anonymousUser, nil
+	}
+	if token != "" {
+		for _, u := range c.Users {
+			if subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 {
+				return u, nil
+			}
+		}
+	}
+	return ServerUser{}, ErrNotAuthorized
+}
+
+func (c *ServerConfig) canCreateSims(u ServerUser) bool {
+	if c == nil || u.Role == RoleAdmin {
+		return true
+	} else if len(c.SimCreators) == 0 {
+		return u.Role >= RoleInstructor
+	} else {
+		return slices.Contains(c.SimCreators, u.Name)
+	}
+}
+
+func (c *ServerConfig) facilityAvailable(facility string) bool {
+	return c == nil || len(c.Facilities) == 0 || slices.Contains(c.Facilities, facility)
+}
@@ -0,0 +1,74 @@
+// pkg/sim/auth_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerConfig(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "server.json")
+	if err := os.WriteFile(fn, []byte(`{
+  "users": [
+    {"name": "sam", "token": "a", "role": "admin"},
+    {"name": "pat", "token": "b", "role": "instructor"},
+    {"name": "lee", "token": "c", "role": "controller"}
+  ],
+  "facilities": ["N90"]
+}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadServerConfig(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := config.authenticate(""); err != ErrNotAuthorized {
+		t.Errorf("expected empty token to be refused, got %v", err)
+	}
+	if _, err := config.authenticate("x"); err != ErrNotAuthorized {
+		t.Errorf("expected unknown token to be refused, got %v", err)
+	}
+
+	for _, test := range []struct {
+		token     string
+		role      Role
+		canCreate bool
+	}{
+		{"a", RoleAdmin, true},
+		{"b", RoleInstructor, true},
+		{"c", RoleController, false},
+	} {
+		u, err := config.authenticate(test.token)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.token, err)
+		} else if u.Role != test.role {
+			t.Errorf("%s: expected role %s, got %s", test.token, test.role, u.Role)
+		} else if config.canCreateSims(u) != test.canCreate {
+			t.Errorf("%s: expected canCreateSims %v", test.token, test.canCreate)
+		}
+	}
+
+	config.SimCreators = []string{"lee"}
+	if u, _ := config.authenticate("b"); config.canCreateSims(u) {
+		t.Errorf("instructor not in sim_creators allowed to create sims")
+	}
+	if u, _ := config.authenticate("c"); !config.canCreateSims(u) {
+		t.Errorf("controller in sim_creators not allowed to create sims")
+	}
+
+	if !config.facilityAvailable("N90") || config.facilityAvailable("PHL") {
+		t.Errorf("facilities not respected")
+	}
+
+	// Without a configuration, anyone may do anything but admin things.
+	var none *ServerConfig
+	if u, err := none.authenticate(""); err != nil || u.Role != RoleInstructor || !none.canCreateSims(u) {
+		t.Errorf("unexpected anonymous user %+v, err %v", u, err)
+	}
+}
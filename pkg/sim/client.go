@@ -19,7 +19,8 @@ import (
 )
 
 type ControlClient struct {
-	proxy *proxy
+	proxy          *proxy
+	reconnectToken string
 
 	lg *log.Logger
 
@@ -27,6 +28,11 @@ type ControlClient struct {
 	lastReturnedTime  time.Time
 	updateCall        *util.PendingCall
 
+	// The world as of the most recent update from the server.
+	world worldCopy
+
+	extrapolated map[string]*extrapolatedTrack
+
 	pendingCalls []*util.PendingCall
 
 	scopeDraw struct {
@@ -45,7 +51,8 @@ func (c *ControlClient) RPCClient() *util.RPCClient {
 	return c.proxy.Client
 }
 
-func NewControlClient(ss State, controllerToken string, client *util.RPCClient, lg *log.Logger) *ControlClient {
+func NewControlClient(ss State, controllerToken string, reconnectToken string, client *util.RPCClient,
+	lg *log.Logger) *ControlClient {
 	return &ControlClient{
 		State: ss,
 		lg:    lg,
@@ -53,10 +60,32 @@ func NewControlClient(ss State, controllerToken string, client *util.RPCClient,
 			ControllerToken: controllerToken,
 			Client:          client,
 		},
+		reconnectToken:    reconnectToken,
 		lastUpdateRequest: time.Now(),
 	}
 }
 
+// canReconnect indicates whether the client can re-attach to its position
+// if its connection to the server is lost.
+func (c *ControlClient) canReconnect() bool {
+	return c.SimName != "" && c.reconnectToken != ""
+}
+
+// reattach switches the client to a new connection to the server after a
+// successful call to SimManager.Reconnect. Calls that were pending on the
+// old connection are abandoned and the next update gets the entire world.
+func (c *ControlClient) reattach(result *NewSimResult, client *util.RPCClient) {
+	c.proxy = &proxy{
+		ControllerToken: result.ControllerToken,
+		Client:          client,
+	}
+	c.State = *result.SimState
+	c.world = worldCopy{}
+	c.updateCall = nil
+	c.pendingCalls = nil
+	c.lastUpdateRequest = time.Now()
+}
+
 func (c *ControlClient) Status() string {
 	if c == nil || c.SimDescription == "" {
 		return "[disconnected]"
@@ -415,7 +444,7 @@ func (c *ControlClient) GetUpdates(eventStream *EventStream, onErr func(error))
 
 		wu := &WorldUpdate{}
 		c.updateCall = &util.PendingCall{
-			Call:      c.proxy.GetWorldUpdate(wu),
+			Call:      c.proxy.GetWorldUpdate(c.world.version, wu),
 			IssueTime: time.Now(),
 			OnSuccess: func(any) {
 				d := time.Since(c.updateCall.IssueTime)
@@ -432,20 +461,14 @@ func (c *ControlClient) GetUpdates(eventStream *EventStream, onErr func(error))
 }
 
 func (c *ControlClient) UpdateWorld(wu *WorldUpdate, eventStream *EventStream) {
-	c.State.Aircraft = wu.Aircraft
-	if wu.Controllers != nil {
-		c.State.Controllers = wu.Controllers
+	if err := c.applyWorldUpdate(wu); err != nil {
+		// Get everything with the next update.
+		c.lg.Warnf("%v; requesting full world update", err)
+		c.world.version = 0
 	}
-	c.State.ERAMComputers = wu.ERAMComputers
-
-	c.State.LaunchConfig = wu.LaunchConfig
 
 	c.State.SimTime = wu.Time
-	c.State.SimIsPaused = wu.SimIsPaused
-	c.State.SimRate = wu.SimRate
-	c.State.TotalDepartures = wu.TotalDepartures
-	c.State.TotalArrivals = wu.TotalArrivals
-	c.State.TotalOverflights = wu.TotalOverflights
+	c.updateExtrapolation(wu.Time)
 
 	// Important: do this after updating aircraft, controllers, etc.,
 	// so that they reflect any changes the events are flagging.
@@ -517,6 +540,143 @@ func (c *ControlClient) SetLaunchConfig(lc LaunchConfig) {
 	c.LaunchConfig = lc // for the UI's benefit...
 }
 
+func (c *ControlClient) AddWeatherCell(cell av.WeatherCell, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.AddWeatherCell(cell),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) DeleteWeatherCell(id string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.DeleteWeatherCell(id),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) AddNOTAM(n NOTAM, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.AddNOTAM(n),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) DeleteNOTAM(id string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.DeleteNOTAM(id),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) RequestRelease(callsign string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.RequestRelease(callsign),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+// ApproveRelease releases the departure; if void is non-zero, the release
+// is void if the aircraft isn't airborne within that time.
+func (c *ControlClient) ApproveRelease(callsign string, void time.Duration, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.ApproveRelease(callsign, void),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) DelayRelease(callsign string, delay time.Duration, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.DelayRelease(callsign, delay),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) DenyRelease(callsign string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.DenyRelease(callsign),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+// ClearForTakeoff is used by a human tower controller to clear a
+// departure holding short of the runway for takeoff.
+func (c *ControlClient) ClearForTakeoff(callsign string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.ClearForTakeoff(callsign),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+// MoveFlightStrip puts the controller's strip for the aircraft in the
+// given bay before the index'th strip there, or at the end if index is
+// negative; the strip is added if the controller doesn't have one yet.
+func (c *ControlClient) MoveFlightStrip(callsign string, bay StripBay, index int, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.MoveFlightStrip(callsign, bay, index),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+// AddFlightStrips adds strips for the aircraft at the end of the given bay
+// with a single request; aircraft that already have strips are skipped.
+func (c *ControlClient) AddFlightStrips(callsigns []string, bay StripBay, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.AddFlightStrips(callsigns, bay),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) RemoveFlightStrip(callsign string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.RemoveFlightStrip(callsign),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) PushFlightStrip(callsign, toController string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.PushFlightStrip(callsign, toController),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) AcknowledgeFlightStrip(callsign string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.AcknowledgeFlightStrip(callsign),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) AddTMI(t TMI, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.AddTMI(t),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
+func (c *ControlClient) DeleteTMI(id string, onErr func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.DeleteTMI(id),
+		IssueTime: time.Now(),
+		OnErr:     onErr,
+	})
+}
+
 // CurrentTime returns an extrapolated value that models the current Sim's time.
 // (Because the Sim may be running remotely, we have to make some approximations,
 // though they shouldn't cause much trouble since we get an update from the Sim
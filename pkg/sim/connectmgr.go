@@ -21,27 +21,50 @@ type ConnectionManager struct {
 
 	newSimConnectionChan     chan Connection
 	serverRPCVersionMismatch bool
+	serverNotAuthorized      bool
 
 	localServer   *Server
 	remoteServer  *Server
 	serverAddress string
+	authToken     string
+	tlsConfig     util.ClientTLSConfig // nil if not using TLS
+
+	// We only listen for server announcements while someone is looking
+	// at the discovered servers so that multiple instances of vice on a
+	// computer don't contend for the discovery port.
+	discovery            *ServerDiscovery // nil when not listening
+	lastDiscoveryAttempt time.Time
+	lastDiscoveryRequest time.Time
 
 	client              *ControlClient
 	connectionStartTime time.Time
 
+	// When the connection to a remote sim is lost, we keep the client
+	// around and try to re-attach to its position until the server's
+	// grace period runs out. reconnectStart is zero when we're not
+	// reconnecting.
+	reconnectStart time.Time
+	reconnectCall  *util.PendingCall
+
 	onNewClient func(*ControlClient)
 	onError     func(error)
+
+	lg *log.Logger
 }
 
-func MakeServerConnection(address, additionalScenario, additionalVideoMap string, lg *log.Logger,
-	onNewClient func(*ControlClient), onError func(error)) (*ConnectionManager, error) {
+func MakeServerConnection(address, authToken string, tlsConfig util.ClientTLSConfig, additionalScenario,
+	additionalVideoMap string, lg *log.Logger, onNewClient func(*ControlClient),
+	onError func(error)) (*ConnectionManager, error) {
 	cm := &ConnectionManager{
 		serverAddress:           address,
+		authToken:               authToken,
+		tlsConfig:               tlsConfig,
 		lastRemoteServerAttempt: time.Now(),
-		remoteSimServerChan:     TryConnectRemoteServer(address, lg),
+		remoteSimServerChan:     TryConnectRemoteServer(address, authToken, tlsConfig, lg),
 		newSimConnectionChan:    make(chan Connection, 2),
 		onNewClient:             onNewClient,
 		onError:                 onError,
+		lg:                      lg,
 	}
 
 	var err error
@@ -63,8 +86,9 @@ func (cm *ConnectionManager) LoadLocalSim(s *Sim, lg *log.Logger) error {
 		return err
 	}
 
-	cm.client = NewControlClient(*result.SimState, result.ControllerToken,
+	cm.client = NewControlClient(*result.SimState, result.ControllerToken, result.ReconnectToken,
 		cm.localServer.RPCClient, lg)
+	cm.reconnectStart, cm.reconnectCall = time.Time{}, nil
 	cm.connectionStartTime = time.Now()
 	if cm.onNewClient != nil {
 		cm.onNewClient(cm.client)
@@ -93,7 +117,52 @@ func (cm *ConnectionManager) ClientIsLocal() bool {
 	return cm.client != nil && cm.client.RPCClient() == cm.localServer.RPCClient
 }
 
+// DiscoveredServers returns the servers that have announced themselves on
+// the local network. The first call starts listening for announcements;
+// listening stops when it hasn't been called for a while.
+func (cm *ConnectionManager) DiscoveredServers() []DiscoveredServer {
+	cm.lastDiscoveryRequest = time.Now()
+	if cm.discovery == nil && time.Since(cm.lastDiscoveryAttempt) > announceInterval {
+		cm.lastDiscoveryAttempt = time.Now()
+		cm.discovery = StartServerDiscovery(cm.lg)
+	}
+	return cm.discovery.Servers()
+}
+
+func (cm *ConnectionManager) ServerAddress() string {
+	return cm.serverAddress
+}
+
+// UseServer switches to the multi-controller server at the given address;
+// the connection is made the next time Update is called.
+func (cm *ConnectionManager) UseServer(address string) {
+	if cm.remoteServer != nil && (cm.client == nil || cm.client.RPCClient() != cm.remoteServer.RPCClient) {
+		cm.remoteServer.Close()
+	}
+	cm.serverAddress = address
+	cm.remoteServer = nil
+	// Close the connection to the previous server if one is in progress.
+	if ch := cm.remoteSimServerChan; ch != nil {
+		go func() {
+			if sc := <-ch; sc.Server != nil {
+				sc.Server.Close()
+			}
+		}()
+	}
+	cm.remoteSimServerChan = nil
+	cm.serverRPCVersionMismatch = false
+	cm.serverNotAuthorized = false
+	cm.lastRemoteServerAttempt = time.Time{}
+}
+
+// Reconnecting indicates whether the connection to the server was lost
+// and the manager is trying to re-attach the client to its sim.
+func (cm *ConnectionManager) Reconnecting() bool {
+	return !cm.reconnectStart.IsZero()
+}
+
 func (cm *ConnectionManager) Disconnect() {
+	cm.reconnectStart, cm.reconnectCall = time.Time{}, nil
 	if cm.client != nil {
 		cm.client.Disconnect()
 		cm.client = nil
@@ -113,15 +182,17 @@ func (cm *ConnectionManager) Update(es *EventStream, lg *log.Logger) {
 		if cm.client != nil {
 			cm.client.Disconnect()
 		}
-		cm.client = NewControlClient(ns.SimState, ns.SimProxy.ControllerToken,
+		cm.client = NewControlClient(ns.SimState, ns.SimProxy.ControllerToken, ns.ReconnectToken,
 			ns.SimProxy.Client, lg)
 		cm.connectionStartTime = time.Now()
+		cm.reconnectStart, cm.reconnectCall = time.Time{}, nil
 
 		if cm.onNewClient != nil {
 			cm.onNewClient(cm.client)
 		}
 
 	case remoteServerConn := <-cm.remoteSimServerChan:
+		cm.remoteSimServerChan = nil
 		if err := remoteServerConn.Err; err != nil {
 			lg.Warn("Unable to connect to remote server", slog.Any("error", err))
 
@@ -130,6 +201,11 @@ func (cm *ConnectionManager) Update(es *EventStream, lg *log.Logger) {
 				if cm.onError != nil {
 					cm.onError(ErrRPCVersionMismatch)
 				}
+			} else if err.Error() == ErrNotAuthorized.Error() {
+				cm.serverNotAuthorized = true
+				if cm.onError != nil {
+					cm.onError(ErrNotAuthorized)
+				}
 			}
 			cm.remoteServer = nil
 		} else {
@@ -139,12 +215,23 @@ func (cm *ConnectionManager) Update(es *EventStream, lg *log.Logger) {
 	default:
 	}
 
-	if cm.remoteServer == nil && time.Since(cm.lastRemoteServerAttempt) > 10*time.Second && !cm.serverRPCVersionMismatch {
+	// Try to reconnect more frequently if we're trying to get back to a
+	// sim.
+	retry := util.Select(cm.Reconnecting(), 2*time.Second, 10*time.Second)
+	if cm.remoteServer == nil && cm.remoteSimServerChan == nil && time.Since(cm.lastRemoteServerAttempt) > retry &&
+		!cm.serverRPCVersionMismatch && !cm.serverNotAuthorized {
 		cm.lastRemoteServerAttempt = time.Now()
-		cm.remoteSimServerChan = TryConnectRemoteServer(cm.serverAddress, lg)
+		cm.remoteSimServerChan = TryConnectRemoteServer(cm.serverAddress, cm.authToken, cm.tlsConfig, lg)
 	}
 
-	if cm.client != nil {
+	if cm.discovery != nil && time.Since(cm.lastDiscoveryRequest) > time.Second {
+		cm.discovery.Stop()
+		cm.discovery = nil
+	}
+
+	if cm.Reconnecting() {
+		cm.updateReconnect(es, lg)
+	} else if cm.client != nil {
 		cm.client.GetUpdates(es,
 			func(err error) {
 				es.Post(Event{
@@ -153,12 +240,17 @@ func (cm *ConnectionManager) Update(es *EventStream, lg *log.Logger) {
 				})
 				if err == ErrRPCTimeout || util.IsRPCServerError(err) {
 					cm.remoteServer = nil
-					cm.client = nil
-					if cm.onNewClient != nil {
-						cm.onNewClient(nil)
-					}
-					if cm.onError != nil {
-						cm.onError(ErrServerDisconnected)
+					if cm.client.canReconnect() {
+						lg.Warnf("Lost connection to server; trying to reconnect")
+						es.Post(Event{
+							Type:    StatusMessageEvent,
+							Message: "Lost connection to the server. Trying to reconnect...",
+						})
+						cm.reconnectStart = time.Now()
+						// Start trying immediately.
+						cm.lastRemoteServerAttempt = time.Time{}
+					} else {
+						cm.connectionLost()
 					}
 				} else if cm.onError != nil {
 					cm.onError(err)
@@ -166,3 +258,71 @@ func (cm *ConnectionManager) Update(es *EventStream, lg *log.Logger) {
 			})
 	}
 }
+
+// updateReconnect tries to re-attach the client to its sim once we have a
+// new connection to the server.
+func (cm *ConnectionManager) updateReconnect(es *EventStream, lg *log.Logger) {
+	if cm.reconnectCall != nil {
+		if cm.reconnectCall.CheckFinished() {
+			cm.reconnectCall = nil
+		} else if time.Since(cm.reconnectCall.IssueTime) > 5*time.Second {
+			// The new connection isn't working either.
+			cm.reconnectCall = nil
+			cm.remoteServer = nil
+		}
+		return
+	}
+
+	if time.Since(cm.reconnectStart) > reconnectGracePeriod {
+		lg.Warnf("Unable to reconnect to server after %s", time.Since(cm.reconnectStart))
+		cm.connectionLost()
+		return
+	}
+
+	if cm.remoteServer == nil {
+		return
+	}
+
+	var result NewSimResult
+	server := cm.remoteServer
+	cm.reconnectCall = &util.PendingCall{
+		Call: server.Go("SimManager.Reconnect", &ReconnectArgs{
+			SimName:        cm.client.SimName,
+			ReconnectToken: cm.client.reconnectToken,
+		}, &result, nil),
+		IssueTime: time.Now(),
+		OnSuccess: func(any) {
+			lg.Infof("Reconnected to server after %s", time.Since(cm.reconnectStart))
+			cm.client.reattach(&result, server.RPCClient)
+			cm.reconnectStart = time.Time{}
+			es.Post(Event{
+				Type:    StatusMessageEvent,
+				Message: "Reconnected to the server.",
+			})
+		},
+		OnErr: func(err error) {
+			err = TryDecodeError(err)
+			lg.Warnf("Reconnect: %v", err)
+			if err == ErrInvalidReconnectToken || err == ErrNoNamedSim {
+				// The server has given up on us.
+				cm.connectionLost()
+			} else {
+				cm.remoteServer = nil
+			}
+		},
+	}
+}
+
+// connectionLost is called when the connection to the server is gone for
+// good; the user will need to start or join a sim again.
+func (cm *ConnectionManager) connectionLost() {
+	cm.remoteServer = nil
+	cm.client = nil
+	cm.reconnectStart, cm.reconnectCall = time.Time{}, nil
+	if cm.onNewClient != nil {
+		cm.onNewClient(nil)
+	}
+	if cm.onError != nil {
+		cm.onError(ErrServerDisconnected)
+	}
+}
@@ -0,0 +1,547 @@
+// pkg/sim/delta.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/util"
+
+	"github.com/brunoga/deep"
+)
+
+// World updates are sent as deltas: the server keeps snapshots of the
+// most recent versions of the world it has sent, shared by all of the
+// sim's controllers, and the next update only includes what has changed
+// since the version the client acknowledges with its request. A client
+// that doesn't have the version an update is relative to asks for a full
+// update, which is sent relative to an empty world.
+//
+// The world is only checked for changes once after each time the sim is
+// updated, so changes made by controllers' commands are sent with the
+// next update after the sim's next step, and the updates to each version
+// are kept with it so that controllers that have the same version share
+// them.
+
+// Maximum number of world snapshots kept for a sim.
+const maxWorldSnapshots = 8
+
+// WorldState is the part of the world other than the aircraft that is
+// sent to clients.
+type WorldState struct {
+	Controllers      map[string]*av.Controller
+	ERAMComputers    *ERAMComputers
+	LaunchConfig     LaunchConfig
+	SimIsPaused      bool
+	SimRate          float32
+	TotalDepartures  int
+	TotalArrivals    int
+	TotalOverflights int
+	WeatherCells     []av.WeatherCell
+	NOTAMs           []NOTAM
+	TMIs             []TMI
+	Releases         []DepartureRelease
+	Surface          []SurfaceTarget
+	FlightStrips     []BayStrip
+	Metering         map[string]MeteredArrival
+	METAR            map[string]*av.METAR
+	ATIS             map[string]av.ATIS
+	NavSummaries     map[string]string
+}
+
+// AircraftDelta holds the fields of an aircraft that have changed; Fields
+// gives their paths (e.g., "Squawk", "Nav.FlightState.Altitude", or
+// `STARRunwayWaypoints["22L"]`) and only those fields are set in Aircraft.
+type AircraftDelta struct {
+	Aircraft av.Aircraft
+	Fields   []string
+}
+
+// worldSnapshot is a copy of the world as it was sent to a client.
+type worldSnapshot struct {
+	version  uint64
+	aircraft map[string]*av.Aircraft
+	state    WorldState
+	// Updates to this version, by the version they are relative to.
+	updates map[uint64]WorldUpdate
+}
+
+// currentWorld returns the world as it is sent to controllers. Aircraft
+// are redacted so that the pilots' intentions stay on the server; the
+// exception is that while the sim is paused, a summary of each aircraft's
+// navigation state is included so that it can be inspected. The returned
+// snapshot shares state with the sim and has no version.
+//
+// Every controller is sent the same view. Redaction removes what only the
+// pilot knows; what's left is what the radar and flight data systems make
+// available at every position, and the scope decides how to show it for
+// the controller's position (e.g., which datablock is drawn depends on who
+// is tracking the aircraft). A shared view also lets the snapshots and
+// updates be shared between controllers.
+func (s *Sim) currentWorld() *worldSnapshot {
+	redacted := make(map[string]*av.Aircraft, len(s.State.Aircraft))
+	var navSummaries map[string]string
+	for callsign, ac := range s.State.Aircraft {
+		redacted[callsign] = ac.Redacted()
+		if s.Paused && ac.FlightPlan != nil {
+			if navSummaries == nil {
+				navSummaries = make(map[string]string)
+			}
+			navSummaries[callsign] = ac.NavSummary(s.lg)
+		}
+	}
+
+	return &worldSnapshot{aircraft: redacted, state: WorldState{
+		Controllers:      s.State.Controllers,
+		ERAMComputers:    s.State.ERAMComputers,
+		LaunchConfig:     s.LaunchConfig,
+		SimIsPaused:      s.Paused,
+		SimRate:          s.SimRate,
+		TotalDepartures:  s.TotalDepartures,
+		TotalArrivals:    s.TotalArrivals,
+		TotalOverflights: s.TotalOverflights,
+		WeatherCells:     s.State.WeatherCells,
+		NOTAMs:           s.State.NOTAMs,
+		TMIs:             s.State.TMIs,
+		Releases:         s.State.DepartureReleases,
+		Surface:          s.State.SurfaceTargets,
+		FlightStrips:     s.State.FlightStrips,
+		Metering:         s.State.Metering,
+		METAR:            s.State.METAR,
+		ATIS:             s.State.ATIS,
+		NavSummaries:     navSummaries,
+	}}
+}
+
+// worldSnapshot returns a snapshot of the current world. If the sim
+// hasn't been updated or nothing has changed since the most recent
+// snapshot, it is returned rather than making a new copy; otherwise the
+// world is copied into a new version.
+func (s *Sim) worldSnapshot() (*worldSnapshot, error) {
+	n := len(s.worldSnapshots)
+	if n > 0 && !s.worldChanged {
+		return s.worldSnapshots[n-1], nil
+	}
+	s.worldChanged = false
+
+	cur := s.currentWorld()
+	if n > 0 {
+		if latest := s.worldSnapshots[n-1]; makeWorldUpdate(latest, cur).empty() {
+			return latest, nil
+		}
+	}
+
+	aircraft, err := deep.Copy(cur.aircraft)
+	if err != nil {
+		return nil, err
+	}
+	state, err := deep.Copy(cur.state)
+	if err != nil {
+		return nil, err
+	}
+
+	s.worldVersion++
+	ws := &worldSnapshot{version: s.worldVersion, aircraft: aircraft, state: state}
+	s.worldSnapshots = append(s.worldSnapshots, ws)
+	if n := len(s.worldSnapshots); n > maxWorldSnapshots {
+		s.worldSnapshots = s.worldSnapshots[n-maxWorldSnapshots:]
+	}
+	return ws, nil
+}
+
+// worldUpdate returns the update from the given version of the world to
+// the current one.
+func (s *Sim) worldUpdate(ack uint64) (WorldUpdate, error) {
+	ws, err := s.worldSnapshot()
+	if err != nil {
+		return WorldUpdate{}, err
+	}
+
+	base := s.lookupWorldSnapshot(ack)
+	if base == nil {
+		ack = 0
+	}
+	if wu, ok := ws.updates[ack]; ok {
+		return wu, nil
+	}
+	wu := makeWorldUpdate(base, ws)
+	if ws.updates == nil {
+		ws.updates = make(map[uint64]WorldUpdate)
+	}
+	ws.updates[ack] = wu
+	return wu, nil
+}
+
+// lookupWorldSnapshot returns the snapshot for the given version of the
+// world, or nil if a full update should be sent.
+func (s *Sim) lookupWorldSnapshot(version uint64) *worldSnapshot {
+	if idx := slices.IndexFunc(s.worldSnapshots, func(ws *worldSnapshot) bool { return ws.version == version }); version != 0 && idx != -1 {
+		return s.worldSnapshots[idx]
+	}
+	return nil
+}
+
+// makeWorldUpdate returns an update with the changes from base to ws; if
+// base is nil, the update has everything.
+func makeWorldUpdate(base, ws *worldSnapshot) WorldUpdate {
+	if base == nil {
+		base = &worldSnapshot{}
+	}
+	wu := WorldUpdate{
+		Version:     ws.version,
+		BaseVersion: base.version,
+	}
+
+	for callsign, ac := range ws.aircraft {
+		if bac, ok := base.aircraft[callsign]; !ok {
+			if wu.AddedAircraft == nil {
+				wu.AddedAircraft = make(map[string]*av.Aircraft)
+			}
+			wu.AddedAircraft[callsign] = ac
+		} else {
+			var d AircraftDelta
+			if d.Fields = diffFields(reflect.ValueOf(bac).Elem(), reflect.ValueOf(ac).Elem(),
+				reflect.ValueOf(&d.Aircraft).Elem(), ""); len(d.Fields) > 0 {
+				if wu.ChangedAircraft == nil {
+					wu.ChangedAircraft = make(map[string]AircraftDelta)
+				}
+				wu.ChangedAircraft[callsign] = d
+			}
+		}
+	}
+	for callsign := range base.aircraft {
+		if _, ok := ws.aircraft[callsign]; !ok {
+			wu.RemovedAircraft = append(wu.RemovedAircraft, callsign)
+		}
+	}
+
+	wu.StateFields = diffFields(reflect.ValueOf(&base.state).Elem(), reflect.ValueOf(&ws.state).Elem(),
+		reflect.ValueOf(&wu.State).Elem(), "")
+
+	return wu
+}
+
+func (wu WorldUpdate) empty() bool {
+	return len(wu.AddedAircraft) == 0 && len(wu.ChangedAircraft) == 0 && len(wu.RemovedAircraft) == 0 &&
+		len(wu.StateFields) == 0
+}
+
+// diffFields compares the exported fields of the structs a and b. Fields
+// that differ are copied from b to the corresponding field of d and their
+// paths are returned.
+func diffFields(a, b, d reflect.Value, prefix string) []string {
+	var fields []string
+	t := a.Type()
+	for i := range t.NumField() {
+		if f := t.Field(i); f.IsExported() {
+			fields = append(fields, diffValues(a.Field(i), b.Field(i), d.Field(i), prefix+f.Name)...)
+		}
+	}
+	return fields
+}
+
+// diffValues compares a and b, copying what differs to d and returning
+// the paths of the differences. Structs, pointers to structs, and maps
+// are compared element by element so that, for example, only the parts
+// of an aircraft's navigation state or the ERAM computers' flight plans
+// that changed are sent. Map elements are given in paths as quoted keys
+// in brackets; a path to a map element that isn't in d's map indicates
+// that it was removed.
+func diffValues(a, b, d reflect.Value, path string) []string {
+	switch {
+	case a.Kind() == reflect.Struct && diffStructFields(a.Type()):
+		return diffFields(a, b, d, path+".")
+
+	case a.Kind() == reflect.Pointer && !a.IsNil() && !b.IsNil() && diffStructFields(a.Type().Elem()):
+		d.Set(reflect.New(a.Type().Elem()))
+		fields := diffFields(a.Elem(), b.Elem(), d.Elem(), path+".")
+		if len(fields) == 0 {
+			d.SetZero()
+		}
+		return fields
+
+	case a.Kind() == reflect.Map && !a.IsNil() && !b.IsNil() && mapKeyString(a.Type().Key()):
+		var fields []string
+		iter := b.MapRange()
+		for iter.Next() {
+			k, vb := iter.Key(), iter.Value()
+			kpath := path + "[" + strconv.Quote(formatMapKey(k)) + "]"
+			dv := reflect.New(vb.Type()).Elem()
+			if va := a.MapIndex(k); !va.IsValid() {
+				dv.Set(vb)
+				fields = append(fields, kpath)
+			} else if f := diffValues(va, vb, dv, kpath); len(f) > 0 {
+				fields = append(fields, f...)
+			} else {
+				continue
+			}
+			if d.IsNil() {
+				d.Set(reflect.MakeMap(d.Type()))
+			}
+			d.SetMapIndex(k, dv)
+		}
+		iter = a.MapRange()
+		for iter.Next() {
+			if !b.MapIndex(iter.Key()).IsValid() {
+				fields = append(fields, path+"["+strconv.Quote(formatMapKey(iter.Key()))+"]")
+			}
+		}
+		return fields
+
+	case !reflect.DeepEqual(a.Interface(), b.Interface()):
+		d.Set(b)
+		return []string{path}
+
+	default:
+		return nil
+	}
+}
+
+// diffStructFields returns whether structs of the given type should be
+// compared field by field. Unexported fields aren't sent to clients, so
+// they can be ignored, but types that encode themselves have to be sent
+// whole.
+func diffStructFields(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, m := range []reflect.Type{reflect.TypeFor[gob.GobEncoder](), reflect.TypeFor[encoding.BinaryMarshaler](),
+		reflect.TypeFor[encoding.TextMarshaler]()} {
+		if t.Implements(m) || reflect.PointerTo(t).Implements(m) {
+			return false
+		}
+	}
+	for i := range t.NumField() {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+func mapKeyString(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func formatMapKey(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	default:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+}
+
+func parseMapKey(s string, t reflect.Type) (reflect.Value, error) {
+	k := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		k.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return k, err
+		}
+		k.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return k, err
+		}
+		k.SetUint(v)
+	default:
+		return k, fmt.Errorf("%s: unexpected map key type", t)
+	}
+	return k, nil
+}
+
+// applyFields copies the fields with the given paths from src to dst.
+// The pointers and maps along the way are copied rather than updated in
+// place so that values shared with earlier updates are unchanged.
+func applyFields(dst, src reflect.Value, fields []string) error {
+	copied := make(map[uintptr]bool)
+	for _, path := range fields {
+		if err := applyValue(dst, src, "."+path, copied); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// applyValue copies the value at the given path in src to dst. copied
+// records the pointers and maps that have already been copied.
+func applyValue(d, s reflect.Value, path string, copied map[uintptr]bool) error {
+	if path == "" {
+		d.Set(s)
+		return nil
+	}
+
+	switch d.Kind() {
+	case reflect.Pointer:
+		if d.IsNil() || s.IsNil() {
+			return errors.New("nil pointer in world update")
+		}
+		if !copied[d.Pointer()] {
+			p := reflect.New(d.Type().Elem())
+			p.Elem().Set(d.Elem())
+			d.Set(p)
+			copied[p.Pointer()] = true
+		}
+		return applyValue(d.Elem(), s.Elem(), path, copied)
+
+	case reflect.Struct:
+		name, ok := strings.CutPrefix(path, ".")
+		if !ok {
+			return errors.New("malformed path in world update")
+		}
+		var rest string
+		if i := strings.IndexAny(name, ".["); i != -1 {
+			name, rest = name[:i], name[i:]
+		}
+		df := d.FieldByName(name)
+		if !df.IsValid() || !df.CanSet() {
+			return fmt.Errorf("%s: unknown field in world update", name)
+		}
+		return applyValue(df, s.FieldByName(name), rest, copied)
+
+	case reflect.Map:
+		q, err := strconv.QuotedPrefix(strings.TrimPrefix(path, "["))
+		if err != nil || !strings.HasPrefix(path, "[") || !strings.HasPrefix(path[1+len(q):], "]") {
+			return errors.New("malformed path in world update")
+		}
+		ks, _ := strconv.Unquote(q)
+		k, err := parseMapKey(ks, d.Type().Key())
+		if err != nil {
+			return err
+		}
+		rest := path[2+len(q):]
+
+		if d.IsNil() || !copied[d.Pointer()] {
+			m := reflect.MakeMapWithSize(d.Type(), d.Len()+1)
+			iter := d.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), iter.Value())
+			}
+			d.Set(m)
+			copied[m.Pointer()] = true
+		}
+
+		sv := s.MapIndex(k)
+		if rest == "" {
+			// Removes the element if it's not in src.
+			d.SetMapIndex(k, sv)
+			return nil
+		}
+		dv := d.MapIndex(k)
+		if !dv.IsValid() || !sv.IsValid() {
+			return fmt.Errorf("%s: missing map element in world update", ks)
+		}
+		v := reflect.New(dv.Type()).Elem()
+		v.Set(dv)
+		if err := applyValue(v, sv, rest, copied); err != nil {
+			return err
+		}
+		d.SetMapIndex(k, v)
+		return nil
+
+	default:
+		return errors.New("malformed path in world update")
+	}
+}
+
+// worldCopy is a client's copy of the world, which is kept up to date by
+// applying world updates. Its version is zero if the next update should
+// include everything.
+type worldCopy struct {
+	version  uint64
+	aircraft map[string]*av.Aircraft
+	state    WorldState
+}
+
+// apply applies the changes in the update to the copy of the world.
+// Aircraft that have changed are copied rather than updated in place so
+// that the aircraft from earlier updates are unchanged.
+func (w *worldCopy) apply(wu *WorldUpdate) error {
+	aircraft := w.aircraft
+	state := w.state
+	if wu.BaseVersion == 0 {
+		aircraft, state = nil, WorldState{}
+	} else if wu.BaseVersion != w.version {
+		return fmt.Errorf("world update is relative to version %d but have %d", wu.BaseVersion, w.version)
+	}
+
+	updated := make(map[string]*av.Aircraft, len(aircraft)+len(wu.AddedAircraft))
+	for callsign, ac := range aircraft {
+		if d, ok := wu.ChangedAircraft[callsign]; ok {
+			nac := *ac
+			if err := applyFields(reflect.ValueOf(&nac).Elem(), reflect.ValueOf(&d.Aircraft).Elem(), d.Fields); err != nil {
+				return err
+			}
+			ac = &nac
+		}
+		updated[callsign] = ac
+	}
+	for callsign, ac := range wu.AddedAircraft {
+		updated[callsign] = ac
+	}
+	for _, callsign := range wu.RemovedAircraft {
+		delete(updated, callsign)
+	}
+
+	if err := applyFields(reflect.ValueOf(&state).Elem(), reflect.ValueOf(&wu.State).Elem(), wu.StateFields); err != nil {
+		return err
+	}
+
+	*w = worldCopy{version: wu.Version, aircraft: updated, state: state}
+	return nil
+}
+
+// applyWorldUpdate applies the changes in the update to the client's
+// copy of the world.
+func (c *ControlClient) applyWorldUpdate(wu *WorldUpdate) error {
+	if err := c.world.apply(wu); err != nil {
+		return err
+	}
+
+	state := c.world.state
+	c.State.Aircraft = c.world.aircraft
+
+	c.State.Controllers = state.Controllers
+	c.State.ERAMComputers = state.ERAMComputers
+	c.State.LaunchConfig = state.LaunchConfig
+	c.State.SimIsPaused = state.SimIsPaused
+	c.State.SimRate = state.SimRate
+	c.State.TotalDepartures = state.TotalDepartures
+	c.State.TotalArrivals = state.TotalArrivals
+	c.State.TotalOverflights = state.TotalOverflights
+	c.State.WeatherCells = state.WeatherCells
+	c.State.NOTAMs = state.NOTAMs
+	c.State.TMIs = state.TMIs
+	c.State.DepartureReleases = state.Releases
+	c.State.SurfaceTargets = state.Surface
+	c.State.FlightStrips = state.FlightStrips
+	c.State.Metering = state.Metering
+	c.State.METAR = util.Select(state.METAR != nil, state.METAR, c.State.METAR)
+	c.State.ATIS = util.Select(state.ATIS != nil, state.ATIS, c.State.ATIS)
+	c.State.NavSummaries = state.NavSummaries
+
+	return nil
+}
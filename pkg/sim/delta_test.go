@@ -0,0 +1,235 @@
+// pkg/sim/delta_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"slices"
+	"testing"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+
+	"github.com/brunoga/deep"
+)
+
+// gobRoundTrip sends the update through gob, as it is over RPC.
+func gobRoundTrip(t *testing.T, wu WorldUpdate) *WorldUpdate {
+	t.Helper()
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(wu); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var rwu WorldUpdate
+	if err := gob.NewDecoder(&b).Decode(&rwu); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return &rwu
+}
+
+func TestWorldUpdateDeltas(t *testing.T) {
+	roundTrip := func(wu WorldUpdate) *WorldUpdate { return gobRoundTrip(t, wu) }
+
+	ac := func(callsign string, alt float32) *av.Aircraft {
+		a := &av.Aircraft{Callsign: callsign, Squawk: 0o1234, Scratchpad: "ABC"}
+		a.Nav.FlightState.Altitude = alt
+		a.Nav.FlightState.Position = math.Point2LL{-73, 40}
+		return a
+	}
+
+	v1 := &worldSnapshot{
+		version:  1,
+		aircraft: map[string]*av.Aircraft{"AAL1": ac("AAL1", 5000), "UAL2": ac("UAL2", 7000)},
+		state:    WorldState{SimRate: 1, TotalDepartures: 2},
+	}
+	v2 := &worldSnapshot{
+		version:  2,
+		aircraft: map[string]*av.Aircraft{"AAL1": ac("AAL1", 5500), "DAL3": ac("DAL3", 3000)},
+		state:    WorldState{SimRate: 1, TotalDepartures: 3},
+	}
+	v2.aircraft["AAL1"].Scratchpad = ""
+
+	var c ControlClient
+	if err := c.applyWorldUpdate(roundTrip(makeWorldUpdate(nil, v1))); err != nil {
+		t.Fatalf("full update: %v", err)
+	}
+	if c.world.version != 1 || len(c.State.Aircraft) != 2 || c.State.TotalDepartures != 2 {
+		t.Errorf("full update not applied: version %d, %d aircraft", c.world.version, len(c.State.Aircraft))
+	}
+	prev := c.State.Aircraft["AAL1"]
+
+	wu := makeWorldUpdate(v1, v2)
+	if !slices.Equal(wu.RemovedAircraft, []string{"UAL2"}) || len(wu.AddedAircraft) != 1 {
+		t.Errorf("expected UAL2 removed and DAL3 added, got %v, %v", wu.RemovedAircraft, wu.AddedAircraft)
+	}
+	if f := wu.ChangedAircraft["AAL1"].Fields; !slices.Equal(f, []string{"Scratchpad", "Nav.FlightState.Altitude"}) {
+		t.Errorf("unexpected changed fields %v", f)
+	}
+	if !slices.Equal(wu.StateFields, []string{"TotalDepartures"}) {
+		t.Errorf("unexpected changed state fields %v", wu.StateFields)
+	}
+
+	if err := c.applyWorldUpdate(roundTrip(wu)); err != nil {
+		t.Fatalf("delta update: %v", err)
+	}
+	if !reflect.DeepEqual(c.State.Aircraft, v2.aircraft) {
+		t.Errorf("aircraft mismatch after delta: %v vs %v", c.State.Aircraft, v2.aircraft)
+	}
+	if c.State.TotalDepartures != 3 || c.State.SimRate != 1 {
+		t.Errorf("state not updated")
+	}
+	if prev.Scratchpad != "ABC" || prev.Nav.FlightState.Altitude != 5000 {
+		t.Errorf("aircraft from the earlier update was modified")
+	}
+
+	// An update relative to a version the client doesn't have is an error.
+	if err := c.applyWorldUpdate(&WorldUpdate{Version: 4, BaseVersion: 3}); err == nil {
+		t.Errorf("expected error for update with unknown base version")
+	}
+}
+
+func TestSharedWorldSnapshots(t *testing.T) {
+	s := &Sim{
+		State: &State{Aircraft: map[string]*av.Aircraft{"AAL1": {Callsign: "AAL1", Squawk: 0o1234}}},
+		controllers: map[string]*ServerController{
+			"a": {Callsign: "JFK_APP", role: RoleController, events: NewEventStream(nil).Subscribe()},
+			"b": {Callsign: "JFK_DEP", role: RoleController, events: NewEventStream(nil).Subscribe()},
+		},
+		eventStream: NewEventStream(nil),
+	}
+
+	// Controllers polling an unchanged world share a single snapshot.
+	var wa, wb WorldUpdate
+	if err := s.GetWorldUpdate("a", 0, &wa); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GetWorldUpdate("b", 0, &wb); err != nil {
+		t.Fatal(err)
+	}
+	if wa.Version != 1 || wb.Version != 1 || len(s.worldSnapshots) != 1 {
+		t.Errorf("expected one shared snapshot, got versions %d, %d and %d snapshots", wa.Version,
+			wb.Version, len(s.worldSnapshots))
+	}
+	if err := s.GetWorldUpdate("a", wa.Version, &wa); err != nil {
+		t.Fatal(err)
+	}
+	if wa.Version != 1 || wa.BaseVersion != 1 || !wa.empty() {
+		t.Errorf("expected empty update for an unchanged world, got %+v", wa)
+	}
+
+	// Changes aren't noticed until the sim has been updated.
+	s.State.Aircraft["AAL1"].Squawk = 0o4321
+	if err := s.GetWorldUpdate("a", wa.Version, &wa); err != nil {
+		t.Fatal(err)
+	}
+	if wa.Version != 1 || !wa.empty() {
+		t.Errorf("expected empty update before the sim was updated, got %+v", wa)
+	}
+
+	// Then a change makes a new version and each controller gets the
+	// changes since the version it has.
+	s.worldChanged = true
+	if err := s.GetWorldUpdate("a", wa.Version, &wa); err != nil {
+		t.Fatal(err)
+	}
+	if wa.Version != 2 || wa.BaseVersion != 1 || len(wa.ChangedAircraft) != 1 {
+		t.Errorf("expected delta from version 1, got %+v", wa)
+	}
+	if s.worldSnapshots[0].aircraft["AAL1"].Squawk != 0o1234 {
+		t.Errorf("snapshot shares state with the sim")
+	}
+	// Controllers with the same version share the update to the new one.
+	if err := s.GetWorldUpdate("b", wb.Version, &wb); err != nil {
+		t.Fatal(err)
+	}
+	if wb.Version != 2 || len(s.worldSnapshots[1].updates) != 1 ||
+		!reflect.DeepEqual(wb.ChangedAircraft, wa.ChangedAircraft) {
+		t.Errorf("expected shared delta from version 1, got %+v", wb)
+	}
+
+	// Old versions eventually age out, after which a full update is sent.
+	for i := range maxWorldSnapshots {
+		s.State.Aircraft["AAL1"].Nav.FlightState.Altitude = float32(1000 * (i + 1))
+		s.worldChanged = true
+		if err := s.GetWorldUpdate("a", wa.Version, &wa); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.worldSnapshots) != maxWorldSnapshots {
+		t.Errorf("expected %d snapshots, got %d", maxWorldSnapshots, len(s.worldSnapshots))
+	}
+	if err := s.GetWorldUpdate("b", wb.Version, &wb); err != nil {
+		t.Fatal(err)
+	}
+	if wb.BaseVersion != 0 || len(wb.AddedAircraft) != 1 {
+		t.Errorf("expected full update for an expired version, got %+v", wb)
+	}
+}
+
+func TestWorldUpdateMapDeltas(t *testing.T) {
+	fp := func(callsign string, alt int) *STARSFlightPlan {
+		return &STARSFlightPlan{FlightPlan: &av.FlightPlan{Callsign: callsign, Altitude: alt}, CoordinationFix: "MERIT"}
+	}
+	v1 := &worldSnapshot{version: 1, state: WorldState{
+		Controllers: map[string]*av.Controller{"JFK_APP": {Callsign: "JFK_APP", Frequency: 132400}},
+		ERAMComputers: &ERAMComputers{Computers: map[string]*ERAMComputer{
+			"ZNY": {
+				Identifier:       "ZNY",
+				FlightPlans:      map[av.Squawk]*STARSFlightPlan{0o1234: fp("AAL1", 12000), 0o2345: fp("UAL2", 8000)},
+				TrackInformation: map[string]*TrackInformation{"AAL1": {Identifier: "AAL1", TrackOwner: "N90"}},
+			},
+			"ZBW": {Identifier: "ZBW"},
+		}},
+	}}
+	v2 := &worldSnapshot{version: 2}
+	var err error
+	if v2.state, err = deep.Copy(v1.state); err != nil {
+		t.Fatal(err)
+	}
+	zny := v2.state.ERAMComputers.Computers["ZNY"]
+	zny.FlightPlans[0o1234].FlightPlan.Altitude = 14000
+	delete(zny.FlightPlans, 0o2345)
+	zny.FlightPlans[0o3456] = fp("DAL3", 5000)
+	v2.state.Controllers["JFK_DEP"] = &av.Controller{Callsign: "JFK_DEP"}
+
+	// Only the changed flight plans and controllers are sent.
+	wu := makeWorldUpdate(v1, v2)
+	slices.Sort(wu.StateFields)
+	if expected := []string{
+		`Controllers["JFK_DEP"]`,
+		`ERAMComputers.Computers["ZNY"].FlightPlans["1253"]`,
+		`ERAMComputers.Computers["ZNY"].FlightPlans["1838"]`,
+		`ERAMComputers.Computers["ZNY"].FlightPlans["668"].FlightPlan.Altitude`,
+	}; !slices.Equal(wu.StateFields, expected) {
+		t.Errorf("expected changed state fields %v, got %v", expected, wu.StateFields)
+	}
+
+	var c ControlClient
+	if err := c.applyWorldUpdate(gobRoundTrip(t, makeWorldUpdate(nil, v1))); err != nil {
+		t.Fatalf("full update: %v", err)
+	}
+	prev := c.State.ERAMComputers
+	if err := c.applyWorldUpdate(gobRoundTrip(t, wu)); err != nil {
+		t.Fatalf("delta update: %v", err)
+	}
+	if !reflect.DeepEqual(c.State.ERAMComputers, v2.state.ERAMComputers) ||
+		!reflect.DeepEqual(c.State.Controllers, v2.state.Controllers) {
+		t.Errorf("state mismatch after delta: %+v vs %+v", c.State.ERAMComputers.Computers["ZNY"],
+			v2.state.ERAMComputers.Computers["ZNY"])
+	}
+	if pzny := prev.Computers["ZNY"]; len(pzny.FlightPlans) != 2 || pzny.FlightPlans[0o1234].FlightPlan.Altitude != 12000 {
+		t.Errorf("ERAM computers from the earlier update were modified")
+	}
+
+	// Updates that don't match the client's world are errors.
+	for _, field := range []string{`ERAMComputers.Computers["ZDC"].FlightPlans["668"]`, `Controllers[JFK_APP]`,
+		`ERAMComputers.Computers["ZNY"].Bogus`} {
+		if err := c.world.apply(&WorldUpdate{Version: 3, BaseVersion: 2, StateFields: []string{field}}); err == nil {
+			t.Errorf("%s: expected error", field)
+		}
+	}
+}
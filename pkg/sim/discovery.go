@@ -0,0 +1,252 @@
+// pkg/sim/discovery.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/util"
+)
+
+// Servers started with announcements enabled periodically broadcast a
+// short JSON description of themselves and their running sims over UDP
+// so that clients on the same network can find them without being told
+// their address.
+
+const ViceDiscoveryPort = 8999
+
+const (
+	announceInterval = 2 * time.Second
+	// Servers that haven't been heard from for this long are dropped.
+	discoveryTimeout = 3 * announceInterval
+	// Keep announcements small enough to fit in a single packet.
+	maxAnnouncementSize = 1200
+)
+
+type serverAnnouncement struct {
+	RPCVersion int             `json:"rpc_version"`
+	Name       string          `json:"name"`
+	Port       int             `json:"port"`
+	TLS        bool            `json:"tls,omitempty"`
+	RequireTLS bool            `json:"require_tls,omitempty"`
+	Sims       []DiscoveredSim `json:"sims,omitempty"`
+}
+
+type DiscoveredSim struct {
+	Name             string   `json:"name"`
+	Scenario         string   `json:"scenario"`
+	CoveredPositions []string `json:"covered,omitempty"`
+}
+
+// DiscoveredServer is a server that has announced itself on the local
+// network.
+type DiscoveredServer struct {
+	Name       string
+	Address    string // host:port
+	TLS        bool
+	RequireTLS bool
+	Sims       []DiscoveredSim
+	LastSeen   time.Time
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Server side
+
+// announceServer broadcasts announcements for the server until the
+// program exits.
+func announceServer(sm *SimManager, name string, port int, opts serverOptions, lg *log.Logger) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		lg.Errorf("unable to announce server: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	lg.Infof("Announcing server as \"%s\" on UDP port %d", name, ViceDiscoveryPort)
+	for {
+		if msg, err := makeAnnouncement(sm, name, port, opts); err != nil {
+			lg.Errorf("announcement: %v", err)
+		} else {
+			for _, addr := range broadcastAddresses() {
+				if _, err := conn.WriteTo(msg, &net.UDPAddr{IP: addr, Port: ViceDiscoveryPort}); err != nil {
+					lg.Debugf("%s: announcement: %v", addr, err)
+				}
+			}
+		}
+		time.Sleep(announceInterval)
+	}
+}
+
+func makeAnnouncement(sm *SimManager, name string, port int, opts serverOptions) ([]byte, error) {
+	a := serverAnnouncement{
+		RPCVersion: ViceRPCVersion,
+		Name:       name,
+		Port:       port,
+		TLS:        opts.tlsConfig != nil,
+		RequireTLS: opts.requireTLS,
+	}
+
+	if opts.config == nil {
+		// Only say what's running if anyone may join.
+		var running map[string]*RemoteSim
+		if err := sm.GetRunningSims(0, &running); err != nil {
+			return nil, err
+		}
+		for _, simName := range util.SortedMapKeys(running) {
+			rs := running[simName]
+			a.Sims = append(a.Sims, DiscoveredSim{
+				Name:             simName,
+				Scenario:         rs.GroupName + "/" + rs.ScenarioName,
+				CoveredPositions: util.SortedMapKeys(rs.CoveredPositions),
+			})
+		}
+	}
+
+	for {
+		msg, err := json.Marshal(a)
+		if err != nil || len(msg) <= maxAnnouncementSize || len(a.Sims) == 0 {
+			return msg, err
+		}
+		// Drop sims until it fits; clients will see the rest once they
+		// connect.
+		a.Sims = a.Sims[:len(a.Sims)-1]
+	}
+}
+
+// broadcastAddresses returns the IPv4 broadcast addresses of the
+// network interfaces that are up.
+func broadcastAddresses() []net.IP {
+	var bcast []net.IP
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				if ip4 := ipnet.IP.To4(); ip4 != nil && len(ipnet.Mask) == net.IPv4len {
+					b := make(net.IP, net.IPv4len)
+					for i := range b {
+						b[i] = ip4[i] | ^ipnet.Mask[i]
+					}
+					bcast = append(bcast, b)
+				}
+			}
+		}
+	}
+
+	if len(bcast) == 0 {
+		bcast = append(bcast, net.IPv4bcast)
+	}
+	return bcast
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Client side
+
+// ServerDiscovery listens for server announcements.
+type ServerDiscovery struct {
+	conn    net.PacketConn
+	mu      sync.Mutex
+	servers map[string]*DiscoveredServer // address ->
+}
+
+// StartServerDiscovery starts listening for announcements; it returns nil
+// if it's unable to, e.g. because another program on this computer is
+// already listening.
+func StartServerDiscovery(lg *log.Logger) *ServerDiscovery {
+	conn, err := net.ListenPacket("udp4", fmt.Sprintf(":%d", ViceDiscoveryPort))
+	if err != nil {
+		lg.Warnf("unable to listen for server announcements: %v", err)
+		return nil
+	}
+
+	sd := &ServerDiscovery{conn: conn, servers: make(map[string]*DiscoveredServer)}
+	go func() {
+		defer conn.Close()
+
+		var buf [2 * maxAnnouncementSize]byte
+		for {
+			n, from, err := conn.ReadFrom(buf[:])
+			if errors.Is(err, net.ErrClosed) {
+				return
+			} else if err != nil {
+				lg.Warnf("server discovery: %v", err)
+				return
+			}
+
+			var a serverAnnouncement
+			if err := json.Unmarshal(buf[:n], &a); err != nil {
+				lg.Debugf("%s: invalid server announcement: %v", from, err)
+				continue
+			}
+			if a.RPCVersion != ViceRPCVersion {
+				// We wouldn't be able to talk to it anyway.
+				continue
+			}
+
+			if udp, ok := from.(*net.UDPAddr); ok {
+				sd.add(DiscoveredServer{
+					Name:       a.Name,
+					Address:    net.JoinHostPort(udp.IP.String(), fmt.Sprintf("%d", a.Port)),
+					TLS:        a.TLS,
+					RequireTLS: a.RequireTLS,
+					Sims:       a.Sims,
+					LastSeen:   time.Now(),
+				})
+			}
+		}
+	}()
+
+	return sd
+}
+
+// Stop stops listening for announcements.
+func (sd *ServerDiscovery) Stop() {
+	sd.conn.Close()
+}
+
+func (sd *ServerDiscovery) add(ds DiscoveredServer) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	sd.servers[ds.Address] = &ds
+}
+
+// Servers returns the servers that have been heard from recently, sorted
+// by name.
+func (sd *ServerDiscovery) Servers() []DiscoveredServer {
+	if sd == nil {
+		return nil
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	var servers []DiscoveredServer
+	for addr, ds := range sd.servers {
+		if time.Since(ds.LastSeen) > discoveryTimeout {
+			delete(sd.servers, addr)
+		} else {
+			servers = append(servers, *ds)
+		}
+	}
+	slices.SortFunc(servers, func(a, b DiscoveredServer) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return servers
+}
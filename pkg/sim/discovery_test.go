@@ -0,0 +1,117 @@
+// pkg/sim/discovery_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+)
+
+func TestMakeAnnouncement(t *testing.T) {
+	sm := NewSimManager(nil, nil, nil, nil, nil)
+	addSims := func(n int) {
+		for i := range n {
+			name := fmt.Sprintf("sim%02d", i)
+			sm.activeSims[name] = &Sim{
+				Name:          name,
+				ScenarioGroup: "N90",
+				Scenario:      strings.Repeat("x", 40),
+				State: &State{
+					PrimaryController: "JFK_APP",
+					Controllers:       make(map[string]*av.Controller),
+				},
+				controllers: make(map[string]*ServerController),
+			}
+		}
+	}
+
+	decode := func(msg []byte) serverAnnouncement {
+		t.Helper()
+		var a serverAnnouncement
+		if err := json.Unmarshal(msg, &a); err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	addSims(3)
+	msg, err := makeAnnouncement(sm, "test", 8000, serverOptions{requireTLS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := decode(msg)
+	if a.Name != "test" || a.Port != 8000 || a.RPCVersion != ViceRPCVersion || !a.RequireTLS || len(a.Sims) != 3 ||
+		a.Sims[0].Name != "sim00" || a.Sims[0].Scenario != "N90/"+strings.Repeat("x", 40) {
+		t.Errorf("unexpected announcement %+v", a)
+	}
+
+	// With many sims, the last ones are dropped so that it fits in a
+	// packet.
+	addSims(50)
+	msg, err = makeAnnouncement(sm, "test", 8000, serverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) > maxAnnouncementSize {
+		t.Errorf("announcement is %d bytes", len(msg))
+	}
+	a = decode(msg)
+	if len(a.Sims) == 0 || len(a.Sims) == 50 || a.Sims[0].Name != "sim00" ||
+		a.Sims[len(a.Sims)-1].Name != fmt.Sprintf("sim%02d", len(a.Sims)-1) {
+		t.Errorf("expected the first sims to be kept, got %d: %+v", len(a.Sims), a.Sims)
+	}
+	// But not so many that another would have fit.
+	a.Sims = append(a.Sims, DiscoveredSim{Name: "sim99", Scenario: "N90/" + strings.Repeat("x", 40)})
+	if b, _ := json.Marshal(a); len(b) <= maxAnnouncementSize {
+		t.Errorf("dropped more sims than necessary")
+	}
+
+	// Servers that restrict access don't say what's running.
+	msg, err = makeAnnouncement(sm, "test", 8000, serverOptions{config: &ServerConfig{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := decode(msg); len(a.Sims) != 0 {
+		t.Errorf("expected no sims for restricted server, got %+v", a.Sims)
+	}
+}
+
+func TestServerDiscoveryExpiry(t *testing.T) {
+	var nilsd *ServerDiscovery
+	if s := nilsd.Servers(); s != nil {
+		t.Errorf("expected no servers without discovery, got %+v", s)
+	}
+
+	now := time.Now()
+	sd := &ServerDiscovery{servers: make(map[string]*DiscoveredServer)}
+	sd.add(DiscoveredServer{Name: "vice", Address: "10.0.0.2:8000", LastSeen: now})
+	sd.add(DiscoveredServer{Name: "alpha", Address: "10.0.0.3:8000", LastSeen: now.Add(-discoveryTimeout / 2)})
+	sd.add(DiscoveredServer{Name: "vice", Address: "10.0.0.1:8000", LastSeen: now})
+	sd.add(DiscoveredServer{Name: "old", Address: "10.0.0.4:8000", LastSeen: now.Add(-discoveryTimeout - time.Second)})
+
+	servers := sd.Servers()
+	var addrs []string
+	for _, s := range servers {
+		addrs = append(addrs, s.Address)
+	}
+	if strings.Join(addrs, " ") != "10.0.0.3:8000 10.0.0.1:8000 10.0.0.2:8000" {
+		t.Errorf("unexpected servers %v", addrs)
+	}
+	if _, ok := sd.servers["10.0.0.4:8000"]; ok {
+		t.Errorf("expired server should have been removed")
+	}
+
+	// Hearing from a server again keeps it around.
+	sd.add(DiscoveredServer{Name: "alpha", Address: "10.0.0.3:8000", LastSeen: now.Add(time.Second)})
+	sd.servers["10.0.0.1:8000"].LastSeen = now.Add(-discoveryTimeout - time.Second)
+	if servers := sd.Servers(); len(servers) != 2 || servers[0].Name != "alpha" {
+		t.Errorf("unexpected servers %+v", servers)
+	}
+}
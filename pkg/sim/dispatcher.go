@@ -7,6 +7,7 @@ package sim
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/math"
@@ -17,11 +18,36 @@ type Dispatcher struct {
 	sm *SimManager
 }
 
-func (sd *Dispatcher) GetWorldUpdate(token string, update *WorldUpdate) error {
-	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
+// authorizedSim returns the Sim for a controller token after checking
+// that the controller's role allows calls that require the given role.
+func (sd *Dispatcher) authorizedSim(token string, role Role) (*Sim, error) {
+	sim, ok := sd.sm.ControllerTokenToSim(token)
+	if !ok {
+		return nil, ErrNoSimForControllerToken
+	}
+	if r, ok := sim.ControllerRole(token); !ok {
+		return nil, ErrInvalidControllerToken
+	} else if r < role {
+		if r == RoleObserver {
+			return nil, ErrObserverCannotControl
+		}
+		return nil, ErrNotAuthorized
+	}
+	return sim, nil
+}
+
+type WorldUpdateArgs struct {
+	ControllerToken string
+	// The version of the world that the client has; zero requests a full
+	// update.
+	AckVersion uint64
+}
+
+func (sd *Dispatcher) GetWorldUpdate(wa *WorldUpdateArgs, update *WorldUpdate) error {
+	if sim, ok := sd.sm.ControllerTokenToSim(wa.ControllerToken); !ok {
 		return ErrNoSimForControllerToken
 	} else {
-		return sim.GetWorldUpdate(token, update)
+		return sim.GetWorldUpdate(wa.ControllerToken, wa.AckVersion, update)
 	}
 }
 
@@ -40,16 +66,16 @@ type ChangeControlPositionArgs struct {
 }
 
 func (sd *Dispatcher) ChangeControlPosition(cs *ChangeControlPositionArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.ControllerTokenToSim(cs.ControllerToken); !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(cs.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.ChangeControlPosition(cs.ControllerToken, cs.Callsign, cs.KeepTracks)
 	}
 }
 
 func (sd *Dispatcher) TakeOrReturnLaunchControl(token string, _ *struct{}) error {
-	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(token, RoleInstructor); err != nil {
+		return err
 	} else {
 		return sim.TakeOrReturnLaunchControl(token)
 	}
@@ -61,8 +87,8 @@ type SetSimRateArgs struct {
 }
 
 func (sd *Dispatcher) SetSimRate(r *SetSimRateArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[r.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(r.ControllerToken, RoleInstructor); err != nil {
+		return err
 	} else {
 		return sim.SetSimRate(r.ControllerToken, r.Rate)
 	}
@@ -74,16 +100,174 @@ type SetLaunchConfigArgs struct {
 }
 
 func (sd *Dispatcher) SetLaunchConfig(lc *SetLaunchConfigArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[lc.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(lc.ControllerToken, RoleInstructor); err != nil {
+		return err
 	} else {
 		return sim.SetLaunchConfig(lc.ControllerToken, lc.Config)
 	}
 }
 
+type WeatherCellArgs struct {
+	ControllerToken string
+	Cell            av.WeatherCell
+}
+
+func (sd *Dispatcher) AddWeatherCell(wc *WeatherCellArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(wc.ControllerToken, RoleInstructor); err != nil {
+		return err
+	} else {
+		return sim.AddWeatherCell(wc.ControllerToken, wc.Cell)
+	}
+}
+
+func (sd *Dispatcher) DeleteWeatherCell(wc *WeatherCellArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(wc.ControllerToken, RoleInstructor); err != nil {
+		return err
+	} else {
+		return sim.DeleteWeatherCell(wc.ControllerToken, wc.Cell.Id)
+	}
+}
+
+type NOTAMArgs struct {
+	ControllerToken string
+	NOTAM           NOTAM
+}
+
+func (sd *Dispatcher) AddNOTAM(na *NOTAMArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(na.ControllerToken, RoleInstructor); err != nil {
+		return err
+	} else {
+		return sim.AddNOTAM(na.ControllerToken, na.NOTAM)
+	}
+}
+
+func (sd *Dispatcher) DeleteNOTAM(na *NOTAMArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(na.ControllerToken, RoleInstructor); err != nil {
+		return err
+	} else {
+		return sim.DeleteNOTAM(na.ControllerToken, na.NOTAM.Id)
+	}
+}
+
+type ReleaseArgs struct {
+	ControllerToken string
+	Callsign        string
+	Time            time.Duration // void time or delay
+}
+
+func (sd *Dispatcher) RequestRelease(ra *ReleaseArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(ra.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.RequestRelease(ra.ControllerToken, ra.Callsign)
+	}
+}
+
+func (sd *Dispatcher) ApproveRelease(ra *ReleaseArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(ra.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.ApproveRelease(ra.ControllerToken, ra.Callsign, ra.Time)
+	}
+}
+
+func (sd *Dispatcher) DelayRelease(ra *ReleaseArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(ra.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.DelayRelease(ra.ControllerToken, ra.Callsign, ra.Time)
+	}
+}
+
+func (sd *Dispatcher) DenyRelease(ra *ReleaseArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(ra.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.DenyRelease(ra.ControllerToken, ra.Callsign)
+	}
+}
+
+func (sd *Dispatcher) ClearForTakeoff(a *AircraftSpecifier, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(a.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.ClearForTakeoff(a.ControllerToken, a.Callsign)
+	}
+}
+
+type FlightStripArgs struct {
+	ControllerToken string
+	Callsign        string
+	Callsigns       []string // AddFlightStrips
+	Bay             StripBay
+	Index           int
+	ToController    string
+}
+
+func (sd *Dispatcher) MoveFlightStrip(fa *FlightStripArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(fa.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.MoveFlightStrip(fa.ControllerToken, fa.Callsign, fa.Bay, fa.Index)
+	}
+}
+
+func (sd *Dispatcher) AddFlightStrips(fa *FlightStripArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(fa.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.AddFlightStrips(fa.ControllerToken, fa.Callsigns, fa.Bay)
+	}
+}
+
+func (sd *Dispatcher) RemoveFlightStrip(fa *FlightStripArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(fa.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.RemoveFlightStrip(fa.ControllerToken, fa.Callsign)
+	}
+}
+
+func (sd *Dispatcher) PushFlightStrip(fa *FlightStripArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(fa.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.PushFlightStrip(fa.ControllerToken, fa.Callsign, fa.ToController)
+	}
+}
+
+func (sd *Dispatcher) AcknowledgeFlightStrip(fa *FlightStripArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(fa.ControllerToken, RoleController); err != nil {
+		return err
+	} else {
+		return sim.AcknowledgeFlightStrip(fa.ControllerToken, fa.Callsign)
+	}
+}
+
+type TMIArgs struct {
+	ControllerToken string
+	TMI             TMI
+}
+
+func (sd *Dispatcher) AddTMI(ta *TMIArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(ta.ControllerToken, RoleInstructor); err != nil {
+		return err
+	} else {
+		return sim.AddTMI(ta.ControllerToken, ta.TMI)
+	}
+}
+
+func (sd *Dispatcher) DeleteTMI(ta *TMIArgs, _ *struct{}) error {
+	if sim, err := sd.authorizedSim(ta.ControllerToken, RoleInstructor); err != nil {
+		return err
+	} else {
+		return sim.DeleteTMI(ta.ControllerToken, ta.TMI.Id)
+	}
+}
+
 func (sd *Dispatcher) TogglePause(token string, _ *struct{}) error {
-	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(token, RoleInstructor); err != nil {
+		return err
 	} else {
 		return sim.TogglePause(token)
 	}
@@ -96,24 +280,24 @@ type SetScratchpadArgs struct {
 }
 
 func (sd *Dispatcher) SetScratchpad(a *SetScratchpadArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(a.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.SetScratchpad(a.ControllerToken, a.Callsign, a.Scratchpad)
 	}
 }
 
 func (sd *Dispatcher) SetSecondaryScratchpad(a *SetScratchpadArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(a.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.SetSecondaryScratchpad(a.ControllerToken, a.Callsign, a.Scratchpad)
 	}
 }
 
 func (sd *Dispatcher) AutoAssociateFP(it *InitiateTrackArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(it.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.AutoAssociateFP(it.ControllerToken, it.Callsign, it.Plan)
 	}
@@ -126,8 +310,8 @@ type SetGlobalLeaderLineArgs struct {
 }
 
 func (sd *Dispatcher) SetGlobalLeaderLine(a *SetGlobalLeaderLineArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(a.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.SetGlobalLeaderLine(a.ControllerToken, a.Callsign, a.Direction)
 	}
@@ -139,8 +323,8 @@ type InitiateTrackArgs struct {
 }
 
 func (sd *Dispatcher) InitiateTrack(it *InitiateTrackArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(it.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.InitiateTrack(it.ControllerToken, it.Callsign, it.Plan)
 	}
@@ -153,8 +337,8 @@ type CreateUnsupportedTrackArgs struct {
 }
 
 func (sd *Dispatcher) CreateUnsupportedTrack(it *CreateUnsupportedTrackArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(it.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.CreateUnsupportedTrack(it.ControllerToken, it.Callsign, it.UnsupportedTrack)
 	}
@@ -167,8 +351,8 @@ type UploadPlanArgs struct {
 }
 
 func (sd *Dispatcher) UploadFlightPlan(it *UploadPlanArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(it.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.UploadFlightPlan(it.ControllerToken, it.Type, it.Plan)
 	}
@@ -182,8 +366,8 @@ type AircraftSpecifier struct {
 type DropTrackArgs AircraftSpecifier
 
 func (sd *Dispatcher) DropTrack(dt *DropTrackArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[dt.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(dt.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.DropTrack(dt.ControllerToken, dt.Callsign)
 	}
@@ -196,24 +380,24 @@ type HandoffArgs struct {
 }
 
 func (sd *Dispatcher) HandoffTrack(h *HandoffArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[h.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(h.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.HandoffTrack(h.ControllerToken, h.Callsign, h.Controller)
 	}
 }
 
 func (sd *Dispatcher) RedirectHandoff(h *HandoffArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[h.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(h.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.RedirectHandoff(h.ControllerToken, h.Callsign, h.Controller)
 	}
 }
 
 func (sd *Dispatcher) AcceptRedirectedHandoff(po *AcceptHandoffArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(po.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.AcceptRedirectedHandoff(po.ControllerToken, po.Callsign)
 	}
@@ -222,8 +406,8 @@ func (sd *Dispatcher) AcceptRedirectedHandoff(po *AcceptHandoffArgs, _ *struct{}
 type AcceptHandoffArgs AircraftSpecifier
 
 func (sd *Dispatcher) AcceptHandoff(ah *AcceptHandoffArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[ah.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(ah.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.AcceptHandoff(ah.ControllerToken, ah.Callsign)
 	}
@@ -232,8 +416,8 @@ func (sd *Dispatcher) AcceptHandoff(ah *AcceptHandoffArgs, _ *struct{}) error {
 type CancelHandoffArgs AircraftSpecifier
 
 func (sd *Dispatcher) CancelHandoff(ch *CancelHandoffArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[ch.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(ch.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.CancelHandoff(ch.ControllerToken, ch.Callsign)
 	}
@@ -252,8 +436,8 @@ type ForceQLArgs struct {
 }
 
 func (sd *Dispatcher) ForceQL(ql *ForceQLArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[ql.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(ql.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.ForceQL(ql.ControllerToken, ql.Callsign, ql.Controller)
 	}
@@ -266,32 +450,32 @@ type GlobalMessageArgs struct {
 }
 
 func (sd *Dispatcher) GlobalMessage(po *GlobalMessageArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(po.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.GlobalMessage(*po)
 	}
 }
 
 func (sd *Dispatcher) PointOut(po *PointOutArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(po.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.PointOut(po.ControllerToken, po.Callsign, po.Controller)
 	}
 }
 
 func (sd *Dispatcher) AcknowledgePointOut(po *PointOutArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(po.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.AcknowledgePointOut(po.ControllerToken, po.Callsign)
 	}
 }
 
 func (sd *Dispatcher) RejectPointOut(po *PointOutArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(po.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.RejectPointOut(po.ControllerToken, po.Callsign)
 	}
@@ -304,8 +488,8 @@ type ToggleSPCArgs struct {
 }
 
 func (sd *Dispatcher) ToggleSPCOverride(ts *ToggleSPCArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[ts.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(ts.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.ToggleSPCOverride(ts.ControllerToken, ts.Callsign, ts.SPC)
 	}
@@ -318,8 +502,8 @@ type AssignAltitudeArgs struct {
 }
 
 func (sd *Dispatcher) SetTemporaryAltitude(alt *AssignAltitudeArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[alt.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(alt.ControllerToken, RoleController); err != nil {
+		return err
 	} else {
 		return sim.SetTemporaryAltitude(alt.ControllerToken, alt.Callsign, alt.Altitude)
 	}
@@ -328,8 +512,8 @@ func (sd *Dispatcher) SetTemporaryAltitude(alt *AssignAltitudeArgs, _ *struct{})
 type DeleteAircraftArgs AircraftSpecifier
 
 func (sd *Dispatcher) DeleteAllAircraft(da *DeleteAircraftArgs, _ *struct{}) error {
-	if sim, ok := sd.sm.controllerTokenToSim[da.ControllerToken]; !ok {
-		return ErrNoSimForControllerToken
+	if sim, err := sd.authorizedSim(da.ControllerToken, RoleInstructor); err != nil {
+		return err
 	} else {
 		return sim.DeleteAllAircraft(da.ControllerToken)
 	}
@@ -350,9 +534,9 @@ type AircraftCommandsResult struct {
 
 func (sd *Dispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result *AircraftCommandsResult) error {
 	token, callsign := cmds.ControllerToken, cmds.Callsign
-	sim, ok := sd.sm.controllerTokenToSim[token]
-	if !ok {
-		return ErrNoSimForControllerToken
+	sim, err := sd.authorizedSim(token, RoleController)
+	if err != nil {
+		return err
 	}
 
 	commands := strings.Fields(cmds.Commands)
@@ -379,6 +563,11 @@ func (sd *Dispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result *Ai
 					rewriteError(err)
 					return nil
 				}
+			} else if command == "CTL" {
+				if err := sim.ClearedToLand(token, callsign); err != nil {
+					rewriteError(err)
+					return nil
+				}
 			} else if command == "CVS" {
 				if err := sim.ClimbViaSID(token, callsign); err != nil {
 					rewriteError(err)
@@ -744,6 +933,17 @@ func (sd *Dispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result *Ai
 					return nil
 				}
 			}
+		case 'W':
+			if command == "WX" {
+				if err := sim.ApproveDeviation(token, callsign); err != nil {
+					rewriteError(err)
+					return nil
+				}
+			} else {
+				rewriteError(ErrInvalidCommandSyntax)
+				return nil
+			}
+
 		case 'X':
 			sim.DeleteAircraft(token, callsign)
 
@@ -762,9 +962,9 @@ type LaunchAircraftArgs struct {
 }
 
 func (sd *Dispatcher) LaunchAircraft(ls *LaunchAircraftArgs, _ *struct{}) error {
-	sim, ok := sd.sm.controllerTokenToSim[ls.ControllerToken]
-	if !ok {
-		return ErrNoSimForControllerToken
+	sim, err := sd.authorizedSim(ls.ControllerToken, RoleInstructor)
+	if err != nil {
+		return err
 	}
 	sim.LaunchAircraft(ls.Aircraft)
 	return nil
@@ -778,9 +978,9 @@ type CreateDepartureArgs struct {
 }
 
 func (sd *Dispatcher) CreateDeparture(da *CreateDepartureArgs, depAc *av.Aircraft) error {
-	sim, ok := sd.sm.controllerTokenToSim[da.ControllerToken]
-	if !ok {
-		return ErrNoSimForControllerToken
+	sim, err := sd.authorizedSim(da.ControllerToken, RoleInstructor)
+	if err != nil {
+		return err
 	}
 	ac, _, err := sim.CreateDeparture(da.Airport, da.Runway, da.Category)
 	if err == nil {
@@ -796,9 +996,9 @@ type CreateArrivalArgs struct {
 }
 
 func (sd *Dispatcher) CreateArrival(aa *CreateArrivalArgs, arrAc *av.Aircraft) error {
-	sim, ok := sd.sm.controllerTokenToSim[aa.ControllerToken]
-	if !ok {
-		return ErrNoSimForControllerToken
+	sim, err := sd.authorizedSim(aa.ControllerToken, RoleInstructor)
+	if err != nil {
+		return err
 	}
 	ac, err := sim.CreateArrival(aa.Group, aa.Airport)
 	if err == nil {
@@ -813,9 +1013,9 @@ type CreateOverflightArgs struct {
 }
 
 func (sd *Dispatcher) CreateOverflight(oa *CreateOverflightArgs, ofAc *av.Aircraft) error {
-	sim, ok := sd.sm.controllerTokenToSim[oa.ControllerToken]
-	if !ok {
-		return ErrNoSimForControllerToken
+	sim, err := sd.authorizedSim(oa.ControllerToken, RoleInstructor)
+	if err != nil {
+		return err
 	}
 	ac, err := sim.CreateOverflight(oa.Group)
 	if err == nil {
@@ -13,25 +13,45 @@ import (
 var (
 	ErrBeaconMismatch            = errors.New("Beacon code mismatch")
 	ErrControllerAlreadySignedIn = errors.New("Controller with that callsign already signed in")
+	ErrDepartureNotReleased      = errors.New("Departure has not been released")
 	ErrDuplicateSimName          = errors.New("A sim with that name already exists")
+	ErrFlightStripNotPushed      = errors.New("Flight strip has not been pushed to you")
 	ErrIllegalACID               = errors.New("Illegal ACID")
 	ErrIllegalACType             = errors.New("Illegal aircraft type")
 	ErrIllegalScratchpad         = errors.New("Illegal scratchpad")
 	ErrInvalidAbbreviatedFP      = errors.New("Invalid abbreviated flight plan")
 	ErrInvalidCommandSyntax      = errors.New("Invalid command syntax")
 	ErrInvalidControllerToken    = errors.New("Invalid controller token")
+	ErrInvalidNOTAM              = errors.New("Invalid NOTAM")
 	ErrInvalidPassword           = errors.New("Invalid password")
+	ErrInvalidReconnectToken     = errors.New("Invalid or expired reconnect token")
+	ErrInvalidTMI                = errors.New("Invalid traffic management initiative")
+	ErrInvalidWeatherCell        = errors.New("Invalid weather cell")
 	ErrNoCoordinationFix         = errors.New("No coordination fix found")
+	ErrNoFlightStrip             = errors.New("No flight strip for that aircraft")
 	ErrNoMatchingFlight          = errors.New("No matching flight")
+	ErrNoMatchingNOTAM           = errors.New("No NOTAM with that id")
+	ErrNoMatchingTMI             = errors.New("No traffic management initiative with that id")
+	ErrNoMatchingWeatherCell     = errors.New("No weather cell with that id")
 	ErrNoNamedSim                = errors.New("No Sim with that name")
+	ErrNoPendingRelease          = errors.New("No departure awaiting release with that callsign")
 	ErrNoSimForControllerToken   = errors.New("No Sim running for controller token")
+	ErrNotAuthorized             = errors.New("Not authorized")
+	ErrNotHoldingShort           = errors.New("Aircraft is not holding short of the runway")
 	ErrNotLaunchController       = errors.New("Not signed in as the launch controller")
+	ErrNotReleaseController      = errors.New("Not the controller for that release")
+	ErrNotTowerController        = errors.New("Not the tower controller for that airport")
+	ErrObserverCannotControl     = errors.New("Observers cannot make changes to the sim")
 	ErrRPCTimeout                = errors.New("RPC call timed out")
 	ErrRPCVersionMismatch        = errors.New("Client and server RPC versions don't match")
+	ErrReleaseAlreadyRequested   = errors.New("Release has already been requested")
+	ErrReleaseNotRequested       = errors.New("Release has not been requested")
 	ErrRestoringSavedState       = errors.New("Errors during state restoration")
+	ErrRunwayClosed              = errors.New("Runway is closed")
 	ErrServerDisconnected        = errors.New("Server disconnected")
 	ErrUnknownFacility           = errors.New("Unknown facility (ARTCC/TRACON)")
 	ErrUnknownControllerFacility = errors.New("Unknown controller facility")
+	ErrUnsupportedAPIVersion     = errors.New("Unsupported API version")
 )
 
 var errorStringToError = map[string]error{
@@ -61,24 +81,44 @@ var errorStringToError = map[string]error{
 
 	ErrBeaconMismatch.Error():            ErrBeaconMismatch,
 	ErrControllerAlreadySignedIn.Error(): ErrControllerAlreadySignedIn,
+	ErrDepartureNotReleased.Error():      ErrDepartureNotReleased,
 	ErrDuplicateSimName.Error():          ErrDuplicateSimName,
+	ErrFlightStripNotPushed.Error():      ErrFlightStripNotPushed,
 	ErrIllegalACID.Error():               ErrIllegalACID,
 	ErrIllegalACType.Error():             ErrIllegalACType,
 	ErrIllegalScratchpad.Error():         ErrIllegalScratchpad,
 	ErrInvalidAbbreviatedFP.Error():      ErrInvalidAbbreviatedFP,
 	ErrInvalidCommandSyntax.Error():      ErrInvalidCommandSyntax,
 	ErrInvalidControllerToken.Error():    ErrInvalidControllerToken,
+	ErrInvalidNOTAM.Error():              ErrInvalidNOTAM,
 	ErrInvalidPassword.Error():           ErrInvalidPassword,
+	ErrInvalidReconnectToken.Error():     ErrInvalidReconnectToken,
+	ErrInvalidTMI.Error():                ErrInvalidTMI,
+	ErrInvalidWeatherCell.Error():        ErrInvalidWeatherCell,
 	ErrNoCoordinationFix.Error():         ErrNoCoordinationFix,
+	ErrNoFlightStrip.Error():             ErrNoFlightStrip,
 	ErrNoMatchingFlight.Error():          ErrNoMatchingFlight,
+	ErrNoMatchingNOTAM.Error():           ErrNoMatchingNOTAM,
+	ErrNoMatchingTMI.Error():             ErrNoMatchingTMI,
+	ErrNoMatchingWeatherCell.Error():     ErrNoMatchingWeatherCell,
 	ErrNoNamedSim.Error():                ErrNoNamedSim,
+	ErrNoPendingRelease.Error():          ErrNoPendingRelease,
 	ErrNoSimForControllerToken.Error():   ErrNoSimForControllerToken,
+	ErrNotAuthorized.Error():             ErrNotAuthorized,
+	ErrNotHoldingShort.Error():           ErrNotHoldingShort,
+	ErrNotReleaseController.Error():      ErrNotReleaseController,
+	ErrNotTowerController.Error():        ErrNotTowerController,
+	ErrObserverCannotControl.Error():     ErrObserverCannotControl,
 	ErrRPCTimeout.Error():                ErrRPCTimeout,
 	ErrRPCVersionMismatch.Error():        ErrRPCVersionMismatch,
+	ErrReleaseAlreadyRequested.Error():   ErrReleaseAlreadyRequested,
+	ErrReleaseNotRequested.Error():       ErrReleaseNotRequested,
 	ErrRestoringSavedState.Error():       ErrRestoringSavedState,
+	ErrRunwayClosed.Error():              ErrRunwayClosed,
 	ErrServerDisconnected.Error():        ErrServerDisconnected,
 	ErrUnknownFacility.Error():           ErrUnknownFacility,
 	ErrUnknownControllerFacility.Error(): ErrUnknownControllerFacility,
+	ErrUnsupportedAPIVersion.Error():     ErrUnsupportedAPIVersion,
 }
 
 func TryDecodeError(e error) error {
@@ -210,6 +210,12 @@ const (
 	ForceQLEvent
 	TransferAcceptedEvent
 	TransferRejectedEvent
+	ReleaseRequestedEvent
+	ReleaseApprovedEvent
+	ReleaseDelayedEvent
+	ReleaseDeniedEvent
+	ReleaseVoidedEvent
+	AcknowledgedFlightStripEvent
 	NumEventTypes
 )
 
@@ -218,7 +224,9 @@ func (t EventType) String() string {
 		"OfferedHandoff", "AcceptedHandoff", "AcceptedRedirectedHandoffEvent", "CanceledHandoff",
 		"RejectedHandoff", "RadioTransmission", "StatusMessage", "ServerBroadcastMessage",
 		"GlobalMessage", "AcknowledgedPointOut", "RejectedPointOut", "Ident", "HandoffControl",
-		"SetGlobalLeaderLine", "TrackClicked", "ForceQL", "TransferAccepted", "TransferRejected"}[t]
+		"SetGlobalLeaderLine", "TrackClicked", "ForceQL", "TransferAccepted", "TransferRejected",
+		"ReleaseRequested", "ReleaseApproved", "ReleaseDelayed", "ReleaseDenied", "ReleaseVoided",
+		"AcknowledgedFlightStrip"}[t]
 }
 
 type Event struct {
@@ -0,0 +1,113 @@
+// pkg/sim/extrapolate.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+)
+
+// Between world updates, the client extrapolates aircraft positions and
+// altitudes so that tracks keep moving when updates are late. When an
+// update arrives, the difference between where the aircraft was
+// extrapolated to be and where it actually is is blended out over a short
+// time rather than having the track jump.
+
+const (
+	// Maximum amount of sim time to extrapolate past the last update.
+	maxExtrapolation = 5 * time.Second
+	// Sim time over which extrapolation errors are blended out.
+	reconcileDuration = 2 * time.Second
+	// Errors larger than this (in nm) aren't blended; the track just
+	// moves to its reported position.
+	maxReconcileDistance = 1
+)
+
+type extrapolatedTrack struct {
+	time         time.Time  // sim time of the world update
+	position     [2]float32 // nm
+	velocity     [2]float32 // nm per second
+	altitude     float32
+	verticalRate float32 // feet per second
+
+	// How far off the previous extrapolation was when the update arrived.
+	positionError [2]float32
+	altitudeError float32
+}
+
+func (et *extrapolatedTrack) at(t time.Time) ([2]float32, float32) {
+	s := float32(math.Clamp(t.Sub(et.time), 0, maxExtrapolation).Seconds())
+	w := math.Max(0, 1-s/float32(reconcileDuration.Seconds()))
+
+	p := math.Add2f(et.position, math.Scale2f(et.velocity, s))
+	p = math.Add2f(p, math.Scale2f(et.positionError, w))
+	alt := et.altitude + s*et.verticalRate + w*et.altitudeError
+	return p, alt
+}
+
+// updateExtrapolation records the aircraft's reported positions and
+// altitudes from a world update for the given sim time.
+func (c *ControlClient) updateExtrapolation(t time.Time) {
+	if c.extrapolated == nil {
+		c.extrapolated = make(map[string]*extrapolatedTrack)
+	}
+
+	for callsign := range c.extrapolated {
+		if _, ok := c.State.Aircraft[callsign]; !ok {
+			delete(c.extrapolated, callsign)
+		}
+	}
+
+	for callsign, ac := range c.State.Aircraft {
+		prev, ok := c.extrapolated[callsign]
+		if ok && !t.After(prev.time) {
+			// No time has passed (e.g., the sim is paused).
+			continue
+		}
+
+		et := &extrapolatedTrack{
+			time:     t,
+			position: math.LL2NM(ac.Position(), ac.NmPerLongitude()),
+			altitude: ac.Altitude(),
+		}
+
+		// Extrapolate along the ground track, which includes the effect
+		// of wind, if the aircraft has moved since the previous update;
+		// otherwise use its heading.
+		var dir [2]float32
+		if ok && et.position != prev.position {
+			dir = math.Normalize2f(math.Sub2f(et.position, prev.position))
+		} else {
+			hdg := math.Radians(ac.Heading() - ac.MagneticVariation())
+			dir = [2]float32{math.Sin(hdg), math.Cos(hdg)}
+		}
+		et.velocity = math.Scale2f(dir, ac.GS()/3600)
+
+		if ok {
+			et.verticalRate = (et.altitude - prev.altitude) / float32(t.Sub(prev.time).Seconds())
+
+			p, alt := prev.at(t)
+			if perr := math.Sub2f(p, et.position); math.Length2f(perr) < maxReconcileDistance {
+				et.positionError = perr
+				et.altitudeError = alt - et.altitude
+			}
+		}
+
+		c.extrapolated[callsign] = et
+	}
+}
+
+// ExtrapolatedTrack returns the aircraft's position and altitude at the
+// given sim time, extrapolated from the most recent world update using its
+// groundspeed, ground track, and vertical rate.
+func (c *ControlClient) ExtrapolatedTrack(ac *av.Aircraft, t time.Time) (math.Point2LL, float32) {
+	if et, ok := c.extrapolated[ac.Callsign]; ok {
+		p, alt := et.at(t)
+		return math.NM2LL(p, ac.NmPerLongitude()), alt
+	}
+	return ac.Position(), ac.Altitude()
+}
@@ -0,0 +1,85 @@
+// pkg/sim/extrapolate_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+)
+
+func TestExtrapolation(t *testing.T) {
+	const nmPerLongitude = 45
+	ac := &av.Aircraft{Callsign: "AAL1"}
+	ac.Nav.FlightState = av.FlightState{
+		Position:       math.Point2LL{-73, 40},
+		Heading:        360,
+		GS:             360, // 0.1nm per second
+		Altitude:       5000,
+		NmPerLongitude: nmPerLongitude,
+	}
+	c := &ControlClient{}
+	c.State.Aircraft = map[string]*av.Aircraft{"AAL1": ac}
+
+	near := func(a, b [2]float32) bool { return math.Length2f(math.Sub2f(a, b)) < 0.001 }
+	track := func(tm time.Time) ([2]float32, float32) {
+		p, alt := c.ExtrapolatedTrack(ac, tm)
+		return math.LL2NM(p, nmPerLongitude), alt
+	}
+
+	// With only one report, the track follows the aircraft's heading.
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	c.updateExtrapolation(t0)
+	p0 := math.LL2NM(ac.Position(), nmPerLongitude)
+	if p, _ := track(t0.Add(2 * time.Second)); !near(p, math.Add2f(p0, [2]float32{0, 0.2})) {
+		t.Errorf("expected extrapolation along the heading, got %v from %v", p, p0)
+	}
+
+	// A strong crosswind: the aircraft is heading north but tracking
+	// east. It's a bit short of where it was extrapolated to be and has
+	// climbed.
+	t1 := t0.Add(time.Second)
+	p1 := math.Add2f(p0, [2]float32{0.099, 0})
+	ac.Nav.FlightState.Position = math.NM2LL(p1, nmPerLongitude)
+	ac.Nav.FlightState.Altitude = 5050
+	c.updateExtrapolation(t1)
+
+	// The earlier extrapolation error is blended in at first...
+	perr := math.Sub2f(math.Add2f(p0, [2]float32{0, 0.1}), p1)
+	if p, alt := track(t1); !near(p, math.Add2f(p1, perr)) || alt != 5000 {
+		t.Errorf("expected the previous extrapolation at the update, got %v %f", p, alt)
+	}
+	// ...and fades out.
+	if p, _ := track(t1.Add(reconcileDuration / 2)); !near(p, math.Add2f(math.Add2f(p1, [2]float32{0.1, 0}),
+		math.Scale2f(perr, 0.5))) {
+		t.Errorf("expected half of the error to be blended out, got %v", p)
+	}
+	// Once it's gone, the track follows the ground track at the
+	// groundspeed and climbs at the observed rate.
+	p, alt := track(t1.Add(reconcileDuration))
+	if !near(p, math.Add2f(p1, [2]float32{0.2, 0})) {
+		t.Errorf("expected extrapolation along the ground track, got %v from %v", p, p1)
+	}
+	if alt != 5150 {
+		t.Errorf("expected altitude 5150, got %f", alt)
+	}
+
+	// Extrapolation stops after a while.
+	if p, _ := track(t1.Add(time.Minute)); !near(p, math.Add2f(p1, math.Scale2f([2]float32{0.1, 0},
+		float32(maxExtrapolation.Seconds())))) {
+		t.Errorf("expected extrapolation to stop after %s, got %v", maxExtrapolation, p)
+	}
+
+	// Large errors aren't blended; the track jumps to the report.
+	t2 := t1.Add(time.Second)
+	p2 := math.Add2f(p1, [2]float32{0, 3})
+	ac.Nav.FlightState.Position = math.NM2LL(p2, nmPerLongitude)
+	c.updateExtrapolation(t2)
+	if p, _ := track(t2); !near(p, p2) {
+		t.Errorf("expected the track at the reported position, got %v vs %v", p, p2)
+	}
+}
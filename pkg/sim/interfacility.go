@@ -0,0 +1,184 @@
+// pkg/sim/interfacility.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/util"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+// AdjacentFacility holds what's needed to run the scopes of a facility
+// from another scenario group whose controllers are signed in to the sim.
+// The traffic all comes from the sim's own scenario; handoffs, pointouts,
+// and flight plan messages between facilities go through the NAS
+// computers.
+type AdjacentFacility struct {
+	ScenarioGroup           string
+	Center                  math.Point2LL
+	Range                   float32
+	RadarSites              map[string]*av.RadarSite
+	Scratchpads             map[string]string
+	DefaultMaps             []string
+	STARSFacilityAdaptation STARSFacilityAdaptation
+
+	videoMaps map[string]*av.VideoMap
+}
+
+// addAdjacentFacility makes the positions of the adjacent scenario group's
+// facility available for sign on.
+func (s *Sim) addAdjacentFacility(sg, adj *ScenarioGroup, ml *av.VideoMapLibrary) {
+	if adj.TRACON == sg.TRACON {
+		return
+	}
+
+	fa := adj.STARSFacilityAdaptation
+	af := &AdjacentFacility{
+		ScenarioGroup:           adj.Name,
+		Center:                  fa.Center,
+		Range:                   fa.Range,
+		RadarSites:              fa.RadarSites,
+		Scratchpads:             fa.Scratchpads,
+		STARSFacilityAdaptation: fa,
+		videoMaps:               loadVideoMaps(fa, ml, s.lg),
+	}
+	if sc, ok := adj.Scenarios[adj.DefaultScenario]; ok {
+		af.Center = util.Select(sc.Center.IsZero(), af.Center, sc.Center)
+		af.Range = util.Select(sc.Range == 0, af.Range, sc.Range)
+		af.DefaultMaps = sc.DefaultMaps
+	}
+	if s.State.AdjacentFacilities == nil {
+		s.State.AdjacentFacilities = make(map[string]*AdjacentFacility)
+	}
+	s.State.AdjacentFacilities[adj.TRACON] = af
+
+	// The facility's own positions are the ones without a facility
+	// identifier.
+	for callsign, ctrl := range adj.ControlPositions {
+		if ctrl.FacilityIdentifier != "" || ctrl.ERAMFacility {
+			continue
+		} else if _, ok := s.SignOnPositions[callsign]; ok {
+			continue
+		}
+
+		c := *ctrl
+		if pc, ok := sg.ControlPositions[callsign]; ok {
+			// Use our scenario group's definition of the position (if
+			// there is one) so that it is shown to our controllers the
+			// way it would be if it was virtual.
+			c = *pc
+		}
+		c.Facility = adj.TRACON
+		c.IsHuman = true
+		s.SignOnPositions[callsign] = &c
+	}
+
+	s.lg.Infof("%s/%s: added adjacent facility", adj.TRACON, adj.Name)
+}
+
+// addCenterPositions allows humans to sign on to the scenario group's
+// center positions; they are handled by virtual controllers otherwise.
+func (s *Sim) addCenterPositions(sg *ScenarioGroup) {
+	tracon, ok := av.DB.TRACONs[sg.TRACON]
+	if !ok {
+		return
+	}
+	for callsign, ctrl := range sg.ControlPositions {
+		if _, ok := s.SignOnPositions[callsign]; ctrl.ERAMFacility && !ok {
+			c := *ctrl
+			c.Facility = tracon.ARTCC
+			c.IsHuman = true
+			s.SignOnPositions[callsign] = &c
+		}
+	}
+}
+
+// assignFacility sets the facility of the sim's own positions so that
+// they can be distinguished from the positions of adjacent facilities.
+func (s *Sim) assignFacility(tracon string) {
+	own := func(ctrl *av.Controller) bool {
+		return ctrl.Facility == "" && ctrl.FacilityIdentifier == "" && !ctrl.ERAMFacility
+	}
+
+	for _, ctrl := range s.SignOnPositions {
+		if own(ctrl) {
+			ctrl.Facility = tracon
+		}
+	}
+	for callsign, ctrl := range s.State.Controllers {
+		if own(ctrl) {
+			// These are shared with the scenario group, so make a copy.
+			c := *ctrl
+			c.Facility = tracon
+			s.State.Controllers[callsign] = &c
+		}
+	}
+}
+
+// STARSComputerForController returns the STARS computer of the given
+// controller's facility. Controllers that aren't at an adjacent
+// facility, including center controllers, use the sim's.
+func (ss *State) STARSComputerForController(ctrl *av.Controller) *STARSComputer {
+	if ctrl != nil {
+		if _, ok := ss.AdjacentFacilities[ctrl.Facility]; ok {
+			if _, stars, err := ss.ERAMComputers.FacilityComputers(ctrl.Facility); err == nil && stars != nil {
+				return stars
+			}
+		}
+	}
+	return ss.STARSComputer()
+}
+
+// facilityAdaptation returns the STARS adaptation for the given
+// controller's facility.
+func (ss *State) facilityAdaptation(ctrl *av.Controller) STARSFacilityAdaptation {
+	if af, ok := ss.AdjacentFacilities[ctrl.Facility]; ok {
+		return af.STARSFacilityAdaptation
+	}
+	return ss.STARSFacilityAdaptation
+}
+
+// sameFacility returns true if both controllers are at the same facility.
+func (ss *State) sameFacility(a, b *av.Controller) bool {
+	fa, _ := ss.FacilityFromController(a.Callsign)
+	fb, _ := ss.FacilityFromController(b.Callsign)
+	return fa == fb
+}
+
+// drawAdjacentFacilitiesUI allows selecting scenario groups from other
+// TRACONs in the same ARTCC whose positions will also be available in a
+// multi-controller sim.
+func (c *NewSimConfiguration) drawAdjacentFacilitiesUI() {
+	imgui.Checkbox("Allow center positions to be signed on", &c.HumanCenterPositions)
+
+	artcc := av.DB.TRACONs[c.TRACONName].ARTCC
+	var tracons []string
+	for _, tracon := range util.SortedMapKeys(c.selectedServer.configs) {
+		if t, ok := av.DB.TRACONs[tracon]; ok && tracon != c.TRACONName && t.ARTCC == artcc {
+			tracons = append(tracons, tracon)
+		}
+	}
+	if len(tracons) == 0 || !imgui.CollapsingHeader("Adjacent facilities") {
+		return
+	}
+
+	for _, tracon := range tracons {
+		for _, group := range util.SortedMapKeys(c.selectedServer.configs[tracon]) {
+			selected := c.AdjacentFacilities[tracon] == group
+			if imgui.Checkbox(tracon+": "+group, &selected) {
+				if selected {
+					if c.AdjacentFacilities == nil {
+						c.AdjacentFacilities = make(map[string]string)
+					}
+					c.AdjacentFacilities[tracon] = group
+				} else {
+					delete(c.AdjacentFacilities, tracon)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// pkg/sim/interfacility_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/util"
+)
+
+func TestAdjacentSTARSMessages(t *testing.T) {
+	artcc := av.DB.TRACONs["N90"].ARTCC
+	ec := MakeERAMComputer(artcc, av.ERAMAdaptation{}, 0, nil)
+
+	var other string
+	for _, id := range util.SortedMapKeys(ec.STARSComputers) {
+		if id != "N90" {
+			other = id
+			break
+		}
+	}
+	if other == "" {
+		t.Skipf("no other TRACONs under %s", artcc)
+	}
+
+	n90, adj := ec.STARSComputers["N90"], ec.STARSComputers[other]
+	if n90.adjacentSTARSComputer(other) != adj {
+		t.Errorf("expected to find %s's STARS computer from N90's", other)
+	}
+	if n90.adjacentSTARSComputer("N90") != nil {
+		t.Errorf("N90 shouldn't be adjacent to itself")
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	msg := FlightPlanMessage{MessageType: InitiateTransfer, BCN: 0o1234}
+	msg.Identifier = "AAL1"
+
+	// Messages to another TRACON under the same ERAM go directly to it.
+	n90.SendTrackInfo(other, msg, now)
+	if len(adj.ReceivedMessages) != 1 || adj.ReceivedMessages[0].Identifier != "AAL1" {
+		t.Errorf("expected message delivered to %s, got %+v", other, adj.ReceivedMessages)
+	}
+	if src := adj.ReceivedMessages[0].SourceID; src != "N901200Z" {
+		t.Errorf("unexpected source id %q", src)
+	}
+
+	// Anything else goes to the overlying ERAM.
+	n90.SendTrackInfo("ZZZ", msg, now)
+	if len(ec.ReceivedMessages) != 1 {
+		t.Errorf("expected message sent to %s, got %+v", artcc, ec.ReceivedMessages)
+	}
+
+	// The ERAM link is restored when a saved sim is loaded.
+	n90.eram = nil
+	ec.Activate(nil)
+	if n90.adjacentSTARSComputer(other) != adj {
+		t.Errorf("expected ERAM link to be restored after Activate")
+	}
+}
+
+func TestSignOnFacilities(t *testing.T) {
+	artcc := av.DB.TRACONs["N90"].ARTCC
+	sg := &ScenarioGroup{
+		TRACON: "N90",
+		ControlPositions: map[string]*av.Controller{
+			"JFK_APP": {Callsign: "JFK_APP"},
+			"NY_CTR":  {Callsign: "NY_CTR", ERAMFacility: true},
+			"PHL_APP": {Callsign: "PHL_APP", FacilityIdentifier: "P"},
+		},
+	}
+	s := &Sim{
+		SignOnPositions: map[string]*av.Controller{"JFK_APP": {Callsign: "JFK_APP", IsHuman: true}},
+		State: &State{
+			TRACON:      "N90",
+			Controllers: util.DuplicateMap(sg.ControlPositions),
+		},
+	}
+
+	s.addCenterPositions(sg)
+	ctr, ok := s.SignOnPositions["NY_CTR"]
+	if !ok || ctr.Facility != artcc || !ctr.IsHuman {
+		t.Errorf("expected NY_CTR to be available at %s, got %+v", artcc, ctr)
+	}
+	if _, ok := s.SignOnPositions["PHL_APP"]; ok {
+		t.Errorf("other facilities' positions shouldn't be added as center positions")
+	}
+
+	s.assignFacility("N90")
+	if f := s.SignOnPositions["JFK_APP"].Facility; f != "N90" {
+		t.Errorf("expected JFK_APP sign-on position at N90, got %q", f)
+	}
+	if f := s.State.Controllers["JFK_APP"].Facility; f != "N90" {
+		t.Errorf("expected JFK_APP controller at N90, got %q", f)
+	}
+	if f := s.State.Controllers["PHL_APP"].Facility; f != "" {
+		t.Errorf("expected PHL_APP's facility to be left alone, got %q", f)
+	}
+	if sg.ControlPositions["JFK_APP"].Facility != "" {
+		t.Errorf("scenario group's controllers shouldn't be modified")
+	}
+
+	if !s.State.sameFacility(s.State.Controllers["JFK_APP"], &av.Controller{Callsign: "N90_DEP"}) {
+		t.Errorf("expected JFK_APP and N90_DEP to be at the same facility")
+	}
+	s.State.Controllers["NY_CTR"] = ctr
+	if s.State.sameFacility(s.State.Controllers["JFK_APP"], ctr) {
+		t.Errorf("JFK_APP and NY_CTR shouldn't be at the same facility")
+	}
+}
@@ -5,6 +5,7 @@
 package sim
 
 import (
+	"crypto/subtle"
 	"errors"
 	"log/slog"
 	"os"
@@ -25,6 +26,7 @@ type SimManager struct {
 	configs              map[string]map[string]*Configuration
 	activeSims           map[string]*Sim
 	controllerTokenToSim map[string]*Sim
+	config               *ServerConfig // nil if access isn't restricted
 	mu                   util.LoggingMutex
 	mapLibrary           *av.VideoMapLibrary
 	startTime            time.Time
@@ -33,12 +35,13 @@ type SimManager struct {
 
 func NewSimManager(scenarioGroups map[string]map[string]*ScenarioGroup,
 	simConfigurations map[string]map[string]*Configuration, mapLib *av.VideoMapLibrary,
-	lg *log.Logger) *SimManager {
+	config *ServerConfig, lg *log.Logger) *SimManager {
 	return &SimManager{
 		scenarioGroups:       scenarioGroups,
 		configs:              simConfigurations,
 		activeSims:           make(map[string]*Sim),
 		controllerTokenToSim: make(map[string]*Sim),
+		config:               config,
 		mapLibrary:           mapLib,
 		startTime:            time.Now(),
 		lg:                   lg,
@@ -48,13 +51,24 @@ func NewSimManager(scenarioGroups map[string]map[string]*ScenarioGroup,
 type NewSimResult struct {
 	SimState        *State
 	ControllerToken string
+	ReconnectToken  string
 }
 
 func (sm *SimManager) New(config *NewSimConfiguration, result *NewSimResult) error {
+	user, err := sm.config.authenticate(config.AuthToken)
+	if err != nil {
+		return err
+	}
+
 	if config.NewSimType == NewSimCreateLocal || config.NewSimType == NewSimCreateRemote {
+		if !sm.config.canCreateSims(user) || !sm.config.facilityAvailable(config.TRACONName) {
+			sm.lg.Warnf("%s: not authorized to create %s sim", user.Name, config.TRACONName)
+			return ErrNotAuthorized
+		}
+
 		sim := NewSim(*config, sm.scenarioGroups, config.NewSimType == NewSimCreateLocal, sm.mapLibrary, sm.lg)
 		sim.prespawn()
-		return sm.Add(sim, result)
+		return sm.add(sim, user.Role, result)
 	} else {
 		sm.mu.Lock(sm.lg)
 		defer sm.mu.Unlock(sm.lg)
@@ -63,15 +77,34 @@ func (sm *SimManager) New(config *NewSimConfiguration, result *NewSimResult) err
 		if !ok {
 			return ErrNoNamedSim
 		}
-		if _, ok := sim.State.Controllers[config.SelectedRemoteSimPosition]; ok {
-			return av.ErrNoController
-		}
 
-		if sim.RequirePassword && config.RemoteSimPassword != sim.Password {
-			return ErrInvalidPassword
-		}
+		var ss *State
+		var token string
+		if config.SelectedRemoteSimPosition == ObserverPosition {
+			// Without a separate observer password, observers need the
+			// sim's password, if it has one.
+			if sim.RequireObserverPassword {
+				if config.RemoteSimPassword != sim.ObserverPassword {
+					return ErrInvalidPassword
+				}
+			} else if sim.RequirePassword && config.RemoteSimPassword != sim.Password {
+				return ErrInvalidPassword
+			}
 
-		ss, token, err := sim.SignOn(config.SelectedRemoteSimPosition)
+			ss, token, err = sim.SignOnObserver(config.ObserverName)
+		} else {
+			if user.Role < RoleController {
+				return ErrNotAuthorized
+			}
+			if _, ok := sim.State.Controllers[config.SelectedRemoteSimPosition]; ok {
+				return av.ErrNoController
+			}
+			if sim.RequirePassword && config.RemoteSimPassword != sim.Password {
+				return ErrInvalidPassword
+			}
+
+			ss, token, err = sim.SignOn(config.SelectedRemoteSimPosition, user.Role)
+		}
 		if err != nil {
 			return err
 		}
@@ -81,12 +114,22 @@ func (sm *SimManager) New(config *NewSimConfiguration, result *NewSimResult) err
 		*result = NewSimResult{
 			SimState:        ss,
 			ControllerToken: token,
+			ReconnectToken:  sim.ReconnectToken(token),
 		}
 		return nil
 	}
 }
 
+// Add adds a previously-running sim; it's used to restore local sims, so
+// it isn't allowed on servers that restrict access.
 func (sm *SimManager) Add(sim *Sim, result *NewSimResult) error {
+	if sm.config != nil {
+		return ErrNotAuthorized
+	}
+	return sm.add(sim, RoleInstructor, result)
+}
+
+func (sm *SimManager) add(sim *Sim, role Role, result *NewSimResult) error {
 	if sim.State == nil {
 		return errors.New("incomplete Sim; nil *State")
 	}
@@ -106,7 +149,7 @@ func (sm *SimManager) Add(sim *Sim, result *NewSimResult) error {
 
 	sm.mu.Unlock(sm.lg)
 
-	ss, token, err := sim.SignOn(sim.State.PrimaryController)
+	ss, token, err := sim.SignOn(sim.State.PrimaryController, role)
 	if err != nil {
 		return err
 	}
@@ -123,7 +166,11 @@ func (sm *SimManager) Add(sim *Sim, result *NewSimResult) error {
 			time.Sleep(100 * time.Millisecond)
 		}
 
-		sm.lg.Infof("%s: terminating sim after %s idle", sim.Name, sim.IdleTime())
+		if sim.isTerminated() {
+			sm.lg.Infof("%s: terminating sim at admin request", sim.Name)
+		} else {
+			sm.lg.Infof("%s: terminating sim after %s idle", sim.Name, sim.IdleTime())
+		}
 		sm.mu.Lock(sm.lg)
 		delete(sm.activeSims, sim.Name)
 		// FIXME: these don't get cleaned up during Sim SignOff()
@@ -138,21 +185,66 @@ func (sm *SimManager) Add(sim *Sim, result *NewSimResult) error {
 	*result = NewSimResult{
 		SimState:        ss,
 		ControllerToken: token,
+		ReconnectToken:  sim.ReconnectToken(token),
 	}
 
 	return nil
 }
 
+type ReconnectArgs struct {
+	SimName        string
+	ReconnectToken string
+}
+
+// Reconnect re-attaches a controller to the position it held in the named
+// sim before its connection dropped.
+func (sm *SimManager) Reconnect(args *ReconnectArgs, result *NewSimResult) error {
+	sm.mu.Lock(sm.lg)
+	defer sm.mu.Unlock(sm.lg)
+
+	sim, ok := sm.activeSims[args.SimName]
+	if !ok {
+		return ErrNoNamedSim
+	}
+
+	ss, token, oldToken, err := sim.Reconnect(args.ReconnectToken)
+	if err != nil {
+		return err
+	}
+
+	delete(sm.controllerTokenToSim, oldToken)
+	sm.controllerTokenToSim[token] = sim
+
+	*result = NewSimResult{
+		SimState:        ss,
+		ControllerToken: token,
+		ReconnectToken:  args.ReconnectToken,
+	}
+	return nil
+}
+
+type SignOnArgs struct {
+	Version   int
+	AuthToken string
+}
+
 type SignOnResult struct {
 	Configurations map[string]map[string]*Configuration
 	RunningSims    map[string]*RemoteSim
+	Role           Role
+	CanCreateSims  bool
 }
 
-func (sm *SimManager) SignOn(version int, result *SignOnResult) error {
-	if version != ViceRPCVersion {
+func (sm *SimManager) SignOn(args *SignOnArgs, result *SignOnResult) error {
+	if args.Version != ViceRPCVersion {
 		return ErrRPCVersionMismatch
 	}
 
+	user, err := sm.config.authenticate(args.AuthToken)
+	if err != nil {
+		return err
+	}
+
 	// Before we acquire the lock...
 	if err := sm.GetRunningSims(0, &result.RunningSims); err != nil {
 		return err
@@ -161,7 +253,14 @@ func (sm *SimManager) SignOn(version int, result *SignOnResult) error {
 	sm.mu.Lock(sm.lg)
 	defer sm.mu.Unlock(sm.lg)
 
-	result.Configurations = sm.configs
+	result.Role = user.Role
+	result.CanCreateSims = sm.config.canCreateSims(user)
+	result.Configurations = make(map[string]map[string]*Configuration)
+	for facility, configs := range sm.configs {
+		if sm.config.facilityAvailable(facility) {
+			result.Configurations[facility] = configs
+		}
+	}
 
 	return nil
 }
@@ -174,27 +273,35 @@ func (sm *SimManager) GetRunningSims(_ int, result *map[string]*RemoteSim) error
 	for name, s := range sm.activeSims {
 		s.mu.Lock(s.lg)
 		rs := &RemoteSim{
-			GroupName:          s.ScenarioGroup,
-			ScenarioName:       s.Scenario,
-			PrimaryController:  s.State.PrimaryController,
-			RequirePassword:    s.RequirePassword,
-			AvailablePositions: make(map[string]struct{}),
-			CoveredPositions:   make(map[string]struct{}),
+			GroupName:               s.ScenarioGroup,
+			ScenarioName:            s.Scenario,
+			PrimaryController:       s.State.PrimaryController,
+			RequirePassword:         s.RequirePassword,
+			RequireObserverPassword: s.RequireObserverPassword,
+			AvailablePositions:      make(map[string]struct{}),
+			CoveredPositions:        make(map[string]struct{}),
 		}
 
 		// Figure out which positions are available; start with all of the possible ones,
 		// then delete those that are active
 		rs.AvailablePositions[s.State.PrimaryController] = struct{}{}
-		for callsign := range s.State.MultiControllers {
+		for callsign := range s.SignOnPositions {
+			// This includes adjacent facilities' positions and center
+			// positions, if they were enabled.
 			rs.AvailablePositions[callsign] = struct{}{}
 		}
 		for _, ctrl := range s.controllers {
+			if ctrl.IsObserver() {
+				rs.Observers = append(rs.Observers, ctrl.observerName)
+				continue
+			}
 			delete(rs.AvailablePositions, ctrl.Callsign)
 			if wc, ok := s.State.Controllers[ctrl.Callsign]; ok && wc.IsHuman {
 				rs.CoveredPositions[ctrl.Callsign] = struct{}{}
 			}
 		}
 		s.mu.Unlock(s.lg)
+		sort.Strings(rs.Observers)
 
 		running[name] = rs
 	}
@@ -206,6 +313,9 @@ func (sm *SimManager) GetRunningSims(_ int, result *map[string]*RemoteSim) error
 const simIdleLimit = 4 * time.Hour
 
 func (sm *SimManager) SimShouldExit(sim *Sim) bool {
+	if sim.isTerminated() {
+		return true
+	}
 	if sim.IdleTime() < simIdleLimit {
 		return false
 	}
@@ -233,6 +343,11 @@ func (sm *SimManager) GetSerializeSim(token string, s *Sim) error {
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	// This includes everything about the aircraft, not just what
+	// controllers see.
+	if role, _ := sim.ControllerRole(token); role < RoleInstructor {
+		return ErrNotAuthorized
+	}
 	*s = *sim
 	return nil
 }
@@ -284,7 +399,7 @@ func (sm *SimManager) getSimStatus() []simStatus {
 
 		var controllers []string
 		for _, ctrl := range sim.controllers {
-			controllers = append(controllers, ctrl.Callsign)
+			controllers = append(controllers, ctrl.displayName())
 		}
 		sort.Strings(controllers)
 		status.Controllers = strings.Join(controllers, ", ")
@@ -296,49 +411,67 @@ func (sm *SimManager) getSimStatus() []simStatus {
 }
 
 type SimBroadcastMessage struct {
-	Password string
-	Message  string
+	Password  string
+	AuthToken string
+	Message   string
 }
 
-func (sm *SimManager) Broadcast(m *SimBroadcastMessage, _ *struct{}) error {
+// authorizeAdmin checks that the caller may perform server administration:
+// on servers that restrict access, authToken must be an admin's;
+// otherwise, password must match the contents of the "password" file.
+func (sm *SimManager) authorizeAdmin(password, authToken string) error {
+	if sm.config != nil {
+		if user, err := sm.config.authenticate(authToken); err != nil {
+			return err
+		} else if user.Role < RoleAdmin {
+			return ErrNotAuthorized
+		}
+		return nil
+	}
+
 	pw, err := os.ReadFile("password")
 	if err != nil {
 		return err
 	}
 
-	password := strings.TrimRight(string(pw), "\n\r")
-	if password != m.Password {
+	pw = []byte(strings.TrimRight(string(pw), "\n\r"))
+	if password == "" || subtle.ConstantTimeCompare(pw, []byte(password)) != 1 {
 		return ErrInvalidPassword
 	}
+	return nil
+}
 
+func (sm *SimManager) Broadcast(m *SimBroadcastMessage, _ *struct{}) error {
+	if err := sm.authorizeAdmin(m.Password, m.AuthToken); err != nil {
+		return err
+	}
+
+	sm.broadcast(m.Message)
+	return nil
+}
+
+func (sm *SimManager) broadcast(msg string) {
 	sm.mu.Lock(sm.lg)
 	defer sm.mu.Unlock(sm.lg)
 
-	sm.lg.Infof("Broadcasting message: %s", m.Message)
+	sm.lg.Infof("Broadcasting message: %s", msg)
 
 	for _, sim := range sm.activeSims {
-		sim.mu.Lock(sim.lg)
-
-		sim.eventStream.Post(Event{
-			Type:    ServerBroadcastMessageEvent,
-			Message: m.Message,
-		})
-
-		sim.mu.Unlock(sim.lg)
+		sim.postServerMessage(msg)
 	}
-	return nil
 }
 
-func BroadcastMessage(hostname, msg, password string, lg *log.Logger) {
-	client, err := getClient(hostname, lg)
+func BroadcastMessage(hostname, msg, password, authToken string, tlsConfig util.ClientTLSConfig, lg *log.Logger) {
+	client, err := getClient(hostname, tlsConfig, lg)
 	if err != nil {
 		lg.Errorf("unable to get client for broadcast: %v", err)
 		return
 	}
 
 	err = client.CallWithTimeout("SimManager.Broadcast", &SimBroadcastMessage{
-		Password: password,
-		Message:  msg,
+		Password:  password,
+		AuthToken: authToken,
+		Message:   msg,
 	}, nil)
 
 	if err != nil {
@@ -0,0 +1,132 @@
+// pkg/sim/manager_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestObserverPasswords(t *testing.T) {
+	sm := NewSimManager(nil, nil, nil, nil, nil)
+	add := func(name string, requirePassword, requireObserverPassword bool) {
+		s := makeTestSim(time.Time{}, nil)
+		s.Name = name
+		s.RequirePassword, s.Password = requirePassword, "sim"
+		s.RequireObserverPassword, s.ObserverPassword = requireObserverPassword, "observer"
+		sm.activeSims[name] = s
+	}
+	add("open", false, false)
+	add("private", true, false)
+	add("observed", true, true)
+
+	for _, test := range []struct {
+		sim, position, password string
+		err                     error
+	}{
+		{"open", ObserverPosition, "", nil},
+		// Without an observer password, observers need the sim's.
+		{"private", ObserverPosition, "", ErrInvalidPassword},
+		{"private", ObserverPosition, "observer", ErrInvalidPassword},
+		{"private", ObserverPosition, "sim", nil},
+		// Otherwise they need the observer password.
+		{"observed", ObserverPosition, "sim", ErrInvalidPassword},
+		{"observed", ObserverPosition, "observer", nil},
+		{"observed", "JFK_APP", "observer", ErrInvalidPassword},
+		{"observed", "JFK_APP", "sim", nil},
+	} {
+		var result NewSimResult
+		err := sm.New(&NewSimConfiguration{
+			NewSimType:                NewSimJoinRemote,
+			SelectedRemoteSim:         test.sim,
+			SelectedRemoteSimPosition: test.position,
+			RemoteSimPassword:         test.password,
+			ObserverName:              "mentor",
+		}, &result)
+		if err != test.err {
+			t.Errorf("%s %s with password %q: expected %v, got %v", test.sim, test.position, test.password,
+				test.err, err)
+		}
+		if err == nil && result.ControllerToken == "" {
+			t.Errorf("%s %s: no token", test.sim, test.position)
+		}
+	}
+
+	var running map[string]*RemoteSim
+	if err := sm.GetRunningSims(0, &running); err != nil {
+		t.Fatal(err)
+	}
+	if obs := running["private"].Observers; !slices.Equal(obs, []string{"mentor"}) {
+		t.Errorf("expected observer in running sims, got %v", obs)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	sm := NewSimManager(nil, nil, nil, nil, nil)
+	s := makeTestSim(time.Time{}, nil)
+	s.Paused = true // keep Update from running the sim
+	sm.activeSims[s.Name] = s
+
+	var nsr NewSimResult
+	if err := sm.New(&NewSimConfiguration{
+		NewSimType:                NewSimJoinRemote,
+		SelectedRemoteSim:         "test",
+		SelectedRemoteSimPosition: "JFK_APP",
+	}, &nsr); err != nil {
+		t.Fatal(err)
+	}
+	if nsr.ReconnectToken == "" || nsr.ReconnectToken == nsr.ControllerToken {
+		t.Fatalf("unexpected reconnect token %q", nsr.ReconnectToken)
+	}
+
+	var result NewSimResult
+	if err := sm.Reconnect(&ReconnectArgs{SimName: "test", ReconnectToken: "bogus"}, &result); err != ErrInvalidReconnectToken {
+		t.Errorf("expected ErrInvalidReconnectToken, got %v", err)
+	}
+	if err := sm.Reconnect(&ReconnectArgs{SimName: "other", ReconnectToken: nsr.ReconnectToken}, &result); err != ErrNoNamedSim {
+		t.Errorf("expected ErrNoNamedSim, got %v", err)
+	}
+
+	// Reconnecting replaces the controller token but keeps the position.
+	if err := sm.Reconnect(&ReconnectArgs{SimName: "test", ReconnectToken: nsr.ReconnectToken}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.ControllerToken == nsr.ControllerToken || result.ReconnectToken != nsr.ReconnectToken ||
+		result.SimState.Callsign != "JFK_APP" {
+		t.Errorf("unexpected reconnect result %+v", result)
+	}
+	if _, ok := sm.ControllerTokenToSim(nsr.ControllerToken); ok {
+		t.Errorf("old controller token should no longer be valid")
+	}
+	if sim, ok := sm.ControllerTokenToSim(result.ControllerToken); !ok || sim != s {
+		t.Errorf("new controller token should map to the sim")
+	}
+	var wu WorldUpdate
+	if err := s.GetWorldUpdate(nsr.ControllerToken, 0, &wu); err != ErrInvalidControllerToken {
+		t.Errorf("expected old token to be refused, got %v", err)
+	}
+	if err := s.GetWorldUpdate(result.ControllerToken, 0, &wu); err != nil {
+		t.Errorf("unexpected error with new token: %v", err)
+	}
+
+	// Controllers are held through the grace period...
+	ctrl := s.controllers[result.ControllerToken]
+	ctrl.lastUpdateCall = time.Now().Add(-reconnectGracePeriod / 2)
+	s.Update()
+	if !s.controllerIsSignedIn("JFK_APP") {
+		t.Errorf("JFK_APP should still be signed in during the grace period")
+	}
+
+	// ...and then signed off, after which they can't reconnect.
+	ctrl.lastUpdateCall = time.Now().Add(-reconnectGracePeriod - time.Second)
+	s.Update()
+	if s.controllerIsSignedIn("JFK_APP") {
+		t.Errorf("JFK_APP should have been signed off after the grace period")
+	}
+	if err := sm.Reconnect(&ReconnectArgs{SimName: "test", ReconnectToken: nsr.ReconnectToken}, &result); err != ErrInvalidReconnectToken {
+		t.Errorf("expected ErrInvalidReconnectToken after sign off, got %v", err)
+	}
+}
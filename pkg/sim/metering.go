@@ -0,0 +1,318 @@
+// pkg/sim/metering.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/util"
+)
+
+const (
+	// Runway acceptance rate (arrivals per hour) used for runways that
+	// don't specify one in the scenario.
+	DefaultAcceptanceRate = 30
+	// Arrivals' schedules are frozen once they're this close to the
+	// runway.
+	meteringFreezeHorizon = 20 * time.Minute
+	// Virtual controllers start absorbing delay once it is more than
+	// this.
+	meteringSpeedDelayThreshold = time.Minute
+	// Delay beyond what can be absorbed with speed control is absorbed
+	// in holding if it is more than this.
+	meteringHoldDelayThreshold = 3 * time.Minute
+)
+
+// MeteredArrival holds the times the arrival metering subsystem has
+// computed for an arrival: its estimated times of arrival (ETAs) at its
+// meter fix and the runway threshold, given its current route and speed,
+// and the scheduled times of arrival (STAs) that it has been assigned.
+type MeteredArrival struct {
+	Callsign    string
+	Airport     string
+	Runway      string
+	MeterFix    string // empty once the aircraft has passed it
+	MeterFixETA time.Time
+	MeterFixSTA time.Time
+	RunwayETA   time.Time
+	RunwaySTA   time.Time
+	Frozen      bool
+
+	// If metering has slowed the aircraft to absorb delay, the speed it
+	// assigned and the aircraft's groundspeed before and (approximately)
+	// after the reduction.
+	MeteringSpeed int
+	UnimpededGS   float32
+	MeteringGS    float32
+}
+
+// Delay returns the amount of time the arrival must lose to meet its
+// runway STA.
+func (m MeteredArrival) Delay() time.Duration {
+	return m.RunwaySTA.Sub(m.RunwayETA)
+}
+
+// MeteredArrivals returns the metered arrivals for the given airport
+// (or all airports, if empty), sorted by runway STA.
+func (ss *State) MeteredArrivals(airport string) []MeteredArrival {
+	var m []MeteredArrival
+	for _, ma := range ss.Metering {
+		if airport == "" || ma.Airport == airport {
+			m = append(m, ma)
+		}
+	}
+	slices.SortFunc(m, func(a, b MeteredArrival) int { return a.RunwaySTA.Compare(b.RunwaySTA) })
+	return m
+}
+
+// acceptanceRate returns the number of arrivals per hour that the runway
+// can accept.
+func (ss *State) acceptanceRate(airport, runway string) int {
+	for _, rwy := range ss.ArrivalRunways {
+		if rwy.Airport == airport && rwy.Runway == runway && rwy.AcceptanceRate > 0 {
+			return rwy.AcceptanceRate
+		}
+	}
+	return DefaultAcceptanceRate
+}
+
+// meteringRunway returns the runway the arrival is expected to land on:
+// that of its assigned approach if it has one and otherwise the first
+// active arrival runway at its destination.
+func (ss *State) meteringRunway(ac *av.Aircraft) (string, bool) {
+	if ac.Nav.Approach.Assigned != nil {
+		return ac.Nav.Approach.Assigned.Runway, true
+	}
+	for _, rwy := range ss.ArrivalRunways {
+		if rwy.Airport == ac.FlightPlan.ArrivalAirport {
+			return rwy.Runway, true
+		}
+	}
+	return "", false
+}
+
+// meteringETAs estimates when the aircraft will reach its meter fix (the
+// fix where it is handed off from the center) and the runway threshold.
+// The aircraft is assumed to hold its current groundspeed (or its
+// assigned speed, if a controller has slowed it) until the meter fix and
+// to then slow steadily to its landing speed. Speed reductions made by
+// metering itself are ignored so that the ETAs don't slip as the
+// aircraft absorbs its delay.
+func meteringETAs(ac *av.Aircraft, ma MeteredArrival, now time.Time) (meterFix string, fixETA, rwyETA time.Time) {
+	fs := &ac.Nav.FlightState
+	gs := math.Max(fs.GS, 60)
+	if ma.MeteringSpeed != 0 {
+		gs = ma.UnimpededGS
+	} else if spd := ac.Nav.Speed.Assigned; spd != nil {
+		gs = math.Max(math.Min(gs, av.IASToTAS(*spd, fs.Altitude)), 60)
+	}
+	finalGS := (gs + ac.Nav.Perf.Speed.Landing) / 2
+
+	fixETA = now
+	p := fs.Position
+	if idx := slices.IndexFunc(ac.Nav.Waypoints, func(wp av.Waypoint) bool { return wp.Handoff }); idx != -1 {
+		meterFix = ac.Nav.Waypoints[idx].Fix
+		d, err := ac.DistanceAlongRoute(meterFix)
+		if err != nil {
+			// Vectored; assume it will be turned back direct to the fix.
+			d = math.NMDistance2LL(p, ac.Nav.Waypoints[idx].Location)
+		}
+		fixETA = now.Add(flightTime(d, gs))
+		p = ac.Nav.Waypoints[idx].Location
+	}
+
+	d := math.NMDistance2LL(p, fs.ArrivalAirportLocation)
+	rwyETA = fixETA.Add(flightTime(d, finalGS))
+	return
+}
+
+// flightTime returns the time it takes to fly the given distance at the
+// given groundspeed.
+func flightTime(nm, gs float32) time.Duration {
+	return time.Duration(nm / gs * float32(time.Hour))
+}
+
+// updateMetering recomputes ETAs for all arrivals, schedules the ones
+// that aren't frozen into runway slots according to each runway's
+// acceptance rate and wake turbulence separation, and has virtual center
+// controllers absorb any resulting delay.
+func (s *Sim) updateMetering() {
+	if s.State.Metering == nil {
+		s.State.Metering = make(map[string]MeteredArrival)
+	}
+
+	// Discard aircraft that have landed or otherwise left.
+	for callsign := range s.State.Metering {
+		if _, ok := s.State.Aircraft[callsign]; !ok {
+			delete(s.State.Metering, callsign)
+		}
+	}
+
+	type runwayKey struct{ airport, runway string }
+	sequences := make(map[runwayKey][]MeteredArrival)
+	for callsign, ac := range s.State.Aircraft {
+		if !s.State.IsArrival(ac) || !ac.IsAirborne() {
+			continue
+		}
+		rwy, ok := s.State.meteringRunway(ac)
+		if !ok {
+			continue
+		}
+
+		ma := s.State.Metering[callsign]
+		ma.Callsign, ma.Airport, ma.Runway = callsign, ac.FlightPlan.ArrivalAirport, rwy
+		if spd := ac.Nav.Speed.Assigned; ma.MeteringSpeed != 0 && (spd == nil || *spd != float32(ma.MeteringSpeed)) {
+			// A controller has since changed the speed metering assigned.
+			ma.MeteringSpeed, ma.UnimpededGS, ma.MeteringGS = 0, 0, 0
+		}
+		ma.MeterFix, ma.MeterFixETA, ma.RunwayETA = meteringETAs(ac, ma, s.SimTime)
+		if !ma.Frozen && (ma.MeterFix == "" || ma.RunwayETA.Sub(s.SimTime) < meteringFreezeHorizon) {
+			ma.Frozen = true
+			if ma.RunwaySTA.IsZero() {
+				ma.RunwaySTA = ma.RunwayETA
+			}
+		}
+
+		k := runwayKey{ma.Airport, ma.Runway}
+		sequences[k] = append(sequences[k], ma)
+	}
+
+	for k, seq := range sequences {
+		rateSpacing := time.Hour / time.Duration(s.State.acceptanceRate(k.airport, k.runway))
+		for _, ma := range sequenceArrivals(seq, rateSpacing, s.wakeSpacing) {
+			s.State.Metering[ma.Callsign] = s.absorbMeteringDelay(ma)
+		}
+	}
+}
+
+// sequenceArrivals assigns STAs to the arrivals for a single runway.
+// Frozen arrivals keep their slots; the rest are sequenced first-come,
+// first-served behind them, separated by at least the runway's
+// acceptance rate and the wake turbulence separation between each pair.
+// The arrivals are returned in sequence order.
+func sequenceArrivals(seq []MeteredArrival, rateSpacing time.Duration,
+	wakeSpacing func(front, back string) time.Duration) []MeteredArrival {
+	slices.SortFunc(seq, func(a, b MeteredArrival) int {
+		if a.Frozen != b.Frozen {
+			return util.Select(a.Frozen, -1, 1)
+		}
+		if a.Frozen {
+			return a.RunwaySTA.Compare(b.RunwaySTA)
+		}
+		return a.RunwayETA.Compare(b.RunwayETA)
+	})
+
+	for i := range seq {
+		ma := &seq[i]
+		if !ma.Frozen {
+			ma.RunwaySTA = ma.RunwayETA
+			if i > 0 {
+				spacing := max(rateSpacing, wakeSpacing(seq[i-1].Callsign, ma.Callsign))
+				if earliest := seq[i-1].RunwaySTA.Add(spacing); earliest.After(ma.RunwaySTA) {
+					ma.RunwaySTA = earliest
+				}
+			}
+		}
+		ma.MeterFixSTA = ma.RunwaySTA.Add(-ma.RunwayETA.Sub(ma.MeterFixETA))
+	}
+	return seq
+}
+
+// wakeSpacing returns the time that must separate the given arrivals at
+// the runway threshold so that the trailing one has the required wake
+// turbulence separation at its landing speed.
+func (s *Sim) wakeSpacing(front, back string) time.Duration {
+	fac, bac := s.State.Aircraft[front], s.State.Aircraft[back]
+	if fac == nil || bac == nil {
+		return 0
+	}
+	cwt := func(ac *av.Aircraft) string {
+		if perf, ok := av.DB.AircraftPerformance[ac.FlightPlan.BaseType()]; ok {
+			return perf.Category.CWT
+		}
+		return "NOWGT"
+	}
+	sep := av.CWTApproachSeparation(cwt(fac), cwt(bac))
+	if sep == 0 {
+		sep = 3 // minimum radar separation
+	}
+	return time.Duration(sep / math.Max(bac.Nav.Perf.Speed.Landing, 60) * float32(time.Hour))
+}
+
+// absorbMeteringDelay has a virtual center controller slow an arrival
+// that has been assigned delay before it reaches its meter fix and, if
+// that isn't enough, hold it at a fix before the meter fix. It returns
+// the arrival, updated to record any speed reduction.
+func (s *Sim) absorbMeteringDelay(ma MeteredArrival) MeteredArrival {
+	ac := s.State.Aircraft[ma.Callsign]
+	if ma.MeterFix == "" || ac == nil || ac.Holding() || s.controllerIsSignedIn(ac.ControllingController) {
+		return ma
+	}
+	if ac.Nav.Speed.Assigned != nil && ma.MeteringSpeed == 0 {
+		// A controller has assigned a speed; leave the aircraft be.
+		return ma
+	}
+
+	ete := ma.MeterFixETA.Sub(s.SimTime)
+	if ete <= 0 {
+		return ma
+	}
+	delay := ma.Delay()
+
+	if ma.MeteringSpeed == 0 {
+		if delay < meteringSpeedDelayThreshold {
+			return ma
+		}
+
+		// Slow the aircraft enough to lose the delay by the meter fix,
+		// but no slower than we'd assign at the center's altitudes.
+		fs := &ac.Nav.FlightState
+		minSpeed := 10 * math.Ceil(math.Max(210, 1.3*ac.Nav.Perf.Speed.Landing)/10)
+		ias := meteringSpeed(fs.IAS, ete, delay, minSpeed)
+		if ias >= fs.IAS-5 {
+			return ma
+		}
+		s.lg.Info("metering speed reduction", slog.String("callsign", ac.Callsign),
+			slog.Float64("speed", float64(ias)), slog.Duration("delay", delay))
+		ac.AssignSpeed(int(ias), false)
+
+		gs := math.Max(fs.GS, 60)
+		ma.MeteringSpeed, ma.UnimpededGS, ma.MeteringGS = int(ias), gs, gs*ias/fs.IAS
+	}
+
+	// Hold for whatever the speed reduction won't absorb between here
+	// and the meter fix.
+	nm := float32(ete.Hours()) * ma.UnimpededGS
+	delay -= speedDelay(nm, ma.UnimpededGS, ma.MeteringGS)
+
+	if delay > meteringHoldDelayThreshold && len(ac.Nav.Waypoints) > 0 && ac.Nav.Waypoints[0].Fix != ma.MeterFix {
+		fix := ac.Nav.Waypoints[0].Fix
+		s.lg.Info("metering hold", slog.String("callsign", ac.Callsign), slog.String("fix", fix),
+			slog.Duration("delay", delay))
+		ac.HoldAtFix(fix, float32(delay.Seconds()))
+	}
+	return ma
+}
+
+// meteringSpeed returns the speed, rounded down to a multiple of 10 knots
+// and no slower than minSpeed, that an aircraft flying at ias must slow
+// to in order to lose the given delay over the given time en route.
+func meteringSpeed(ias float32, ete, delay time.Duration, minSpeed float32) float32 {
+	spd := ias * float32(ete) / float32(ete+delay)
+	return math.Max(minSpeed, 10*math.Floor(spd/10))
+}
+
+// speedDelay returns the time that is lost by flying the given distance
+// at slowGS rather than gs.
+func speedDelay(nm, gs, slowGS float32) time.Duration {
+	if slowGS <= 0 || slowGS >= gs {
+		return 0
+	}
+	return flightTime(nm, slowGS) - flightTime(nm, gs)
+}
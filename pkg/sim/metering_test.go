@@ -0,0 +1,95 @@
+// pkg/sim/metering_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSequenceArrivals(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(min float64) time.Time { return t0.Add(time.Duration(min * float64(time.Minute))) }
+
+	seq := []MeteredArrival{
+		{Callsign: "AAL1", MeterFixETA: at(0), RunwayETA: at(10)},
+		{Callsign: "UAL2", MeterFixETA: at(0.5), RunwayETA: at(10.5)},
+		// Frozen with a slot later than its ETA; it keeps it and goes first.
+		{Callsign: "DAL3", MeterFixETA: at(5), RunwayETA: at(15), RunwaySTA: at(16), Frozen: true},
+		{Callsign: "JBU4", MeterFixETA: at(20), RunwayETA: at(30)},
+	}
+	wake := func(front, back string) time.Duration {
+		if front == "UAL2" {
+			return 4 * time.Minute
+		}
+		return 0
+	}
+
+	// 30 an hour: two minutes between arrivals.
+	seq = sequenceArrivals(seq, 2*time.Minute, wake)
+
+	expect := []struct {
+		callsign string
+		sta      time.Time
+		fixSTA   time.Time
+	}{
+		{"DAL3", at(16), at(6)},
+		{"AAL1", at(18), at(8)},  // behind DAL3 at the acceptance rate
+		{"UAL2", at(20), at(10)}, // behind AAL1 at the acceptance rate
+		{"JBU4", at(30), at(20)}, // UAL2's wake spacing is less than its own gap
+	}
+	if len(seq) != len(expect) {
+		t.Fatalf("expected %d arrivals, got %d", len(expect), len(seq))
+	}
+	for i, e := range expect {
+		ma := seq[i]
+		if ma.Callsign != e.callsign {
+			t.Errorf("#%d: expected %s, got %s", i, e.callsign, ma.Callsign)
+		}
+		if !ma.RunwaySTA.Equal(e.sta) {
+			t.Errorf("%s: expected runway STA %s, got %s", ma.Callsign, e.sta, ma.RunwaySTA)
+		}
+		if !ma.MeterFixSTA.Equal(e.fixSTA) {
+			t.Errorf("%s: expected meter fix STA %s, got %s", ma.Callsign, e.fixSTA, ma.MeterFixSTA)
+		}
+	}
+
+	// Wake turbulence separation wins when it's longer than the
+	// acceptance rate spacing.
+	seq = sequenceArrivals([]MeteredArrival{
+		{Callsign: "UAL2", RunwayETA: at(10)},
+		{Callsign: "AAL1", RunwayETA: at(10.5)},
+	}, 2*time.Minute, wake)
+	if sta := seq[1].RunwaySTA; !sta.Equal(at(14)) {
+		t.Errorf("expected wake-spaced STA %s, got %s", at(14), sta)
+	}
+}
+
+func TestMeteringDelay(t *testing.T) {
+	// 20 minutes to the meter fix at 300 knots; losing 4 minutes needs
+	// 250 knots.
+	if spd := meteringSpeed(300, 20*time.Minute, 4*time.Minute, 210); spd != 250 {
+		t.Errorf("expected 250 knots, got %f", spd)
+	}
+	// Speeds are rounded down to a multiple of 10 knots...
+	if spd := meteringSpeed(300, 20*time.Minute, 3*time.Minute, 210); spd != 260 {
+		t.Errorf("expected 260 knots, got %f", spd)
+	}
+	// ...but not below the minimum.
+	if spd := meteringSpeed(300, 10*time.Minute, 10*time.Minute, 210); spd != 210 {
+		t.Errorf("expected 210 knots, got %f", spd)
+	}
+
+	// 100nm at 250 rather than 300 knots loses 4 minutes.
+	if d := speedDelay(100, 300, 250); d.Round(time.Second) != 4*time.Minute {
+		t.Errorf("expected 4m delay absorbed, got %s", d)
+	}
+	if d := speedDelay(100, 300, 300); d != 0 {
+		t.Errorf("expected no delay absorbed at the same speed, got %s", d)
+	}
+	if d := speedDelay(100, 300, 0); d != 0 {
+		t.Errorf("expected no delay absorbed without a speed reduction, got %s", d)
+	}
+}
@@ -87,6 +87,7 @@ func MakeERAMComputer(fac string, adapt av.ERAMAdaptation, starsBeaconBank int,
 	for id, tracon := range av.DB.TRACONs {
 		if tracon.ARTCC == fac {
 			sc := MakeSTARSComputer(id, ec.STARSCodePool)
+			sc.eram = ec
 			ec.STARSComputers[id] = sc
 		}
 	}
@@ -102,6 +103,7 @@ func (comp *ERAMComputer) Activate(ec *ERAMComputers) {
 	// the copy saved in ERAMComputer.
 	for _, sc := range comp.STARSComputers {
 		sc.Activate(comp.STARSCodePool)
+		sc.eram = comp
 	}
 }
 
@@ -288,6 +290,15 @@ func (comp *ERAMComputer) SortMessages(simTime time.Time, lg *log.Logger) {
 			comp.TrackInformation[msg.Identifier].HandoffController = msg.HandoffController
 			comp.SquawkCodePool.Return(msg.BCN)
 
+			if comp.TrackInformation[msg.Identifier].FlightPlan == nil {
+				// We never had the plan; use the one that came with the
+				// transfer.
+				if msg.BCN == av.Squawk(0) {
+					break
+				}
+				comp.TrackInformation[msg.Identifier].FlightPlan = msg.FlightPlan()
+			}
+
 			for name, fixes := range comp.Adaptation.CoordinationFixes {
 				alt := comp.TrackInformation[msg.Identifier].FlightPlan.Altitude
 				if fix, err := fixes.Fix(alt); err != nil {
@@ -336,7 +347,7 @@ func (comp *ERAMComputer) SortMessages(simTime time.Time, lg *log.Logger) {
 		}
 	}
 
-	clear(comp.ReceivedMessages)
+	comp.ReceivedMessages = nil
 }
 
 func (ec *ERAMComputer) FixForRouteAndAltitude(route string, altitude string) *av.AdaptationFix {
@@ -370,15 +381,14 @@ func (comp *ERAMComputer) HandoffTrack(ac *av.Aircraft, from, to *av.Controller,
 
 	if stars, ok := comp.STARSComputers[from.FacilityIdentifier]; ok { // in host ERAM
 		comp.SendMessageToSTARSFacility(stars.Identifier, msg)
-	} else { // needs to go through another ERAM
-		// FIXME: need to get access to the full set of ERAM computers
-		/*
-			receivingERAM, _, err := comp.ERAMComputers.FacilityComputers(to.FacilityIdentifier)
-			if err != nil {
-				return err
-			}
-			comp.SendMessageToERAM(receivingERAM.Identifier, msg)
-		*/
+	} else if stars, ok := comp.STARSComputers[to.Facility]; ok { // TRACON under us
+		comp.SendMessageToSTARSFacility(stars.Identifier, msg)
+	} else if comp.eramComputers != nil { // needs to go through another ERAM
+		if _, ok := comp.eramComputers.Computers[to.Facility]; ok {
+			return comp.SendMessageToERAM(to.Facility, msg)
+		} else if tracon, ok := av.DB.TRACONs[to.Facility]; ok {
+			return comp.SendMessageToERAM(tracon.ARTCC, msg)
+		}
 	}
 	return nil
 }
@@ -428,6 +438,8 @@ type STARSComputer struct {
 	STARSInbox        map[string]*[]FlightPlanMessage // Other STARS Facilities' inboxes
 	UnsupportedTracks []UnsupportedTrack
 	SquawkCodePool    *av.SquawkCodePool
+
+	eram *ERAMComputer // overlying ERAM; do not include when we serialize
 }
 
 func MakeSTARSComputer(id string, sq *av.SquawkCodePool) *STARSComputer {
@@ -453,6 +465,8 @@ func (comp *STARSComputer) SendTrackInfo(receivingFacility string, msg FlightPla
 	msg.SourceID = formatSourceID(comp.Identifier, simTime)
 	if inbox := comp.STARSInbox[receivingFacility]; inbox != nil {
 		*inbox = append(*inbox, msg)
+	} else if stars := comp.adjacentSTARSComputer(receivingFacility); stars != nil {
+		stars.ReceivedMessages = append(stars.ReceivedMessages, msg)
 	} else {
 		comp.SendToOverlyingERAMFacility(msg)
 	}
@@ -462,9 +476,19 @@ func formatSourceID(id string, t time.Time) string {
 	return id + t.Format("1504Z")
 }
 
+// adjacentSTARSComputer returns the STARS computer for another terminal
+// facility under the same ERAM, if there is one.
+func (comp *STARSComputer) adjacentSTARSComputer(facility string) *STARSComputer {
+	if comp.eram == nil || facility == comp.Identifier {
+		return nil
+	}
+	return comp.eram.STARSComputers[facility]
+}
+
 func (comp *STARSComputer) SendToOverlyingERAMFacility(msg FlightPlanMessage) {
-	// FIXME(mtrokel): this crashes on a handoff to an adjacent facility
-	// *comp.ERAMInbox = append(*comp.ERAMInbox, msg)
+	if comp.eram != nil {
+		comp.eram.ReceivedMessages = append(comp.eram.ReceivedMessages, msg)
+	}
 }
 
 func (comp *STARSComputer) RequestFlightPlan(bcn av.Squawk, simTime time.Time) {
@@ -602,7 +626,7 @@ func (comp *STARSComputer) HandoffTrack(callsign string, from *av.Controller, to
 		return av.ErrNoAircraftForCallsign
 	}
 
-	if to.Facility != from.Facility { // inter-facility
+	if to.Facility != from.Facility && trk.FlightPlan != nil { // inter-facility
 		msg := trk.FlightPlan.Message()
 		msg.SourceID = formatSourceID(from.Callsign, simTime)
 		msg.TrackInformation = TrackInformation{
@@ -647,7 +671,17 @@ func (comp *STARSComputer) AcceptHandoff(ac *av.Aircraft, ctrl *av.Controller,
 		return av.ErrNoAircraftForCallsign
 	}
 
-	if octrl := controllers[trk.TrackOwner]; octrl != nil && octrl.FacilityIdentifier != "" { // inter-facility
+	if octrl := controllers[trk.TrackOwner]; octrl != nil && octrl.IsHuman && octrl.Facility != "" &&
+		octrl.Facility != ctrl.Facility && trk.FlightPlan != nil { // from a controller at an adjacent facility in the sim
+		msg := trk.FlightPlan.Message()
+		msg.SourceID = formatSourceID(ctrl.Callsign, simTime)
+		msg.TrackInformation = TrackInformation{
+			TrackOwner: ctrl.Callsign,
+		}
+		msg.MessageType = AcceptRecallTransfer
+		msg.Identifier = ac.Callsign
+		comp.SendTrackInfo(octrl.Facility, msg, simTime)
+	} else if octrl != nil && octrl.FacilityIdentifier != "" { // inter-facility
 		fp := comp.ContainedPlans[ac.Squawk]
 		if fp == nil {
 			fp = trk.FlightPlan
@@ -716,7 +750,7 @@ func (comp *STARSComputer) CancelHandoff(ac *av.Aircraft, ctrl *av.Controller,
 		return av.ErrInvalidController
 	}
 
-	if octrl.Facility != ctrl.Facility { // inter-facility
+	if octrl.Facility != ctrl.Facility && trk.FlightPlan != nil { // inter-facility
 		msg := trk.FlightPlan.Message()
 		msg.SourceID = formatSourceID(ctrl.Callsign, simTime)
 		msg.TrackInformation = TrackInformation{
@@ -862,6 +896,20 @@ func (comp *STARSComputer) SortReceivedMessages(e *EventStream) {
 
 					delete(comp.ContainedPlans, msg.BCN)
 
+					e.Post(Event{
+						Type:         TransferAcceptedEvent,
+						Callsign:     msg.Identifier,
+						ToController: msg.TrackOwner,
+					})
+				} else if msg.BCN != av.Squawk(0) {
+					// The plan came along with the transfer from an
+					// adjacent facility.
+					comp.TrackInformation[msg.Identifier] = &TrackInformation{
+						TrackOwner:        msg.TrackOwner,
+						HandoffController: msg.HandoffController,
+						FlightPlan:        msg.FlightPlan(),
+					}
+
 					e.Post(Event{
 						Type:         TransferAcceptedEvent,
 						Callsign:     msg.Identifier,
@@ -902,7 +950,7 @@ func (comp *STARSComputer) SortReceivedMessages(e *EventStream) {
 		}
 	}
 
-	clear(comp.ReceivedMessages)
+	comp.ReceivedMessages = nil
 }
 
 func (comp *STARSComputer) AssociateFlightPlans(s *Sim) {
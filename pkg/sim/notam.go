@@ -0,0 +1,177 @@
+// pkg/sim/notam.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/util"
+)
+
+type NOTAMType string
+
+const (
+	NOTAMRunwayClosed  NOTAMType = "runway_closed"
+	NOTAMGlideslopeOut NOTAMType = "glideslope_out"
+	NOTAMVORUnusable   NOTAMType = "vor_unusable"
+	NOTAMApproachNA    NOTAMType = "approach_na"
+)
+
+var NOTAMTypes = []NOTAMType{NOTAMRunwayClosed, NOTAMGlideslopeOut, NOTAMVORUnusable, NOTAMApproachNA}
+
+// NOTAM describes an outage that pilots and the sim's traffic generation
+// account for.
+type NOTAM struct {
+	Id       string    `json:"id"`
+	Type     NOTAMType `json:"type"`
+	Airport  string    `json:"airport,omitempty"`  // all but VOR unusable
+	Runway   string    `json:"runway,omitempty"`   // runway closed and glideslope out
+	Approach string    `json:"approach,omitempty"` // approach not authorized
+	Navaid   string    `json:"navaid,omitempty"`   // VOR unusable
+}
+
+// Check returns an error wrapping ErrInvalidNOTAM if the NOTAM doesn't
+// refer to a valid airport, runway, approach, or navaid.
+func (n NOTAM) Check(airports map[string]*av.Airport) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf(format+": %w", append(args, ErrInvalidNOTAM)...)
+	}
+
+	if n.Type == NOTAMVORUnusable {
+		if _, ok := av.DB.Navaids[n.Navaid]; !ok {
+			return invalid("%s: unknown navaid", n.Navaid)
+		}
+		return nil
+	}
+
+	ap, ok := airports[n.Airport]
+	if !ok {
+		return invalid("%s: unknown airport", n.Airport)
+	}
+
+	switch n.Type {
+	case NOTAMRunwayClosed, NOTAMGlideslopeOut:
+		if n.Runway == "" {
+			return invalid("must specify \"runway\"")
+		}
+		if _, ok := av.LookupRunway(n.Airport, n.Runway); !ok {
+			return invalid("%s: unknown runway at %s", n.Runway, n.Airport)
+		}
+		if n.Type == NOTAMGlideslopeOut {
+			for _, appr := range ap.Approaches {
+				if appr.Type == av.ILSApproach && appr.Runway == n.Runway {
+					return nil
+				}
+			}
+			return invalid("%s: no ILS approach to runway", n.Runway)
+		}
+	case NOTAMApproachNA:
+		if _, ok := ap.Approaches[n.Approach]; !ok {
+			return invalid("%s: unknown approach", n.Approach)
+		}
+	default:
+		return invalid("%s: unknown NOTAM type", n.Type)
+	}
+	return nil
+}
+
+// String returns the NOTAM in the abbreviated form shown in the STARS
+// NOTAM list.
+func (n NOTAM) String() string {
+	ap := strings.TrimPrefix(n.Airport, "K")
+	switch n.Type {
+	case NOTAMRunwayClosed:
+		return ap + " RWY " + n.Runway + " CLSD"
+	case NOTAMGlideslopeOut:
+		return ap + " ILS " + n.Runway + " GS OTS"
+	case NOTAMVORUnusable:
+		return n.Navaid + " VOR U/S"
+	case NOTAMApproachNA:
+		return ap + " " + n.Approach + " NA"
+	default:
+		return string(n.Type)
+	}
+}
+
+// addNOTAM adds the NOTAM, assigning it an id if it doesn't have one, and
+// returns its id.
+func (ss *State) addNOTAM(n NOTAM) string {
+	if n.Id == "" {
+		for i := 1; ; i++ {
+			id := "N" + strconv.Itoa(i)
+			if !slices.ContainsFunc(ss.NOTAMs, func(o NOTAM) bool { return o.Id == id }) {
+				n.Id = id
+				break
+			}
+		}
+	}
+	ss.NOTAMs = append(ss.NOTAMs, n)
+	return n.Id
+}
+
+func (ss *State) hasNOTAM(match func(n NOTAM) bool) bool {
+	return slices.ContainsFunc(ss.NOTAMs, match)
+}
+
+func (ss *State) RunwayClosed(airport, runway string) bool {
+	return ss.hasNOTAM(func(n NOTAM) bool {
+		return n.Type == NOTAMRunwayClosed && n.Airport == airport && n.Runway == runway
+	})
+}
+
+func (ss *State) GlideslopeOut(airport, runway string) bool {
+	return ss.hasNOTAM(func(n NOTAM) bool {
+		return n.Type == NOTAMGlideslopeOut && n.Airport == airport && n.Runway == runway
+	})
+}
+
+func (ss *State) NavaidUnusable(navaid string) bool {
+	return ss.hasNOTAM(func(n NOTAM) bool { return n.Type == NOTAMVORUnusable && n.Navaid == navaid })
+}
+
+// ApproachUnavailable returns the reason that pilots can't fly the given
+// approach, or the empty string if it's available.
+func (ss *State) ApproachUnavailable(airport, id string) string {
+	ap, ok := ss.Airports[airport]
+	if !ok {
+		return ""
+	}
+	appr, ok := ap.Approaches[id]
+	if !ok {
+		return ""
+	}
+
+	if ss.RunwayClosed(airport, appr.Runway) {
+		return "runway " + appr.Runway + " is closed"
+	}
+	if ss.hasNOTAM(func(n NOTAM) bool {
+		return n.Type == NOTAMApproachNA && n.Airport == airport && n.Approach == id
+	}) {
+		return "the " + appr.FullName + " approach is not authorized"
+	}
+	return ""
+}
+
+// availableApproach returns the id of an approach at the airport that is
+// to one of the active arrival runways and isn't affected by a NOTAM.
+func (ss *State) availableApproach(airport string) (string, bool) {
+	ap, ok := ss.Airports[airport]
+	if !ok {
+		return "", false
+	}
+	for _, id := range util.SortedMapKeys(ap.Approaches) {
+		rwy := ap.Approaches[id].Runway
+		if slices.ContainsFunc(ss.ArrivalRunways, func(r ScenarioGroupArrivalRunway) bool {
+			return r.Airport == airport && r.Runway == rwy
+		}) && ss.ApproachUnavailable(airport, id) == "" {
+			return id, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,47 @@
+// pkg/sim/notam_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"errors"
+	"testing"
+
+	av "github.com/mmp/vice/pkg/aviation"
+)
+
+func TestNOTAMCheck(t *testing.T) {
+	const icao = "KZZN"
+	addTestAirport(t, av.FAAAirport{Id: icao, Runways: []av.Runway{{Id: "4L"}, {Id: "22R"}}})
+
+	airports := map[string]*av.Airport{
+		icao: &av.Airport{
+			Approaches: map[string]*av.Approach{
+				"I4L": &av.Approach{Type: av.ILSApproach, Runway: "4L"},
+			},
+		},
+	}
+
+	for _, test := range []struct {
+		n     NOTAM
+		valid bool
+	}{
+		{NOTAM{Type: NOTAMRunwayClosed, Airport: icao, Runway: "22R"}, true},
+		{NOTAM{Type: NOTAMRunwayClosed, Airport: icao, Runway: "13"}, false},
+		{NOTAM{Type: NOTAMRunwayClosed, Airport: icao}, false},
+		{NOTAM{Type: NOTAMRunwayClosed, Airport: "KZZW", Runway: "22R"}, false},
+		{NOTAM{Type: NOTAMGlideslopeOut, Airport: icao, Runway: "4L"}, true},
+		{NOTAM{Type: NOTAMGlideslopeOut, Airport: icao, Runway: "22R"}, false},
+		{NOTAM{Type: NOTAMGlideslopeOut, Airport: icao, Runway: "31"}, false},
+		{NOTAM{Type: NOTAMApproachNA, Airport: icao, Approach: "I4L"}, true},
+		{NOTAM{Type: NOTAMApproachNA, Airport: icao, Approach: "R22R"}, false},
+	} {
+		err := test.n.Check(airports)
+		if test.valid && err != nil {
+			t.Errorf("%+v: unexpected error %v", test.n, err)
+		} else if !test.valid && !errors.Is(err, ErrInvalidNOTAM) {
+			t.Errorf("%+v: expected ErrInvalidNOTAM, got %v", test.n, err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package sim
 
 import (
 	"net/rpc"
+	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/math"
@@ -45,8 +46,11 @@ func (s *proxy) GetSerializeSim() (*Sim, error) {
 	return &sim, err
 }
 
-func (s *proxy) GetWorldUpdate(wu *WorldUpdate) *rpc.Call {
-	return s.Client.Go("Sim.GetWorldUpdate", s.ControllerToken, wu, nil)
+func (s *proxy) GetWorldUpdate(ack uint64, wu *WorldUpdate) *rpc.Call {
+	return s.Client.Go("Sim.GetWorldUpdate", &WorldUpdateArgs{
+		ControllerToken: s.ControllerToken,
+		AckVersion:      ack,
+	}, wu, nil)
 }
 
 func (s *proxy) SetSimRate(r float32) *rpc.Call {
@@ -57,6 +61,124 @@ func (s *proxy) SetSimRate(r float32) *rpc.Call {
 		}, nil, nil)
 }
 
+func (s *proxy) AddWeatherCell(cell av.WeatherCell) *rpc.Call {
+	return s.Client.Go("Sim.AddWeatherCell", &WeatherCellArgs{
+		ControllerToken: s.ControllerToken,
+		Cell:            cell,
+	}, nil, nil)
+}
+
+func (s *proxy) DeleteWeatherCell(id string) *rpc.Call {
+	return s.Client.Go("Sim.DeleteWeatherCell", &WeatherCellArgs{
+		ControllerToken: s.ControllerToken,
+		Cell:            av.WeatherCell{Id: id},
+	}, nil, nil)
+}
+
+func (s *proxy) AddNOTAM(n NOTAM) *rpc.Call {
+	return s.Client.Go("Sim.AddNOTAM", &NOTAMArgs{
+		ControllerToken: s.ControllerToken,
+		NOTAM:           n,
+	}, nil, nil)
+}
+
+func (s *proxy) DeleteNOTAM(id string) *rpc.Call {
+	return s.Client.Go("Sim.DeleteNOTAM", &NOTAMArgs{
+		ControllerToken: s.ControllerToken,
+		NOTAM:           NOTAM{Id: id},
+	}, nil, nil)
+}
+
+func (s *proxy) RequestRelease(callsign string) *rpc.Call {
+	return s.Client.Go("Sim.RequestRelease", &ReleaseArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+	}, nil, nil)
+}
+
+func (s *proxy) ApproveRelease(callsign string, void time.Duration) *rpc.Call {
+	return s.Client.Go("Sim.ApproveRelease", &ReleaseArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		Time:            void,
+	}, nil, nil)
+}
+
+func (s *proxy) DelayRelease(callsign string, delay time.Duration) *rpc.Call {
+	return s.Client.Go("Sim.DelayRelease", &ReleaseArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		Time:            delay,
+	}, nil, nil)
+}
+
+func (s *proxy) DenyRelease(callsign string) *rpc.Call {
+	return s.Client.Go("Sim.DenyRelease", &ReleaseArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+	}, nil, nil)
+}
+
+func (s *proxy) ClearForTakeoff(callsign string) *rpc.Call {
+	return s.Client.Go("Sim.ClearForTakeoff", &AircraftSpecifier{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+	}, nil, nil)
+}
+
+func (s *proxy) MoveFlightStrip(callsign string, bay StripBay, index int) *rpc.Call {
+	return s.Client.Go("Sim.MoveFlightStrip", &FlightStripArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		Bay:             bay,
+		Index:           index,
+	}, nil, nil)
+}
+
+func (s *proxy) AddFlightStrips(callsigns []string, bay StripBay) *rpc.Call {
+	return s.Client.Go("Sim.AddFlightStrips", &FlightStripArgs{
+		ControllerToken: s.ControllerToken,
+		Callsigns:       callsigns,
+		Bay:             bay,
+	}, nil, nil)
+}
+
+func (s *proxy) RemoveFlightStrip(callsign string) *rpc.Call {
+	return s.Client.Go("Sim.RemoveFlightStrip", &FlightStripArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+	}, nil, nil)
+}
+
+func (s *proxy) PushFlightStrip(callsign, toController string) *rpc.Call {
+	return s.Client.Go("Sim.PushFlightStrip", &FlightStripArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+		ToController:    toController,
+	}, nil, nil)
+}
+
+func (s *proxy) AcknowledgeFlightStrip(callsign string) *rpc.Call {
+	return s.Client.Go("Sim.AcknowledgeFlightStrip", &FlightStripArgs{
+		ControllerToken: s.ControllerToken,
+		Callsign:        callsign,
+	}, nil, nil)
+}
+
+func (s *proxy) AddTMI(t TMI) *rpc.Call {
+	return s.Client.Go("Sim.AddTMI", &TMIArgs{
+		ControllerToken: s.ControllerToken,
+		TMI:             t,
+	}, nil, nil)
+}
+
+func (s *proxy) DeleteTMI(id string) *rpc.Call {
+	return s.Client.Go("Sim.DeleteTMI", &TMIArgs{
+		ControllerToken: s.ControllerToken,
+		TMI:             TMI{Id: id},
+	}, nil, nil)
+}
+
 func (s *proxy) SetLaunchConfig(lc LaunchConfig) *rpc.Call {
 	return s.Client.Go("Sim.SetLaunchConfig",
 		&SetLaunchConfigArgs{
@@ -0,0 +1,261 @@
+// pkg/sim/release.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/rand"
+	"github.com/mmp/vice/pkg/util"
+)
+
+// Maximum number of departures that may be waiting for a release at an
+// airport; no more are spawned there until one of them departs.
+const maxDeparturesAwaitingRelease = 3
+
+type ReleaseStatus int
+
+const (
+	// The departure is ready to go but the tower hasn't called for its
+	// release.
+	ReleaseNotRequested ReleaseStatus = iota
+	ReleaseRequested
+	ReleaseApproved
+	ReleaseDelayed
+)
+
+func (rs ReleaseStatus) String() string {
+	return []string{"RDY", "REQ", "REL", "DLY"}[rs]
+}
+
+// DepartureRelease tracks an IFR departure that is waiting on the ground
+// for the departure controller to release it.
+type DepartureRelease struct {
+	Callsign            string
+	AircraftType        string
+	Airport             string
+	Runway              string
+	Exit                string
+	Tower               string // controller that requests the release
+	DepartureController string // controller that approves it
+	Status              ReleaseStatus
+	RequestTime         time.Time
+	ReleaseTime         time.Time
+	VoidTime            time.Time // if non-zero, the release is void if the aircraft isn't off by then
+	NextRequestTime     time.Time // when a virtual tower will call for the release (again)
+	TakeoffTime         time.Time // when a virtual tower will clear a released aircraft for takeoff
+}
+
+// DepartureRelease returns the pending release for the given callsign, if
+// there is one.
+func (ss *State) DepartureRelease(callsign string) (*DepartureRelease, bool) {
+	idx := slices.IndexFunc(ss.DepartureReleases, func(r DepartureRelease) bool { return r.Callsign == callsign })
+	if idx == -1 {
+		return nil, false
+	}
+	return &ss.DepartureReleases[idx], true
+}
+
+// towerController returns the tower position for the given airport, or
+// the empty string if none is defined.
+func (ss *State) towerController(airport string) string {
+	if ap, ok := ss.Airports[airport]; ok {
+		for _, id := range util.SortedMapKeys(ap.Approaches) {
+			if twr := ap.Approaches[id].TowerController; twr != "" {
+				return twr
+			}
+		}
+	}
+	return ""
+}
+
+func (s *Sim) departuresAwaitingRelease(airport string) int {
+	n := 0
+	for _, r := range s.State.DepartureReleases {
+		if r.Airport == airport {
+			n++
+		}
+	}
+	return n
+}
+
+// holdForRelease requires a release for a departure that is taxiing out
+// before it can take off.
+func (s *Sim) holdForRelease(ac *av.Aircraft, runway string) {
+	airport := ac.FlightPlan.DepartureAirport
+	s.State.DepartureReleases = append(s.State.DepartureReleases, DepartureRelease{
+		Callsign:            ac.Callsign,
+		AircraftType:        ac.FlightPlan.TypeWithoutSuffix(),
+		Airport:             airport,
+		Runway:              runway,
+		Exit:                ac.FlightPlan.Exit,
+		Tower:               s.State.towerController(airport),
+		DepartureController: s.State.DepartureController(ac, s.lg),
+		// Time to taxi out before the tower calls.
+		NextRequestTime: s.SimTime.Add(time.Duration(30+rand.Intn(90)) * time.Second),
+	})
+	s.lg.Info("departure holding for release", slog.String("callsign", ac.Callsign))
+}
+
+func (s *Sim) virtualTower(r *DepartureRelease) bool {
+	return r.Tower == "" || !s.controllerIsSignedIn(r.Tower)
+}
+
+func (s *Sim) requestRelease(r *DepartureRelease) {
+	r.Status = ReleaseRequested
+	r.RequestTime = s.SimTime
+	// The departure controller may have signed on or off since the
+	// aircraft was created.
+	if ac, ok := s.PendingDepartures[r.Callsign]; ok {
+		r.DepartureController = s.State.DepartureController(ac, s.lg)
+	}
+	s.lg.Info("release requested", slog.String("callsign", r.Callsign), slog.String("to", r.DepartureController))
+	s.eventStream.Post(Event{
+		Type:           ReleaseRequestedEvent,
+		Callsign:       r.Callsign,
+		FromController: r.Tower,
+		ToController:   r.DepartureController,
+	})
+}
+
+func (s *Sim) approveRelease(r *DepartureRelease, void time.Duration) {
+	r.Status = ReleaseApproved
+	r.ReleaseTime = s.SimTime
+	r.VoidTime = time.Time{}
+	if void > 0 {
+		r.VoidTime = s.SimTime.Add(void)
+	}
+	// It takes a little while for the tower to get the aircraft rolling.
+	r.TakeoffTime = s.SimTime.Add(time.Duration(30+rand.Intn(120)) * time.Second)
+
+	s.lg.Info("release approved", slog.String("callsign", r.Callsign), slog.Time("void", r.VoidTime))
+	s.eventStream.Post(Event{
+		Type:           ReleaseApprovedEvent,
+		Callsign:       r.Callsign,
+		FromController: r.DepartureController,
+		ToController:   r.Tower,
+	})
+}
+
+// updateReleases has virtual towers request releases and virtual
+// departure controllers approve them and voids releases that weren't
+// used in time. Released departures take off in updateSurface.
+func (s *Sim) updateReleases() {
+	for i := range s.State.DepartureReleases {
+		r := &s.State.DepartureReleases[i]
+
+		switch r.Status {
+		case ReleaseNotRequested, ReleaseDelayed:
+			if s.virtualTower(r) && !s.SimTime.Before(r.NextRequestTime) {
+				s.requestRelease(r)
+			}
+
+		case ReleaseRequested:
+			if !s.controllerIsSignedIn(r.DepartureController) && s.SimTime.Sub(r.RequestTime) > 5*time.Second {
+				s.approveRelease(r, 0)
+			}
+
+		case ReleaseApproved:
+			if t, ok := s.State.SurfaceTarget(r.Callsign); ok && t.State == SurfaceTakeoffRoll {
+				// Off before the void time.
+			} else if !r.VoidTime.IsZero() && !s.SimTime.Before(r.VoidTime) {
+				s.lg.Info("release void", slog.String("callsign", r.Callsign))
+				s.eventStream.Post(Event{
+					Type:           ReleaseVoidedEvent,
+					Callsign:       r.Callsign,
+					FromController: r.Tower,
+					ToController:   r.DepartureController,
+				})
+				r.Status = ReleaseNotRequested
+				r.NextRequestTime = s.SimTime.Add(time.Minute)
+			}
+		}
+	}
+}
+
+func (s *Sim) RequestRelease(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	r, ok := s.State.DepartureRelease(callsign)
+	if !ok {
+		return ErrNoPendingRelease
+	} else if ctrl.Callsign != r.Tower {
+		return ErrNotReleaseController
+	} else if r.Status == ReleaseRequested || r.Status == ReleaseApproved {
+		return ErrReleaseAlreadyRequested
+	}
+
+	s.requestRelease(r)
+	return nil
+}
+
+// ApproveRelease releases the departure; if void is non-zero, the release
+// is void if the aircraft isn't airborne within that time.
+func (s *Sim) ApproveRelease(token, callsign string, void time.Duration) error {
+	return s.dispatchReleaseResponse(token, callsign, func(r *DepartureRelease) {
+		s.approveRelease(r, void)
+	})
+}
+
+// DelayRelease has the tower hold the departure; the tower should call
+// for release again after the given delay.
+func (s *Sim) DelayRelease(token, callsign string, delay time.Duration) error {
+	return s.dispatchReleaseResponse(token, callsign, func(r *DepartureRelease) {
+		r.Status = ReleaseDelayed
+		r.NextRequestTime = s.SimTime.Add(delay)
+		s.lg.Info("release delayed", slog.String("callsign", r.Callsign), slog.Duration("delay", delay))
+		s.eventStream.Post(Event{
+			Type:           ReleaseDelayedEvent,
+			Callsign:       r.Callsign,
+			FromController: r.DepartureController,
+			ToController:   r.Tower,
+		})
+	})
+}
+
+// DenyRelease denies the release request; the departure stays on the
+// ground and the tower may request its release again later.
+func (s *Sim) DenyRelease(token, callsign string) error {
+	return s.dispatchReleaseResponse(token, callsign, func(r *DepartureRelease) {
+		r.Status = ReleaseNotRequested
+		r.NextRequestTime = s.SimTime.Add(5 * time.Minute)
+		s.lg.Info("release denied", slog.String("callsign", r.Callsign))
+		s.eventStream.Post(Event{
+			Type:           ReleaseDeniedEvent,
+			Callsign:       r.Callsign,
+			FromController: r.DepartureController,
+			ToController:   r.Tower,
+		})
+	})
+}
+
+func (s *Sim) dispatchReleaseResponse(token, callsign string, respond func(r *DepartureRelease)) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	r, ok := s.State.DepartureRelease(callsign)
+	if !ok {
+		return ErrNoPendingRelease
+	} else if ctrl.Callsign != r.DepartureController {
+		return ErrNotReleaseController
+	} else if r.Status != ReleaseRequested {
+		return ErrReleaseNotRequested
+	}
+
+	respond(r)
+	return nil
+}
@@ -0,0 +1,117 @@
+// pkg/sim/release_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+func makeReleaseTestSim(now time.Time) *Sim {
+	s := makeTestSim(now, map[string]string{"twr": "JFK_TWR", "dep": "NY_DEP"})
+	s.State.DepartureReleases = []DepartureRelease{{
+		Callsign:            "AAL1",
+		Airport:             "KJFK",
+		Runway:              "31L",
+		Tower:               "JFK_TWR",
+		DepartureController: "NY_DEP",
+		NextRequestTime:     now.Add(time.Minute),
+	}}
+	return s
+}
+
+func TestReleaseRPCs(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := makeReleaseTestSim(now)
+	sm := &SimManager{controllerTokenToSim: map[string]*Sim{"twr": s, "dep": s}}
+	d := &Dispatcher{sm: sm}
+
+	status := func() ReleaseStatus {
+		r, ok := s.State.DepartureRelease("AAL1")
+		if !ok {
+			t.Fatal("release not found")
+		}
+		return r.Status
+	}
+
+	for _, test := range []struct {
+		name string
+		call func(*ReleaseArgs, *struct{}) error
+		args ReleaseArgs
+		err  error
+	}{
+		{"unknown token", d.RequestRelease, ReleaseArgs{ControllerToken: "x", Callsign: "AAL1"}, ErrNoSimForControllerToken},
+		{"unknown callsign", d.RequestRelease, ReleaseArgs{ControllerToken: "twr", Callsign: "UAL2"}, ErrNoPendingRelease},
+		{"departure requests", d.RequestRelease, ReleaseArgs{ControllerToken: "dep", Callsign: "AAL1"}, ErrNotReleaseController},
+		{"approve before request", d.ApproveRelease, ReleaseArgs{ControllerToken: "dep", Callsign: "AAL1"}, ErrReleaseNotRequested},
+		{"tower requests", d.RequestRelease, ReleaseArgs{ControllerToken: "twr", Callsign: "AAL1"}, nil},
+		{"request again", d.RequestRelease, ReleaseArgs{ControllerToken: "twr", Callsign: "AAL1"}, ErrReleaseAlreadyRequested},
+		{"tower approves", d.ApproveRelease, ReleaseArgs{ControllerToken: "twr", Callsign: "AAL1"}, ErrNotReleaseController},
+		{"departure delays", d.DelayRelease, ReleaseArgs{ControllerToken: "dep", Callsign: "AAL1", Time: 2 * time.Minute}, nil},
+		{"deny after delay", d.DenyRelease, ReleaseArgs{ControllerToken: "dep", Callsign: "AAL1"}, ErrReleaseNotRequested},
+	} {
+		var reply struct{}
+		if err := test.call(&test.args, &reply); err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+	}
+	if st := status(); st != ReleaseDelayed {
+		t.Errorf("expected release to be delayed, got %s", st)
+	}
+	if r, _ := s.State.DepartureRelease("AAL1"); !r.NextRequestTime.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("expected tower to call again at %s, got %s", now.Add(2*time.Minute), r.NextRequestTime)
+	}
+
+	// A human tower isn't prompted by the sim to call again.
+	s.SimTime = now.Add(3 * time.Minute)
+	s.updateReleases()
+	if st := status(); st != ReleaseDelayed {
+		t.Errorf("expected release to still be delayed, got %s", st)
+	}
+
+	var reply struct{}
+	if err := d.RequestRelease(&ReleaseArgs{ControllerToken: "twr", Callsign: "AAL1"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ApproveRelease(&ReleaseArgs{ControllerToken: "dep", Callsign: "AAL1", Time: 3 * time.Minute},
+		&reply); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := s.State.DepartureRelease("AAL1")
+	if r.Status != ReleaseApproved || !r.VoidTime.Equal(s.SimTime.Add(3*time.Minute)) {
+		t.Errorf("expected release approved with void time, got %+v", *r)
+	}
+
+	// Not off by the void time; the tower has to ask again.
+	s.SimTime = r.VoidTime
+	s.updateReleases()
+	if st := status(); st != ReleaseNotRequested {
+		t.Errorf("expected release to be void, got %s", st)
+	}
+}
+
+func TestVirtualReleases(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := makeReleaseTestSim(now)
+	s.controllers = nil // both positions are virtual
+
+	s.updateReleases()
+	r, _ := s.State.DepartureRelease("AAL1")
+	if r.Status != ReleaseNotRequested {
+		t.Errorf("expected tower to wait before calling, got %s", r.Status)
+	}
+
+	s.SimTime = r.NextRequestTime
+	s.updateReleases()
+	if r.Status != ReleaseRequested || !r.RequestTime.Equal(s.SimTime) {
+		t.Errorf("expected virtual tower to request release, got %+v", *r)
+	}
+
+	s.SimTime = s.SimTime.Add(10 * time.Second)
+	s.updateReleases()
+	if r.Status != ReleaseApproved || !r.VoidTime.IsZero() || !r.TakeoffTime.After(s.SimTime) {
+		t.Errorf("expected virtual departure controller to release, got %+v", *r)
+	}
+}
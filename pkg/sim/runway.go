@@ -0,0 +1,150 @@
+// pkg/sim/runway.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/rand"
+	"github.com/mmp/vice/pkg/util"
+)
+
+const (
+	// Arrivals that are still on the runway when the next arrival is
+	// this close to the threshold cause it to go around.
+	goAroundCheckDistance = 0.75 // nm
+	// Departures aren't rolled if an arrival is this close to the
+	// threshold of the runway.
+	departureArrivalGap = 2 // nm
+)
+
+// RunwayOccupancy records an aircraft that is on a runway, either rolling
+// for departure or slowing down and exiting after landing.
+type RunwayOccupancy struct {
+	Callsign  string
+	Departure bool
+	Until     time.Time
+}
+
+func runwayKey(airport, runway string) string {
+	return airport + "/" + runway
+}
+
+// runwayOccupancyTime returns a randomized amount of time that the
+// aircraft will spend on the runway; larger aircraft take longer both to
+// get airborne and to slow down and exit after landing.
+func runwayOccupancyTime(ac *av.Aircraft, departure bool) time.Duration {
+	var base int // seconds
+	switch ac.Nav.Perf.Category.CWT {
+	case "A", "B":
+		base = util.Select(departure, 45, 60)
+	case "C", "D", "E", "F":
+		base = util.Select(departure, 35, 50)
+	default:
+		base = util.Select(departure, 25, 40)
+	}
+	return time.Duration(base+rand.Intn(15)) * time.Second
+}
+
+// RunwayOccupied returns the aircraft currently occupying the given
+// runway, if there is one.
+func (s *Sim) RunwayOccupied(airport, runway string) (RunwayOccupancy, bool) {
+	occ, ok := s.RunwayOccupancy[runwayKey(airport, runway)]
+	if !ok || !s.SimTime.Before(occ.Until) {
+		return RunwayOccupancy{}, false
+	}
+	return occ, true
+}
+
+func (s *Sim) occupyRunway(ac *av.Aircraft, airport, runway string, departure bool) {
+	if s.RunwayOccupancy == nil {
+		s.RunwayOccupancy = make(map[string]RunwayOccupancy)
+	}
+	occ := RunwayOccupancy{
+		Callsign:  ac.Callsign,
+		Departure: departure,
+		Until:     s.SimTime.Add(runwayOccupancyTime(ac, departure)),
+	}
+	s.RunwayOccupancy[runwayKey(airport, runway)] = occ
+	s.lg.Info("runway occupied", slog.String("airport", airport), slog.String("runway", runway),
+		slog.Any("occupancy", occ))
+}
+
+// arrivalRunway returns the airport and runway of the approach the
+// aircraft has been cleared for, if any.
+func arrivalRunway(ac *av.Aircraft) (string, string, bool) {
+	if ac.FlightPlan == nil || ac.Nav.Approach.Assigned == nil || !ac.Nav.Approach.Cleared {
+		return "", "", false
+	}
+	return ac.FlightPlan.ArrivalAirport, ac.Nav.Approach.Assigned.Runway, true
+}
+
+// runwayClearForDeparture returns true if a departure can start its
+// takeoff roll: there's no one else on the runway and there's no arrival
+// about to land on it.
+func (s *Sim) runwayClearForDeparture(airport, runway string) bool {
+	if _, ok := s.RunwayOccupied(airport, runway); ok {
+		return false
+	}
+	for _, ac := range s.State.Aircraft {
+		if ap, rwy, ok := arrivalRunway(ac); ok && ap == airport && rwy == runway {
+			if d, err := ac.DistanceToEndOfApproach(); err == nil && d < departureArrivalGap {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkRunwayClear has the tower send an arrival around if it is about to
+// land but the runway is still occupied. Arrivals also go around if a
+// human tower controller hasn't cleared them to land.
+func (s *Sim) checkRunwayClear(ac *av.Aircraft) {
+	airport, runway, ok := arrivalRunway(ac)
+	if !ok {
+		return
+	}
+	d, err := ac.DistanceToEndOfApproach()
+	if err != nil || d > goAroundCheckDistance {
+		return
+	}
+
+	if twr := ac.Nav.Approach.Assigned.TowerController; !ac.ClearedToLand && twr != "" && s.controllerIsSignedIn(twr) {
+		s.lg.Info("no landing clearance; going around", slog.String("callsign", ac.Callsign))
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: fmt.Sprintf("%s going around: no landing clearance for %s runway %s", ac.Callsign, airport, runway),
+		})
+		s.goAround(ac)
+		return
+	}
+
+	occ, ok := s.RunwayOccupied(airport, runway)
+	if !ok || occ.Callsign == ac.Callsign {
+		return
+	}
+
+	s.lg.Info("runway occupied; going around", slog.String("callsign", ac.Callsign),
+		slog.String("runway", runway), slog.String("occupied_by", occ.Callsign))
+	s.eventStream.Post(Event{
+		Type: StatusMessageEvent,
+		Message: fmt.Sprintf("%s sent around: %s runway %s occupied by %s %s", ac.Callsign, airport, runway,
+			util.Select(occ.Departure, "departing", "landing"), occ.Callsign),
+	})
+	s.goAround(ac)
+}
+
+// landed is called when an arrival reaches the end of its approach; it
+// occupies the runway until it has slowed down and exited and then taxis
+// in.
+func (s *Sim) landed(ac *av.Aircraft) {
+	if airport, runway, ok := arrivalRunway(ac); ok {
+		s.occupyRunway(ac, airport, runway, false)
+		s.taxiIn(ac, airport, runway)
+	}
+}
@@ -0,0 +1,95 @@
+// pkg/sim/runway_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+)
+
+func TestRunwayOccupancyTime(t *testing.T) {
+	heavy, light := &av.Aircraft{}, &av.Aircraft{}
+	heavy.Nav.Perf.Category.CWT = "B"
+	light.Nav.Perf.Category.CWT = "I"
+
+	for i := 0; i < 100; i++ {
+		h, l := runwayOccupancyTime(heavy, false), runwayOccupancyTime(light, false)
+		if h < 60*time.Second || h >= 75*time.Second {
+			t.Errorf("heavy landing occupancy %s out of range", h)
+		}
+		if l < 40*time.Second || l >= 55*time.Second {
+			t.Errorf("light landing occupancy %s out of range", l)
+		}
+		if d := runwayOccupancyTime(heavy, true); d >= h+15*time.Second || d < 45*time.Second {
+			t.Errorf("heavy departure occupancy %s out of range", d)
+		}
+	}
+}
+
+func TestRunwayOccupancy(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := makeTestSim(now, nil)
+
+	dep := &av.Aircraft{Callsign: "AAL1"}
+	s.occupyRunway(dep, "KJFK", "31L", true)
+	occ, ok := s.RunwayOccupied("KJFK", "31L")
+	if !ok || occ.Callsign != "AAL1" || !occ.Departure {
+		t.Fatalf("expected 31L to be occupied by departing AAL1, got %+v %v", occ, ok)
+	}
+	if _, ok := s.RunwayOccupied("KJFK", "31R"); ok {
+		t.Errorf("31R shouldn't be occupied")
+	}
+	if s.runwayClearForDeparture("KJFK", "31L") {
+		t.Errorf("31L shouldn't be clear for departure while occupied")
+	}
+
+	// An arrival about to land sees the occupied runway and goes around.
+	arr := makeApproachAircraft("UAL2", 0.5)
+	s.State.Aircraft[arr.Callsign] = arr
+	s.checkRunwayClear(arr)
+	if arr.Nav.Approach.Assigned != nil {
+		t.Errorf("expected UAL2 to go around")
+	}
+
+	// One further out doesn't yet.
+	arr = makeApproachAircraft("DAL3", 3)
+	s.State.Aircraft[arr.Callsign] = arr
+	s.checkRunwayClear(arr)
+	if arr.Nav.Approach.Assigned == nil {
+		t.Errorf("DAL3 shouldn't go around yet")
+	}
+
+	// Once the departure is off, the runway is clear.
+	s.SimTime = occ.Until
+	if _, ok := s.RunwayOccupied("KJFK", "31L"); ok {
+		t.Errorf("31L should no longer be occupied")
+	}
+	if !s.runwayClearForDeparture("KJFK", "31L") {
+		t.Errorf("31L should be clear for departure")
+	}
+	s.checkRunwayClear(arr)
+	if arr.Nav.Approach.Assigned == nil {
+		t.Errorf("DAL3 shouldn't go around with the runway clear")
+	}
+
+	// Departures wait for an arrival that's close in.
+	arr = makeApproachAircraft("JBU4", 1)
+	s.State.Aircraft[arr.Callsign] = arr
+	if s.runwayClearForDeparture("KJFK", "31L") {
+		t.Errorf("31L shouldn't be clear for departure with JBU4 on short final")
+	}
+
+	// Landing aircraft occupy the runway themselves but don't go
+	// around for it.
+	s.occupyRunway(arr, "KJFK", "31L", false)
+	arr.Nav.FlightState.Position = math.Point2LL{-73.76, 40.64 - 0.5/60}
+	s.checkRunwayClear(arr)
+	if arr.Nav.Approach.Assigned == nil {
+		t.Errorf("JBU4 shouldn't go around for itself")
+	}
+}
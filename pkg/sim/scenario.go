@@ -99,6 +99,7 @@ type STARSControllerConfig struct {
 type Airspace struct {
 	Boundaries map[string][]math.Point2LL            `json:"boundaries"`
 	Volumes    map[string][]ControllerAirspaceVolume `json:"volumes"`
+	SpecialUse []av.SpecialUseAirspace               `json:"special_use"`
 }
 
 type ControllerAirspaceVolume struct {
@@ -133,6 +134,13 @@ type Scenario struct {
 	CenterString string        `json:"center"`
 	Range        float32       `json:"range"`
 	DefaultMaps  []string      `json:"default_maps"`
+
+	WeatherCells []av.WeatherCell `json:"weather_cells"`
+	NOTAMs       []NOTAM          `json:"notams"`
+	TMIs         []TMI            `json:"tmis"`
+	// Optional METARs, keyed by airport, used when the sim isn't using
+	// live or local weather.
+	METAR map[string]string `json:"metar,omitempty"`
 }
 
 type ScenarioGroupDepartureRunway struct {
@@ -145,8 +153,9 @@ type ScenarioGroupDepartureRunway struct {
 }
 
 type ScenarioGroupArrivalRunway struct {
-	Airport string `json:"airport"`
-	Runway  string `json:"runway"`
+	Airport        string `json:"airport"`
+	Runway         string `json:"runway"`
+	AcceptanceRate int    `json:"acceptance_rate,omitempty"` // arrivals per hour
 }
 
 func (s *Scenario) PostDeserialize(sg *ScenarioGroup, e *util.ErrorLogger) {
@@ -290,6 +299,9 @@ func (s *Scenario) PostDeserialize(sg *ScenarioGroup, e *util.ErrorLogger) {
 				e.ErrorString("no approach found that reaches this runway")
 			}
 		}
+		if rwy.AcceptanceRate < 0 {
+			e.ErrorString("\"acceptance_rate\" must be positive")
+		}
 		e.Pop()
 	}
 
@@ -638,6 +650,67 @@ func (s *Scenario) PostDeserialize(sg *ScenarioGroup, e *util.ErrorLogger) {
 		}
 	}
 
+	for i, cell := range s.WeatherCells {
+		e.Push(fmt.Sprintf("Weather cell %d", i))
+		if cell.Center.IsZero() {
+			e.ErrorString("must specify \"center\"")
+		}
+		if cell.Radius <= 0 {
+			e.ErrorString("\"radius\" must be greater than zero")
+		}
+		if cell.Level < 1 || cell.Level > 6 {
+			e.ErrorString("\"level\" %d must be between 1 and 6", cell.Level)
+		}
+		if cell.Top <= 0 {
+			e.ErrorString("must specify \"top\"")
+		}
+		if cell.Id != "" && slices.ContainsFunc(s.WeatherCells[:i],
+			func(c av.WeatherCell) bool { return c.Id == cell.Id }) {
+			e.ErrorString("\"id\" \"%s\" is used by multiple weather cells", cell.Id)
+		}
+		e.Pop()
+	}
+
+	for i, n := range s.NOTAMs {
+		e.Push(fmt.Sprintf("NOTAM %d", i))
+		if err := n.Check(sg.Airports); err != nil {
+			e.Error(err)
+		}
+		if n.Id != "" && slices.ContainsFunc(s.NOTAMs[:i], func(o NOTAM) bool { return o.Id == n.Id }) {
+			e.ErrorString("\"id\" \"%s\" is used by multiple NOTAMs", n.Id)
+		}
+		e.Pop()
+	}
+
+	for i, t := range s.TMIs {
+		e.Push(fmt.Sprintf("TMI %d", i))
+		if t.Type == TMIEDCT {
+			// The sim's start time isn't known in advance.
+			e.ErrorString("EDCTs can't be specified in scenarios")
+		} else if err := t.Check(sg.Airports); err != nil {
+			e.Error(err)
+		}
+		if t.Id != "" && slices.ContainsFunc(s.TMIs[:i], func(o TMI) bool { return o.Id == t.Id }) {
+			e.ErrorString("\"id\" \"%s\" is used by multiple TMIs", t.Id)
+		}
+		e.Pop()
+	}
+
+	for _, icao := range util.SortedMapKeys(s.METAR) {
+		e.Push("METAR " + icao)
+		if _, ok := sg.Airports[icao]; !ok {
+			e.ErrorString("airport not found")
+		}
+		if m, err := av.ParseMETAR(s.METAR[icao]); err != nil {
+			e.Error(err)
+		} else if m.AirportICAO != icao {
+			e.ErrorString("METAR is for \"%s\"", m.AirportICAO)
+		} else if _, err := m.GetWind(); err != nil {
+			e.Error(err)
+		}
+		e.Pop()
+	}
+
 	fa := sg.STARSFacilityAdaptation
 	if len(s.DefaultMaps) > 0 {
 		if len(fa.ControllerConfigs) > 0 {
@@ -788,6 +861,16 @@ func (sg *ScenarioGroup) PostDeserialize(multiController bool, e *util.ErrorLogg
 		}
 	}
 
+	for i, sua := range sg.Airspace.SpecialUse {
+		e.Push("Special use airspace " + util.Select(sua.Name != "", sua.Name, strconv.Itoa(i)))
+		sua.Check(e)
+		if sua.Name != "" && slices.ContainsFunc(sg.Airspace.SpecialUse[:i],
+			func(s av.SpecialUseAirspace) bool { return s.Name == sua.Name }) {
+			e.ErrorString("multiple special use airspaces have this name")
+		}
+		e.Pop()
+	}
+
 	if sg.PrimaryAirport == "" {
 		e.ErrorString("\"primary_airport\" not specified")
 	} else if ap, ok := av.DB.Airports[sg.PrimaryAirport]; !ok {
@@ -6,6 +6,7 @@ package sim
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"html/template"
 	"io"
@@ -26,13 +27,18 @@ import (
 
 const ViceServerAddress = "vice.pharr.org"
 const ViceServerPort = 8000 + ViceRPCVersion
-const ViceRPCVersion = 17
+const ViceRPCVersion = 19
+
+// ViceHTTPPort is the port for server status and administration.
+const ViceHTTPPort = 6502
 
 type Server struct {
 	*util.RPCClient
-	name        string
-	configs     map[string]map[string]*Configuration
-	runningSims map[string]*RemoteSim
+	name          string
+	configs       map[string]map[string]*Configuration
+	runningSims   map[string]*RemoteSim
+	authToken     string
+	canCreateSims bool
 }
 
 type serverConnection struct {
@@ -44,8 +50,60 @@ func (s *Server) Close() error {
 	return s.RPCClient.Close()
 }
 
-func RunServer(extraScenario string, extraVideoMap string, serverPort int, lg *log.Logger) {
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", serverPort))
+// ServerOptions holds the settings for RunServer.
+type ServerOptions struct {
+	Port    int
+	APIPort int // 0 disables the JSON API
+
+	// JSON file with a ServerConfig that restricts access to the server;
+	// if empty, access is unrestricted.
+	ConfigFilename string
+
+	// Certificate and private key PEM files. If given, clients may connect
+	// using TLS; if RequireTLS is set, they must.
+	TLSCertFile string
+	TLSKeyFile  string
+	RequireTLS  bool
+
+	// Announce the server to clients on the local network.
+	Announce bool
+}
+
+// serverOptions holds the settings for runServer once files have been
+// loaded.
+type serverOptions struct {
+	apiPort    int
+	config     *ServerConfig
+	tlsConfig  *tls.Config
+	requireTLS bool
+	announce   bool
+}
+
+func RunServer(extraScenario string, extraVideoMap string, opts ServerOptions, lg *log.Logger) {
+	so := serverOptions{apiPort: opts.APIPort, requireTLS: opts.RequireTLS, announce: opts.Announce}
+
+	if opts.ConfigFilename != "" {
+		var err error
+		if so.config, err = LoadServerConfig(opts.ConfigFilename); err != nil {
+			lg.Errorf("%v", err)
+			os.Exit(1)
+		}
+		lg.Infof("%s: restricting access to %d users", opts.ConfigFilename, len(so.config.Users))
+	}
+
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			lg.Errorf("%v", err)
+			os.Exit(1)
+		}
+		so.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	} else if opts.RequireTLS {
+		lg.Errorf("A certificate and key must be provided to require TLS")
+		os.Exit(1)
+	}
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", opts.Port))
 	if err != nil {
 		lg.Errorf("tcp listen: %v", err)
 		return
@@ -53,11 +111,19 @@ func RunServer(extraScenario string, extraVideoMap string, serverPort int, lg *l
 
 	// If we're just running the server, we don't care about the returned
 	// configs...
-	runServer(l, false, extraScenario, extraVideoMap, lg)
+	runServer(l, false, so, extraScenario, extraVideoMap, lg)
 }
 
-func getClient(hostname string, lg *log.Logger) (*util.RPCClient, error) {
-	conn, err := net.Dial("tcp", hostname)
+// getClient connects to the server at the given address, using TLS if
+// tlsConfig is non-nil.
+func getClient(hostname string, tlsConfig util.ClientTLSConfig, lg *log.Logger) (*util.RPCClient, error) {
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.Dial("tcp", hostname, tlsConfig(hostname))
+	} else {
+		conn, err = net.Dial("tcp", hostname)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -72,25 +138,31 @@ func getClient(hostname string, lg *log.Logger) (*util.RPCClient, error) {
 	return &util.RPCClient{rpc.NewClientWithCodec(codec)}, nil
 }
 
-func TryConnectRemoteServer(hostname string, lg *log.Logger) chan *serverConnection {
+func TryConnectRemoteServer(hostname string, authToken string, tlsConfig util.ClientTLSConfig,
+	lg *log.Logger) chan *serverConnection {
 	ch := make(chan *serverConnection, 1)
 	go func() {
-		if client, err := getClient(hostname, lg); err != nil {
+		if client, err := getClient(hostname, tlsConfig, lg); err != nil {
 			ch <- &serverConnection{Err: err}
 			return
 		} else {
 			var so SignOnResult
 			start := time.Now()
-			if err := client.CallWithTimeout("SimManager.SignOn", ViceRPCVersion, &so); err != nil {
+			if err := client.CallWithTimeout("SimManager.SignOn", &SignOnArgs{
+				Version:   ViceRPCVersion,
+				AuthToken: authToken,
+			}, &so); err != nil {
 				ch <- &serverConnection{Err: err}
 			} else {
 				lg.Debugf("%s: server returned configuration in %s", hostname, time.Since(start))
 				ch <- &serverConnection{
 					Server: &Server{
-						RPCClient:   client,
-						name:        "Network (Multi-controller)",
-						configs:     so.Configurations,
-						runningSims: so.RunningSims,
+						RPCClient:     client,
+						name:          "Network (Multi-controller)",
+						configs:       so.Configurations,
+						runningSims:   so.RunningSims,
+						authToken:     authToken,
+						canCreateSims: so.CanCreateSims,
 					},
 				}
 			}
@@ -108,29 +180,31 @@ func LaunchLocalServer(extraScenario string, extraVideoMap string, lg *log.Logge
 
 	port := l.Addr().(*net.TCPAddr).Port
 
-	configsChan, mapLibrary := runServer(l, true, extraScenario, extraVideoMap, lg)
+	configsChan, mapLibrary := runServer(l, true, serverOptions{}, extraScenario, extraVideoMap, lg)
 
 	ch := make(chan *Server, 1)
 	go func() {
 		configs := <-configsChan
 
-		client, err := getClient(fmt.Sprintf("localhost:%d", port), lg)
+		client, err := getClient(fmt.Sprintf("localhost:%d", port), nil, lg)
 		if err != nil {
 			lg.Errorf("unable to get client: %v", err)
 			os.Exit(1)
 		}
 
 		ch <- &Server{
-			RPCClient: client,
-			name:      "Local (Single controller)",
-			configs:   configs,
+			RPCClient:     client,
+			name:          "Local (Single controller)",
+			configs:       configs,
+			canCreateSims: true,
 		}
 	}()
 
 	return ch, mapLibrary, nil
 }
 
-func runServer(l net.Listener, isLocal bool, extraScenario string, extraVideoMap string,
+// runServer starts the server on the given listener.
+func runServer(l net.Listener, isLocal bool, opts serverOptions, extraScenario string, extraVideoMap string,
 	lg *log.Logger) (chan map[string]map[string]*Configuration, *av.VideoMapLibrary) {
 	ch := make(chan map[string]map[string]*Configuration, 1)
 
@@ -141,11 +215,19 @@ func runServer(l net.Listener, isLocal bool, extraScenario string, extraVideoMap
 		e.PrintErrors(lg)
 		os.Exit(1)
 	}
+	if opts.config != nil {
+		for _, facility := range opts.config.Facilities {
+			if _, ok := simConfigurations[facility]; !ok {
+				lg.Errorf("%s: facility in server configuration not found", facility)
+				os.Exit(1)
+			}
+		}
+	}
 
 	server := func() {
 		server := rpc.NewServer()
 
-		sm := NewSimManager(scenarioGroups, simConfigurations, mapLib, lg)
+		sm := NewSimManager(scenarioGroups, simConfigurations, mapLib, opts.config, lg)
 		if err := server.Register(sm); err != nil {
 			lg.Errorf("unable to register SimManager: %v", err)
 			os.Exit(1)
@@ -155,11 +237,27 @@ func runServer(l net.Listener, isLocal bool, extraScenario string, extraVideoMap
 			os.Exit(1)
 		}
 
-		go launchHTTPStats(sm)
+		go launchHTTPStats(sm, opts)
+		if opts.apiPort != 0 {
+			go launchAPIServer(sm, opts.apiPort, opts.tlsConfig, opts.requireTLS, lg)
+		}
+		if opts.announce {
+			name, err := os.Hostname()
+			if err != nil {
+				lg.Warnf("hostname: %v", err)
+				name = "vice"
+			}
+			go announceServer(sm, name, l.Addr().(*net.TCPAddr).Port, opts, lg)
+		}
 
 		ch <- simConfigurations
 
-		lg.Infof("Listening on %+v", l)
+		if opts.tlsConfig != nil {
+			l = util.MakeTLSListener(l, opts.tlsConfig, opts.requireTLS, lg)
+			lg.Infof("Accepting TLS connections (required: %v)", opts.requireTLS)
+		}
+
+		lg.Infof("Listening on %s", l.Addr())
 
 		for {
 			conn, err := l.Accept()
@@ -189,7 +287,7 @@ func runServer(l net.Listener, isLocal bool, extraScenario string, extraVideoMap
 
 var launchTime time.Time
 
-func launchHTTPStats(sm *SimManager) {
+func launchHTTPStats(sm *SimManager, opts serverOptions) {
 	launchTime = time.Now()
 	http.HandleFunc("/sup", func(w http.ResponseWriter, r *http.Request) {
 		statsHandler(w, r, sm)
@@ -206,8 +304,19 @@ func launchHTTPStats(sm *SimManager) {
 		}
 	})
 
-	if err := http.ListenAndServe(":6502", nil); err != nil {
+	registerAdminHandlers(http.DefaultServeMux, sm)
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", ViceHTTPPort))
+	if err != nil {
 		sm.lg.Errorf("Failed to start HTTP server for stats: %v\n", err)
+		return
+	}
+	if opts.tlsConfig != nil {
+		// Admin requests include access tokens.
+		l = util.MakeTLSListener(l, opts.tlsConfig, opts.requireTLS, sm.lg)
+	}
+	if err := http.Serve(l, nil); err != nil {
+		sm.lg.Errorf("HTTP server for stats: %v\n", err)
 	}
 }
 
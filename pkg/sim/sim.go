@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/rpc"
 	"runtime"
 	"slices"
@@ -27,7 +28,6 @@ import (
 	"github.com/mmp/vice/pkg/util"
 
 	"github.com/brunoga/deep"
-	getweather "github.com/checkandmate1/AirportWeatherData"
 	"github.com/mmp/imgui-go/v4"
 )
 
@@ -35,9 +35,22 @@ const initialSimSeconds = 45
 
 var (
 	airportWind = make(map[string]av.Wind)
-	windRequest = make(map[string]chan getweather.MetarData)
+	windRequest = make(map[string]chan *av.METAR)
+	// When fetching an airport's wind last failed; it isn't requested
+	// again until windRetryInterval has passed.
+	windFailure = make(map[string]time.Time)
 )
 
+const windRetryInterval = time.Minute
+
+// resetWind discards the fetched airport winds, e.g., when the weather
+// source changes.
+func resetWind() {
+	clear(airportWind)
+	clear(windRequest)
+	clear(windFailure)
+}
+
 type Configuration struct {
 	ScenarioConfigs  map[string]*SimScenarioConfiguration
 	ControlPositions map[string]*av.Controller
@@ -81,6 +94,9 @@ type LaunchConfig struct {
 	ArrivalPushes               bool
 	ArrivalPushFrequencyMinutes int
 	ArrivalPushLengthMinutes    int
+	// If set, departures don't take off until the tower has called for
+	// their release and the departure controller has released them.
+	RequireReleases bool
 }
 
 func MakeLaunchConfig(dep []ScenarioGroupDepartureRunway, inbound map[string]map[string]int) LaunchConfig {
@@ -150,6 +166,7 @@ func (lc *LaunchConfig) DrawDepartureUI(p platform.Platform) (changed bool) {
 	imgui.Text(fmt.Sprintf("Overall departure rate: %d / hour", sumRates))
 
 	changed = imgui.SliderFloatV("Sequencing challenge", &lc.DepartureChallenge, 0, 1, "%.02f", 0) || changed
+	changed = imgui.Checkbox("Require IFR releases", &lc.RequireReleases) || changed
 	flags := imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsRowBg | imgui.TableFlagsSizingStretchProp
 
 	tableScale := util.Select(runtime.GOOS == "windows", p.DPIScale(), float32(1))
@@ -315,12 +332,22 @@ type NewSimConfiguration struct {
 	NewSimName      string // for create remote only
 	RequirePassword bool   // for create remote only
 	Password        string // for create remote only
-	NewSimType      int
 
-	LiveWeather               bool
+	RequireObserverPassword bool   // for create remote only
+	ObserverPassword        string // for create remote only
+	ObserversHearRadio      bool   // for create remote only
+	NewSimType              int
+
+	WeatherSource             int
+	WeatherDir                string // for WeatherSourceLocal
 	SelectedRemoteSim         string
 	SelectedRemoteSimPosition string
 	RemoteSimPassword         string // for join remote only
+	ObserverName              string // for join remote as an observer only
+	AuthToken                 string // identifies the user to servers that restrict access
+
+	AdjacentFacilities   map[string]string // TRACON -> scenario group; for create remote only
+	HumanCenterPositions bool              // for create remote only
 
 	lastRemoteSimsUpdate time.Time
 	updateRemoteSimsCall *util.PendingCall
@@ -333,14 +360,21 @@ type NewSimConfiguration struct {
 }
 
 type RemoteSim struct {
-	GroupName          string
-	ScenarioName       string
-	PrimaryController  string
-	RequirePassword    bool
-	AvailablePositions map[string]struct{}
-	CoveredPositions   map[string]struct{}
+	GroupName               string
+	ScenarioName            string
+	PrimaryController       string
+	RequirePassword         bool
+	RequireObserverPassword bool
+	AvailablePositions      map[string]struct{}
+	CoveredPositions        map[string]struct{}
+	Observers               []string
 }
 
+// ObserverPosition is the position that is selected to join a remote sim
+// as an observer. Observers receive world updates but can't make any
+// changes to the sim.
+const ObserverPosition = "Observer"
+
 const (
 	NewSimCreateLocal = iota
 	NewSimCreateRemote
@@ -399,6 +433,7 @@ func (c *NewSimConfiguration) SetTRACON(name string) {
 	}
 	c.TRACONName = name
 	c.GroupName = util.SortedMapKeys(c.TRACON)[0]
+	c.AdjacentFacilities = nil
 
 	c.SetScenario(c.GroupName, c.TRACON[c.GroupName].DefaultScenario)
 }
@@ -450,6 +485,8 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 	}
 
 	tableScale := util.Select(runtime.GOOS == "windows", p.DPIScale(), float32(1))
+	c.drawDiscoveredServers(tableScale)
+
 	if c.mgr.remoteServer != nil {
 		if imgui.BeginTableV("server", 2, 0, imgui.Vec2{tableScale * 500, 0}, 0.) {
 			imgui.TableNextRow()
@@ -469,12 +506,14 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 			imgui.TableNextRow()
 			imgui.TableNextColumn()
 			imgui.TableNextColumn()
+			uiStartDisable(!c.mgr.remoteServer.canCreateSims)
 			if imgui.RadioButtonInt("Create multi-controller", &c.NewSimType, NewSimCreateRemote) &&
 				origType != NewSimCreateRemote {
 				c.selectedServer = c.mgr.remoteServer
 				c.SetTRACON(*c.defaultTRACON)
 				c.DisplayError = nil
 			}
+			uiEndDisable(!c.mgr.remoteServer.canCreateSims)
 
 			imgui.TableNextRow()
 			imgui.TableNextColumn()
@@ -614,6 +653,20 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 					imgui.PopStyleColor()
 				}
 			}
+
+			imgui.Checkbox("Require Observer Password", &c.RequireObserverPassword)
+			if c.RequireObserverPassword {
+				imgui.InputTextV("Observer Password", &c.ObserverPassword, 0, nil)
+				if c.ObserverPassword == "" {
+					imgui.SameLine()
+					imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{.7, .1, .1, 1})
+					imgui.Text(renderer.FontAwesomeIconExclamationTriangle)
+					imgui.PopStyleColor()
+				}
+			}
+			imgui.Checkbox("Observers hear all radio transmissions", &c.ObserversHearRadio)
+
+			c.drawAdjacentFacilitiesUI()
 		}
 
 		if imgui.BeginTableV("scenario", 2, 0, imgui.Vec2{tableScale * 500, 0}, 0.) {
@@ -643,19 +696,35 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 			imgui.TableNextRow()
 			imgui.TableNextColumn()
 			imgui.Text("Wind:")
+			if imgui.RadioButtonInt("Scenario", &c.WeatherSource, WeatherSourceScenario) {
+				resetWind()
+			}
+			imgui.SameLine()
 			uiStartDisable(!validAirport)
-			imgui.Checkbox("Live Weather", &c.LiveWeather)
-			if !validAirport {
-				c.LiveWeather = false
+			if imgui.RadioButtonInt("Live", &c.WeatherSource, WeatherSourceLive) {
+				resetWind()
+			}
+			if !validAirport && c.WeatherSource == WeatherSourceLive {
+				c.WeatherSource = WeatherSourceScenario
 			}
 			uiEndDisable(!validAirport)
+			imgui.SameLine()
+			if imgui.RadioButtonInt("Local files", &c.WeatherSource, WeatherSourceLocal) {
+				resetWind()
+			}
+			if c.WeatherSource == WeatherSourceLocal {
+				if imgui.InputTextV("METAR directory", &c.WeatherDir, 0, nil) {
+					resetWind()
+				}
+			}
 			imgui.TableNextColumn()
 			wind := c.Scenario.Wind
-			if c.LiveWeather {
+			if c.WeatherSource != WeatherSourceScenario {
 				var ok bool
 				if wind, ok = airportWind[c.Scenario.PrimaryAirport]; !ok {
 					primary := c.Scenario.PrimaryAirport
-					wind, ok = getWind(primary, c.lg)
+					wp := MakeWeatherProvider(c.WeatherSource, c.WeatherDir, nil)
+					wind, ok = getWind(primary, wp, c.lg)
 					if !ok {
 						wind = c.Scenario.Wind
 					}
@@ -672,13 +741,12 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 			} else {
 				imgui.Text(fmt.Sprintf("%v at %d", dir, wind.Speed))
 			}
-			uiStartDisable(!c.LiveWeather)
+			uiStartDisable(c.WeatherSource == WeatherSourceScenario)
 			refresh := imgui.Button("Refresh Weather")
 			if refresh {
-				clear(airportWind)
-				clear(windRequest)
+				resetWind()
 			}
-			uiEndDisable(!c.LiveWeather)
+			uiEndDisable(c.WeatherSource == WeatherSourceScenario)
 			imgui.EndTable()
 
 		}
@@ -709,10 +777,6 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 
 			for _, simName := range util.SortedMapKeys(runningSims) {
 				rs := runningSims[simName]
-				if len(rs.AvailablePositions) == 0 {
-					// No open positions left; don't even offer it.
-					continue
-				}
 
 				imgui.PushID(simName)
 				imgui.TableNextRow()
@@ -743,8 +807,12 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 				covered, available := len(rs.CoveredPositions), len(rs.AvailablePositions)
 				controllers := fmt.Sprintf("%d / %d", covered, covered+available)
 				imgui.Text(controllers)
-				if imgui.IsItemHovered() && len(rs.CoveredPositions) > 0 {
-					imgui.SetTooltip(strings.Join(util.SortedMapKeys(rs.CoveredPositions), ", "))
+				if imgui.IsItemHovered() && (len(rs.CoveredPositions) > 0 || len(rs.Observers) > 0) {
+					tip := strings.Join(util.SortedMapKeys(rs.CoveredPositions), ", ")
+					if len(rs.Observers) > 0 {
+						tip += "\nObservers: " + strings.Join(rs.Observers, ", ")
+					}
+					imgui.SetTooltip(strings.TrimSpace(tip))
 				}
 
 				imgui.PopID()
@@ -753,8 +821,12 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 		}
 
 		// Handle the case of someone else signing in to the position
-		if _, ok := rs.AvailablePositions[c.SelectedRemoteSimPosition]; c.SelectedRemoteSimPosition != "Observer" && !ok {
-			c.SelectedRemoteSimPosition = util.SortedMapKeys(rs.AvailablePositions)[0]
+		if _, ok := rs.AvailablePositions[c.SelectedRemoteSimPosition]; c.SelectedRemoteSimPosition != ObserverPosition && !ok {
+			if len(rs.AvailablePositions) > 0 {
+				c.SelectedRemoteSimPosition = util.SortedMapKeys(rs.AvailablePositions)[0]
+			} else {
+				c.SelectedRemoteSimPosition = ObserverPosition
+			}
 		}
 
 		if imgui.BeginComboV("Position", c.SelectedRemoteSimPosition, 0) {
@@ -767,13 +839,18 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 				}
 			}
 
-			if imgui.SelectableV("Observer", "Observer" == c.SelectedRemoteSimPosition, 0, imgui.Vec2{}) {
-				c.SelectedRemoteSimPosition = "Observer"
+			if imgui.SelectableV(ObserverPosition, ObserverPosition == c.SelectedRemoteSimPosition, 0, imgui.Vec2{}) {
+				c.SelectedRemoteSimPosition = ObserverPosition
 			}
 
 			imgui.EndCombo()
 		}
-		if rs.RequirePassword {
+		if c.SelectedRemoteSimPosition == ObserverPosition {
+			imgui.InputTextV("Name", &c.ObserverName, 0, nil)
+			if rs.RequireObserverPassword {
+				imgui.InputTextV("Observer Password", &c.RemoteSimPassword, 0, nil)
+			}
+		} else if rs.RequirePassword {
 			imgui.InputTextV("Password", &c.RemoteSimPassword, 0, nil)
 		}
 	}
@@ -788,19 +865,18 @@ func (c *NewSimConfiguration) DrawRatesUI(p platform.Platform) bool {
 	return false
 }
 
-func getWind(airport string, lg *log.Logger) (av.Wind, bool) {
+func getWind(airport string, wp WeatherProvider, lg *log.Logger) (av.Wind, bool) {
 	for airport, ch := range windRequest {
 		select {
-		case w := <-ch:
-			dirStr := fmt.Sprintf("%v", w.Wdir)
-			dir, err := strconv.Atoi(dirStr)
-			if err != nil {
-				lg.Errorf("Error converting %v to an int: %v", dirStr, err)
-			}
-			airportWind[airport] = av.Wind{
-				Direction: int32(dir),
-				Speed:     int32(w.Wspd),
-				Gust:      int32(w.Wgst),
+		case m := <-ch:
+			if m == nil {
+				windFailure[airport] = time.Now()
+			} else if w, err := m.GetWind(); err != nil {
+				lg.Errorf("%s: %v", airport, err)
+				windFailure[airport] = time.Now()
+			} else {
+				airportWind[airport] = w
+				delete(windFailure, airport)
 			}
 			delete(windRequest, airport)
 		default:
@@ -814,27 +890,101 @@ func getWind(airport string, lg *log.Logger) (av.Wind, bool) {
 	} else if _, ok := windRequest[airport]; ok {
 		// it's been requested but we don't have it yet
 		return av.Wind{}, false
+	} else if t, ok := windFailure[airport]; ok && time.Since(t) < windRetryInterval {
+		// The last request failed; don't hammer the provider (or the
+		// log) with requests every frame. The caller falls back to the
+		// scenario's wind in the meantime.
+		return av.Wind{}, false
 	} else {
 		// It hasn't been requested nor is in airportWind
-		c := make(chan getweather.MetarData)
+		c := make(chan *av.METAR, 1)
 		windRequest[airport] = c
 		go func() {
-			weather, err := getweather.GetWeather(airport)
-			if len(err) != 0 {
-				lg.Errorf("%v", err)
+			m, err := wp.GetMETAR(airport)
+			if err != nil {
+				lg.Errorf("%s: %v", airport, err)
 			}
-			c <- weather
+			c <- m
 		}()
 		return av.Wind{}, false
 	}
 
 }
 
+// drawDiscoveredServers lists the servers on the local network and lets
+// the user choose one to connect to.
+func (c *NewSimConfiguration) drawDiscoveredServers(tableScale float32) {
+	servers := c.mgr.DiscoveredServers()
+	if len(servers) == 0 {
+		return
+	}
+
+	imgui.Text("Servers on the local network:")
+	flags := imgui.TableFlagsBordersH | imgui.TableFlagsBordersOuterV | imgui.TableFlagsRowBg |
+		imgui.TableFlagsSizingFixedFit
+	if imgui.BeginTableV("discovered", 3, flags, imgui.Vec2{tableScale * 500, 0}, 0.) {
+		imgui.TableSetupColumn("Server")
+		imgui.TableSetupColumn("Simulations")
+		imgui.TableSetupColumn("")
+		imgui.TableHeadersRow()
+
+		for _, ds := range servers {
+			imgui.PushID(ds.Address)
+			imgui.TableNextRow()
+
+			imgui.TableNextColumn()
+			imgui.Text(ds.Name)
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip(ds.Address)
+			}
+
+			imgui.TableNextColumn()
+			var names, details []string
+			for _, s := range ds.Sims {
+				names = append(names, s.Name)
+				d := s.Name + ": " + s.Scenario
+				if len(s.CoveredPositions) > 0 {
+					d += " (" + strings.Join(s.CoveredPositions, ", ") + ")"
+				}
+				details = append(details, d)
+			}
+			imgui.Text(util.Select(len(names) > 0, strings.Join(names, ", "), "(none)"))
+			if imgui.IsItemHovered() && len(details) > 0 {
+				imgui.SetTooltip(strings.Join(details, "\n"))
+			}
+
+			imgui.TableNextColumn()
+			if ds.Address == c.mgr.ServerAddress() {
+				imgui.Text(util.Select(c.mgr.remoteServer != nil, "Connected", "Connecting..."))
+			} else if ds.RequireTLS && c.mgr.tlsConfig == nil {
+				imgui.Text("Requires TLS")
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip("Start vice with -tls or -tlstofu to connect to this server.")
+				}
+			} else if imgui.Button("Connect") {
+				c.mgr.UseServer(ds.Address)
+				// There's no remote server until the connection is made.
+				c.NewSimType = NewSimCreateLocal
+				c.selectedServer = c.mgr.localServer
+				c.SetTRACON(*c.defaultTRACON)
+				c.DisplayError = nil
+			}
+
+			imgui.PopID()
+		}
+		imgui.EndTable()
+	}
+	imgui.Separator()
+}
+
 func (c *NewSimConfiguration) OkDisabled() bool {
-	return c.NewSimType == NewSimCreateRemote && (c.NewSimName == "" || (c.RequirePassword && c.Password == ""))
+	return c.NewSimType == NewSimCreateRemote && (c.NewSimName == "" || (c.RequirePassword && c.Password == "") ||
+		(c.RequireObserverPassword && c.ObserverPassword == ""))
 }
 
 func (c *NewSimConfiguration) Start() error {
+	c.AuthToken = c.selectedServer.authToken
+
 	var result NewSimResult
 	if err := c.selectedServer.CallWithTimeout("SimManager.New", c, &result); err != nil {
 		err = TryDecodeError(err)
@@ -856,14 +1006,16 @@ func (c *NewSimConfiguration) Start() error {
 			ControllerToken: result.ControllerToken,
 			Client:          c.selectedServer.RPCClient,
 		},
+		ReconnectToken: result.ReconnectToken,
 	})
 
 	return nil
 }
 
 type Connection struct {
-	SimState State
-	SimProxy *proxy
+	SimState       State
+	SimProxy       *proxy
+	ReconnectToken string
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -881,10 +1033,20 @@ type Sim struct {
 
 	controllers     map[string]*ServerController // from token
 	SignOnPositions map[string]*av.Controller
+	// Virtual controllers that were replaced when a human signed on to
+	// their position; they are restored when the human signs off.
+	replacedVirtualControllers map[string]*av.Controller
 
 	eventStream *EventStream
 	lg          *log.Logger
 
+	// Incremented for each new snapshot of the world; see delta.go.
+	worldVersion   uint64
+	worldSnapshots []*worldSnapshot
+	// Set when the sim is updated so that the world is checked for
+	// changes the next time a snapshot is needed.
+	worldChanged bool
+
 	LaunchConfig LaunchConfig
 
 	// airport -> runway -> category
@@ -893,6 +1055,13 @@ type Sim struct {
 	sameGateDepartures int
 	sameDepartureCap   int
 
+	// The next ERAM computer identification number to try to assign.
+	nextECID int
+
+	// miles-in-trail fix -> callsign of the last aircraft that crossed
+	// it, for checking compliance.
+	lastMITCrossing map[string]string
+
 	// We track an overall "at what time do we launch the next departure"
 	// time for each airport. When that time is reached, we'll pick a
 	// runway, category, etc., based on the respective rates.
@@ -905,6 +1074,16 @@ type Sim struct {
 	// callsign -> "to" controller
 	PointOuts map[string]map[string]PointOut
 
+	// Departures that are on the ground waiting to be released, by
+	// callsign.
+	PendingDepartures map[string]*av.Aircraft
+	// Departures held at the gate by TMIs, in the order they were
+	// created.
+	HeldDepartures []HeldDeparture
+
+	// Aircraft currently on runways, keyed by "airport/runway".
+	RunwayOccupancy map[string]RunwayOccupancy
+
 	TotalDepartures  int
 	TotalArrivals    int
 	TotalOverflights int
@@ -914,6 +1093,11 @@ type Sim struct {
 	RequirePassword bool
 	Password        string
 
+	RequireObserverPassword bool
+	ObserverPassword        string
+	// Whether observers receive all radio transmissions
+	ObserversHearRadio bool
+
 	lastSimUpdate time.Time
 
 	SimTime        time.Time // this is our fake time--accounting for pauses & simRate..
@@ -923,9 +1107,16 @@ type Sim struct {
 	lastLogTime    time.Time
 	SimRate        float32
 	Paused         bool
+	terminated     bool // by the server admin; the sim exits shortly after
 
 	NextPushStart time.Time // both w.r.t. sim time
 	PushEnd       time.Time
+
+	// Where METARs come from; they are refreshed hourly.
+	WeatherSource   int
+	WeatherDir      string
+	NextMETARUpdate time.Time
+	fetchingMETAR   bool
 }
 
 type Handoff struct {
@@ -938,13 +1129,35 @@ type PointOut struct {
 	AcceptTime     time.Time
 }
 
+// How long a multi-controller sim holds a controller's position and tracks
+// after their connection drops so that they can reconnect.
+const reconnectGracePeriod = 2 * time.Minute
+
 type ServerController struct {
-	Callsign            string
+	Callsign string
+	// For observers, Callsign is ObserverPosition and this is the name
+	// they gave when signing on.
+	observerName        string
+	role                Role
+	reconnectToken      string // for re-attaching after a dropped connection; see Reconnect
 	lastUpdateCall      time.Time
 	warnedNoUpdateCalls bool
 	events              *EventsSubscription
 }
 
+// IsObserver returns true if the controller is an observer.
+func (sc *ServerController) IsObserver() bool {
+	return sc.Callsign == ObserverPosition
+}
+
+// displayName returns the name used for the controller in messages.
+func (sc *ServerController) displayName() string {
+	if sc.IsObserver() {
+		return sc.observerName + " (observer)"
+	}
+	return sc.Callsign
+}
+
 func (sc *ServerController) LogValue() slog.Value {
 	return slog.GroupValue(
 		slog.String("callsign", sc.Callsign),
@@ -989,6 +1202,10 @@ func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*Scena
 		Password:        ssc.Password,
 		RequirePassword: ssc.RequirePassword,
 
+		ObserverPassword:        ssc.ObserverPassword,
+		RequireObserverPassword: ssc.RequireObserverPassword,
+		ObserversHearRadio:      ssc.ObserversHearRadio,
+
 		SimTime:        time.Now(),
 		lastUpdateTime: time.Now(),
 
@@ -1036,7 +1253,26 @@ func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*Scena
 		add(sc.SoloController)
 	}
 
-	s.State = newState(ssc.Scenario.SelectedSplit, ssc.LiveWeather, isLocal, s, sg, sc, mapLib, lg)
+	s.WeatherSource, s.WeatherDir = ssc.WeatherSource, ssc.WeatherDir
+	s.NextMETARUpdate = s.SimTime.Add(time.Hour)
+	wp := MakeWeatherProvider(ssc.WeatherSource, ssc.WeatherDir, sc)
+	s.State = newState(ssc.Scenario.SelectedSplit, wp, isLocal, s, sg, sc, mapLib, lg)
+
+	if !isLocal {
+		for _, tracon := range util.SortedMapKeys(ssc.AdjacentFacilities) {
+			if adj, ok := scenarioGroups[tracon][ssc.AdjacentFacilities[tracon]]; ok {
+				s.addAdjacentFacility(sg, adj, mapLib)
+			} else {
+				lg.Errorf("%s/%s: unknown adjacent facility", tracon, ssc.AdjacentFacilities[tracon])
+			}
+		}
+		if len(s.State.AdjacentFacilities) > 0 {
+			s.assignFacility(sg.TRACON)
+		}
+		if ssc.HumanCenterPositions {
+			s.addCenterPositions(sg)
+		}
+	}
 
 	s.setInitialSpawnTimes()
 
@@ -1065,50 +1301,86 @@ func (s *Sim) LogValue() slog.Value {
 		slog.Any("aircraft", s.State.Aircraft))
 }
 
-func (s *Sim) SignOn(callsign string) (*State, string, error) {
+// SignOn signs on a controller at the given position; role limits what
+// the returned token may be used for.
+func (s *Sim) SignOn(callsign string, role Role) (*State, string, error) {
 	if err := s.signOn(callsign); err != nil {
 		return nil, "", err
 	}
+	return s.addServerController(&ServerController{Callsign: callsign, role: role})
+}
+
+// SignOnObserver signs on an observer with the given name; the returned
+// token can be used to get world updates but not to change the sim.
+func (s *Sim) SignOnObserver(name string) (*State, string, error) {
+	if name == "" {
+		name = ObserverPosition
+	}
 
+	s.mu.Lock(s.lg)
+	s.eventStream.Post(Event{
+		Type:    StatusMessageEvent,
+		Message: name + " is observing.",
+	})
+	s.lg.Infof("%s: observer signed on", name)
+	s.mu.Unlock(s.lg)
+
+	return s.addServerController(&ServerController{Callsign: ObserverPosition, observerName: name, role: RoleObserver})
+}
+
+func makeToken() (string, error) {
 	var buf [16]byte
 	if _, err := crand.Read(buf[:]); err != nil {
-		return nil, "", err
+		return "", err
 	}
-	token := base64.StdEncoding.EncodeToString(buf[:])
+	return base64.StdEncoding.EncodeToString(buf[:]), nil
+}
 
-	s.controllers[token] = &ServerController{
-		Callsign:       callsign,
-		lastUpdateCall: time.Now(),
-		events:         s.eventStream.Subscribe(),
+func (s *Sim) addServerController(sc *ServerController) (*State, string, error) {
+	token, err := makeToken()
+	if err != nil {
+		return nil, "", err
+	}
+	if sc.reconnectToken, err = makeToken(); err != nil {
+		return nil, "", err
 	}
 
-	return s.State.GetStateForController(callsign), token, nil
+	sc.lastUpdateCall = time.Now()
+	sc.events = s.eventStream.Subscribe()
+	s.controllers[token] = sc
+
+	return s.State.GetStateForController(sc.Callsign), token, nil
 }
 
 func (s *Sim) signOn(callsign string) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
 
-	if callsign != "Observer" {
-		if s.controllerIsSignedIn(callsign) {
-			return ErrControllerAlreadySignedIn
-		}
+	if s.controllerIsSignedIn(callsign) {
+		return ErrControllerAlreadySignedIn
+	}
 
-		ctrl, ok := s.SignOnPositions[callsign]
-		if !ok {
-			return av.ErrNoController
+	ctrl, ok := s.SignOnPositions[callsign]
+	if !ok {
+		return av.ErrNoController
+	}
+	if vctrl, ok := s.State.Controllers[callsign]; ok && !vctrl.IsHuman {
+		if s.replacedVirtualControllers == nil {
+			s.replacedVirtualControllers = make(map[string]*av.Controller)
 		}
-		// Make a copy of the *Controller and set the sign on time.
-		sctrl := *ctrl
-		sctrl.SignOnTime = time.Now()
-		s.State.Controllers[callsign] = &sctrl
+		s.replacedVirtualControllers[callsign] = vctrl
+	}
 
-		if callsign == s.State.PrimaryController {
-			// The primary controller signed in so the sim will resume.
-			// Reset lastUpdateTime so that the next time Update() is
-			// called for the sim, we don't try to run a ton of steps.
-			s.lastUpdateTime = time.Now()
-		}
+	// Make a copy of the *Controller and set the sign on time.
+	sctrl := *ctrl
+	sctrl.SignOnTime = time.Now()
+	s.State.Controllers[callsign] = &sctrl
+
+	if callsign == s.State.PrimaryController {
+		// The primary controller signed in so the sim will resume.
+		// Reset lastUpdateTime so that the next time Update() is
+		// called for the sim, we don't try to run a ton of steps.
+		s.lastUpdateTime = time.Now()
 	}
 
 	s.eventStream.Post(Event{
@@ -1139,13 +1411,18 @@ func (s *Sim) SignOff(token string) error {
 
 		ctrl.events.Unsubscribe()
 		delete(s.controllers, token)
-		delete(s.State.Controllers, ctrl.Callsign)
+		if vctrl, ok := s.replacedVirtualControllers[ctrl.Callsign]; ok {
+			s.State.Controllers[ctrl.Callsign] = vctrl
+			delete(s.replacedVirtualControllers, ctrl.Callsign)
+		} else {
+			delete(s.State.Controllers, ctrl.Callsign)
+		}
 
 		s.eventStream.Post(Event{
 			Type:    StatusMessageEvent,
-			Message: ctrl.Callsign + " has signed off.",
+			Message: ctrl.displayName() + " has signed off.",
 		})
-		s.lg.Infof("%s: controller signing off", ctrl.Callsign)
+		s.lg.Infof("%s: controller signing off", ctrl.displayName())
 	}
 	return nil
 }
@@ -1211,24 +1488,29 @@ type GlobalMessage struct {
 	FromController string
 }
 
+// WorldUpdate holds the changes to the world since the version that the
+// client acknowledged in its request; see delta.go.
 type WorldUpdate struct {
-	Aircraft    map[string]*av.Aircraft
-	Controllers map[string]*av.Controller
-	Time        time.Time
+	// The changes are relative to BaseVersion; if it is zero, the update
+	// includes everything.
+	Version, BaseVersion uint64
 
-	ERAMComputers *ERAMComputers
+	Time   time.Time
+	Events []Event
 
-	LaunchConfig LaunchConfig
+	AddedAircraft   map[string]*av.Aircraft
+	RemovedAircraft []string
+	ChangedAircraft map[string]AircraftDelta
 
-	SimIsPaused      bool
-	SimRate          float32
-	Events           []Event
-	TotalDepartures  int
-	TotalArrivals    int
-	TotalOverflights int
+	// Only the fields listed in StateFields are set in State.
+	State       WorldState
+	StateFields []string
 }
 
-func (s *Sim) GetWorldUpdate(token string, update *WorldUpdate) error {
+// GetWorldUpdate returns the changes to the world since the given
+// version, which the client has acknowledged receiving. If ack is zero
+// or the snapshot for it is no longer available, everything is sent.
+func (s *Sim) GetWorldUpdate(token string, ack uint64, update *WorldUpdate) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
 
@@ -1238,27 +1520,23 @@ func (s *Sim) GetWorldUpdate(token string, update *WorldUpdate) error {
 		ctrl.lastUpdateCall = time.Now()
 		if ctrl.warnedNoUpdateCalls {
 			ctrl.warnedNoUpdateCalls = false
-			s.lg.Warnf("%s: connection re-established", ctrl.Callsign)
+			s.lg.Warnf("%s: connection re-established", ctrl.displayName())
 			s.eventStream.Post(Event{
 				Type:    StatusMessageEvent,
-				Message: ctrl.Callsign + " is back online.",
+				Message: ctrl.displayName() + " is back online.",
 			})
 		}
 
 		var err error
-		*update, err = deep.Copy(WorldUpdate{
-			Aircraft:         s.State.Aircraft,
-			Controllers:      s.State.Controllers,
-			ERAMComputers:    s.State.ERAMComputers,
-			Time:             s.SimTime,
-			LaunchConfig:     s.LaunchConfig,
-			SimIsPaused:      s.Paused,
-			SimRate:          s.SimRate,
-			Events:           ctrl.events.Get(),
-			TotalDepartures:  s.TotalDepartures,
-			TotalArrivals:    s.TotalArrivals,
-			TotalOverflights: s.TotalOverflights,
-		})
+		if *update, err = s.worldUpdate(ack); err != nil {
+			return err
+		}
+		update.Time = s.SimTime
+		events := ctrl.events.Get()
+		if ctrl.IsObserver() && !s.ObserversHearRadio {
+			events = util.FilterSlice(events, func(e Event) bool { return e.Type != RadioTransmissionEvent })
+		}
+		update.Events, err = deep.Copy(events)
 
 		return err
 	}
@@ -1299,6 +1577,8 @@ func (s *Sim) Update() {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
 
+	s.worldChanged = true
+
 	startUpdate := time.Now()
 	defer func() {
 		if d := time.Since(startUpdate); d > 200*time.Millisecond {
@@ -1312,24 +1592,25 @@ func (s *Sim) Update() {
 	}
 
 	if s.Name != "" {
-		// Sign off controllers we haven't heard from in 15 seconds so that
-		// someone else can take their place. We only make this check for
-		// multi-controller sims; we don't want to do this for local sims
-		// so that we don't kick people off e.g. when their computer
-		// sleeps.
+		// Sign off controllers we haven't heard from within the reconnect
+		// grace period so that someone else can take their place; until
+		// then, their position and tracks are held for them. We only make
+		// this check for multi-controller sims; we don't want to do this
+		// for local sims so that we don't kick people off e.g. when their
+		// computer sleeps.
 		for token, ctrl := range s.controllers {
 			if time.Since(ctrl.lastUpdateCall) > 5*time.Second {
 				if !ctrl.warnedNoUpdateCalls {
 					ctrl.warnedNoUpdateCalls = true
-					s.lg.Warnf("%s: no messages for 5 seconds", ctrl.Callsign)
+					s.lg.Warnf("%s: no messages for 5 seconds", ctrl.displayName())
 					s.eventStream.Post(Event{
 						Type:    StatusMessageEvent,
-						Message: ctrl.Callsign + " has not been heard from for 5 seconds. Connection lost?",
+						Message: ctrl.displayName() + " has not been heard from for 5 seconds. Connection lost?",
 					})
 				}
 
-				if time.Since(ctrl.lastUpdateCall) > 15*time.Second {
-					s.lg.Warnf("%s: signing off idle controller", ctrl.Callsign)
+				if time.Since(ctrl.lastUpdateCall) > reconnectGracePeriod {
+					s.lg.Warnf("%s: signing off idle controller", ctrl.displayName())
 					s.mu.Unlock(s.lg)
 					s.SignOff(token)
 					s.mu.Lock(s.lg)
@@ -1436,9 +1717,20 @@ func (s *Sim) updateState() {
 	// Update the simulation state once a second.
 	if now.Sub(s.lastSimUpdate) >= time.Second {
 		s.lastSimUpdate = now
+		s.updateWeather(time.Second)
+		s.updateMETAR()
+		s.updateMetering()
+		s.updateTMIs()
+		s.releaseHeldDepartures()
+		s.updateReleases()
+		s.updateSurface()
+		s.updateStrips()
+
 		for callsign, ac := range s.State.Aircraft {
 			passedWaypoint := ac.Update(s.State, s.lg)
 			if passedWaypoint != nil {
+				s.checkMITCompliance(ac, passedWaypoint)
+
 				if passedWaypoint.Handoff {
 					// Handoff from virtual controller to a human controller.
 					ctrl := s.ResolveController(ac.WaypointHandoffController)
@@ -1474,36 +1766,34 @@ func (s *Sim) updateState() {
 
 				if passedWaypoint.Delete {
 					s.lg.Info("deleting aircraft at waypoint", slog.Any("waypoint", passedWaypoint))
+					s.landed(ac)
 					delete(s.State.Aircraft, ac.Callsign)
 				}
 			}
 
+			// Deviate around any weather ahead.
+			if rt := ac.UpdateWeatherDeviation(s.State.WeatherCells); len(rt) > 0 {
+				if s.controllerIsSignedIn(ac.ControllingController) {
+					PostRadioEvents(ac.Callsign, rt, s)
+				} else if ac.Nav.Deviation != nil {
+					// Virtual controllers approve all deviation requests.
+					ac.ApproveDeviation()
+				}
+			}
+
 			// Possibly go around
 			// FIXME: maintain GoAroundDistance, state, in Sim, not Aircraft
 			if ac.GoAroundDistance != nil {
 				if d, err := ac.DistanceToEndOfApproach(); err == nil && d < *ac.GoAroundDistance {
 					s.lg.Info("randomly going around")
 					ac.GoAroundDistance = nil // only go around once
-					rt := ac.GoAround()
-					ac.ControllingController = s.State.DepartureController(ac, s.lg)
-					PostRadioEvents(ac.Callsign, rt, s)
-
-					// If it was handed off to tower, hand it back to us
-					if ac.TrackingController != "" && ac.TrackingController != ac.ApproachController {
-						ac.HandoffTrackController = s.State.DepartureController(ac, s.lg)
-						if ac.HandoffTrackController == "" {
-							ac.HandoffTrackController = ac.ApproachController
-						}
-						s.PostEvent(Event{
-							Type:           OfferedHandoffEvent,
-							Callsign:       ac.Callsign,
-							FromController: ac.TrackingController,
-							ToController:   ac.ApproachController,
-						})
-					}
+					s.goAround(ac)
 				}
 			}
 
+			// Go around if the runway isn't clear.
+			s.checkRunwayClear(ac)
+
 			// Possibly contact the departure controller
 			if ac.DepartureContactAltitude != 0 && ac.Nav.FlightState.Altitude >= ac.DepartureContactAltitude {
 				// Time to check in
@@ -1548,6 +1838,202 @@ func (s *Sim) updateState() {
 	s.State.ERAMComputers.Update(s)
 }
 
+// updateWeather moves and evolves the weather cells, discarding any that
+// have dissipated.
+func (s *Sim) updateWeather(dt time.Duration) {
+	for i := range s.State.WeatherCells {
+		s.State.WeatherCells[i].Update(dt, s.State.NmPerLongitude)
+	}
+	s.State.WeatherCells = util.FilterSlice(s.State.WeatherCells,
+		func(c av.WeatherCell) bool { return !c.Expired() })
+}
+
+// updateMETAR refreshes the METARs hourly and updates the ATIS to
+// match. Live and local weather are fetched asynchronously; static
+// scenario weather just has its altimeter drift a bit.
+func (s *Sim) updateMETAR() {
+	if s.fetchingMETAR || s.SimTime.Before(s.NextMETARUpdate) {
+		return
+	}
+	s.NextMETARUpdate = s.SimTime.Add(time.Hour)
+
+	if s.WeatherSource == WeatherSourceScenario {
+		for _, m := range s.State.METAR {
+			if alt, err := strconv.Atoi(strings.TrimPrefix(m.Altimeter, "A")); err == nil {
+				m.Altimeter = fmt.Sprintf("A%d", alt-1+rand.Intn(3))
+			}
+			m.Time = s.SimTime.UTC().Format("021504Z")
+		}
+		s.postATISUpdates(s.State.updateATIS())
+		return
+	}
+
+	airports := util.SortedMapKeys(s.State.METAR)
+	wp := MakeWeatherProvider(s.WeatherSource, s.WeatherDir, nil)
+	s.fetchingMETAR = true
+	go func() {
+		metar := make(map[string]*av.METAR)
+		for _, ap := range airports {
+			if m, err := wp.GetMETAR(ap); err != nil {
+				s.lg.Warnf("%s: unable to update METAR: %v", ap, err)
+			} else {
+				metar[ap] = m
+			}
+		}
+
+		s.mu.Lock(s.lg)
+		defer s.mu.Unlock(s.lg)
+		s.fetchingMETAR = false
+		maps.Copy(s.State.METAR, metar)
+		s.postATISUpdates(s.State.updateATIS())
+	}()
+}
+
+func (s *Sim) postATISUpdates(airports []string) {
+	for _, ap := range airports {
+		atis := s.State.ATIS[ap]
+		s.lg.Info("ATIS updated", slog.String("airport", ap), slog.String("code", atis.Code))
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: ap + " information " + av.ATISPhonetic(atis.Code) + " is now current",
+		})
+	}
+}
+
+// contactMessage returns the aircraft's check-in message; arrivals report
+// the ATIS they have and pick up the current one if theirs is out of date.
+func (s *Sim) contactMessage(ac *av.Aircraft) string {
+	atis := s.State.ATIS[ac.FlightPlan.ArrivalAirport]
+	msg, code := ac.ContactMessage(s.ReportingPoints, atis.Code)
+	ac.ATIS = code
+	return msg
+}
+
+func (s *Sim) AddWeatherCell(token string, cell av.WeatherCell) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if cell.Center.IsZero() || cell.Radius <= 0 || cell.Level < 1 || cell.Level > 6 || cell.Top <= 0 {
+		return ErrInvalidWeatherCell
+	} else {
+		id := s.State.addWeatherCell(cell)
+		s.lg.Info("added weather cell", slog.String("id", id), slog.Any("cell", cell))
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: ctrl.Callsign + " added weather cell " + id,
+		})
+		return nil
+	}
+}
+
+func (s *Sim) DeleteWeatherCell(token string, id string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if _, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	}
+
+	idx := slices.IndexFunc(s.State.WeatherCells, func(c av.WeatherCell) bool { return c.Id == id })
+	if idx == -1 {
+		return ErrNoMatchingWeatherCell
+	}
+	s.State.WeatherCells = slices.Delete(s.State.WeatherCells, idx, idx+1)
+	s.lg.Info("deleted weather cell", slog.String("id", id))
+	return nil
+}
+
+func (s *Sim) AddNOTAM(token string, n NOTAM) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if err := n.Check(s.State.Airports); err != nil {
+		s.lg.Warnf("%+v: invalid NOTAM: %v", n, err)
+		return ErrInvalidNOTAM
+	} else {
+		n.Id = ""
+		id := s.State.addNOTAM(n)
+		s.lg.Info("added NOTAM", slog.String("id", id), slog.Any("notam", n))
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: ctrl.Callsign + " issued NOTAM " + n.String(),
+		})
+		return nil
+	}
+}
+
+func (s *Sim) DeleteNOTAM(token string, id string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+
+	idx := slices.IndexFunc(s.State.NOTAMs, func(n NOTAM) bool { return n.Id == id })
+	if idx == -1 {
+		return ErrNoMatchingNOTAM
+	}
+	n := s.State.NOTAMs[idx]
+	s.State.NOTAMs = slices.Delete(s.State.NOTAMs, idx, idx+1)
+	s.lg.Info("cancelled NOTAM", slog.String("id", id))
+	s.eventStream.Post(Event{
+		Type:    StatusMessageEvent,
+		Message: ctrl.Callsign + " cancelled NOTAM " + n.String(),
+	})
+	return nil
+}
+
+func (s *Sim) AddTMI(token string, t TMI) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if err := t.Check(s.State.Airports); err != nil {
+		s.lg.Warnf("%+v: invalid TMI: %v", t, err)
+		return ErrInvalidTMI
+	} else {
+		t.Id = ""
+		t.Violations = 0
+		id := s.State.addTMI(t)
+		s.lg.Info("added TMI", slog.String("id", id), slog.Any("tmi", t))
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: ctrl.Callsign + " issued TMI " + t.String(),
+		})
+		return nil
+	}
+}
+
+func (s *Sim) DeleteTMI(token string, id string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+
+	idx := slices.IndexFunc(s.State.TMIs, func(t TMI) bool { return t.Id == id })
+	if idx == -1 {
+		return ErrNoMatchingTMI
+	}
+	t := s.State.TMIs[idx]
+	s.State.TMIs = slices.Delete(s.State.TMIs, idx, idx+1)
+	s.lg.Info("cancelled TMI", slog.String("id", id))
+	s.eventStream.Post(Event{
+		Type:    StatusMessageEvent,
+		Message: ctrl.Callsign + " cancelled TMI " + t.String(),
+	})
+	return nil
+}
+
 func PostRadioEvents(from string, transmissions []av.RadioTransmission, ep EventPoster) {
 	for _, rt := range transmissions {
 		ep.PostEvent(Event{
@@ -1590,6 +2076,61 @@ func (s *Sim) IdleTime() time.Duration {
 	return time.Since(s.lastUpdateTime)
 }
 
+// ReconnectToken returns the token that the controller with the given
+// controller token can later pass to Reconnect.
+func (s *Sim) ReconnectToken(token string) string {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if sc, ok := s.controllers[token]; ok {
+		return sc.reconnectToken
+	}
+	return ""
+}
+
+// Reconnect re-attaches a controller whose connection dropped to its
+// position, returning a new controller token; the previous one is no
+// longer valid. The caller should request a full world update.
+func (s *Sim) Reconnect(reconnectToken string) (ss *State, token string, oldToken string, err error) {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	for tok, ctrl := range s.controllers {
+		if ctrl.reconnectToken != reconnectToken || reconnectToken == "" {
+			continue
+		}
+
+		if token, err = makeToken(); err != nil {
+			return
+		}
+		delete(s.controllers, tok)
+		s.controllers[token] = ctrl
+
+		ctrl.lastUpdateCall = time.Now()
+		ctrl.warnedNoUpdateCalls = false
+
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: ctrl.displayName() + " has reconnected.",
+		})
+		s.lg.Infof("%s: controller reconnected", ctrl.displayName())
+
+		return s.State.GetStateForController(ctrl.Callsign), token, tok, nil
+	}
+	return nil, "", "", ErrInvalidReconnectToken
+}
+
+// ControllerRole returns the role of the controller with the given token.
+func (s *Sim) ControllerRole(token string) (Role, bool) {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if sc, ok := s.controllers[token]; ok {
+		return sc.role, true
+	}
+	return RoleObserver, false
+}
+
 func (s *Sim) controllerIsSignedIn(callsign string) bool {
 	for _, ctrl := range s.controllers {
 		if ctrl.Callsign == callsign {
@@ -1676,6 +2217,18 @@ func sampleRateMap2(rates map[string]map[string]int) (string, string, int) {
 	return result0, result1, rateSum
 }
 
+// openDepartureRates returns the airport's departure rates, excluding
+// runways that are closed by NOTAM.
+func (s *Sim) openDepartureRates(airport string) map[string]map[string]int {
+	rates := make(map[string]map[string]int)
+	for rwy, categoryRates := range s.LaunchConfig.DepartureRates[airport] {
+		if !s.State.RunwayClosed(airport, rwy) {
+			rates[rwy] = categoryRates
+		}
+	}
+	return rates
+}
+
 func randomWait(rate int, pushActive bool) time.Duration {
 	if rate == 0 {
 		return 365 * 24 * time.Hour
@@ -1689,6 +2242,28 @@ func randomWait(rate int, pushActive bool) time.Duration {
 	return time.Duration(seconds * float32(time.Second))
 }
 
+// goAround has the aircraft go around and returns it to the departure
+// controller.
+func (s *Sim) goAround(ac *av.Aircraft) {
+	rt := ac.GoAround()
+	ac.ControllingController = s.State.DepartureController(ac, s.lg)
+	PostRadioEvents(ac.Callsign, rt, s)
+
+	// If it was handed off to tower, hand it back to us
+	if ac.TrackingController != "" && ac.TrackingController != ac.ApproachController {
+		ac.HandoffTrackController = s.State.DepartureController(ac, s.lg)
+		if ac.HandoffTrackController == "" {
+			ac.HandoffTrackController = ac.ApproachController
+		}
+		s.PostEvent(Event{
+			Type:           OfferedHandoffEvent,
+			Callsign:       ac.Callsign,
+			FromController: ac.TrackingController,
+			ToController:   ac.ApproachController,
+		})
+	}
+}
+
 func (s *Sim) spawnAircraft() {
 	now := s.SimTime
 
@@ -1734,26 +2309,42 @@ func (s *Sim) spawnAircraft() {
 			continue
 		}
 
+		if s.LaunchConfig.RequireReleases && s.departuresAwaitingRelease(airport) >= maxDeparturesAwaitingRelease {
+			continue
+		}
+		if s.heldDepartures(airport) >= maxHeldDepartures {
+			continue
+		}
+
 		// Figure out which category to launch
-		runway, category, rateSum := sampleRateMap2(s.LaunchConfig.DepartureRates[airport])
+		runway, category, rateSum := sampleRateMap2(s.openDepartureRates(airport))
 		if rateSum == 0 {
 			s.lg.Errorf("%s: couldn't find an active runway for spawning departure?", airport)
 			continue
 		}
-
 		s.lg.Infof("%s/%s/%s: previous departure", airport, runway, category)
-		ac, dep, err := s.createDepartureNoLock(airport, runway, category)
+		ac, dep, err := s.createDepartureNoLock(airport, runway, category, true)
 		if err != nil {
 			s.lg.Infof("CreateDeparture error: %v", err)
 		} else {
 			s.lastDeparture[airport][runway][category] = dep
-			s.lg.Infof("%s/%s/%s: launch departure", airport, runway, category)
-			s.launchAircraftNoLock(*ac)
+			s.lg.Infof("%s/%s/%s: new departure", airport, runway, category)
+			s.holdOrTaxiDeparture(ac, runway)
 			s.NextDepartureSpawn[airport] = now.Add(randomWait(rateSum, false))
 		}
 	}
 }
 
+// taxiDeparture starts a departure that the TMIs allow to go taxiing out
+// to the runway.
+func (s *Sim) taxiDeparture(ac *av.Aircraft, runway string) {
+	s.State.releasedEDCT(ac.Callsign)
+	s.taxiOut(ac, runway)
+	if s.LaunchConfig.RequireReleases {
+		s.holdForRelease(ac, runway)
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // Commands from the user
 
@@ -1852,6 +2443,11 @@ func (s *Sim) launchAircraftNoLock(ac av.Aircraft) {
 		return
 	}
 
+	// Arrivals have picked up the ATIS before they check in.
+	if s.State.IsArrival(&ac) {
+		ac.ATIS = s.State.ATIS[ac.FlightPlan.ArrivalAirport].Code
+	}
+
 	s.State.Aircraft[ac.Callsign] = &ac
 
 	ac.Nav.Check(s.lg)
@@ -1881,7 +2477,7 @@ func (s *Sim) dispatchCommand(token string, callsign string,
 		return av.ErrNoAircraftForCallsign
 	} else {
 		// TODO(mtrokel): this needs to be updated for the STARS tracking stuff
-		if sc.Callsign == "Observer" {
+		if sc.IsObserver() {
 			return av.ErrOtherControllerHasTrack
 		}
 
@@ -2130,7 +2726,7 @@ func (s *Sim) InitiateTrack(token, callsign string, fp *STARSFlightPlan) error {
 				ac.ControllingController = ctrl.Callsign
 			}
 
-			if err := s.State.STARSComputer().InitiateTrack(callsign, ctrl.Callsign, fp, haveControl); err != nil {
+			if err := s.State.STARSComputerForController(ctrl).InitiateTrack(callsign, ctrl.Callsign, fp, haveControl); err != nil {
 				//s.lg.Errorf("InitiateTrack: %v", err)
 			}
 			if err := s.State.ERAMComputer().InitiateTrack(callsign, ctrl.Callsign, fp); err != nil {
@@ -2156,7 +2752,7 @@ func (s *Sim) DropTrack(token, callsign string) error {
 			ac.TrackingController = ""
 			ac.ControllingController = ""
 
-			if err := s.State.STARSComputer().DropTrack(ac); err != nil {
+			if err := s.State.STARSComputerForController(ctrl).DropTrack(ac); err != nil {
 				//s.lg.Errorf("STARS DropTrack: %v", err)
 			}
 			if err := s.State.ERAMComputer().DropTrack(ac); err != nil {
@@ -2195,7 +2791,7 @@ func (s *Sim) HandoffTrack(token, callsign, controller string) error {
 			} else {
 				// Disallow handoff if there's a beacon code mismatch.
 				squawkingSPC, _ := av.SquawkIsSPC(ac.Squawk)
-				if trk := s.State.STARSComputer().TrackInformation[ac.Callsign]; trk != nil {
+				if trk := s.State.STARSComputerForController(ctrl).TrackInformation[ac.Callsign]; trk != nil {
 					if ac.Squawk != trk.FlightPlan.AssignedSquawk && !squawkingSPC {
 						return ErrBeaconMismatch
 					}
@@ -2217,7 +2813,7 @@ func (s *Sim) HandoffTrack(token, callsign, controller string) error {
 
 			ac.HandoffTrackController = octrl.Callsign
 
-			if err := s.State.STARSComputer().HandoffTrack(ac.Callsign, ctrl, octrl, s.SimTime); err != nil {
+			if err := s.State.STARSComputerForController(ctrl).HandoffTrack(ac.Callsign, ctrl, octrl, s.SimTime); err != nil {
 				//s.lg.Errorf("HandoffTrack: %v", err)
 			}
 
@@ -2265,7 +2861,7 @@ func (s *Sim) HandoffControl(token, callsign string) error {
 				})
 				radioTransmissions = append(radioTransmissions, av.RadioTransmission{
 					Controller: ac.TrackingController,
-					Message:    ac.ContactMessage(s.ReportingPoints),
+					Message:    s.contactMessage(ac),
 					Type:       av.RadioTransmissionContact,
 				})
 			} else {
@@ -2285,7 +2881,8 @@ func (s *Sim) HandoffControl(token, callsign string) error {
 
 			ac.ControllingController = ac.TrackingController
 
-			if err := s.State.STARSComputer().HandoffControl(callsign, ac.TrackingController); err != nil {
+			stars := s.State.STARSComputerForController(s.State.Controllers[ac.TrackingController])
+			if err := stars.HandoffControl(callsign, ac.TrackingController); err != nil {
 				//s.lg.Errorf("HandoffControl: %v", err)
 			}
 
@@ -2331,8 +2928,8 @@ func (s *Sim) AcceptHandoff(token, callsign string) error {
 			ac.HandoffTrackController = ""
 			ac.TrackingController = ctrl.Callsign
 
-			if err := s.State.STARSComputer().AcceptHandoff(ac, ctrl, s.State.Controllers,
-				s.State.STARSFacilityAdaptation, s.SimTime); err != nil {
+			if err := s.State.STARSComputerForController(ctrl).AcceptHandoff(ac, ctrl, s.State.Controllers,
+				s.State.facilityAdaptation(ctrl), s.SimTime); err != nil {
 				//s.lg.Errorf("AcceptHandoff: %v", err)
 			}
 
@@ -2341,7 +2938,7 @@ func (s *Sim) AcceptHandoff(token, callsign string) error {
 				ac.ControllingController = ctrl.Callsign
 				return []av.RadioTransmission{av.RadioTransmission{
 					Controller: ctrl.Callsign,
-					Message:    ac.ContactMessage(s.ReportingPoints),
+					Message:    s.contactMessage(ac),
 					Type:       av.RadioTransmissionContact,
 				}}
 			} else {
@@ -2360,7 +2957,7 @@ func (s *Sim) CancelHandoff(token, callsign string) error {
 			ac.HandoffTrackController = ""
 			ac.RedirectedHandoff = av.RedirectedHandoff{}
 
-			err := s.State.STARSComputer().CancelHandoff(ac, ctrl, s.State.Controllers, s.SimTime)
+			err := s.State.STARSComputerForController(ctrl).CancelHandoff(ac, ctrl, s.State.Controllers, s.SimTime)
 			if err != nil {
 				//s.lg.Errorf("CancelHandoff: %v", err)
 			}
@@ -2398,7 +2995,7 @@ func (s *Sim) RedirectHandoff(token, callsign, controller string) error {
 			ac.RedirectedHandoff.AddRedirector(ctrl)
 			ac.RedirectedHandoff.RedirectedTo = octrl.Callsign
 
-			s.State.STARSComputer().RedirectHandoff(ac, ctrl, octrl)
+			s.State.STARSComputerForController(ctrl).RedirectHandoff(ac, ctrl, octrl)
 
 			return nil
 		})
@@ -2433,7 +3030,7 @@ func (s *Sim) AcceptRedirectedHandoff(token, callsign string) error {
 				}
 			}
 
-			err := s.State.STARSComputer().AcceptRedirectedHandoff(ac, ctrl)
+			err := s.State.STARSComputerForController(ctrl).AcceptRedirectedHandoff(ac, ctrl)
 			if err != nil {
 				//s.lg.Errorf("AcceptRedirectedHandoff: %v", err)
 			}
@@ -2470,8 +3067,9 @@ func (s *Sim) PointOut(token, callsign, controller string) error {
 				return av.ErrOtherControllerHasTrack
 			} else if octrl := s.State.Controllers[controller]; octrl == nil {
 				return av.ErrNoController
-			} else if octrl.Facility != ctrl.Facility {
-				// Can't point out to another STARS facility.
+			} else if !s.State.sameFacility(octrl, ctrl) && !s.controllerIsSignedIn(octrl.Callsign) {
+				// Interfacility point outs can only be made to adjacent
+				// facilities' controllers that are in the sim.
 				return av.ErrInvalidController
 			} else if octrl.Callsign == ctrl.Callsign {
 				// Can't point out to ourself
@@ -2494,7 +3092,7 @@ func (s *Sim) pointOut(callsign string, from *av.Controller, to *av.Controller)
 		Callsign:       callsign,
 	})
 
-	if err := s.State.STARSComputer().PointOut(callsign, to.Callsign); err != nil {
+	if err := s.State.STARSComputerForController(from).PointOut(callsign, to.Callsign); err != nil {
 		//s.lg.Errorf("PointOut: %v", err)
 	}
 
@@ -2537,7 +3135,8 @@ func (s *Sim) AcknowledgePointOut(token, callsign string) error {
 
 			delete(s.PointOuts[callsign], ctrl.Callsign)
 
-			err := s.State.STARSComputer().AcknowledgePointOut(ac.Callsign, ctrl.Callsign)
+			stars := s.State.STARSComputerForController(s.State.Controllers[ac.TrackingController])
+			err := stars.AcknowledgePointOut(ac.Callsign, ctrl.Callsign)
 			if err != nil {
 				//s.lg.Errorf("AcknowledgePointOut: %v", err)
 			}
@@ -2568,7 +3167,8 @@ func (s *Sim) RejectPointOut(token, callsign string) error {
 
 			delete(s.PointOuts[callsign], ctrl.Callsign)
 
-			err := s.State.STARSComputer().RejectPointOut(ac.Callsign, ctrl.Callsign)
+			stars := s.State.STARSComputerForController(s.State.Controllers[ac.TrackingController])
+			err := stars.RejectPointOut(ac.Callsign, ctrl.Callsign)
 			if err != nil {
 				//s.lg.Errorf("RejectPointOut: %v", err)
 			}
@@ -2628,15 +3228,25 @@ func (s *Sim) AssignHeading(hdg *HeadingArgs) error {
 			if hdg.Present {
 				return ac.FlyPresentHeading()
 			} else if hdg.LeftDegrees != 0 {
-				return ac.TurnLeft(hdg.LeftDegrees)
+				return ac.TurnLeft(hdg.LeftDegrees, s.State.WeatherCells)
 			} else if hdg.RightDegrees != 0 {
-				return ac.TurnRight(hdg.RightDegrees)
+				return ac.TurnRight(hdg.RightDegrees, s.State.WeatherCells)
 			} else {
-				return ac.AssignHeading(hdg.Heading, hdg.Turn)
+				return ac.AssignHeading(hdg.Heading, hdg.Turn, s.State.WeatherCells)
 			}
 		})
 }
 
+func (s *Sim) ApproveDeviation(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchControllingCommand(token, callsign,
+		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
+			return ac.ApproveDeviation()
+		})
+}
+
 func (s *Sim) AssignSpeed(token, callsign string, speed int, afterAltitude bool) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
@@ -2723,7 +3333,10 @@ func (s *Sim) DirectFix(token, callsign, fix string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.DirectFix(fix)
+			if s.State.NavaidUnusable(fix) {
+				return ac.Unable(av.FixReadback(fix) + " is unusable")
+			}
+			return ac.DirectFix(fix, s.State.ActiveRestrictedAirspace(s.SimTime))
 		})
 }
 
@@ -2763,6 +3376,9 @@ func (s *Sim) AtFixCleared(token, callsign, fix, approach string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
+			if reason := s.State.ApproachUnavailable(ac.FlightPlan.ArrivalAirport, approach); reason != "" {
+				return ac.Unable(reason)
+			}
 			return ac.AtFixCleared(fix, approach)
 		})
 }
@@ -2781,6 +3397,9 @@ func (s *Sim) ExpectApproach(token, callsign, approach string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
+			if reason := s.State.ApproachUnavailable(ac.FlightPlan.ArrivalAirport, approach); reason != "" {
+				return ac.Unable(reason)
+			}
 			return ac.ExpectApproach(approach, ap, s.lg)
 		})
 }
@@ -2791,11 +3410,25 @@ func (s *Sim) ClearedApproach(token, callsign, approach string, straightIn bool)
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
+			if reason := s.State.ApproachUnavailable(ac.FlightPlan.ArrivalAirport, approach); reason != "" {
+				return ac.Unable(reason)
+			}
+
+			var rt []av.RadioTransmission
 			if straightIn {
-				return ac.ClearedStraightInApproach(approach)
+				rt = ac.ClearedStraightInApproach(approach)
 			} else {
-				return ac.ClearedApproach(approach, s.lg)
+				rt = ac.ClearedApproach(approach, s.lg)
 			}
+
+			if appr := ac.Nav.Approach.Assigned; ac.Nav.Approach.Cleared && appr != nil &&
+				appr.Type == av.ILSApproach && s.State.GlideslopeOut(ac.FlightPlan.ArrivalAirport, appr.Runway) {
+				ac.FlyLocalizerOnly()
+				for i := range rt {
+					rt[i].Message += ", localizer only"
+				}
+			}
+			return rt
 		})
 }
 
@@ -2906,6 +3539,17 @@ func (s *Sim) DeleteAllAircraft(token string) error {
 			return err
 		}
 	}
+
+	// Also clear out departures waiting on the ground for a release.
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+	clear(s.PendingDepartures)
+	s.HeldDepartures = nil
+	clear(s.RunwayOccupancy)
+	s.State.DepartureReleases = nil
+	s.State.SurfaceTargets = nil
+	s.State.FlightStrips = nil
+
 	return nil
 }
 
@@ -2966,7 +3610,46 @@ var badCallsigns map[string]interface{} = map[string]interface{}{
 	"ICE001":  nil,
 }
 
-func (ss *State) sampleAircraft(icao, fleet string, lg *log.Logger) (*av.Aircraft, string) {
+// newECID returns an ERAM computer identification number for a new
+// flight plan that isn't being used by any other aircraft in the sim.
+func (s *Sim) newECID() string {
+	inUse := make(map[string]bool)
+	for _, m := range []map[string]*av.Aircraft{s.State.Aircraft, s.PendingDepartures} {
+		for _, ac := range m {
+			if ac.FlightPlan != nil {
+				inUse[ac.FlightPlan.ECID] = true
+			}
+		}
+	}
+	for _, h := range s.HeldDepartures {
+		inUse[h.Aircraft.FlightPlan.ECID] = true
+	}
+
+	for i := 0; i < 1000; i++ {
+		ecid := fmt.Sprintf("%03d", s.nextECID)
+		s.nextECID = (s.nextECID + 1) % 1000
+		if !inUse[ecid] {
+			return ecid
+		}
+	}
+	return "XXX"
+}
+
+// callsignInUse reports whether the callsign belongs to an aircraft that
+// is flying, on the ground at an airport, or waiting to depart.
+func (s *Sim) callsignInUse(callsign string) bool {
+	if _, ok := s.State.Aircraft[callsign]; ok {
+		return true
+	} else if _, ok := s.PendingDepartures[callsign]; ok {
+		return true
+	} else if _, ok := s.State.SurfaceTarget(callsign); ok {
+		return true
+	}
+	return slices.ContainsFunc(s.HeldDepartures, func(h HeldDeparture) bool { return h.Aircraft.Callsign == callsign })
+}
+
+func (s *Sim) sampleAircraft(icao, fleet string) (*av.Aircraft, string) {
+	lg := s.lg
 	al, ok := av.DB.Airlines[icao]
 	if !ok {
 		// TODO: this should be caught at load validation time...
@@ -3026,7 +3709,7 @@ func (ss *State) sampleAircraft(icao, fleet string, lg *log.Logger) (*av.Aircraf
 				id += string(rune('A' + rand.Intn(26)))
 			}
 		}
-		if _, ok := ss.Aircraft[callsign+id]; ok {
+		if s.callsignInUse(callsign + id) {
 			continue // it already exits
 		} else if _, ok := badCallsigns[callsign+id]; ok {
 			continue // nope
@@ -3076,13 +3759,14 @@ func (s *Sim) createArrivalNoLock(group string, arrivalAirport string) (*av.Airc
 	arr := arrivals[idx]
 
 	airline := rand.SampleSlice(arr.Airlines[arrivalAirport])
-	ac, acType := s.State.sampleAircraft(airline.ICAO, airline.Fleet, s.lg)
+	ac, acType := s.sampleAircraft(airline.ICAO, airline.Fleet)
 	if ac == nil {
 		return nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
 
 	// ac.Squawk = artcc.CreateSquawk()
 	ac.FlightPlan = ac.NewFlightPlan(av.IFR, acType, airline.Airport, arrivalAirport)
+	ac.FlightPlan.ECID = s.newECID()
 
 	// Figure out which controller will (for starters) get the arrival
 	// handoff. For single-user, it's easy.  Otherwise, figure out which
@@ -3109,6 +3793,16 @@ func (s *Sim) createArrivalNoLock(group string, arrivalAirport string) (*av.Airc
 		return nil, err
 	}
 
+	// Don't have arrivals expect an approach to a closed runway or one
+	// that is not authorized; switch them to one that is available, if
+	// possible.
+	if id := ac.Nav.Approach.AssignedId; id != "" && s.State.ApproachUnavailable(arrivalAirport, id) != "" {
+		ac.Nav.Approach = av.NavApproach{}
+		if id, ok := s.State.availableApproach(arrivalAirport); ok {
+			ac.ExpectApproach(id, s.State.Airports[arrivalAirport], s.lg)
+		}
+	}
+
 	facility, ok := s.State.FacilityFromController(ac.TrackingController)
 	if !ok {
 		return nil, ErrUnknownControllerFacility
@@ -3121,10 +3815,15 @@ func (s *Sim) createArrivalNoLock(group string, arrivalAirport string) (*av.Airc
 func (s *Sim) CreateDeparture(departureAirport, runway, category string) (*av.Aircraft, *av.Departure, error) {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
-	return s.createDepartureNoLock(departureAirport, runway, category)
+	return s.createDepartureNoLock(departureAirport, runway, category, false)
 }
 
-func (s *Sim) createDepartureNoLock(departureAirport, runway, category string) (*av.Aircraft, *av.Departure, error) {
+// createDepartureNoLock creates a departure from the given airport and
+// runway. If applyTMIs is true, a flight with an EDCT whose time has come
+// is created in preference to a random departure; the caller is
+// responsible for holding departures that other TMIs apply to (see
+// holdOrTaxiDeparture).
+func (s *Sim) createDepartureNoLock(departureAirport, runway, category string, applyTMIs bool) (*av.Aircraft, *av.Departure, error) {
 	challenge := s.LaunchConfig.DepartureChallenge
 	lastDeparture := s.lastDeparture[departureAirport][runway][category]
 
@@ -3140,13 +3839,27 @@ func (s *Sim) createDepartureNoLock(departureAirport, runway, category string) (
 	if idx == -1 {
 		return nil, nil, av.ErrUnknownRunway
 	}
+	if s.State.RunwayClosed(departureAirport, runway) {
+		return nil, nil, ErrRunwayClosed
+	}
 	rwy := &s.State.DepartureRunways[idx]
 
 	var dep *av.Departure
+	var airline av.DepartureAirline
+	var edct TMI
+	if applyTMIs {
+		if t, ok := s.pendingEDCT(departureAirport); ok {
+			// A flight with an EDCT is ready to go; it's next.
+			if d, al, ok := edctDeparture(ap, rwy, t.Callsign); ok {
+				dep, airline, edct = d, al, t
+			}
+		}
+	}
+
 	if s.sameDepartureCap == 0 {
 		s.sameDepartureCap = rand.Intn(3) + 1 // Set the initial max same departure cap (1-3)
 	}
-	if rand.Float32() < challenge && lastDeparture != nil && s.sameGateDepartures < s.sameDepartureCap {
+	if dep == nil && rand.Float32() < challenge && lastDeparture != nil && s.sameGateDepartures < s.sameDepartureCap {
 		// 50/50 split between the exact same departure and a departure to
 		// the same gate as the last departure.
 		pred := util.Select(rand.Float32() < .5,
@@ -3181,7 +3894,7 @@ func (s *Sim) createDepartureNoLock(departureAirport, runway, category string) (
 		dep = &ap.Departures[idx]
 	}
 
-	if lastDeparture != nil && (dep.Exit == lastDeparture.Exit && s.sameGateDepartures >= s.sameDepartureCap) {
+	if edct.Callsign == "" && lastDeparture != nil && (dep.Exit == lastDeparture.Exit && s.sameGateDepartures >= s.sameDepartureCap) {
 		return nil, nil, fmt.Errorf("couldn't make a departure")
 	}
 
@@ -3195,13 +3908,19 @@ func (s *Sim) createDepartureNoLock(departureAirport, runway, category string) (
 		s.sameGateDepartures = 0
 	}
 
-	airline := rand.SampleSlice(dep.Airlines)
-	ac, acType := s.State.sampleAircraft(airline.ICAO, airline.Fleet, s.lg)
+	if edct.Callsign == "" {
+		airline = rand.SampleSlice(dep.Airlines)
+	}
+	ac, acType := s.sampleAircraft(airline.ICAO, airline.Fleet)
 	if ac == nil {
 		return nil, nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
+	if edct.Callsign != "" {
+		ac.Callsign = edct.Callsign
+	}
 
 	ac.FlightPlan = ac.NewFlightPlan(av.IFR, acType, departureAirport, dep.Destination)
+	ac.FlightPlan.ECID = s.newECID()
 	exitRoute := rwy.ExitRoutes[dep.Exit]
 	if err := ac.InitializeDeparture(ap, departureAirport, dep, runway, exitRoute,
 		s.State.NmPerLongitude, s.State.MagneticVariation, s.State.Scratchpads,
@@ -3231,13 +3950,14 @@ func (s *Sim) createOverflightNoLock(group string) (*av.Aircraft, error) {
 	of := rand.SampleSlice(overflights)
 
 	airline := rand.SampleSlice(of.Airlines)
-	ac, acType := s.State.sampleAircraft(airline.ICAO, airline.Fleet, s.lg)
+	ac, acType := s.sampleAircraft(airline.ICAO, airline.Fleet)
 	if ac == nil {
 		return nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
 
 	ac.FlightPlan = ac.NewFlightPlan(av.IFR, acType, airline.DepartureAirport,
 		airline.ArrivalAirport)
+	ac.FlightPlan.ECID = s.newECID()
 
 	// Figure out which controller will (for starters) get the handoff. For
 	// single-user, it's easy.  Otherwise, figure out which control
@@ -0,0 +1,119 @@
+// pkg/sim/sim_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+)
+
+// makeTestSim returns a sim named "test" at the given time without any
+// aircraft. JFK_APP is the primary controller and is available to sign
+// on; controllers gives the tokens and callsigns of controllers who are
+// already signed on. Tests fill in whatever else they need.
+func makeTestSim(now time.Time, controllers map[string]string) *Sim {
+	s := &Sim{
+		Name: "test",
+		State: &State{
+			PrimaryController: "JFK_APP",
+			Aircraft:          make(map[string]*av.Aircraft),
+			Controllers:       make(map[string]*av.Controller),
+			NmPerLongitude:    45,
+			MagneticVariation: 13,
+		},
+		SignOnPositions:   map[string]*av.Controller{"JFK_APP": {Callsign: "JFK_APP"}},
+		controllers:       make(map[string]*ServerController),
+		PendingDepartures: make(map[string]*av.Aircraft),
+		SimTime:           now,
+		eventStream:       NewEventStream(nil),
+	}
+	for token, callsign := range controllers {
+		s.controllers[token] = &ServerController{Callsign: callsign, role: RoleController}
+		s.State.Controllers[callsign] = &av.Controller{Callsign: callsign}
+	}
+	return s
+}
+
+// addTestAirport adds a made-up airport to the FAA database for the
+// duration of the test so that tests don't depend on the database's
+// contents. Each test should use its own airport.
+func addTestAirport(t *testing.T, ap av.FAAAirport) {
+	prev, ok := av.DB.Airports[ap.Id]
+	av.DB.Airports[ap.Id] = ap
+	t.Cleanup(func() {
+		if ok {
+			av.DB.Airports[ap.Id] = prev
+		} else {
+			delete(av.DB.Airports, ap.Id)
+		}
+	})
+}
+
+// makeApproachAircraft returns an aircraft cleared for an approach to
+// KJFK runway 31L that is the given distance from the end of it.
+func makeApproachAircraft(callsign string, nm float32) *av.Aircraft {
+	thresh := math.Point2LL{-73.76, 40.64}
+	ac := &av.Aircraft{
+		Callsign:   callsign,
+		FlightPlan: &av.FlightPlan{ArrivalAirport: "KJFK"},
+	}
+	ac.Nav.Approach.Assigned = &av.Approach{Runway: "31L"}
+	ac.Nav.Approach.Cleared = true
+	ac.Nav.Waypoints = []av.Waypoint{{Fix: "_31L_THRESHOLD", Location: thresh}, {Fix: "KJFK", Location: thresh}}
+	// Due south of the threshold.
+	ac.Nav.FlightState.Position = math.Point2LL{thresh[0], thresh[1] - nm/60}
+	return ac
+}
+
+// makeTestDeparture returns a departure from the given airport with a
+// flight plan whose route goes over the given fixes.
+func makeTestDeparture(callsign, airport string, p math.Point2LL, fixes ...string) *av.Aircraft {
+	ac := &av.Aircraft{
+		Callsign: callsign,
+		FlightPlan: &av.FlightPlan{Callsign: callsign, AircraftType: "B738", DepartureAirport: airport,
+			ArrivalAirport: "KBOS"},
+	}
+	ac.Nav.FlightState.Position = p
+	for _, fix := range fixes {
+		ac.Nav.Waypoints = append(ac.Nav.Waypoints, av.Waypoint{Fix: fix})
+	}
+	return ac
+}
+
+func TestSampleAircraftCallsign(t *testing.T) {
+	// A made-up airline with only nine possible callsigns.
+	prev, ok := av.DB.Airlines["ZZT"]
+	al := av.Airline{ICAO: "ZZT", Fleets: map[string][]av.FleetAircraft{"default": {{ICAO: "B738", Count: 1}}}}
+	al.Callsign.CallsignFormats = []string{"#"}
+	av.DB.Airlines["ZZT"] = al
+	t.Cleanup(func() {
+		if ok {
+			av.DB.Airlines["ZZT"] = prev
+		} else {
+			delete(av.DB.Airlines, "ZZT")
+		}
+	})
+
+	s := makeTestSim(time.Time{}, nil)
+	for _, cs := range []string{"ZZT1", "ZZT2", "ZZT3"} {
+		s.State.Aircraft[cs] = &av.Aircraft{Callsign: cs}
+	}
+	for _, cs := range []string{"ZZT4", "ZZT5"} {
+		s.PendingDepartures[cs] = &av.Aircraft{Callsign: cs}
+	}
+	s.State.SurfaceTargets = []SurfaceTarget{{Callsign: "ZZT6"}, {Callsign: "ZZT7"}}
+	s.HeldDepartures = []HeldDeparture{{Aircraft: &av.Aircraft{Callsign: "ZZT8"}}}
+
+	for range 20 {
+		if ac, _ := s.sampleAircraft("ZZT", ""); ac == nil {
+			t.Fatal("no aircraft sampled")
+		} else if ac.Callsign != "ZZT9" {
+			t.Errorf("got callsign %s, which is already in use", ac.Callsign)
+		}
+	}
+}
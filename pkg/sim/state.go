@@ -5,9 +5,10 @@
 package sim
 
 import (
-	"fmt"
 	"log/slog"
 	gomath "math"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,7 +19,6 @@ import (
 	"github.com/mmp/vice/pkg/util"
 
 	"github.com/brunoga/deep"
-	getweather "github.com/checkandmate1/AirportWeatherData"
 )
 
 const serverCallsign = "__SERVER__"
@@ -26,6 +26,7 @@ const serverCallsign = "__SERVER__"
 type State struct {
 	Aircraft    map[string]*av.Aircraft
 	METAR       map[string]*av.METAR
+	ATIS        map[string]av.ATIS
 	Controllers map[string]*av.Controller
 
 	DepartureAirports map[string]*av.Airport
@@ -51,10 +52,19 @@ type State struct {
 	Center                   math.Point2LL
 	Range                    float32
 	Wind                     av.Wind
+	WeatherCells             []av.WeatherCell
+	NOTAMs                   []NOTAM
+	TMIs                     []TMI
+	DepartureReleases        []DepartureRelease
+	SurfaceTargets           []SurfaceTarget
+	FlightStrips             []BayStrip
+	Metering                 map[string]MeteredArrival
+	NavSummaries             map[string]string // only sent while paused
 	Callsign                 string
 	ScenarioDefaultVideoMaps []string
 	ApproachAirspace         []ControllerAirspaceVolume
 	DepartureAirspace        []ControllerAirspaceVolume
+	SpecialUseAirspace       []av.SpecialUseAirspace
 	DepartureRunways         []ScenarioGroupDepartureRunway
 	ArrivalRunways           []ScenarioGroupArrivalRunway
 	Scratchpads              map[string]string
@@ -63,6 +73,9 @@ type State struct {
 	TotalArrivals            int
 	TotalOverflights         int
 	STARSFacilityAdaptation  STARSFacilityAdaptation
+	// Facilities from other scenario groups whose controllers share the
+	// sim, keyed by TRACON.
+	AdjacentFacilities map[string]*AdjacentFacility
 
 	ControllerVideoMaps        []av.VideoMap
 	ControllerDefaultVideoMaps []string
@@ -70,12 +83,13 @@ type State struct {
 	videoMaps map[string]*av.VideoMap
 }
 
-func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *ScenarioGroup, sc *Scenario,
+func newState(selectedSplit string, wp WeatherProvider, isLocal bool, s *Sim, sg *ScenarioGroup, sc *Scenario,
 	ml *av.VideoMapLibrary, lg *log.Logger) *State {
 	ss := &State{
 		Callsign:      serverCallsign,
 		Aircraft:      make(map[string]*av.Aircraft),
 		METAR:         make(map[string]*av.METAR),
+		ATIS:          make(map[string]av.ATIS),
 		Controllers:   make(map[string]*av.Controller),
 		ERAMComputers: MakeERAMComputers(sg.STARSFacilityAdaptation.BeaconBank, lg),
 	}
@@ -97,6 +111,15 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 	ss.MagneticVariation = sg.MagneticVariation
 	ss.NmPerLongitude = sg.NmPerLongitude
 	ss.Wind = sc.Wind
+	for _, cell := range sc.WeatherCells {
+		ss.addWeatherCell(cell)
+	}
+	for _, n := range sc.NOTAMs {
+		ss.addNOTAM(n)
+	}
+	for _, t := range sc.TMIs {
+		ss.addTMI(t)
+	}
 	ss.Airports = sg.Airports
 	ss.Fixes = sg.Fixes
 	ss.PrimaryAirport = sg.PrimaryAirport
@@ -109,6 +132,7 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 	ss.InboundFlows = sg.InboundFlows
 	ss.ApproachAirspace = sc.ApproachAirspace
 	ss.DepartureAirspace = sc.DepartureAirspace
+	ss.SpecialUseAirspace = sg.Airspace.SpecialUse
 	ss.DepartureRunways = sc.DepartureRunways
 	ss.ArrivalRunways = sc.ArrivalRunways
 	ss.LaunchConfig = s.LaunchConfig
@@ -118,7 +142,7 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 	ss.SimDescription = s.Scenario
 	ss.SimTime = s.SimTime
 	ss.STARSFacilityAdaptation = sg.STARSFacilityAdaptation
-	ss.videoMaps = ss.loadVideoMaps(ml, lg)
+	ss.videoMaps = loadVideoMaps(ss.STARSFacilityAdaptation, ml, lg)
 
 	for _, callsign := range sc.VirtualControllers {
 		// Skip controllers that are in MultiControllers
@@ -135,70 +159,6 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 		}
 	}
 
-	// Make some fake METARs; slightly different for all airports.
-	var alt int
-
-	fakeMETAR := func(icao string) {
-		alt = 2980 + rand.Intn(40)
-		spd := ss.Wind.Speed - 3 + rand.Int31n(6)
-		var wind string
-		if spd < 0 {
-			wind = "00000KT"
-		} else if spd < 4 {
-			wind = fmt.Sprintf("VRB%02dKT", spd)
-		} else {
-			dir := 10 * ((ss.Wind.Direction + 5) / 10)
-			dir += [3]int32{-10, 0, 10}[rand.Intn(3)]
-			wind = fmt.Sprintf("%03d%02d", dir, spd)
-			gst := ss.Wind.Gust - 3 + rand.Int31n(6)
-			if gst-ss.Wind.Speed > 5 {
-				wind += fmt.Sprintf("G%02d", gst)
-			}
-			wind += "KT"
-		}
-
-		// Just provide the stuff that the STARS display shows
-		ss.METAR[icao] = &av.METAR{
-			AirportICAO: icao,
-			Wind:        wind,
-			Altimeter:   fmt.Sprintf("A%d", alt-2+rand.Intn(4)),
-		}
-	}
-
-	realMETAR := func(icao string) {
-		weather, errors := getweather.GetWeather(icao)
-		if len(errors) != 0 {
-			lg.Errorf("%s: error getting weather: %+v", icao, errors)
-		}
-
-		dir := -1 // VRB by default
-		if d, ok := weather.Wdir.(int); ok {
-			dir = d
-		} else if d, ok := weather.Wdir.(float64); ok {
-			dir = int(d)
-		}
-
-		var wind string
-		if spd := weather.Wspd; spd <= 0 {
-			wind = "00000KT"
-		} else if dir == -1 {
-			wind = fmt.Sprintf("VRB%vKT", spd)
-		} else {
-			wind = fmt.Sprintf("%03d%02d", dir, spd)
-			if weather.Wgst > 5 {
-				wind += fmt.Sprintf("G%02d", weather.Wgst)
-			}
-			wind += "KT"
-		}
-
-		// Just provide the stuff that the STARS display shows
-		ss.METAR[icao] = &av.METAR{
-			AirportICAO: icao,
-			Wind:        wind,
-			Altimeter:   "A" + getAltimiter(weather.RawMETAR),
-		}
-	}
-
 	ss.DepartureAirports = make(map[string]*av.Airport)
 	for name := range s.LaunchConfig.DepartureRates {
 		ss.DepartureAirports[name] = ss.Airports[name]
@@ -211,26 +171,45 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 			}
 		}
 	}
-	if liveWeather {
-		for ap := range ss.DepartureAirports {
-			realMETAR(ap)
+	// If the provider doesn't have weather for an airport, fall back to
+	// the scenario's.
+	static := MakeWeatherProvider(WeatherSourceScenario, "", sc)
+	getMETAR := func(icao string) {
+		if _, ok := ss.METAR[icao]; ok {
+			return
 		}
-		for ap := range ss.ArrivalAirports {
-			realMETAR(ap)
+		m, err := wp.GetMETAR(icao)
+		if err != nil {
+			lg.Errorf("%s: error getting weather: %v", icao, err)
+			m, err = static.GetMETAR(icao)
 		}
-	} else {
-		for ap := range ss.DepartureAirports {
-			fakeMETAR(ap)
+		if err == nil {
+			ss.METAR[icao] = m
 		}
-		for ap := range ss.ArrivalAirports {
-			fakeMETAR(ap)
+	}
+	for ap := range ss.DepartureAirports {
+		getMETAR(ap)
+	}
+	for ap := range ss.ArrivalAirports {
+		getMETAR(ap)
+	}
+
+	if _, ok := wp.(*StaticWeatherProvider); !ok {
+		// Use the primary airport's actual wind for the sim.
+		getMETAR(ss.PrimaryAirport)
+		if m, ok := ss.METAR[ss.PrimaryAirport]; ok {
+			if w, err := m.GetWind(); err == nil {
+				ss.Wind = w
+			}
 		}
 	}
 
+	ss.updateATIS()
+
 	return ss
 }
 
-func (s *State) loadVideoMaps(ml *av.VideoMapLibrary, lg *log.Logger) map[string]*av.VideoMap {
+func loadVideoMaps(fa STARSFacilityAdaptation, ml *av.VideoMapLibrary, lg *log.Logger) map[string]*av.VideoMap {
 	maps := make(map[string]*av.VideoMap)
 
 	add := func(name string) {
@@ -240,7 +219,7 @@ func (s *State) loadVideoMaps(ml *av.VideoMapLibrary, lg *log.Logger) map[string
 			maps[name] = &av.VideoMap{}
 		} else {
 			var err error
-			maps[name], err = ml.GetMap(s.STARSFacilityAdaptation.VideoMapFile, name)
+			maps[name], err = ml.GetMap(fa.VideoMapFile, name)
 			if err != nil {
 				// This should have been caught during post deserialize...
 				lg.Errorf("%s: %v", name, err)
@@ -248,10 +227,10 @@ func (s *State) loadVideoMaps(ml *av.VideoMapLibrary, lg *log.Logger) map[string
 		}
 	}
 
-	for _, name := range s.STARSFacilityAdaptation.VideoMapNames {
+	for _, name := range fa.VideoMapNames {
 		add(name)
 	}
-	for _, ctrl := range s.STARSFacilityAdaptation.ControllerConfigs {
+	for _, ctrl := range fa.ControllerConfigs {
 		for _, name := range ctrl.VideoMapNames {
 			add(name)
 		}
@@ -268,10 +247,30 @@ func (s *State) GetStateForController(callsign string) *State {
 	state := deep.MustCopy(*s)
 	state.Callsign = callsign
 
+	// Pilots' navigation state stays on the server.
+	for cs, ac := range state.Aircraft {
+		state.Aircraft[cs] = ac.Redacted()
+	}
+
+	// Controllers from adjacent facilities get their own facility's scope
+	// configuration.
+	fa, videoMaps, defaultMaps := s.STARSFacilityAdaptation, s.videoMaps, s.ScenarioDefaultVideoMaps
+	if ctrl, ok := s.Controllers[callsign]; ok {
+		if af, ok := s.AdjacentFacilities[ctrl.Facility]; ok {
+			fa, videoMaps, defaultMaps = af.STARSFacilityAdaptation, af.videoMaps, af.DefaultMaps
+			state.TRACON = ctrl.Facility
+			state.STARSFacilityAdaptation = af.STARSFacilityAdaptation
+			state.Center, state.Range = af.Center, af.Range
+			state.RadarSites = af.RadarSites
+			state.Scratchpads = af.Scratchpads
+		}
+	}
+	state.AdjacentFacilities = nil
+
 	// Now copy the appropriate video maps into ControllerVideoMaps and ControllerDefaultVideoMaps
-	if config, ok := s.STARSFacilityAdaptation.ControllerConfigs[callsign]; ok && len(config.VideoMapNames) > 0 {
+	if config, ok := fa.ControllerConfigs[callsign]; ok && len(config.VideoMapNames) > 0 {
 		for _, name := range config.VideoMapNames {
-			if m, ok := s.videoMaps[name]; !ok || name == "" {
+			if m, ok := videoMaps[name]; !ok || name == "" {
 				state.ControllerVideoMaps = append(state.ControllerVideoMaps, av.VideoMap{})
 			} else {
 				state.ControllerVideoMaps = append(state.ControllerVideoMaps, *m)
@@ -279,31 +278,67 @@ func (s *State) GetStateForController(callsign string) *State {
 		}
 		state.ControllerDefaultVideoMaps = config.DefaultMaps
 	} else {
-		for _, name := range s.STARSFacilityAdaptation.VideoMapNames {
-			if m, ok := s.videoMaps[name]; !ok || name == "" {
+		for _, name := range fa.VideoMapNames {
+			if m, ok := videoMaps[name]; !ok || name == "" {
 				state.ControllerVideoMaps = append(state.ControllerVideoMaps, av.VideoMap{})
 			} else {
 				state.ControllerVideoMaps = append(state.ControllerVideoMaps, *m)
 			}
 		}
-		state.ControllerDefaultVideoMaps = s.ScenarioDefaultVideoMaps
+		state.ControllerDefaultVideoMaps = defaultMaps
 	}
 
 	return &state
 }
 
-func getAltimiter(metar string) string {
-	for _, indexString := range []string{" A3", " A2"} {
-		index := strings.Index(metar, indexString)
-		if index != -1 && index+6 < len(metar) {
-			return metar[index+2 : index+6]
+// updateATIS regenerates the ATIS for each airport that has a METAR,
+// advancing the code for the ones whose contents have changed. It returns
+// the airports with new codes.
+func (ss *State) updateATIS() []string {
+	var updated []string
+	for _, icao := range util.SortedMapKeys(ss.METAR) {
+		var approaches, arrivals, departures []string
+		for _, rwy := range ss.ArrivalRunways {
+			if rwy.Airport != icao || slices.Contains(arrivals, rwy.Runway) {
+				continue
+			}
+			arrivals = append(arrivals, rwy.Runway)
+			if ap, ok := ss.Airports[icao]; ok {
+				for _, name := range util.SortedMapKeys(ap.Approaches) {
+					if appr := ap.Approaches[name]; appr.Runway == rwy.Runway && appr.FullName != "" {
+						approaches = append(approaches, appr.FullName)
+					}
+				}
+			}
+		}
+		for _, rwy := range ss.DepartureRunways {
+			if rwy.Airport == icao && !slices.Contains(departures, rwy.Runway) {
+				departures = append(departures, rwy.Runway)
+			}
+		}
+
+		contents := av.MakeATISContents(ss.METAR[icao], approaches, arrivals, departures)
+		if cur, ok := ss.ATIS[icao]; !ok {
+			// Start with a random code so that not every airport is at A.
+			ss.ATIS[icao] = av.ATIS{Airport: icao, Code: string(rune('A' + rand.Intn(26))), Contents: contents}
+		} else if cur.Contents != contents {
+			ss.ATIS[icao] = av.ATIS{Airport: icao, Code: av.NextATISCode(cur.Code), Contents: contents}
+			updated = append(updated, icao)
 		}
 	}
-	return ""
+	return updated
 }
 
 func (s *State) Activate(ml *av.VideoMapLibrary, lg *log.Logger) {
-	s.videoMaps = s.loadVideoMaps(ml, lg)
+	if s.ATIS == nil {
+		// Sims saved before the ATIS was generated
+		s.ATIS = make(map[string]av.ATIS)
+		s.updateATIS()
+	}
+	s.videoMaps = loadVideoMaps(s.STARSFacilityAdaptation, ml, lg)
+	for _, af := range s.AdjacentFacilities {
+		af.videoMaps = loadVideoMaps(af.STARSFacilityAdaptation, ml, lg)
+	}
 	// Make the ERAMComputers aware of each other.
 	s.ERAMComputers.Activate()
 }
@@ -403,6 +438,20 @@ func (s *State) IsIntraFacility(ac *av.Aircraft) bool {
 	return s.IsDeparture(ac) && s.IsArrival(ac)
 }
 
+// ActiveSpecialUseAirspace returns the special use airspace that is
+// active at the given time.
+func (ss *State) ActiveSpecialUseAirspace(t time.Time) []av.SpecialUseAirspace {
+	return util.FilterSlice(ss.SpecialUseAirspace,
+		func(s av.SpecialUseAirspace) bool { return s.Active(t) })
+}
+
+// ActiveRestrictedAirspace returns the active special use airspace that
+// IFR aircraft may not be routed through.
+func (ss *State) ActiveRestrictedAirspace(t time.Time) []av.SpecialUseAirspace {
+	return util.FilterSlice(ss.SpecialUseAirspace,
+		func(s av.SpecialUseAirspace) bool { return s.Restricted() && s.Active(t) })
+}
+
 func (ss *State) InhibitCAVolumes() []av.AirspaceVolume {
 	return ss.STARSFacilityAdaptation.InhibitCAVolumes
 }
@@ -429,6 +478,24 @@ func (ss *State) GetWindVector(p math.Point2LL, alt float32) math.Point2LL {
 	return vWind
 }
 
+// addWeatherCell adds the given cell, assigning it a unique id if it
+// doesn't have one already. The cell's id is returned.
+func (ss *State) addWeatherCell(cell av.WeatherCell) string {
+	used := func(id string) bool {
+		return slices.ContainsFunc(ss.WeatherCells, func(c av.WeatherCell) bool { return c.Id == id })
+	}
+	if cell.Id == "" || used(cell.Id) {
+		for i := 1; ; i++ {
+			if id := "WX" + strconv.Itoa(i); !used(id) {
+				cell.Id = id
+				break
+			}
+		}
+	}
+	ss.WeatherCells = append(ss.WeatherCells, cell)
+	return cell.Id
+}
+
 func (ss *State) FacilityFromController(callsign string) (string, bool) {
 	if controller := ss.Controllers[callsign]; controller != nil {
 		if controller.Facility != "" {
@@ -0,0 +1,237 @@
+// pkg/sim/strips.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"log/slog"
+	"slices"
+
+	av "github.com/mmp/vice/pkg/aviation"
+)
+
+type StripBay int
+
+const (
+	StripBayPending StripBay = iota
+	StripBayActive
+	StripBayHandedOff
+	NumStripBays
+)
+
+func (b StripBay) String() string {
+	return []string{"Pending", "Active", "Handed Off"}[b]
+}
+
+// BayStrip records that a controller has a flight strip for an aircraft
+// in one of their strip bays. Within a controller's bay, strips are kept
+// in the order they appear in State.FlightStrips.
+type BayStrip struct {
+	Callsign   string
+	Controller string
+	Bay        StripBay
+	// If non-empty, the controller that pushed the strip; it's cleared
+	// when the receiving controller acknowledges it.
+	PushedBy string
+}
+
+// FlightStrip returns the given controller's strip for the aircraft, if
+// they have one.
+func (ss *State) FlightStrip(controller, callsign string) (*BayStrip, bool) {
+	idx := slices.IndexFunc(ss.FlightStrips, func(fs BayStrip) bool {
+		return fs.Controller == controller && fs.Callsign == callsign
+	})
+	if idx == -1 {
+		return nil, false
+	}
+	return &ss.FlightStrips[idx], true
+}
+
+// StripBay returns the given controller's strips in the specified bay,
+// in order.
+func (ss *State) StripBay(controller string, bay StripBay) []BayStrip {
+	var strips []BayStrip
+	for _, fs := range ss.FlightStrips {
+		if fs.Controller == controller && fs.Bay == bay {
+			strips = append(strips, fs)
+		}
+	}
+	return strips
+}
+
+// insertStrip adds the strip to the end of its bay or before the
+// index'th strip already in the bay.
+func (s *Sim) insertStrip(fs BayStrip, index int) {
+	n := 0
+	for i, other := range s.State.FlightStrips {
+		if other.Controller == fs.Controller && other.Bay == fs.Bay {
+			if n == index {
+				s.State.FlightStrips = slices.Insert(s.State.FlightStrips, i, fs)
+				return
+			}
+			n++
+		}
+	}
+	s.State.FlightStrips = append(s.State.FlightStrips, fs)
+}
+
+func (s *Sim) removeStrip(controller, callsign string) (BayStrip, bool) {
+	idx := slices.IndexFunc(s.State.FlightStrips, func(fs BayStrip) bool {
+		return fs.Controller == controller && fs.Callsign == callsign
+	})
+	if idx == -1 {
+		return BayStrip{}, false
+	}
+	fs := s.State.FlightStrips[idx]
+	s.State.FlightStrips = slices.Delete(s.State.FlightStrips, idx, idx+1)
+	return fs, true
+}
+
+// updateStrips removes strips for aircraft that are no longer in the sim.
+func (s *Sim) updateStrips() {
+	s.State.FlightStrips = slices.DeleteFunc(s.State.FlightStrips, func(fs BayStrip) bool {
+		_, ok := s.State.Aircraft[fs.Callsign]
+		_, pending := s.PendingDepartures[fs.Callsign]
+		return !ok && !pending
+	})
+}
+
+// MoveFlightStrip puts the controller's strip for the aircraft in the
+// given bay, before the index'th strip already there or at the end if
+// index is negative. The strip is added if the controller doesn't have
+// one for the aircraft yet.
+func (s *Sim) MoveFlightStrip(token, callsign string, bay StripBay, index int) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	_, ok = s.State.Aircraft[callsign]
+	if _, pending := s.PendingDepartures[callsign]; !ok && !pending {
+		return av.ErrNoAircraftForCallsign
+	}
+	if bay < 0 || bay >= NumStripBays {
+		return ErrInvalidCommandSyntax
+	}
+
+	fs, ok := s.removeStrip(ctrl.Callsign, callsign)
+	if !ok {
+		fs = BayStrip{Callsign: callsign, Controller: ctrl.Callsign}
+	}
+	fs.Bay = bay
+	s.insertStrip(fs, index)
+	return nil
+}
+
+// AddFlightStrips adds strips for the aircraft at the end of the given
+// bay. Aircraft the controller already has a strip for and ones that have
+// left the sim since the request was made are skipped.
+func (s *Sim) AddFlightStrips(token string, callsigns []string, bay StripBay) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	if bay < 0 || bay >= NumStripBays {
+		return ErrInvalidCommandSyntax
+	}
+
+	for _, callsign := range callsigns {
+		_, ok := s.State.Aircraft[callsign]
+		if _, pending := s.PendingDepartures[callsign]; !ok && !pending {
+			continue
+		}
+		if _, ok := s.State.FlightStrip(ctrl.Callsign, callsign); !ok {
+			s.insertStrip(BayStrip{Callsign: callsign, Controller: ctrl.Callsign, Bay: bay}, -1)
+		}
+	}
+	return nil
+}
+
+func (s *Sim) RemoveFlightStrip(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	if _, ok := s.removeStrip(ctrl.Callsign, callsign); !ok {
+		return ErrNoFlightStrip
+	}
+	return nil
+}
+
+// PushFlightStrip moves the controller's strip for the aircraft to the
+// pending bay of the specified controller, who must then acknowledge it.
+func (s *Sim) PushFlightStrip(token, callsign, toController string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	if _, ok := s.State.Controllers[toController]; !ok || toController == ctrl.Callsign {
+		return av.ErrNoController
+	}
+	if _, ok := s.State.FlightStrip(ctrl.Callsign, callsign); !ok {
+		return ErrNoFlightStrip
+	}
+
+	s.removeStrip(ctrl.Callsign, callsign)
+	// The receiving controller only gets one strip for the aircraft.
+	s.removeStrip(toController, callsign)
+	s.insertStrip(BayStrip{
+		Callsign:   callsign,
+		Controller: toController,
+		Bay:        StripBayPending,
+		PushedBy:   ctrl.Callsign,
+	}, -1)
+
+	s.lg.Info("pushed flight strip", slog.String("callsign", callsign),
+		slog.String("from", ctrl.Callsign), slog.String("to", toController))
+	s.eventStream.Post(Event{
+		Type:           PushedFlightStripEvent,
+		Callsign:       callsign,
+		FromController: ctrl.Callsign,
+		ToController:   toController,
+	})
+	return nil
+}
+
+// AcknowledgeFlightStrip acknowledges a strip that was pushed to the
+// controller and moves it to their active bay.
+func (s *Sim) AcknowledgeFlightStrip(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	fs, ok := s.State.FlightStrip(ctrl.Callsign, callsign)
+	if !ok {
+		return ErrNoFlightStrip
+	} else if fs.PushedBy == "" {
+		return ErrFlightStripNotPushed
+	}
+
+	s.eventStream.Post(Event{
+		Type:           AcknowledgedFlightStripEvent,
+		Callsign:       callsign,
+		FromController: ctrl.Callsign,
+		ToController:   fs.PushedBy,
+	})
+
+	ack, _ := s.removeStrip(ctrl.Callsign, callsign)
+	ack.PushedBy = ""
+	ack.Bay = StripBayActive
+	s.insertStrip(ack, -1)
+	return nil
+}
@@ -0,0 +1,155 @@
+// pkg/sim/strips_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+)
+
+func TestFlightStrips(t *testing.T) {
+	s := makeTestSim(time.Time{}, map[string]string{"app": "JFK_APP", "twr": "JFK_TWR"})
+	for _, cs := range []string{"AAL1", "UAL2", "DAL3"} {
+		s.State.Aircraft[cs] = &av.Aircraft{Callsign: cs}
+	}
+	s.PendingDepartures["JBU4"] = &av.Aircraft{Callsign: "JBU4"}
+	d := &Dispatcher{sm: &SimManager{controllerTokenToSim: map[string]*Sim{"app": s, "twr": s}}}
+
+	bay := func(ctrl string, b StripBay) []string {
+		var cs []string
+		for _, fs := range s.State.StripBay(ctrl, b) {
+			cs = append(cs, fs.Callsign)
+		}
+		return cs
+	}
+	expectBay := func(ctrl string, b StripBay, expected ...string) {
+		t.Helper()
+		if cs := bay(ctrl, b); !slices.Equal(cs, expected) {
+			t.Errorf("%s %s bay: expected %v, got %v", ctrl, b, expected, cs)
+		}
+	}
+	var reply struct{}
+	call := func(f func(*FlightStripArgs, *struct{}) error, fa FlightStripArgs) error {
+		return f(&fa, &reply)
+	}
+
+	// Strips are added at the end or before the given index.
+	for _, cs := range []string{"AAL1", "UAL2", "JBU4"} {
+		if err := call(d.MoveFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: cs, Bay: StripBayActive,
+			Index: -1}); err != nil {
+			t.Fatalf("%s: %v", cs, err)
+		}
+	}
+	if err := call(d.MoveFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "DAL3", Bay: StripBayActive,
+		Index: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expectBay("JFK_APP", StripBayActive, "AAL1", "DAL3", "UAL2", "JBU4")
+
+	// Moving a strip doesn't duplicate it.
+	if err := call(d.MoveFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "JBU4", Bay: StripBayActive,
+		Index: 0}); err != nil {
+		t.Fatal(err)
+	}
+	expectBay("JFK_APP", StripBayActive, "JBU4", "AAL1", "DAL3", "UAL2")
+	if err := call(d.MoveFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "UAL2",
+		Bay: StripBayHandedOff, Index: -1}); err != nil {
+		t.Fatal(err)
+	}
+	expectBay("JFK_APP", StripBayActive, "JBU4", "AAL1", "DAL3")
+	expectBay("JFK_APP", StripBayHandedOff, "UAL2")
+
+	for _, test := range []struct {
+		name string
+		f    func(*FlightStripArgs, *struct{}) error
+		fa   FlightStripArgs
+		err  error
+	}{
+		{"unknown token", d.MoveFlightStrip, FlightStripArgs{ControllerToken: "x", Callsign: "AAL1"}, ErrNoSimForControllerToken},
+		{"unknown aircraft", d.MoveFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "N123"}, av.ErrNoAircraftForCallsign},
+		{"bad bay", d.MoveFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "AAL1", Bay: NumStripBays}, ErrInvalidCommandSyntax},
+		{"no strip to remove", d.RemoveFlightStrip, FlightStripArgs{ControllerToken: "twr", Callsign: "AAL1"}, ErrNoFlightStrip},
+		{"no strip to push", d.PushFlightStrip, FlightStripArgs{ControllerToken: "twr", Callsign: "AAL1", ToController: "JFK_APP"}, ErrNoFlightStrip},
+		{"push to self", d.PushFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "AAL1", ToController: "JFK_APP"}, av.ErrNoController},
+		{"push to nobody", d.PushFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "AAL1", ToController: "ZZZ"}, av.ErrNoController},
+		{"not pushed", d.AcknowledgeFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "AAL1"}, ErrFlightStripNotPushed},
+	} {
+		if err := call(test.f, test.fa); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// Pushing moves the strip to the other controller's pending bay
+	// until they acknowledge it.
+	if err := call(d.PushFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "AAL1",
+		ToController: "JFK_TWR"}); err != nil {
+		t.Fatal(err)
+	}
+	expectBay("JFK_APP", StripBayActive, "JBU4", "DAL3")
+	expectBay("JFK_TWR", StripBayPending, "AAL1")
+	if fs, ok := s.State.FlightStrip("JFK_TWR", "AAL1"); !ok || fs.PushedBy != "JFK_APP" {
+		t.Errorf("expected strip pushed by JFK_APP, got %+v", fs)
+	}
+	if err := call(d.AcknowledgeFlightStrip, FlightStripArgs{ControllerToken: "twr", Callsign: "AAL1"}); err != nil {
+		t.Fatal(err)
+	}
+	expectBay("JFK_TWR", StripBayPending)
+	expectBay("JFK_TWR", StripBayActive, "AAL1")
+	if fs, _ := s.State.FlightStrip("JFK_TWR", "AAL1"); fs.PushedBy != "" {
+		t.Errorf("expected acknowledged strip to no longer be pushed, got %+v", fs)
+	}
+
+	if err := call(d.RemoveFlightStrip, FlightStripArgs{ControllerToken: "app", Callsign: "DAL3"}); err != nil {
+		t.Fatal(err)
+	}
+	expectBay("JFK_APP", StripBayActive, "JBU4")
+
+	// Strips go away with their aircraft.
+	delete(s.PendingDepartures, "JBU4")
+	delete(s.State.Aircraft, "AAL1")
+	s.updateStrips()
+	expectBay("JFK_APP", StripBayActive)
+	expectBay("JFK_TWR", StripBayActive)
+	expectBay("JFK_APP", StripBayHandedOff, "UAL2")
+}
+
+func TestAddFlightStrips(t *testing.T) {
+	s := makeTestSim(time.Time{}, map[string]string{"app": "JFK_APP"})
+	for _, cs := range []string{"AAL1", "UAL2", "DAL3"} {
+		s.State.Aircraft[cs] = &av.Aircraft{Callsign: cs}
+	}
+	s.PendingDepartures["JBU4"] = &av.Aircraft{Callsign: "JBU4"}
+
+	if err := s.MoveFlightStrip("app", "UAL2", StripBayActive, -1); err != nil {
+		t.Fatal(err)
+	}
+	// The existing strip stays where it is and the one for the departed
+	// aircraft is skipped.
+	if err := s.AddFlightStrips("app", []string{"AAL1", "UAL2", "SWA9", "JBU4", "DAL3"}, StripBayPending); err != nil {
+		t.Fatal(err)
+	}
+	for bay, expected := range map[StripBay][]string{
+		StripBayPending: {"AAL1", "JBU4", "DAL3"},
+		StripBayActive:  {"UAL2"},
+	} {
+		var cs []string
+		for _, fs := range s.State.StripBay("JFK_APP", bay) {
+			cs = append(cs, fs.Callsign)
+		}
+		if !slices.Equal(cs, expected) {
+			t.Errorf("%s bay: expected %v, got %v", bay, expected, cs)
+		}
+	}
+
+	if err := s.AddFlightStrips("bogus", []string{"DAL3"}, StripBayPending); err != ErrInvalidControllerToken {
+		t.Errorf("expected invalid token error, got %v", err)
+	}
+	if err := s.AddFlightStrips("app", []string{"DAL3"}, NumStripBays); err != ErrInvalidCommandSyntax {
+		t.Errorf("expected invalid syntax error, got %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+// pkg/sim/surface.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/rand"
+	"github.com/mmp/vice/pkg/util"
+)
+
+const (
+	taxiSpeed = 15 // knots
+	// Hold short points are this far to the side of the runway
+	// centerline, just before the threshold.
+	holdShortOffset = 0.08 // nm
+)
+
+type SurfaceState int
+
+const (
+	SurfaceTaxiingOut SurfaceState = iota
+	SurfaceHoldingShort
+	SurfaceTakeoffRoll
+	SurfaceRollout
+	SurfaceTaxiingIn
+)
+
+func (s SurfaceState) String() string {
+	return []string{"TAXI", "HOLD", "ROLL", "LAND", "TAXI"}[s]
+}
+
+// SurfaceTarget is an aircraft on the ground at one of the scenario's
+// airports: a departure taxiing out and waiting for takeoff clearance or
+// an arrival that has landed and is taxiing in.
+type SurfaceTarget struct {
+	Callsign     string
+	AircraftType string
+	Airport      string
+	Runway       string
+	Departure    bool
+	State        SurfaceState
+	Position     math.Point2LL
+	Heading      float32
+	Tower        string
+	// Departures' flight plans, so that their strips can be printed
+	// before they're airborne.
+	FlightPlan *av.FlightPlan
+
+	// The target moves from From to To between Start and End.
+	From, To   math.Point2LL
+	Start, End time.Time
+	// When a departure reached the hold short point; the virtual tower
+	// clears them for takeoff in this order.
+	QueueTime time.Time
+}
+
+// SurfaceTarget returns the surface target with the given callsign, if
+// there is one.
+func (ss *State) SurfaceTarget(callsign string) (*SurfaceTarget, bool) {
+	idx := slices.IndexFunc(ss.SurfaceTargets, func(t SurfaceTarget) bool { return t.Callsign == callsign })
+	if idx == -1 {
+		return nil, false
+	}
+	return &ss.SurfaceTargets[idx], true
+}
+
+// DepartureQueue returns the departures holding short of the given
+// runway, in the order they got there.
+func (ss *State) DepartureQueue(airport, runway string) []SurfaceTarget {
+	var q []SurfaceTarget
+	for _, t := range ss.SurfaceTargets {
+		if t.Airport == airport && t.Runway == runway && t.State == SurfaceHoldingShort {
+			q = append(q, t)
+		}
+	}
+	slices.SortFunc(q, func(a, b SurfaceTarget) int { return a.QueueTime.Compare(b.QueueTime) })
+	return q
+}
+
+// TowerAirport returns the airport for which the given controller is the
+// tower, if any.
+func (ss *State) TowerAirport(callsign string) (string, bool) {
+	for _, airport := range util.SortedMapKeys(ss.Airports) {
+		if ss.towerController(airport) == callsign {
+			return airport, true
+		}
+	}
+	return "", false
+}
+
+// runwayEnds returns the threshold of the given runway and the threshold
+// at its opposite end.
+func runwayEnds(airport, runway string) (math.Point2LL, math.Point2LL, bool) {
+	rwy, ok := av.LookupRunway(airport, runway)
+	if !ok {
+		return math.Point2LL{}, math.Point2LL{}, false
+	}
+	opp, ok := av.LookupOppositeRunway(airport, runway)
+	if !ok {
+		return math.Point2LL{}, math.Point2LL{}, false
+	}
+	return rwy.Threshold, opp.Threshold, true
+}
+
+func (ss *State) holdShortPoint(threshold, end math.Point2LL) math.Point2LL {
+	t := math.LL2NM(threshold, ss.NmPerLongitude)
+	dir := math.Normalize2f(math.Sub2f(math.LL2NM(end, ss.NmPerLongitude), t))
+	perp := [2]float32{dir[1], -dir[0]}
+	p := math.Add2f(t, math.Add2f(math.Scale2f(perp, holdShortOffset), math.Scale2f(dir, -holdShortOffset/2)))
+	return math.NM2LL(p, ss.NmPerLongitude)
+}
+
+func (ss *State) rampLocation(airport string) math.Point2LL {
+	if ap, ok := ss.Airports[airport]; ok && !ap.Location.IsZero() {
+		return ap.Location
+	}
+	return av.DB.Airports[airport].Location
+}
+
+func taxiTime(from, to math.Point2LL) time.Duration {
+	// Taxi routes aren't straight lines, so pad the time a bit.
+	s := 1.5*math.NMDistance2LL(from, to)/taxiSpeed*3600 + float32(rand.Intn(60))
+	return time.Duration(math.Clamp(s, 60, 600)) * time.Second
+}
+
+func (s *Sim) move(t *SurfaceTarget, state SurfaceState, from, to math.Point2LL, d time.Duration) {
+	t.State = state
+	t.From, t.To = from, to
+	t.Start, t.End = s.SimTime, s.SimTime.Add(d)
+	t.Position = from
+	if from != to {
+		t.Heading = math.Heading2LL(from, to, s.State.NmPerLongitude, s.State.MagneticVariation)
+	}
+}
+
+// taxiOut keeps a newly-created departure on the ground; it taxis from
+// the ramp to the runway and then waits for takeoff clearance.
+func (s *Sim) taxiOut(ac *av.Aircraft, runway string) {
+	if s.PendingDepartures == nil {
+		s.PendingDepartures = make(map[string]*av.Aircraft)
+	}
+	s.PendingDepartures[ac.Callsign] = ac
+
+	airport := ac.FlightPlan.DepartureAirport
+	t := SurfaceTarget{
+		Callsign:     ac.Callsign,
+		AircraftType: ac.FlightPlan.TypeWithoutSuffix(),
+		Airport:      airport,
+		Runway:       runway,
+		Departure:    true,
+		Tower:        s.State.towerController(airport),
+		FlightPlan:   ac.FlightPlan,
+	}
+
+	ramp := s.State.rampLocation(airport)
+	hold := ramp
+	if thr, end, ok := runwayEnds(airport, runway); ok {
+		hold = s.State.holdShortPoint(thr, end)
+	}
+	s.move(&t, SurfaceTaxiingOut, ramp, hold, taxiTime(ramp, hold))
+
+	s.State.SurfaceTargets = append(s.State.SurfaceTargets, t)
+	s.lg.Info("taxiing out", slog.String("callsign", ac.Callsign), slog.String("runway", runway))
+}
+
+// taxiIn is called when an arrival lands; it rolls out along the runway
+// and then taxis to the ramp.
+func (s *Sim) taxiIn(ac *av.Aircraft, airport, runway string) {
+	t := SurfaceTarget{
+		Callsign: ac.Callsign,
+		Airport:  airport,
+		Runway:   runway,
+		Tower:    s.State.towerController(airport),
+	}
+	if ac.FlightPlan != nil {
+		t.AircraftType = ac.FlightPlan.TypeWithoutSuffix()
+	}
+
+	exit := ac.Position()
+	if thr, end, ok := runwayEnds(airport, runway); ok {
+		exit = math.Point2LL(math.Lerp2f(.6, thr, end))
+	}
+	occ, _ := s.RunwayOccupied(airport, runway)
+	s.move(&t, SurfaceRollout, ac.Position(), exit, occ.Until.Sub(s.SimTime))
+
+	s.State.SurfaceTargets = append(s.State.SurfaceTargets, t)
+}
+
+func (s *Sim) virtualTowerForSurface(t *SurfaceTarget) bool {
+	return t.Tower == "" || !s.controllerIsSignedIn(t.Tower)
+}
+
+// releasedForTakeoff returns true if the departure doesn't need a
+// release or has been released and it's time for it to go.
+func (s *Sim) releasedForTakeoff(t *SurfaceTarget, checkTime bool) bool {
+	r, ok := s.State.DepartureRelease(t.Callsign)
+	return !ok || (r.Status == ReleaseApproved && (!checkTime || !s.SimTime.Before(r.TakeoffTime)))
+}
+
+func (s *Sim) startTakeoffRoll(t *SurfaceTarget) {
+	roll := 30 * time.Second
+	if ac, ok := s.PendingDepartures[t.Callsign]; ok {
+		s.occupyRunway(ac, t.Airport, t.Runway, true)
+		occ, _ := s.RunwayOccupied(t.Airport, t.Runway)
+		roll = occ.Until.Sub(s.SimTime)
+	}
+
+	from, to := t.Position, t.Position
+	if thr, end, ok := runwayEnds(t.Airport, t.Runway); ok {
+		from, to = thr, math.Point2LL(math.Lerp2f(.5, thr, end))
+	}
+	s.move(t, SurfaceTakeoffRoll, from, to, roll)
+	s.lg.Info("takeoff roll", slog.String("callsign", t.Callsign), slog.String("runway", t.Runway))
+}
+
+// updateSurface moves the surface targets along, has virtual towers
+// clear departures for takeoff, and launches departures once they're
+// airborne.
+func (s *Sim) updateSurface() {
+	var remove []string
+	for i := range s.State.SurfaceTargets {
+		t := &s.State.SurfaceTargets[i]
+
+		if d := t.End.Sub(t.Start); d > 0 {
+			x := math.Clamp(float32(s.SimTime.Sub(t.Start))/float32(d), 0, 1)
+			t.Position = math.Point2LL(math.Lerp2f(x, t.From, t.To))
+		}
+		done := !s.SimTime.Before(t.End)
+
+		switch t.State {
+		case SurfaceTaxiingOut:
+			if done {
+				t.State = SurfaceHoldingShort
+				t.QueueTime = s.SimTime
+			}
+
+		case SurfaceHoldingShort:
+			if s.virtualTowerForSurface(t) && s.releasedForTakeoff(t, true) &&
+				s.State.DepartureQueue(t.Airport, t.Runway)[0].Callsign == t.Callsign &&
+				s.runwayClearForDeparture(t.Airport, t.Runway) {
+				s.startTakeoffRoll(t)
+			}
+
+		case SurfaceTakeoffRoll:
+			if done {
+				if ac, ok := s.PendingDepartures[t.Callsign]; ok {
+					s.launchAircraftNoLock(*ac)
+					delete(s.PendingDepartures, t.Callsign)
+				}
+				s.State.DepartureReleases = slices.DeleteFunc(s.State.DepartureReleases,
+					func(r DepartureRelease) bool { return r.Callsign == t.Callsign })
+				remove = append(remove, t.Callsign)
+			}
+
+		case SurfaceRollout:
+			if done {
+				ramp := s.State.rampLocation(t.Airport)
+				s.move(t, SurfaceTaxiingIn, t.Position, ramp, taxiTime(t.Position, ramp))
+			}
+
+		case SurfaceTaxiingIn:
+			if done {
+				remove = append(remove, t.Callsign)
+			}
+		}
+	}
+
+	s.State.SurfaceTargets = slices.DeleteFunc(s.State.SurfaceTargets,
+		func(t SurfaceTarget) bool { return slices.Contains(remove, t.Callsign) })
+}
+
+// ClearForTakeoff is called by a human tower controller to clear a
+// departure that is holding short for takeoff.
+func (s *Sim) ClearForTakeoff(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	}
+	t, ok := s.State.SurfaceTarget(callsign)
+	if !ok || t.State != SurfaceHoldingShort {
+		return ErrNotHoldingShort
+	} else if ctrl.Callsign != t.Tower {
+		return ErrNotTowerController
+	} else if !s.releasedForTakeoff(t, false) {
+		return ErrDepartureNotReleased
+	}
+
+	s.startTakeoffRoll(t)
+	PostRadioEvents(callsign, []av.RadioTransmission{av.RadioTransmission{
+		Controller: ctrl.Callsign,
+		Message:    "cleared for takeoff runway " + t.Runway,
+		Type:       av.RadioTransmissionReadback,
+	}}, s)
+	return nil
+}
+
+func (s *Sim) ClearedToLand(token, callsign string) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	return s.dispatchControllingCommand(token, callsign,
+		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
+			return ac.ClearToLand()
+		})
+}
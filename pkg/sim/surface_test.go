@@ -0,0 +1,171 @@
+// pkg/sim/surface_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+)
+
+// Use a made-up airport so that the tests don't depend on the FAA
+// database contents; the ramp is about a mile from the runway.
+const surfaceTestAirport = "KZZV"
+
+func makeSurfaceTestSim(t *testing.T, now time.Time) *Sim {
+	addTestAirport(t, av.FAAAirport{
+		Id:       surfaceTestAirport,
+		Location: math.Point2LL{-73.78, 40.66},
+		Runways: []av.Runway{
+			{Id: "31L", Threshold: math.Point2LL{-73.76, 40.63}},
+			{Id: "13R", Threshold: math.Point2LL{-73.80, 40.65}},
+		},
+	})
+
+	s := makeTestSim(now, map[string]string{"twr": "JFK_TWR", "dep": "NY_DEP"})
+	s.State.PrimaryController = "NY_DEP"
+	return s
+}
+
+func makeSurfaceTestDeparture(callsign string) *av.Aircraft {
+	return makeTestDeparture(callsign, surfaceTestAirport, math.Point2LL{})
+}
+
+func TestTaxiTime(t *testing.T) {
+	p := math.Point2LL{-73.78, 40.64}
+	for i := 0; i < 100; i++ {
+		// Short taxis still take a minute.
+		if d := taxiTime(p, p); d != time.Minute {
+			t.Errorf("expected one minute for no distance, got %s", d)
+		}
+		// 1nm at 15 knots, padded by half, plus up to a minute.
+		q := math.Point2LL{p[0], p[1] + 1./60}
+		if d := taxiTime(p, q); d < 6*time.Minute || d >= 7*time.Minute {
+			t.Errorf("1nm taxi time %s out of range", d)
+		}
+		// And long ones are capped.
+		q = math.Point2LL{p[0], p[1] + 1}
+		if d := taxiTime(p, q); d != 10*time.Minute {
+			t.Errorf("expected long taxi capped at 10 minutes, got %s", d)
+		}
+	}
+}
+
+func TestVirtualTowerDepartures(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := makeSurfaceTestSim(t, now)
+
+	s.taxiOut(makeSurfaceTestDeparture("AAL1"), "31L")
+	s.SimTime = now.Add(time.Second)
+	s.taxiOut(makeSurfaceTestDeparture("UAL2"), "31L")
+
+	t1, _ := s.State.SurfaceTarget("AAL1")
+	t2, _ := s.State.SurfaceTarget("UAL2")
+	if t1.State != SurfaceTaxiingOut || t1.End.Sub(t1.Start) < time.Minute || t1.End.Sub(t1.Start) > 10*time.Minute {
+		t.Fatalf("unexpected taxi out %+v", *t1)
+	}
+	first, second := "AAL1", "UAL2"
+	end1, end2 := t1.End, t2.End
+	if end2.Before(end1) {
+		first, second = second, first
+		end1, end2 = end2, end1
+	}
+
+	// Midway, the target is between the ramp and the runway.
+	s.SimTime = t1.Start.Add(t1.End.Sub(t1.Start) / 2)
+	s.updateSurface()
+	if t1, _ := s.State.SurfaceTarget("AAL1"); t1.Position == t1.From || t1.Position == t1.To {
+		t.Errorf("expected AAL1 to be taxiing, at %v", t1.Position)
+	}
+
+	// The first to reach the runway is cleared for takeoff right away.
+	s.SimTime = end1
+	s.updateSurface()
+	s.updateSurface()
+	if tg, _ := s.State.SurfaceTarget(first); tg.State != SurfaceTakeoffRoll {
+		t.Errorf("expected %s to be rolling, got %s", first, tg.State)
+	}
+	occ, ok := s.RunwayOccupied(surfaceTestAirport, "31L")
+	if !ok || occ.Callsign != first {
+		t.Errorf("expected %s on the runway, got %+v", first, occ)
+	}
+
+	// The second waits for the runway.
+	s.SimTime = end2
+	if s.SimTime.Before(occ.Until) {
+		s.updateSurface()
+		s.updateSurface()
+		if tg, _ := s.State.SurfaceTarget(second); tg.State != SurfaceHoldingShort {
+			t.Errorf("expected %s to be holding short, got %s", second, tg.State)
+		}
+		if q := s.State.DepartureQueue(surfaceTestAirport, "31L"); len(q) != 1 || q[0].Callsign != second {
+			t.Errorf("expected %s in the departure queue, got %+v", second, q)
+		}
+	}
+
+	// Once the first is airborne, it's launched and the second goes.
+	s.SimTime = occ.Until.Add(time.Second)
+	s.updateSurface()
+	if _, ok := s.State.SurfaceTarget(first); ok {
+		t.Errorf("%s should no longer be on the surface", first)
+	}
+	if _, ok := s.State.Aircraft[first]; !ok {
+		t.Errorf("%s should have been launched", first)
+	}
+	if _, ok := s.PendingDepartures[first]; ok {
+		t.Errorf("%s should no longer be pending", first)
+	}
+	s.updateSurface()
+	if tg, _ := s.State.SurfaceTarget(second); tg.State != SurfaceTakeoffRoll {
+		t.Errorf("expected %s to be rolling, got %s", second, tg.State)
+	}
+}
+
+func TestClearForTakeoff(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := makeSurfaceTestSim(t, now)
+	s.State.Airports = map[string]*av.Airport{
+		surfaceTestAirport: {Approaches: map[string]*av.Approach{"I31L": {Runway: "31L", TowerController: "JFK_TWR"}}},
+	}
+
+	ac := makeSurfaceTestDeparture("AAL1")
+	s.taxiOut(ac, "31L")
+	s.holdForRelease(ac, "31L")
+
+	if err := s.ClearForTakeoff("twr", "AAL1"); err != ErrNotHoldingShort {
+		t.Errorf("expected ErrNotHoldingShort while taxiing, got %v", err)
+	}
+
+	tg, _ := s.State.SurfaceTarget("AAL1")
+	s.SimTime = tg.End
+	s.updateSurface()
+	if tg.State != SurfaceHoldingShort {
+		t.Fatalf("expected a human tower's departure to hold short, got %s", tg.State)
+	}
+
+	if err := s.ClearForTakeoff("dep", "AAL1"); err != ErrNotTowerController {
+		t.Errorf("expected ErrNotTowerController, got %v", err)
+	}
+	if err := s.ClearForTakeoff("twr", "AAL1"); err != ErrDepartureNotReleased {
+		t.Errorf("expected ErrDepartureNotReleased, got %v", err)
+	}
+
+	if err := s.RequestRelease("twr", "AAL1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApproveRelease("dep", "AAL1", 0); err != nil {
+		t.Fatal(err)
+	}
+	// The tower can go right away rather than waiting for the time a
+	// virtual tower would.
+	if err := s.ClearForTakeoff("twr", "AAL1"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if tg.State != SurfaceTakeoffRoll {
+		t.Errorf("expected AAL1 to be rolling, got %s", tg.State)
+	}
+}
@@ -0,0 +1,345 @@
+// pkg/sim/tmi.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/rand"
+)
+
+type TMIType string
+
+const (
+	TMIMilesInTrail TMIType = "mit"
+	TMIGroundStop   TMIType = "ground_stop"
+	TMIEDCT         TMIType = "edct"
+)
+
+var TMITypes = []TMIType{TMIMilesInTrail, TMIGroundStop, TMIEDCT}
+
+// Maximum number of departures from an airport that may be held at the
+// gate by TMIs; no more are created there until some have been released.
+const maxHeldDepartures = 5
+
+// HeldDeparture is a departure that has been created but is held at the
+// gate until the TMIs that apply to it allow it to go.
+type HeldDeparture struct {
+	Aircraft *av.Aircraft
+	Runway   string
+}
+
+// TMI is a traffic management initiative that constrains when departures
+// are released and how they are spaced when they are handed off to
+// center.
+type TMI struct {
+	Id       string  `json:"id"`
+	Type     TMIType `json:"type"`
+	Fix      string  `json:"fix,omitempty"`      // miles-in-trail: coordination fix
+	Miles    int     `json:"miles,omitempty"`    // miles-in-trail
+	Airport  string  `json:"airport,omitempty"`  // ground stop: destination; EDCT: departure airport
+	Callsign string  `json:"callsign,omitempty"` // EDCT
+	// Ground stops end at Time, if it is set; EDCT departures may not
+	// be released before it.
+	Time time.Time `json:"time"`
+
+	// Number of aircraft that crossed a miles-in-trail fix without the
+	// required spacing.
+	Violations int `json:"violations,omitempty"`
+}
+
+// isCoordinationFix returns true if the fix is a coordination fix in any
+// of the ERAM adaptations.
+func isCoordinationFix(fix string) bool {
+	for _, adapt := range av.DB.ERAMAdaptations {
+		if _, ok := adapt.CoordinationFixes[fix]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error if the TMI is incompletely specified or refers
+// to an unknown fix or airport.
+func (t TMI) Check(airports map[string]*av.Airport) error {
+	switch t.Type {
+	case TMIMilesInTrail:
+		if !isCoordinationFix(t.Fix) {
+			return fmt.Errorf("%s: not a coordination fix", t.Fix)
+		}
+		if t.Miles <= 0 {
+			return fmt.Errorf("\"miles\" must be greater than zero")
+		}
+	case TMIGroundStop:
+		if _, ok := av.DB.Airports[t.Airport]; !ok {
+			return fmt.Errorf("%s: unknown airport", t.Airport)
+		}
+	case TMIEDCT:
+		if _, ok := airports[t.Airport]; !ok {
+			return fmt.Errorf("%s: unknown airport", t.Airport)
+		}
+		if t.Callsign == "" {
+			return fmt.Errorf("must specify \"callsign\"")
+		}
+		if t.Time.IsZero() {
+			return fmt.Errorf("must specify EDCT time")
+		}
+	default:
+		return fmt.Errorf("%s: unknown TMI type", t.Type)
+	}
+	return nil
+}
+
+// String returns the TMI in the abbreviated form shown in the STARS TMI
+// list.
+func (t TMI) String() string {
+	switch t.Type {
+	case TMIMilesInTrail:
+		s := fmt.Sprintf("%s %d MIT", t.Fix, t.Miles)
+		if t.Violations > 0 {
+			s += fmt.Sprintf(" %d VIOL", t.Violations)
+		}
+		return s
+	case TMIGroundStop:
+		s := "GS " + strings.TrimPrefix(t.Airport, "K")
+		if !t.Time.IsZero() {
+			s += " UNTIL " + t.Time.UTC().Format("1504")
+		}
+		return s
+	case TMIEDCT:
+		return t.Callsign + " EDCT " + t.Time.UTC().Format("1504")
+	default:
+		return string(t.Type)
+	}
+}
+
+// addTMI adds the TMI, assigning it an id if it doesn't have one, and
+// returns its id.
+func (ss *State) addTMI(t TMI) string {
+	if t.Id == "" {
+		for i := 1; ; i++ {
+			id := "T" + strconv.Itoa(i)
+			if !slices.ContainsFunc(ss.TMIs, func(o TMI) bool { return o.Id == id }) {
+				t.Id = id
+				break
+			}
+		}
+	}
+	ss.TMIs = append(ss.TMIs, t)
+	return t.Id
+}
+
+// GroundStopped returns true if departures to the given airport are
+// subject to a ground stop.
+func (ss *State) GroundStopped(destination string, now time.Time) bool {
+	return slices.ContainsFunc(ss.TMIs, func(t TMI) bool {
+		return t.Type == TMIGroundStop && t.Airport == destination && (t.Time.IsZero() || now.Before(t.Time))
+	})
+}
+
+// departureRestricted returns a non-empty string giving the reason if
+// the departure may not be released now.
+func (s *Sim) departureRestricted(ac *av.Aircraft) string {
+	fp := ac.FlightPlan
+	if fp == nil {
+		return ""
+	}
+	if s.State.GroundStopped(fp.ArrivalAirport, s.SimTime) {
+		return "ground stop for " + fp.ArrivalAirport
+	}
+
+	for _, t := range s.State.TMIs {
+		switch t.Type {
+		case TMIEDCT:
+			if t.Callsign == ac.Callsign && s.SimTime.Before(t.Time) {
+				return "EDCT " + t.Time.UTC().Format("1504")
+			}
+
+		case TMIMilesInTrail:
+			if !slices.ContainsFunc(ac.Nav.Waypoints, func(wp av.Waypoint) bool { return wp.Fix == t.Fix }) {
+				continue
+			}
+			// Don't release the departure until the previous one from
+			// the same airport headed for the fix is far enough away
+			// that it will have the required spacing; ones still on the
+			// ground are too close.
+			for _, m := range []map[string]*av.Aircraft{s.State.Aircraft, s.PendingDepartures} {
+				for _, other := range m {
+					if other.Callsign == ac.Callsign || other.FlightPlan == nil ||
+						other.FlightPlan.DepartureAirport != fp.DepartureAirport ||
+						!slices.ContainsFunc(other.Nav.Waypoints, func(wp av.Waypoint) bool { return wp.Fix == t.Fix }) {
+						continue
+					}
+					if d := math.NMDistance2LL(other.Position(), ac.Position()); d < float32(t.Miles) {
+						return fmt.Sprintf("%d MIT at %s", t.Miles, t.Fix)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// pendingEDCT returns the EDCT for a departure from the given airport
+// whose time has come, if there is one.
+func (s *Sim) pendingEDCT(airport string) (TMI, bool) {
+	for _, t := range s.State.TMIs {
+		if t.Type == TMIEDCT && t.Airport == airport && !s.SimTime.Before(t.Time) {
+			_, launched := s.State.Aircraft[t.Callsign]
+			_, pending := s.PendingDepartures[t.Callsign]
+			held := slices.ContainsFunc(s.HeldDepartures,
+				func(h HeldDeparture) bool { return h.Aircraft.Callsign == t.Callsign })
+			if !launched && !pending && !held {
+				return t, true
+			}
+		}
+	}
+	return TMI{}, false
+}
+
+// edctDeparture returns a departure from the airport's departures that
+// the given runway handles and that is flown by the airline of the EDCT
+// flight, along with that airline. If none of the departures is flown by
+// the airline, any departure for the runway is returned with the
+// airline's default fleet.
+func edctDeparture(ap *av.Airport, rwy *ScenarioGroupDepartureRunway, callsign string) (*av.Departure, av.DepartureAirline, bool) {
+	icao := callsign
+	if i := strings.IndexFunc(callsign, func(r rune) bool { return r < 'A' || r > 'Z' }); i != -1 {
+		icao = callsign[:i]
+	}
+	if _, ok := av.DB.Airlines[icao]; !ok || len(icao) != 3 {
+		// Not an airline flight.
+		return nil, av.DepartureAirline{}, false
+	}
+
+	handled := func(d av.Departure) bool {
+		_, ok := rwy.ExitRoutes[d.Exit] // make sure the runway handles the exit
+		return ok && (rwy.Category == "" || rwy.Category == ap.ExitCategories[d.Exit])
+	}
+	flownBy := func(d av.Departure) (av.DepartureAirline, bool) {
+		idx := slices.IndexFunc(d.Airlines, func(al av.DepartureAirline) bool { return al.ICAO == icao })
+		if idx == -1 {
+			return av.DepartureAirline{}, false
+		}
+		return d.Airlines[idx], true
+	}
+
+	if idx := rand.SampleFiltered(ap.Departures, func(d av.Departure) bool {
+		_, ok := flownBy(d)
+		return ok && handled(d)
+	}); idx != -1 {
+		al, _ := flownBy(ap.Departures[idx])
+		return &ap.Departures[idx], al, true
+	}
+	if idx := rand.SampleFiltered(ap.Departures, handled); idx != -1 {
+		return &ap.Departures[idx], av.DepartureAirline{ICAO: icao}, true
+	}
+	return nil, av.DepartureAirline{}, false
+}
+
+// releasedEDCT removes the EDCT for the given callsign now that it has
+// departed.
+func (ss *State) releasedEDCT(callsign string) {
+	ss.TMIs = slices.DeleteFunc(ss.TMIs, func(t TMI) bool { return t.Type == TMIEDCT && t.Callsign == callsign })
+}
+
+// updateTMIs removes ground stops that have expired.
+func (s *Sim) updateTMIs() {
+	s.State.TMIs = slices.DeleteFunc(s.State.TMIs, func(t TMI) bool {
+		if t.Type == TMIGroundStop && !t.Time.IsZero() && !s.SimTime.Before(t.Time) {
+			s.lg.Info("ground stop expired", slog.String("airport", t.Airport))
+			s.eventStream.Post(Event{
+				Type:    StatusMessageEvent,
+				Message: "Ground stop for " + t.Airport + " has ended",
+			})
+			return true
+		}
+		return false
+	})
+}
+
+// heldDepartures returns the number of departures from the airport that
+// are held at the gate.
+func (s *Sim) heldDepartures(airport string) int {
+	n := 0
+	for _, h := range s.HeldDepartures {
+		if h.Aircraft.FlightPlan.DepartureAirport == airport {
+			n++
+		}
+	}
+	return n
+}
+
+// holdOrTaxiDeparture starts a new departure taxiing out unless a TMI
+// applies to it, in which case it's held at the gate until
+// releaseHeldDepartures finds that it may go.
+func (s *Sim) holdOrTaxiDeparture(ac *av.Aircraft, runway string) {
+	if reason := s.departureRestricted(ac); reason != "" {
+		s.lg.Info("holding departure", slog.String("callsign", ac.Callsign), slog.String("reason", reason))
+		s.HeldDepartures = append(s.HeldDepartures, HeldDeparture{Aircraft: ac, Runway: runway})
+	} else {
+		s.taxiDeparture(ac, runway)
+	}
+}
+
+// releaseHeldDepartures starts the held departures that the TMIs now
+// allow to go taxiing out, in the order they were held.
+func (s *Sim) releaseHeldDepartures() {
+	for i := 0; i < len(s.HeldDepartures); {
+		h := s.HeldDepartures[i]
+		if s.departureRestricted(h.Aircraft) != "" {
+			i++
+			continue
+		}
+		// Remove it first so that the departures after it see it as
+		// pending rather than held.
+		s.HeldDepartures = slices.Delete(s.HeldDepartures, i, i+1)
+		s.lg.Info("releasing held departure", slog.String("callsign", h.Aircraft.Callsign))
+		s.taxiDeparture(h.Aircraft, h.Runway)
+	}
+}
+
+// checkMITCompliance is called when an aircraft passes a waypoint; if
+// there's a miles-in-trail restriction at the waypoint's fix, it reports
+// an aircraft that doesn't have the required spacing from the one that
+// crossed the fix before it.
+func (s *Sim) checkMITCompliance(ac *av.Aircraft, wp *av.Waypoint) {
+	for i := range s.State.TMIs {
+		t := &s.State.TMIs[i]
+		if t.Type != TMIMilesInTrail || t.Fix != wp.Fix {
+			continue
+		}
+
+		if s.lastMITCrossing == nil {
+			s.lastMITCrossing = make(map[string]string)
+		}
+		prev := s.lastMITCrossing[t.Fix]
+		s.lastMITCrossing[t.Fix] = ac.Callsign
+
+		lead, ok := s.State.Aircraft[prev]
+		if !ok || prev == ac.Callsign {
+			continue
+		}
+		// The trailing aircraft is at the fix, so the spacing is the
+		// leader's distance past it.
+		if d := math.NMDistance2LL(lead.Position(), wp.Location); d < float32(t.Miles) {
+			t.Violations++
+			s.lg.Info("MIT violation", slog.String("callsign", ac.Callsign), slog.String("fix", t.Fix),
+				slog.Float64("distance", float64(d)), slog.Int("miles", t.Miles))
+			s.eventStream.Post(Event{
+				Type: StatusMessageEvent,
+				Message: fmt.Sprintf("%s crossed %s %.1f miles in trail of %s; %d MIT required",
+					ac.Callsign, t.Fix, d, lead.Callsign, t.Miles),
+			})
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// pkg/sim/tmi_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+)
+
+func makeTMITestSim(now time.Time, tmis ...TMI) *Sim {
+	s := makeTestSim(now, nil)
+	s.State.TMIs = tmis
+	return s
+}
+
+func makeTMITestAircraft(callsign string, p math.Point2LL, fixes ...string) *av.Aircraft {
+	return makeTestDeparture(callsign, "KJFK", p, fixes...)
+}
+
+func TestDepartureRestricted(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	jfk := math.Point2LL{-73.78, 40.64}
+
+	// Ground stops apply until they end.
+	s := makeTMITestSim(now, TMI{Type: TMIGroundStop, Airport: "KBOS", Time: now.Add(time.Hour)})
+	ac := makeTMITestAircraft("AAL1", jfk, "MERIT")
+	if s.departureRestricted(ac) == "" {
+		t.Errorf("expected ground stop to hold departure")
+	}
+	s.SimTime = now.Add(2 * time.Hour)
+	s.updateTMIs()
+	if len(s.State.TMIs) != 0 || s.departureRestricted(ac) != "" {
+		t.Errorf("expected ground stop to have expired")
+	}
+
+	// EDCT flights wait for their time.
+	s = makeTMITestSim(now, TMI{Type: TMIEDCT, Airport: "KJFK", Callsign: "AAL1", Time: now.Add(10 * time.Minute)})
+	if s.departureRestricted(ac) == "" {
+		t.Errorf("expected EDCT to hold departure")
+	}
+	if _, ok := s.pendingEDCT("KJFK"); ok {
+		t.Errorf("EDCT shouldn't be pending before its time")
+	}
+	s.SimTime = now.Add(10 * time.Minute)
+	if s.departureRestricted(ac) != "" {
+		t.Errorf("expected EDCT departure to be released")
+	}
+	if edct, ok := s.pendingEDCT("KJFK"); !ok || edct.Callsign != "AAL1" {
+		t.Errorf("expected pending EDCT for AAL1, got %+v", edct)
+	}
+	s.PendingDepartures["AAL1"] = ac
+	if _, ok := s.pendingEDCT("KJFK"); ok {
+		t.Errorf("EDCT shouldn't be pending once the flight has been created")
+	}
+
+	// Miles-in-trail holds the departure until the previous one over
+	// the fix is far enough away.
+	s = makeTMITestSim(now, TMI{Type: TMIMilesInTrail, Fix: "MERIT", Miles: 15})
+	s.State.Aircraft["UAL2"] = makeTMITestAircraft("UAL2", math.Point2LL{-73.70, 40.70}, "MERIT")
+	if s.departureRestricted(ac) == "" {
+		t.Errorf("expected MIT to hold departure")
+	}
+	s.State.Aircraft["UAL2"].Nav.FlightState.Position = math.Point2LL{-73.0, 41.2}
+	if s.departureRestricted(ac) != "" {
+		t.Errorf("expected departure to be released once the leader is in trail")
+	}
+	if s.departureRestricted(makeTMITestAircraft("DAL3", jfk, "GAYEL")) != "" {
+		t.Errorf("MIT shouldn't apply to departures not routed over the fix")
+	}
+}
+
+func TestEDCTDeparture(t *testing.T) {
+	ap := &av.Airport{
+		Departures: []av.Departure{
+			{Exit: "MERIT", Destination: "KBOS", Airlines: []av.DepartureAirline{{ICAO: "JBU"}}},
+			{Exit: "WAVEY", Destination: "KMIA", Airlines: []av.DepartureAirline{{ICAO: "AAL", Fleet: "long"}}},
+			{Exit: "GAYEL", Destination: "KORD", Airlines: []av.DepartureAirline{{ICAO: "AAL"}}},
+		},
+	}
+	rwy := &ScenarioGroupDepartureRunway{
+		ExitRoutes: map[string]av.ExitRoute{"MERIT": {}, "WAVEY": {}},
+	}
+
+	// The departure flown by the EDCT flight's airline and handled by the
+	// runway.
+	dep, al, ok := edctDeparture(ap, rwy, "AAL123")
+	if !ok || dep.Exit != "WAVEY" || al.ICAO != "AAL" || al.Fleet != "long" {
+		t.Errorf("expected AAL WAVEY departure, got %+v %+v %v", dep, al, ok)
+	}
+
+	// Airlines that don't fly from the airport get a departure the runway
+	// handles with their default fleet.
+	dep, al, ok = edctDeparture(ap, rwy, "DAL45")
+	if !ok || (dep.Exit != "MERIT" && dep.Exit != "WAVEY") || al.ICAO != "DAL" || al.Fleet != "" {
+		t.Errorf("expected DAL departure with default fleet, got %+v %+v %v", dep, al, ok)
+	}
+
+	if _, _, ok := edctDeparture(ap, rwy, "N123AB"); ok {
+		t.Errorf("expected no departure for an unknown airline")
+	}
+}
+
+func TestHeldDepartures(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	jfk := math.Point2LL{-73.78, 40.64}
+
+	s := makeTMITestSim(now, TMI{Type: TMIGroundStop, Airport: "KBOS", Time: now.Add(time.Hour)},
+		TMI{Type: TMIMilesInTrail, Fix: "MERIT", Miles: 15})
+	aal := makeTMITestAircraft("AAL1", jfk, "MERIT")
+	aal.FlightPlan.ECID = "001"
+	s.holdOrTaxiDeparture(aal, "31L")
+	if len(s.HeldDepartures) != 1 || s.heldDepartures("KJFK") != 1 || len(s.PendingDepartures) != 0 {
+		t.Fatalf("expected ground stop to hold departure; held %+v", s.HeldDepartures)
+	}
+
+	// The same flight stays held while the ground stop is in effect.
+	s.SimTime = now.Add(30 * time.Minute)
+	s.releaseHeldDepartures()
+	if len(s.HeldDepartures) != 1 || len(s.PendingDepartures) != 0 {
+		t.Errorf("expected departure to remain held")
+	}
+
+	// Another departure behind it over MERIT is held for miles in trail.
+	ual := makeTMITestAircraft("UAL2", jfk, "MERIT")
+	ual.FlightPlan.ArrivalAirport = "KORD"
+	s.holdOrTaxiDeparture(ual, "31L")
+	if len(s.HeldDepartures) != 1 || s.PendingDepartures["UAL2"] != ual {
+		t.Errorf("expected UAL2 to taxi out")
+	}
+
+	// Once the ground stop ends, the held flight is released, but only
+	// once UAL2 is far enough ahead.
+	s.SimTime = now.Add(2 * time.Hour)
+	s.updateTMIs()
+	s.releaseHeldDepartures()
+	if len(s.HeldDepartures) != 1 {
+		t.Errorf("expected MIT to keep AAL1 held")
+	}
+	delete(s.PendingDepartures, "UAL2")
+	ual.Nav.FlightState.Position = math.Point2LL{-73.0, 41.2}
+	s.State.Aircraft["UAL2"] = ual
+	s.releaseHeldDepartures()
+	if len(s.HeldDepartures) != 0 || s.PendingDepartures["AAL1"] != aal {
+		t.Errorf("expected the held flight to be released")
+	}
+	if aal.FlightPlan.ECID != "001" {
+		t.Errorf("held flight's ECID changed to %s", aal.FlightPlan.ECID)
+	}
+	if _, ok := s.State.SurfaceTarget("AAL1"); !ok {
+		t.Errorf("expected released flight to be taxiing")
+	}
+
+	// Held EDCT flights aren't created again.
+	s = makeTMITestSim(now, TMI{Type: TMIEDCT, Airport: "KJFK", Callsign: "AAL1", Time: now},
+		TMI{Type: TMIGroundStop, Airport: "KBOS"})
+	s.holdOrTaxiDeparture(makeTMITestAircraft("AAL1", jfk), "31L")
+	if _, ok := s.pendingEDCT("KJFK"); ok || len(s.HeldDepartures) != 1 {
+		t.Errorf("EDCT shouldn't be pending while the flight is held")
+	}
+}
+
+func TestMITCompliance(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := makeTMITestSim(now, TMI{Type: TMIMilesInTrail, Fix: "MERIT", Miles: 10})
+	merit := &av.Waypoint{Fix: "MERIT", Location: math.Point2LL{-73.0, 40.5}}
+
+	// Aircraft are checked as they cross the fix; mkac returns one that
+	// is there.
+	mkac := func(callsign string) *av.Aircraft {
+		ac := makeTMITestAircraft(callsign, merit.Location, "MERIT")
+		s.State.Aircraft[ac.Callsign] = ac
+		return ac
+	}
+
+	lead := mkac("AAL1")
+	s.checkMITCompliance(lead, merit)
+
+	// Roughly 20nm in trail when UAL2 crosses.
+	lead.Nav.FlightState.Position = math.Point2LL{-73.0, 40.83}
+	ok := mkac("UAL2")
+	s.checkMITCompliance(ok, merit)
+	if v := s.State.TMIs[0].Violations; v != 0 {
+		t.Errorf("expected no violations, got %d", v)
+	}
+
+	// Roughly 5nm in trail of UAL2. (Where the aircraft are when they're
+	// handed off doesn't matter.)
+	ok.Nav.FlightState.Position = math.Point2LL{-73.0, 40.58}
+	close := mkac("DAL3")
+	s.checkMITCompliance(close, merit)
+	if v := s.State.TMIs[0].Violations; v != 1 {
+		t.Errorf("expected one violation, got %d", v)
+	}
+
+	// Crossing other fixes doesn't count.
+	close.Nav.FlightState.Position = math.Point2LL{-73.0, 40.51}
+	other := mkac("JBU4")
+	s.checkMITCompliance(other, &av.Waypoint{Fix: "GAYEL", Location: merit.Location})
+	if v := s.State.TMIs[0].Violations; v != 1 {
+		t.Errorf("expected one violation, got %d", v)
+	}
+	if s.lastMITCrossing["MERIT"] != "DAL3" {
+		t.Errorf("expected DAL3 to be the last to cross MERIT, got %s", s.lastMITCrossing["MERIT"])
+	}
+}
@@ -0,0 +1,309 @@
+// pkg/sim/weather.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/rand"
+
+	getweather "github.com/checkandmate1/AirportWeatherData"
+)
+
+// WeatherProvider provides current weather observations and forecasts
+// for airports.
+type WeatherProvider interface {
+	GetMETAR(icao string) (*av.METAR, error)
+	// GetTAF returns the raw text of the airport's current TAF.
+	GetTAF(icao string) (string, error)
+}
+
+// Where the weather for a new sim comes from.
+const (
+	WeatherSourceScenario = iota
+	WeatherSourceLive
+	WeatherSourceLocal
+)
+
+var (
+	ErrNoTAF     = errors.New("No TAF available")
+	ErrNoWeather = errors.New("No weather available")
+)
+
+// MakeWeatherProvider returns the WeatherProvider for the given
+// WeatherSource* value; dir is only used for WeatherSourceLocal. Live
+// weather is cached on disk so that it remains available offline.
+func MakeWeatherProvider(source int, dir string, sc *Scenario) WeatherProvider {
+	switch source {
+	case WeatherSourceLive:
+		if cacheDir := weatherCacheDir(); cacheDir != "" {
+			return &CachingWeatherProvider{
+				Provider: LiveWeatherProvider{},
+				Dir:      cacheDir,
+				MaxAge:   30 * time.Minute,
+			}
+		}
+		return LiveWeatherProvider{}
+	case WeatherSourceLocal:
+		return DirectoryWeatherProvider{Dir: dir}
+	default:
+		if sc == nil {
+			return &StaticWeatherProvider{}
+		}
+		return &StaticWeatherProvider{Wind: sc.Wind, METAR: sc.METAR}
+	}
+}
+
+func weatherCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	dir = filepath.Join(dir, "Vice", "metar")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return ""
+	}
+	return dir
+}
+
+///////////////////////////////////////////////////////////////////////////
+// LiveWeatherProvider
+
+// LiveWeatherProvider fetches current METARs from aviationweather.gov.
+type LiveWeatherProvider struct{}
+
+func (LiveWeatherProvider) GetMETAR(icao string) (*av.METAR, error) {
+	weather, errs := getweather.GetWeather(icao)
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return av.ParseMETAR(weather.RawMETAR)
+}
+
+func (LiveWeatherProvider) GetTAF(icao string) (string, error) {
+	// aviationweather.gov's METAR feed doesn't include TAFs.
+	return "", ErrNoTAF
+}
+
+///////////////////////////////////////////////////////////////////////////
+// DirectoryWeatherProvider
+
+// DirectoryWeatherProvider reads METARs and TAFs from text files in a
+// directory. An airport's reports are looked for in <ICAO>.metar and
+// <ICAO>.taf; failing that, metar.txt and taf.txt may hold reports for
+// multiple airports, one per line (or, for TAFs, starting on a line that
+// begins with the airport, with indented continuation lines). The last
+// report for an airport is used.
+type DirectoryWeatherProvider struct {
+	Dir string
+}
+
+func (d DirectoryWeatherProvider) GetMETAR(icao string) (*av.METAR, error) {
+	text, err := d.read(icao, "metar")
+	if err != nil {
+		return nil, err
+	}
+	return av.ParseMETAR(text)
+}
+
+func (d DirectoryWeatherProvider) GetTAF(icao string) (string, error) {
+	text, err := d.read(icao, "taf")
+	if err == ErrNoWeather {
+		err = ErrNoTAF
+	}
+	return text, err
+}
+
+func (d DirectoryWeatherProvider) read(icao string, ext string) (string, error) {
+	if b, err := os.ReadFile(filepath.Join(d.Dir, icao+"."+ext)); err == nil {
+		if text := lastReport(string(b), icao); text != "" {
+			return text, nil
+		}
+	}
+
+	f, err := os.Open(filepath.Join(d.Dir, ext+".txt"))
+	if err != nil {
+		return "", ErrNoWeather
+	}
+	defer f.Close()
+
+	var sb strings.Builder
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		sb.WriteString(sc.Text() + "\n")
+	}
+	if text := lastReport(sb.String(), icao); text != "" {
+		return text, nil
+	}
+	return "", ErrNoWeather
+}
+
+// lastReport returns the last report for the given airport in the text;
+// indented lines are continuations of the previous report.
+func lastReport(text string, icao string) string {
+	var report, cur []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if cur != nil {
+				cur = append(cur, strings.TrimSpace(line))
+			}
+			continue
+		}
+
+		if cur != nil {
+			report = cur
+		}
+		cur = nil
+		f := strings.Fields(line)
+		for len(f) > 0 && (f[0] == "METAR" || f[0] == "SPECI" || f[0] == "TAF" || f[0] == "AMD" || f[0] == "COR") {
+			f = f[1:]
+		}
+		if len(f) > 0 && f[0] == icao {
+			cur = []string{strings.Join(f, " ")}
+		}
+	}
+	if cur != nil {
+		report = cur
+	}
+	return strings.Join(report, " ")
+}
+
+///////////////////////////////////////////////////////////////////////////
+// CachingWeatherProvider
+
+// CachingWeatherProvider saves the reports returned by another provider
+// to a directory, in the format read by DirectoryWeatherProvider. Cached
+// reports newer than MaxAge are used directly; older ones are used if the
+// underlying provider fails.
+type CachingWeatherProvider struct {
+	Provider WeatherProvider
+	Dir      string
+	MaxAge   time.Duration
+}
+
+func (c *CachingWeatherProvider) GetMETAR(icao string) (*av.METAR, error) {
+	cache := DirectoryWeatherProvider{Dir: c.Dir}
+	if c.fresh(icao, "metar") {
+		if m, err := cache.GetMETAR(icao); err == nil {
+			return m, nil
+		}
+	}
+
+	m, err := c.Provider.GetMETAR(icao)
+	if err != nil {
+		if cm, cerr := cache.GetMETAR(icao); cerr == nil {
+			return cm, nil
+		}
+		return nil, err
+	}
+
+	c.save(icao, "metar", m.String())
+	return m, nil
+}
+
+func (c *CachingWeatherProvider) GetTAF(icao string) (string, error) {
+	cache := DirectoryWeatherProvider{Dir: c.Dir}
+	if c.fresh(icao, "taf") {
+		if t, err := cache.GetTAF(icao); err == nil {
+			return t, nil
+		}
+	}
+
+	t, err := c.Provider.GetTAF(icao)
+	if err != nil {
+		if ct, cerr := cache.GetTAF(icao); cerr == nil {
+			return ct, nil
+		}
+		return "", err
+	}
+
+	c.save(icao, "taf", t)
+	return t, nil
+}
+
+func (c *CachingWeatherProvider) fresh(icao string, ext string) bool {
+	fi, err := os.Stat(filepath.Join(c.Dir, icao+"."+ext))
+	return err == nil && time.Since(fi.ModTime()) < c.MaxAge
+}
+
+func (c *CachingWeatherProvider) save(icao string, ext string, text string) {
+	// Errors are ignored; the cache is just a convenience.
+	os.WriteFile(filepath.Join(c.Dir, icao+"."+ext), []byte(text+"\n"), 0o600)
+}
+
+///////////////////////////////////////////////////////////////////////////
+// StaticWeatherProvider
+
+// StaticWeatherProvider provides the weather specified in the scenario:
+// airports with METARs given there use them, and the others get a
+// plausible METAR based on the scenario's wind. The altimeter and
+// temperature are chosen once and only vary slightly from airport to
+// airport, as they would for nearby airports.
+type StaticWeatherProvider struct {
+	Wind  av.Wind
+	METAR map[string]string
+
+	once        sync.Once
+	altimeter   int
+	temperature int
+	spread      int // temperature - dewpoint
+}
+
+func (s *StaticWeatherProvider) GetMETAR(icao string) (*av.METAR, error) {
+	if text, ok := s.METAR[icao]; ok {
+		return av.ParseMETAR(text)
+	}
+
+	s.once.Do(func() {
+		s.altimeter = 2980 + rand.Intn(40)
+		s.temperature = 5 + rand.Intn(20)
+		s.spread = 2 + rand.Intn(10)
+	})
+
+	spd := s.Wind.Speed - 3 + rand.Int31n(6)
+	var wind string
+	if spd < 0 {
+		wind = "00000KT"
+	} else if spd < 4 {
+		wind = fmt.Sprintf("VRB%02dKT", spd)
+	} else {
+		dir := 10 * ((s.Wind.Direction + 5) / 10)
+		dir += [3]int32{-10, 0, 10}[rand.Intn(3)]
+		wind = fmt.Sprintf("%03d%02d", dir, spd)
+		gst := s.Wind.Gust - 3 + rand.Int31n(6)
+		if gst-s.Wind.Speed > 5 {
+			wind += fmt.Sprintf("G%02d", gst)
+		}
+		wind += "KT"
+	}
+
+	temp := s.temperature - 1 + rand.Intn(3)
+	dewpt := temp - s.spread
+	tempString := func(t int) string {
+		if t < 0 {
+			return fmt.Sprintf("M%02d", -t)
+		}
+		return fmt.Sprintf("%02d", t)
+	}
+
+	return av.ParseMETAR(fmt.Sprintf("%s %s %s 10SM FEW%03d %s/%s A%d", icao,
+		time.Now().UTC().Format("021504Z"), wind, 40+10*rand.Intn(20), tempString(temp),
+		tempString(dewpt), s.altimeter-2+rand.Intn(4)))
+}
+
+func (s *StaticWeatherProvider) GetTAF(icao string) (string, error) {
+	return "", ErrNoTAF
+}
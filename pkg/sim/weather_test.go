@@ -0,0 +1,178 @@
+// pkg/sim/weather_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+)
+
+func TestStaticWeatherProvider(t *testing.T) {
+	wp := &StaticWeatherProvider{Wind: av.Wind{Direction: 270, Speed: 12}}
+
+	minAlt, maxAlt := 9999, 0
+	for _, icao := range []string{"KJFK", "KLGA", "KEWR", "KTEB", "KHPN", "KISP", "KFRG", "KMMU"} {
+		m, err := wp.GetMETAR(icao)
+		if err != nil {
+			t.Fatalf("%s: %v", icao, err)
+		}
+		alt, err := strconv.Atoi(strings.TrimPrefix(m.Altimeter, "A"))
+		if err != nil {
+			t.Fatalf("%s: bad altimeter %q", icao, m.Altimeter)
+		}
+		minAlt, maxAlt = min(minAlt, alt), max(maxAlt, alt)
+	}
+	if maxAlt-minAlt > 3 {
+		t.Errorf("altimeters vary from %d to %d; expected nearby airports to be similar", minAlt, maxAlt)
+	}
+}
+
+type testWeatherProvider struct {
+	metar *av.METAR
+	taf   string
+	err   error
+	calls *atomic.Int32 // optional
+}
+
+func (p testWeatherProvider) GetMETAR(icao string) (*av.METAR, error) {
+	if p.calls != nil {
+		p.calls.Add(1)
+	}
+	return p.metar, p.err
+}
+
+func (p testWeatherProvider) GetTAF(icao string) (string, error) {
+	return p.taf, p.err
+}
+
+func TestCachingWeatherProvider(t *testing.T) {
+	m, err := av.ParseMETAR("KJFK 181851Z 31012G22KT 10SM FEW050 BKN250 18/06 A3001 RMK AO2 SLP162")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const taf = "KJFK 181720Z 1818/1924 31012KT P6SM FEW050 FM190200 30008KT P6SM SKC"
+
+	dir := t.TempDir()
+	c := &CachingWeatherProvider{Provider: testWeatherProvider{metar: m, taf: taf}, Dir: dir, MaxAge: time.Hour}
+	if _, err := c.GetMETAR("KJFK"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTAF("KJFK"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cached reports should be used, remarks and all, when the
+	// provider fails.
+	c = &CachingWeatherProvider{Provider: testWeatherProvider{err: errors.New("offline")}, Dir: dir}
+	cm, err := c.GetMETAR("KJFK")
+	if err != nil {
+		t.Fatalf("expected cached METAR, got %v", err)
+	}
+	if *cm != *m {
+		t.Errorf("cached METAR %+v doesn't match original %+v", *cm, *m)
+	}
+	if ct, err := c.GetTAF("KJFK"); err != nil || ct != taf {
+		t.Errorf("expected cached TAF %q, got %q, %v", taf, ct, err)
+	}
+	if _, err := c.GetTAF("KLGA"); err == nil {
+		t.Errorf("expected error for TAF that was never cached")
+	}
+}
+
+func TestDirectoryWeatherProvider(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("KJFK.metar", "METAR KJFK 181751Z 30010KT 10SM FEW050 18/06 A3002\n"+
+		"METAR KJFK 181851Z 31012KT 10SM FEW050 18/06 A3001\n")
+	write("metar.txt", "KLGA 181851Z 29008KT 10SM SKC 19/05 A3000\n"+
+		"SPECI KEWR 181851Z 32015G25KT 5SM BR OVC008 17/15 A2998\n")
+	write("KJFK.taf", "TAF KJFK 181720Z 1818/1924 31012KT P6SM FEW050\n"+
+		"     FM190200 30008KT P6SM SKC\n")
+	write("taf.txt", "TAF AMD KLGA 181530Z 1815/1918 29010KT P6SM SCT040\n"+
+		"  FM182200 32012KT P6SM BKN050\n"+
+		"TAF KEWR 181720Z 1818/1924 32015G25KT 5SM BR OVC008\n"+
+		"TAF AMD KLGA 181745Z 1818/1918 30010KT P6SM SCT040\n"+
+		"\tFM190000 33012KT P6SM BKN050\n")
+
+	wp := DirectoryWeatherProvider{Dir: dir}
+	for icao, altimeter := range map[string]string{"KJFK": "A3001", "KLGA": "A3000", "KEWR": "A2998"} {
+		if m, err := wp.GetMETAR(icao); err != nil {
+			t.Errorf("%s: %v", icao, err)
+		} else if m.Altimeter != altimeter {
+			t.Errorf("%s: got altimeter %s, expected %s", icao, m.Altimeter, altimeter)
+		}
+	}
+	if _, err := wp.GetMETAR("KBOS"); err != ErrNoWeather {
+		t.Errorf("expected ErrNoWeather for missing METAR, got %v", err)
+	}
+
+	for icao, taf := range map[string]string{
+		"KJFK": "KJFK 181720Z 1818/1924 31012KT P6SM FEW050 FM190200 30008KT P6SM SKC",
+		"KLGA": "KLGA 181745Z 1818/1918 30010KT P6SM SCT040 FM190000 33012KT P6SM BKN050",
+		"KEWR": "KEWR 181720Z 1818/1924 32015G25KT 5SM BR OVC008",
+	} {
+		if text, err := wp.GetTAF(icao); err != nil {
+			t.Errorf("%s: %v", icao, err)
+		} else if text != taf {
+			t.Errorf("%s: got TAF %q, expected %q", icao, text, taf)
+		}
+	}
+	if _, err := wp.GetTAF("KBOS"); err != ErrNoTAF {
+		t.Errorf("expected ErrNoTAF for missing TAF, got %v", err)
+	}
+	if _, err := (DirectoryWeatherProvider{Dir: filepath.Join(dir, "missing")}).GetTAF("KJFK"); err != ErrNoTAF {
+		t.Errorf("expected ErrNoTAF for missing directory, got %v", err)
+	}
+}
+
+func TestGetWindBackoff(t *testing.T) {
+	resetWind()
+	t.Cleanup(resetWind)
+
+	var calls atomic.Int32
+	wp := testWeatherProvider{err: errors.New("offline"), calls: &calls}
+	wait := func() {
+		t.Helper()
+		// Poll as the UI does until the request has finished.
+		for start := time.Now(); ; time.Sleep(time.Millisecond) {
+			getWind("KJFK", wp, nil)
+			if _, ok := windRequest["KJFK"]; !ok {
+				return
+			}
+			if time.Since(start) > 5*time.Second {
+				t.Fatal("wind request didn't finish")
+			}
+		}
+	}
+
+	wait()
+	for range 100 {
+		if _, ok := getWind("KJFK", wp, nil); ok {
+			t.Fatal("expected no wind from failing provider")
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected one request after failure, got %d", n)
+	}
+
+	// Once the retry interval has passed, it's requested again.
+	windFailure["KJFK"] = time.Now().Add(-windRetryInterval)
+	wait()
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected a second request after the retry interval, got %d", n)
+	}
+}
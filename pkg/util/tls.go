@@ -0,0 +1,184 @@
+// pkg/util/tls.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package util
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/mmp/vice/pkg/log"
+)
+
+var ErrTLSRequired = errors.New("Server requires a TLS connection")
+var ErrServerCertificateChanged = errors.New("Server's certificate doesn't match the one seen previously")
+
+///////////////////////////////////////////////////////////////////////////
+// Server side
+
+// MakeTLSListener returns a listener that accepts both TLS and plain
+// connections, distinguishing them by the first byte the client sends.
+// If requireTLS is true, plain connections are closed.
+func MakeTLSListener(l net.Listener, config *tls.Config, requireTLS bool, lg *log.Logger) net.Listener {
+	return &tlsListener{Listener: l, config: config, requireTLS: requireTLS, lg: lg}
+}
+
+type tlsListener struct {
+	net.Listener
+	config     *tls.Config
+	requireTLS bool
+	lg         *log.Logger
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// Don't wait for the client here; Accept would block until it sent
+	// something.
+	return &sniffConn{Conn: c, l: l}, nil
+}
+
+// sniffConn decides whether the connection uses TLS the first time it's
+// read from or written to; after that, Conn is either the original
+// connection or a *tls.Conn wrapping it.
+type sniffConn struct {
+	net.Conn
+	l    *tlsListener
+	once sync.Once
+	err  error
+}
+
+// The first byte of a TLS handshake record.
+const tlsHandshakeRecord = 0x16
+
+func (c *sniffConn) sniff() {
+	c.once.Do(func() {
+		br := bufio.NewReader(c.Conn)
+		b, err := br.Peek(1)
+		if err != nil {
+			c.err = err
+			return
+		}
+
+		bc := &bufferedConn{Conn: c.Conn, r: br}
+		if b[0] == tlsHandshakeRecord {
+			c.Conn = tls.Server(bc, c.l.config)
+		} else if c.l.requireTLS {
+			c.l.lg.Warnf("%s: closing non-TLS connection", c.Conn.RemoteAddr())
+			c.err = ErrTLSRequired
+			c.Conn.Close()
+		} else {
+			c.Conn = bc
+		}
+	})
+}
+
+func (c *sniffConn) Read(b []byte) (int, error) {
+	if c.sniff(); c.err != nil {
+		return 0, c.err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *sniffConn) Write(b []byte) (int, error) {
+	if c.sniff(); c.err != nil {
+		return 0, c.err
+	}
+	return c.Conn.Write(b)
+}
+
+// bufferedConn returns data buffered by the bufio.Reader before reading
+// more from the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Client side
+
+// ClientTLSConfig returns the TLS configuration to use to connect to the
+// server at the given address (host:port). A nil ClientTLSConfig means
+// that TLS isn't used.
+type ClientTLSConfig func(address string) *tls.Config
+
+// MakeTOFUTLSConfig returns TLS configurations for connecting to servers
+// with self-signed certificates. The first time a server is seen at an
+// address, its certificate's fingerprint is saved in the given file;
+// subsequent connections to that address fail if the server presents a
+// different certificate.
+func MakeTOFUTLSConfig(knownServersFilename string, lg *log.Logger) ClientTLSConfig {
+	ks := &knownServers{filename: knownServersFilename, lg: lg}
+	return func(address string) *tls.Config {
+		return &tls.Config{
+			// Verification is done by VerifyConnection instead.
+			InsecureSkipVerify: true,
+			VerifyConnection:   func(cs tls.ConnectionState) error { return ks.verify(address, cs) },
+		}
+	}
+}
+
+type knownServers struct {
+	filename string
+	lg       *log.Logger
+	mu       sync.Mutex
+}
+
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// verify checks the certificate of the server at the given address.
+// Servers are identified by address rather than the connection's server
+// name, which is empty when connecting to an IP address.
+func (ks *knownServers) verify(address string, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("Server did not provide a certificate")
+	}
+	fingerprint := CertificateFingerprint(cs.PeerCertificates[0])
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	servers := make(map[string]string)
+	if b, err := os.ReadFile(ks.filename); err == nil {
+		if err := json.Unmarshal(b, &servers); err != nil {
+			return fmt.Errorf("%s: %w", ks.filename, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if fp, ok := servers[address]; ok {
+		if fp != fingerprint {
+			ks.lg.Errorf("%s: certificate fingerprint %s doesn't match saved %s", address, fingerprint, fp)
+			return ErrServerCertificateChanged
+		}
+		return nil
+	}
+
+	ks.lg.Infof("%s: trusting certificate with fingerprint %s on first use", address, fingerprint)
+	servers[address] = fingerprint
+	b, err := json.MarshalIndent(servers, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ks.filename, b, 0o600)
+}
@@ -0,0 +1,161 @@
+// pkg/util/tls_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package util
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generateSelfSignedCertificate returns a certificate for the given IP
+// address that is valid for a day.
+func generateSelfSignedCertificate(t *testing.T, ip string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := crand.Int(crand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"vice"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP(ip)},
+	}
+
+	der, err := x509.CreateCertificate(crand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startEchoServer runs a server at the given loopback address with a new
+// self-signed certificate that echoes back lines it receives. It returns
+// the server's address and a function that stops it.
+func startEchoServer(t *testing.T, addr string, requireTLS bool) (string, func()) {
+	cert := generateSelfSignedCertificate(t, "127.0.0.1")
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	tl := MakeTLSListener(l, &tls.Config{Certificates: []tls.Certificate{cert}}, requireTLS, nil)
+	go func() {
+		for {
+			c, err := tl.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				if line, err := bufio.NewReader(c).ReadString('\n'); err == nil {
+					c.Write([]byte(line))
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func echo(c net.Conn) (string, error) {
+	defer c.Close()
+	if _, err := c.Write([]byte("hello\n")); err != nil {
+		return "", err
+	}
+	return bufio.NewReader(c).ReadString('\n')
+}
+
+func TestTLS(t *testing.T) {
+	known := filepath.Join(t.TempDir(), "known.json")
+	config := MakeTOFUTLSConfig(known, nil)
+
+	addr, stop := startEchoServer(t, "127.0.0.1:0", true)
+
+	// The first connection is trusted and the fingerprint saved.
+	c, err := tls.Dial("tcp", addr, config(addr))
+	if err != nil {
+		t.Fatalf("TLS dial: %v", err)
+	}
+	fingerprint := CertificateFingerprint(c.ConnectionState().PeerCertificates[0])
+	if s, err := echo(c); err != nil || s != "hello\n" {
+		t.Errorf("TLS echo: got %q, err %v", s, err)
+	}
+	if b, err := os.ReadFile(known); err != nil || !strings.Contains(string(b), fingerprint) {
+		t.Errorf("fingerprint %s not saved: %q, err %v", fingerprint, string(b), err)
+	}
+
+	// Connecting again with the same certificate is fine.
+	if c, err := tls.Dial("tcp", addr, config(addr)); err != nil {
+		t.Errorf("second TLS dial: %v", err)
+	} else {
+		c.Close()
+	}
+
+	// Plain connections are refused if TLS is required.
+	if c, err := net.Dial("tcp", addr); err != nil {
+		t.Fatal(err)
+	} else if s, err := echo(c); err == nil {
+		t.Errorf("plain connection to TLS-only server echoed %q", s)
+	}
+
+	// A different server on another port has its own certificate, which
+	// is trusted the first time it's seen.
+	addr2, _ := startEchoServer(t, "127.0.0.1:0", false)
+	c, err = tls.Dial("tcp", addr2, config(addr2))
+	if err != nil {
+		t.Fatalf("TLS dial of second server: %v", err)
+	}
+	fingerprint2 := CertificateFingerprint(c.ConnectionState().PeerCertificates[0])
+	c.Close()
+	if b, err := os.ReadFile(known); err != nil || !strings.Contains(string(b), fingerprint) ||
+		!strings.Contains(string(b), fingerprint2) {
+		t.Errorf("expected both fingerprints saved: %q, err %v", string(b), err)
+	}
+
+	// It accepts plain connections.
+	if c, err := net.Dial("tcp", addr2); err != nil {
+		t.Fatal(err)
+	} else if s, err := echo(c); err != nil || s != "hello\n" {
+		t.Errorf("plain echo: got %q, err %v", s, err)
+	}
+
+	// A server that replaces the first one at its address has a different
+	// certificate, which shouldn't be trusted.
+	stop()
+	startEchoServer(t, addr, true)
+	if _, err := tls.Dial("tcp", addr, config(addr)); !errors.Is(err, ErrServerCertificateChanged) {
+		t.Errorf("expected certificate mismatch error, got %v", err)
+	}
+	// The second server is still trusted.
+	if c, err := tls.Dial("tcp", addr2, config(addr2)); err != nil {
+		t.Errorf("TLS dial of second server: %v", err)
+	} else {
+		c.Close()
+	}
+}
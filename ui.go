@@ -1168,9 +1168,41 @@ type LaunchControlWindow struct {
 	controlClient       *sim.ControlClient
 	departures          []*LaunchDeparture
 	arrivalsOverflights []*LaunchArrivalOverflight
+	wx                  LaunchWeatherCell
+	notam               LaunchNOTAM
+	tmi                 LaunchTMI
 	lg                  *log.Logger
 }
 
+// LaunchWeatherCell holds the user's in-progress specification of a new
+// weather cell to add to the sim.
+type LaunchWeatherCell struct {
+	Center   string
+	Radius   float32
+	Level    int32
+	Top      int32
+	Heading  int32
+	Speed    int32
+	Growth   float32
+	Lifetime int32
+	Error    string
+}
+
+// LaunchNOTAM holds the user's in-progress specification of a new NOTAM.
+type LaunchNOTAM struct {
+	sim.NOTAM
+	Error string
+}
+
+// LaunchTMI holds the user's in-progress specification of a new traffic
+// management initiative.
+type LaunchTMI struct {
+	sim.TMI
+	Minutes int32 // until the EDCT or the end of the ground stop
+	Miles   int32
+	Error   string
+}
+
 type LaunchDeparture struct {
 	Aircraft           av.Aircraft
 	Airport            string
@@ -1203,7 +1235,13 @@ func (la *LaunchArrivalOverflight) Reset() {
 }
 
 func MakeLaunchControlWindow(controlClient *sim.ControlClient, lg *log.Logger) *LaunchControlWindow {
-	lc := &LaunchControlWindow{controlClient: controlClient}
+	lc := &LaunchControlWindow{
+		controlClient: controlClient,
+		wx:            LaunchWeatherCell{Radius: 5, Level: 4, Top: 35000, Lifetime: 60},
+		notam:         LaunchNOTAM{NOTAM: sim.NOTAM{Type: sim.NOTAMRunwayClosed}},
+		tmi:           LaunchTMI{TMI: sim.TMI{Type: sim.TMIMilesInTrail}, Miles: 10, Minutes: 15},
+		lg:            lg,
+	}
 
 	config := &controlClient.LaunchConfig
 	for _, airport := range util.SortedMapKeys(config.DepartureRates) {
@@ -1480,6 +1518,21 @@ func (lc *LaunchControlWindow) Draw(eventStream *sim.EventStream, p platform.Pla
 		}
 	}
 
+	imgui.Separator()
+	if imgui.CollapsingHeader("Weather") {
+		lc.drawWeatherUI(p)
+	}
+
+	imgui.Separator()
+	if imgui.CollapsingHeader("NOTAMs") {
+		lc.drawNOTAMUI()
+	}
+
+	imgui.Separator()
+	if imgui.CollapsingHeader("Traffic Management") {
+		lc.drawTMIUI()
+	}
+
 	imgui.End()
 
 	if !showLaunchControls {
@@ -1487,6 +1540,249 @@ func (lc *LaunchControlWindow) Draw(eventStream *sim.EventStream, p platform.Pla
 	}
 }
 
+func (lc *LaunchControlWindow) drawWeatherUI(p platform.Platform) {
+	cells := lc.controlClient.State.WeatherCells
+	if len(cells) == 0 {
+		imgui.Text("No weather cells")
+	} else {
+		flags := imgui.TableFlagsBordersH | imgui.TableFlagsBordersOuterV | imgui.TableFlagsRowBg |
+			imgui.TableFlagsSizingStretchProp
+		tableScale := util.Select(runtime.GOOS == "windows", p.DPIScale(), float32(1))
+		if imgui.BeginTableV("wx", 7, flags, imgui.Vec2{tableScale * 500, 0}, 0.0) {
+			imgui.TableSetupColumn("Id")
+			imgui.TableSetupColumn("Level")
+			imgui.TableSetupColumn("Radius")
+			imgui.TableSetupColumn("Top")
+			imgui.TableSetupColumn("Moving")
+			imgui.TableSetupColumn("Age")
+			imgui.TableHeadersRow()
+
+			for _, c := range cells {
+				imgui.PushID(c.Id)
+				imgui.TableNextRow()
+
+				imgui.TableNextColumn()
+				imgui.Text(c.Id)
+				imgui.TableNextColumn()
+				imgui.Text(strconv.Itoa(c.CurrentLevel()))
+				imgui.TableNextColumn()
+				imgui.Text(fmt.Sprintf("%.1f nm", c.Radius))
+				imgui.TableNextColumn()
+				imgui.Text(av.FormatAltitude(float32(c.Top)))
+				imgui.TableNextColumn()
+				if c.Speed > 0 {
+					imgui.Text(fmt.Sprintf("%03d@%.0f", int(c.Heading), c.Speed))
+				} else {
+					imgui.Text("--")
+				}
+				imgui.TableNextColumn()
+				imgui.Text(fmt.Sprintf("%.0f min", c.Age))
+				imgui.TableNextColumn()
+				if imgui.Button(renderer.FontAwesomeIconTrash) {
+					lc.controlClient.DeleteWeatherCell(c.Id,
+						func(err error) { lc.lg.Warnf("DeleteWeatherCell: %v", err) })
+				}
+
+				imgui.PopID()
+			}
+			imgui.EndTable()
+		}
+	}
+
+	imgui.Separator()
+	imgui.Text("New cell")
+	wx := &lc.wx
+	imgui.InputTextV("Center (fix or lat-long)", &wx.Center, 0, nil)
+	imgui.SliderFloatV("Radius (nm)", &wx.Radius, 1, 30, "%.1f", 0)
+	imgui.SliderInt("Level", &wx.Level, 1, 6)
+	imgui.InputIntV("Top (feet)", &wx.Top, 1000, 5000, 0)
+	imgui.InputIntV("Moving toward (degrees)", &wx.Heading, 10, 30, 0)
+	imgui.SliderInt("Speed (knots)", &wx.Speed, 0, 60)
+	imgui.SliderFloatV("Growth (nm/hour)", &wx.Growth, -10, 10, "%.1f", 0)
+	imgui.SliderInt("Lifetime (minutes, 0=unlimited)", &wx.Lifetime, 0, 240)
+
+	if imgui.Button("Add") {
+		if pos, ok := lc.controlClient.State.Locate(wx.Center); !ok {
+			wx.Error = "\"" + wx.Center + "\": unknown location"
+		} else {
+			wx.Error = ""
+			lc.controlClient.AddWeatherCell(av.WeatherCell{
+				Center:   pos,
+				Radius:   wx.Radius,
+				Level:    int(wx.Level),
+				Top:      int(wx.Top),
+				Heading:  float32(wx.Heading),
+				Speed:    float32(wx.Speed),
+				Growth:   wx.Growth,
+				Lifetime: float32(wx.Lifetime),
+			}, func(err error) { lc.lg.Warnf("AddWeatherCell: %v", err) })
+		}
+	}
+	if wx.Error != "" {
+		imgui.SameLine()
+		imgui.Text(wx.Error)
+	}
+}
+
+func (lc *LaunchControlWindow) drawNOTAMUI() {
+	notams := lc.controlClient.State.NOTAMs
+	if len(notams) == 0 {
+		imgui.Text("No NOTAMs")
+	}
+	for _, n := range notams {
+		imgui.PushID(n.Id)
+		if imgui.Button(renderer.FontAwesomeIconTrash) {
+			lc.controlClient.DeleteNOTAM(n.Id, func(err error) { lc.lg.Warnf("DeleteNOTAM: %v", err) })
+		}
+		imgui.SameLine()
+		imgui.Text(n.Id + ": " + n.String())
+		imgui.PopID()
+	}
+
+	imgui.Separator()
+	imgui.Text("New NOTAM")
+	nt := &lc.notam
+	if imgui.BeginComboV("Type", notamTypeName(nt.Type), imgui.ComboFlagsHeightLarge) {
+		for _, t := range sim.NOTAMTypes {
+			if imgui.SelectableV(notamTypeName(t), t == nt.Type, 0, imgui.Vec2{}) {
+				nt.Type = t
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	switch nt.Type {
+	case sim.NOTAMRunwayClosed, sim.NOTAMGlideslopeOut:
+		imgui.InputTextV("Airport", &nt.Airport, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.InputTextV("Runway", &nt.Runway, imgui.InputTextFlagsCharsUppercase, nil)
+	case sim.NOTAMApproachNA:
+		imgui.InputTextV("Airport", &nt.Airport, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.InputTextV("Approach", &nt.Approach, imgui.InputTextFlagsCharsUppercase, nil)
+	case sim.NOTAMVORUnusable:
+		imgui.InputTextV("VOR", &nt.Navaid, imgui.InputTextFlagsCharsUppercase, nil)
+	}
+
+	if imgui.Button("Add") {
+		n := sim.NOTAM{Type: nt.Type}
+		switch nt.Type {
+		case sim.NOTAMRunwayClosed, sim.NOTAMGlideslopeOut:
+			n.Airport, n.Runway = nt.Airport, nt.Runway
+		case sim.NOTAMApproachNA:
+			n.Airport, n.Approach = nt.Airport, nt.Approach
+		case sim.NOTAMVORUnusable:
+			n.Navaid = nt.Navaid
+		}
+
+		if err := n.Check(lc.controlClient.State.Airports); err != nil {
+			nt.Error = err.Error()
+		} else {
+			nt.Error = ""
+			lc.controlClient.AddNOTAM(n, func(err error) { lc.lg.Warnf("AddNOTAM: %v", err) })
+		}
+	}
+	if nt.Error != "" {
+		imgui.SameLine()
+		imgui.Text(nt.Error)
+	}
+}
+
+func (lc *LaunchControlWindow) drawTMIUI() {
+	tmis := lc.controlClient.State.TMIs
+	if len(tmis) == 0 {
+		imgui.Text("No traffic management initiatives")
+	}
+	for _, t := range tmis {
+		imgui.PushID(t.Id)
+		if imgui.Button(renderer.FontAwesomeIconTrash) {
+			lc.controlClient.DeleteTMI(t.Id, func(err error) { lc.lg.Warnf("DeleteTMI: %v", err) })
+		}
+		imgui.SameLine()
+		imgui.Text(t.Id + ": " + t.String())
+		imgui.PopID()
+	}
+
+	imgui.Separator()
+	imgui.Text("New TMI")
+	lt := &lc.tmi
+	if imgui.BeginComboV("Type##tmi", tmiTypeName(lt.Type), imgui.ComboFlagsHeightLarge) {
+		for _, t := range sim.TMITypes {
+			if imgui.SelectableV(tmiTypeName(t), t == lt.Type, 0, imgui.Vec2{}) {
+				lt.Type = t
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	switch lt.Type {
+	case sim.TMIMilesInTrail:
+		imgui.InputTextV("Coordination fix", &lt.Fix, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.InputIntV("Miles in trail", &lt.Miles, 1, 5, 0)
+	case sim.TMIGroundStop:
+		imgui.InputTextV("Destination", &lt.Airport, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.InputIntV("Minutes (0 until cancelled)", &lt.Minutes, 5, 15, 0)
+	case sim.TMIEDCT:
+		imgui.InputTextV("Callsign", &lt.Callsign, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.InputTextV("Departure airport", &lt.Airport, imgui.InputTextFlagsCharsUppercase, nil)
+		imgui.InputIntV("Minutes from now", &lt.Minutes, 1, 5, 0)
+	}
+
+	if imgui.Button("Add##tmi") {
+		t := sim.TMI{Type: lt.Type}
+		now := lc.controlClient.CurrentTime()
+		switch lt.Type {
+		case sim.TMIMilesInTrail:
+			t.Fix, t.Miles = lt.Fix, int(lt.Miles)
+		case sim.TMIGroundStop:
+			t.Airport = lt.Airport
+			if lt.Minutes > 0 {
+				t.Time = now.Add(time.Duration(lt.Minutes) * time.Minute)
+			}
+		case sim.TMIEDCT:
+			t.Callsign, t.Airport = lt.Callsign, lt.Airport
+			t.Time = now.Add(time.Duration(lt.Minutes) * time.Minute)
+		}
+
+		if err := t.Check(lc.controlClient.State.Airports); err != nil {
+			lt.Error = err.Error()
+		} else {
+			lt.Error = ""
+			lc.controlClient.AddTMI(t, func(err error) { lc.lg.Warnf("AddTMI: %v", err) })
+		}
+	}
+	if lt.Error != "" {
+		imgui.SameLine()
+		imgui.Text(lt.Error)
+	}
+}
+
+func tmiTypeName(t sim.TMIType) string {
+	switch t {
+	case sim.TMIMilesInTrail:
+		return "Miles-in-trail"
+	case sim.TMIGroundStop:
+		return "Ground stop"
+	case sim.TMIEDCT:
+		return "EDCT"
+	default:
+		return string(t)
+	}
+}
+
+func notamTypeName(t sim.NOTAMType) string {
+	switch t {
+	case sim.NOTAMRunwayClosed:
+		return "Runway closed"
+	case sim.NOTAMGlideslopeOut:
+		return "ILS glideslope out of service"
+	case sim.NOTAMVORUnusable:
+		return "VOR unusable"
+	case sim.NOTAMApproachNA:
+		return "Approach not authorized"
+	default:
+		return string(t)
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////
 
 var keyboardWindowVisible bool
@@ -1538,6 +1834,7 @@ Either one or both of *A* and *S* may be specified.`, "*CCAMRN/A110+*"},
 	[3]string{"*CSI_appr", `"Cleared straight-in _appr_ approach.`, "*CSII6*"},
 	[3]string{"*I*", `"Intercept the localizer."`, "*I*"},
 	[3]string{"*ID*", `"Ident."`, "*ID*"},
+	[3]string{"*WX*", `"Deviation approved", in response to a pilot's request to deviate for weather.`, "*WX*"},
 	[3]string{"*CVS*", `"Climb via the SID"`, "*CVS*"},
 	[3]string{"*DVS*", `"Descend via the STAR"`, "*CVS*"},
 	[3]string{"*P*", `"Toggles Pause/Unpause"`, "*P*"},